@@ -11,6 +11,7 @@ import (
 
 	"aibot/internal/config"
 	"aibot/internal/database"
+	"aibot/internal/sessionlock"
 	"aibot/models"
 
 	"github.com/joho/godotenv"
@@ -44,6 +45,13 @@ func main() {
 		log.Fatalf("会话文件不存在: %s\n请先通过管理前端点击“登录”，完成一次验证码/密码登录后再运行本脚本。", sessionPath)
 	}
 
+	// 正式客户端运行时不能同时使用同一份 session（会触发 AUTH_KEY_DUPLICATED 导致会话被吊销）
+	lock, err := sessionlock.Acquire(sessionPath)
+	if err != nil {
+		log.Fatalf("%v\n请先在管理前端停止该账号（或停止后端服务）后再运行本脚本。", err)
+	}
+	defer lock.Release()
+
 	log.Printf("使用账号 [ID=%d, 手机=%s, Session=%s]", account.ID, account.PhoneNumber, sessionPath)
 
 	// 2. 创建 Telegram 客户端，使用已有会话
@@ -9,6 +9,7 @@ import (
 
 	"aibot/internal/config"
 	"aibot/internal/database"
+	"aibot/internal/sessionlock"
 	"aibot/models"
 
 	"github.com/gotd/td/telegram"
@@ -34,6 +35,13 @@ func main() {
 	sessionDir := filepath.Join("data", "sessions")
 	sessionPath := filepath.Join(sessionDir, fmt.Sprintf("%s.session", account.PhoneNumber))
 
+	// 正式客户端运行时不能同时使用同一份 session（会触发 AUTH_KEY_DUPLICATED 导致会话被吊销）
+	lock, err := sessionlock.Acquire(sessionPath)
+	if err != nil {
+		log.Fatalf("%v\n请先在管理前端停止该账号（或停止后端服务）后再运行本脚本。", err)
+	}
+	defer lock.Release()
+
 	log.Printf("使用账号: %s, Session: %s", account.PhoneNumber, sessionPath)
 
 	client := telegram.NewClient(
@@ -8,9 +8,11 @@ import (
 	"strings"
 
 	"aibot/internal/database"
+	"aibot/internal/telegram"
 	"aibot/models"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"gorm.io/gorm"
 )
 
@@ -41,6 +43,11 @@ func GetAccounts(c *gin.Context) {
 	if status := c.Query("status"); status != "" {
 		query = query.Where("status = ?", status)
 	}
+
+	// 支持账号类型过滤（user/bot）
+	if accountType := c.Query("type"); accountType != "" {
+		query = query.Where("type = ?", accountType)
+	}
 	
 	var total int64
 	query.Model(&models.Account{}).Count(&total)
@@ -50,8 +57,14 @@ func GetAccounts(c *gin.Context) {
 		return
 	}
 	
+	// 附带客户端运行时状态
+	items := make([]accountWithRuntime, len(accounts))
+	for i := range accounts {
+		items[i] = accountWithRuntime{Account: accounts[i], Runtime: clientRuntime(accounts[i].ID)}
+	}
+	
 	c.JSON(http.StatusOK, gin.H{
-		"data": accounts,
+		"data": items,
 		"total": total,
 		"page": page,
 		"page_size": pageSize,
@@ -75,19 +88,152 @@ func GetAccount(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": account})
 }
 
+// accountWithRuntime 账号及其客户端运行时状态
+type accountWithRuntime struct {
+	models.Account
+	Runtime *telegram.ClientRuntime `json:"runtime"`
+}
+
+// clientRuntime 获取账号客户端的运行时状态，管理器不可用时返回 nil
+func clientRuntime(accountID uint) *telegram.ClientRuntime {
+	if tgManagerGetter == nil {
+		return nil
+	}
+	type ManagerInterface interface {
+		GetClientRuntime(accountID uint) telegram.ClientRuntime
+	}
+	mgr, ok := tgManagerGetter().(ManagerInterface)
+	if !ok {
+		return nil
+	}
+	runtime := mgr.GetClientRuntime(accountID)
+	return &runtime
+}
+
+// GetAccountRuntime 获取账号客户端的运行时状态（连接中/运行中/退避重启/需要重新登录/已停止）
+func GetAccountRuntime(c *gin.Context) {
+	var account models.Account
+	if err := database.DB.First(&account, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "账号不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	manager, ok := getTGManager(c)
+	if !ok {
+		return
+	}
+	type ManagerInterface interface {
+		GetClientRuntime(accountID uint) telegram.ClientRuntime
+	}
+	mgr, ok := manager.(ManagerInterface)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "管理器类型不匹配"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": mgr.GetClientRuntime(account.ID)})
+}
+
+// accountSwitches 账号中有默认值、但零值也有意义的字段
+// 按结构体创建/更新时 false 和 0 会被忽略（创建时被数据库默认值覆盖），需要按提交的字段单独更新
+type accountSwitches struct {
+	Enabled           *bool `json:"enabled"`
+	AutoReply         *bool `json:"auto_reply"`
+	SplitByNewline    *bool `json:"split_by_newline"`
+	ReplyToMentions   *bool `json:"reply_to_mentions"`
+	ReplyProbability  *int  `json:"reply_probability"`
+	MentionReplyLimit *int  `json:"mention_reply_limit"`
+}
+
+// validate 校验提交的数值
+func (s accountSwitches) validate() string {
+	if s.ReplyProbability != nil && (*s.ReplyProbability < 0 || *s.ReplyProbability > 100) {
+		return "回复概率必须在 0-100 之间"
+	}
+	if s.MentionReplyLimit != nil && *s.MentionReplyLimit < 0 {
+		return "每小时触发回复上限不能为负数（0 表示不限制）"
+	}
+	return ""
+}
+
+// updates 提交了的字段
+func (s accountSwitches) updates() map[string]interface{} {
+	updates := make(map[string]interface{})
+	if s.Enabled != nil {
+		updates["enabled"] = *s.Enabled
+	}
+	if s.AutoReply != nil {
+		updates["auto_reply"] = *s.AutoReply
+	}
+	if s.SplitByNewline != nil {
+		updates["split_by_newline"] = *s.SplitByNewline
+	}
+	if s.ReplyToMentions != nil {
+		updates["reply_to_mentions"] = *s.ReplyToMentions
+	}
+	if s.ReplyProbability != nil {
+		updates["reply_probability"] = *s.ReplyProbability
+	}
+	if s.MentionReplyLimit != nil {
+		updates["mention_reply_limit"] = *s.MentionReplyLimit
+	}
+	return updates
+}
+
+// accountCredentials 只写不读的凭证字段（账号模型不从 JSON 读取，也不在响应中返回）
+type accountCredentials struct {
+	BotToken string `json:"bot_token"`
+}
+
 // CreateAccount 创建账号
 func CreateAccount(c *gin.Context) {
 	var account models.Account
+	var switches accountSwitches
+	var credentials accountCredentials
 	
-	if err := c.ShouldBindJSON(&account); err != nil {
+	if err := c.ShouldBindBodyWith(&account, binding.JSON); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
 		return
 	}
+	if err := c.ShouldBindBodyWith(&switches, binding.JSON); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if err := c.ShouldBindBodyWith(&credentials, binding.JSON); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if msg := switches.validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
 
 	// 基本清洗，去掉前后空格，避免 API_HASH 前后多空格导致 Telegram 报错
 	account.PhoneNumber = strings.TrimSpace(account.PhoneNumber)
 	account.APIHash = strings.TrimSpace(account.APIHash)
 	account.Nickname = strings.TrimSpace(account.Nickname)
+	account.BotToken = strings.TrimSpace(credentials.BotToken)
+
+	switch account.Type {
+	case "", telegram.AccountTypeUser:
+		account.Type = telegram.AccountTypeUser
+		account.BotToken = ""
+	case telegram.AccountTypeBot:
+		// 机器人账号使用 Bot Token 登录，以机器人ID作为唯一标识
+		phone, err := telegram.BotAccountPhone(account.BotToken)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		account.PhoneNumber = phone
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "账号类型无效，应为 user 或 bot"})
+		return
+	}
 	
 	// 验证必填字段
 	if account.PhoneNumber == "" || account.APIID == 0 || account.APIHash == "" || account.AIApiKey == "" {
@@ -110,6 +256,8 @@ func CreateAccount(c *gin.Context) {
 			existing.Enabled = account.Enabled
 			existing.Nickname = account.Nickname
 			existing.SessionFile = account.SessionFile
+			existing.Type = account.Type
+			existing.BotToken = account.BotToken
 			// 清除删除标记
 			existing.DeletedAt = gorm.DeletedAt{}
 
@@ -151,6 +299,10 @@ func CreateAccount(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败: " + err.Error()})
 		return
 	}
+	// 提交为 false/0 的字段创建时被数据库默认值覆盖
+	if updates := switches.updates(); len(updates) > 0 {
+		database.DB.Model(&account).Updates(updates)
+	}
 	
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "账号创建成功",
@@ -173,21 +325,67 @@ func UpdateAccount(c *gin.Context) {
 	}
 	
 	var updateData models.Account
-	if err := c.ShouldBindJSON(&updateData); err != nil {
+	var switches accountSwitches
+	var credentials accountCredentials
+	if err := c.ShouldBindBodyWith(&updateData, binding.JSON); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
 		return
 	}
+	if err := c.ShouldBindBodyWith(&switches, binding.JSON); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if err := c.ShouldBindBodyWith(&credentials, binding.JSON); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if msg := switches.validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
 
 	// 清洗更新数据
 	updateData.PhoneNumber = strings.TrimSpace(updateData.PhoneNumber)
 	updateData.APIHash = strings.TrimSpace(updateData.APIHash)
 	updateData.Nickname = strings.TrimSpace(updateData.Nickname)
+	updateData.BotToken = strings.TrimSpace(credentials.BotToken)
+
+	// 账号类型创建后不能修改；机器人账号只能更换同一个机器人的 Token
+	if updateData.Type != "" && updateData.Type != account.Type {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "账号类型不能修改"})
+		return
+	}
+	if account.Type == telegram.AccountTypeBot {
+		if updateData.BotToken != "" {
+			phone, err := telegram.BotAccountPhone(updateData.BotToken)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if phone != account.PhoneNumber {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Bot Token 不属于该机器人"})
+				return
+			}
+		}
+		updateData.PhoneNumber = ""
+	} else {
+		updateData.BotToken = ""
+	}
+	// 隐私模式由登录时从 Telegram 读取
+	updateData.BotPrivacyMode = false
 	
 	// 更新字段（排除ID和创建时间）
 	if err := database.DB.Model(&account).Updates(updateData).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败: " + err.Error()})
 		return
 	}
+	// 按结构体更新会忽略 false/0，提交了的开关字段单独更新
+	if updates := switches.updates(); len(updates) > 0 {
+		if err := database.DB.Model(&account).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败: " + err.Error()})
+			return
+		}
+	}
 	
 	// 重新查询获取最新数据
 	database.DB.First(&account, id)
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"aibot/internal/config"
+	"aibot/internal/database"
+	"aibot/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testBotToken = "123456789:AAFabcdefghijklmnopqrstuvwxyz0123456"
+
+// accountRouter 账号接口路由（内存 SQLite 数据库）
+func accountRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	db, err := database.Init(config.DatabaseConfig{Driver: database.DriverSQLite, Path: ":memory:"})
+	if err != nil {
+		t.Fatalf("init database: %v", err)
+	}
+	t.Cleanup(func() { database.Close(db) })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/accounts", GetAccounts)
+	router.GET("/accounts/:id", GetAccount)
+	router.POST("/accounts", CreateAccount)
+	router.PUT("/accounts/:id", UpdateAccount)
+	return router
+}
+
+// doJSON 发送请求，返回状态码和响应体
+func doJSON(t *testing.T, router *gin.Engine, method, path string, body interface{}) (int, string) {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code, w.Body.String()
+}
+
+// expectNoBotToken 响应中不能出现 Bot Token，只返回是否已设置
+func expectNoBotToken(t *testing.T, what, body string) {
+	t.Helper()
+	if strings.Contains(body, `"bot_token"`) || strings.Contains(body, "AAFabcdefghijklmnopqrstuvwxyz") {
+		t.Fatalf("%s exposes the bot token: %s", what, body)
+	}
+	if !strings.Contains(body, `"has_bot_token":true`) {
+		t.Fatalf("%s missing has_bot_token: %s", what, body)
+	}
+}
+
+func TestAccountResponsesHideBotToken(t *testing.T) {
+	router := accountRouter(t)
+
+	code, body := doJSON(t, router, http.MethodPost, "/accounts", map[string]interface{}{
+		"type":       "bot",
+		"bot_token":  testBotToken,
+		"api_id":     1,
+		"api_hash":   "hash",
+		"ai_api_key": "key",
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("create = %d: %s", code, body)
+	}
+	expectNoBotToken(t, "create", body)
+
+	var account models.Account
+	if err := database.DB.First(&account).Error; err != nil {
+		t.Fatalf("load account: %v", err)
+	}
+	if account.BotToken != testBotToken || account.PhoneNumber != "bot123456789" {
+		t.Fatalf("stored account = %q / %q", account.PhoneNumber, account.BotToken)
+	}
+
+	code, body = doJSON(t, router, http.MethodGet, "/accounts", nil)
+	if code != http.StatusOK {
+		t.Fatalf("list = %d: %s", code, body)
+	}
+	expectNoBotToken(t, "list", body)
+
+	code, body = doJSON(t, router, http.MethodGet, "/accounts/1", nil)
+	if code != http.StatusOK {
+		t.Fatalf("get = %d: %s", code, body)
+	}
+	expectNoBotToken(t, "get", body)
+
+	// 不提交 Token 时保留原值
+	code, body = doJSON(t, router, http.MethodPut, "/accounts/1", map[string]interface{}{"nickname": "bot"})
+	if code != http.StatusOK {
+		t.Fatalf("update = %d: %s", code, body)
+	}
+	expectNoBotToken(t, "update", body)
+
+	// 更换为同一个机器人的新 Token
+	newToken := "123456789:BBFabcdefghijklmnopqrstuvwxyz0123456"
+	if code, body = doJSON(t, router, http.MethodPut, "/accounts/1", map[string]interface{}{"bot_token": newToken}); code != http.StatusOK {
+		t.Fatalf("update token = %d: %s", code, body)
+	}
+	database.DB.First(&account, 1)
+	if account.BotToken != newToken {
+		t.Fatalf("bot token = %q, want the updated token", account.BotToken)
+	}
+
+	// 其他机器人的 Token 不能使用
+	if code, body = doJSON(t, router, http.MethodPut, "/accounts/1", map[string]interface{}{"bot_token": "987654321:AAFabcdefghijklmnopqrstuvwxyz0123456"}); code != http.StatusBadRequest {
+		t.Fatalf("update with another bot's token = %d: %s", code, body)
+	}
+}
+
+func TestUserAccountHasNoBotToken(t *testing.T) {
+	router := accountRouter(t)
+
+	code, body := doJSON(t, router, http.MethodPost, "/accounts", map[string]interface{}{
+		"phone_number": "+10000000002",
+		"bot_token":    testBotToken,
+		"api_id":       1,
+		"api_hash":     "hash",
+		"ai_api_key":   "key",
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("create = %d: %s", code, body)
+	}
+	if !strings.Contains(body, `"has_bot_token":false`) {
+		t.Fatalf("user account should not keep a bot token: %s", body)
+	}
+}
@@ -19,6 +19,21 @@ func SetTGManagerGetter(getter func() interface{}) {
 	tgManagerGetter = getter
 }
 
+// getTGManager 获取Telegram管理器，未初始化时直接写入错误响应
+func getTGManager(c *gin.Context) (interface{}, bool) {
+	if tgManagerGetter == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Telegram管理器未初始化"})
+		return nil, false
+	}
+
+	manager := tgManagerGetter()
+	if manager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法获取Telegram管理器"})
+		return nil, false
+	}
+	return manager, true
+}
+
 // SubmitAuthCode 提交验证码
 func SubmitAuthCode(c *gin.Context) {
 	accountIDStr := c.Param("id")
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"aibot/internal/database"
+	"aibot/internal/telegram"
+	"aibot/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetCallbackActions 获取回调动作列表
+func GetCallbackActions(c *gin.Context) {
+	var actions []models.CallbackAction
+
+	query := database.DB
+
+	// 支持群组过滤（global=true 只看全局动作）
+	if groupID := c.Query("group_id"); groupID != "" {
+		query = query.Where("group_id = ?", groupID)
+	} else if c.Query("global") == "true" {
+		query = query.Where("group_id IS NULL")
+	}
+	if accountID := c.Query("account_id"); accountID != "" {
+		query = query.Where("account_id = ?", accountID)
+	}
+
+	if err := query.Order("data ASC, id ASC").Find(&actions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": actions})
+}
+
+// GetCallbackAction 获取单个回调动作
+func GetCallbackAction(c *gin.Context) {
+	action, ok := findCallbackAction(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": action})
+}
+
+// CreateCallbackAction 创建回调动作
+func CreateCallbackAction(c *gin.Context) {
+	action := models.CallbackAction{Enabled: true}
+
+	if err := c.ShouldBindJSON(&action); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if err := telegram.ValidateCallbackAction(&action); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "回调动作无效: " + err.Error()})
+		return
+	}
+
+	// 统计字段由系统维护
+	action.HitCount = 0
+	action.LastHitAt = nil
+
+	if err := database.DB.Create(&action).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败: " + err.Error()})
+		return
+	}
+	// enabled 有默认值，创建时为 false 会被数据库默认值覆盖
+	if !action.Enabled {
+		database.DB.Model(&action).Update("enabled", false)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "回调动作创建成功",
+		"data":    action,
+	})
+}
+
+// UpdateCallbackAction 更新回调动作
+func UpdateCallbackAction(c *gin.Context) {
+	action, ok := findCallbackAction(c)
+	if !ok {
+		return
+	}
+
+	// 在原动作上绑定，未提交的字段保持不变
+	updated := *action
+	if err := c.ShouldBindJSON(&updated); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if err := telegram.ValidateCallbackAction(&updated); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "回调动作无效: " + err.Error()})
+		return
+	}
+
+	// 系统维护的字段不允许修改
+	updated.ID = action.ID
+	updated.HitCount = action.HitCount
+	updated.LastHitAt = action.LastHitAt
+	updated.CreatedAt = action.CreatedAt
+
+	if err := database.DB.Save(&updated).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "回调动作更新成功",
+		"data":    updated,
+	})
+}
+
+// DeleteCallbackAction 删除回调动作
+func DeleteCallbackAction(c *gin.Context) {
+	if err := database.DB.Delete(&models.CallbackAction{}, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "回调动作删除成功"})
+}
+
+// GetCallbackClicks 获取按钮点击记录
+func GetCallbackClicks(c *gin.Context) {
+	var clicks []models.CallbackClick
+
+	query := database.DB
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	offset := (page - 1) * pageSize
+
+	if accountID := c.Query("account_id"); accountID != "" {
+		query = query.Where("account_id = ?", accountID)
+	}
+	if groupID := c.Query("group_id"); groupID != "" {
+		query = query.Where("group_id = ?", groupID)
+	}
+	if actionID := c.Query("callback_action_id"); actionID != "" {
+		query = query.Where("callback_action_id = ?", actionID)
+	}
+	if messageID := c.Query("telegram_message_id"); messageID != "" {
+		query = query.Where("telegram_message_id = ?", messageID)
+	}
+	if data := c.Query("data"); data != "" {
+		query = query.Where("data = ?", data)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// 同一查询条件用于计数、汇总和分页
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	query.Model(&models.CallbackClick{}).Count(&total)
+
+	// 按回调数据汇总点击次数（如“有帮助/没帮助”的反馈统计）
+	var summary []struct {
+		Data  string `json:"data"`
+		Count int64  `json:"count"`
+	}
+	query.Model(&models.CallbackClick{}).Select("data, COUNT(*) AS count").Group("data").Order("count DESC").Scan(&summary)
+
+	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&clicks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      clicks,
+		"summary":   summary,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// findCallbackAction 查找回调动作，失败时直接写入响应
+func findCallbackAction(c *gin.Context) (*models.CallbackAction, bool) {
+	var action models.CallbackAction
+	if err := database.DB.First(&action, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "回调动作不存在"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return nil, false
+	}
+	return &action, true
+}
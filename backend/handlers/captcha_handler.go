@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"aibot/internal/database"
+	"aibot/internal/telegram"
+	"aibot/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetGroupCaptcha 获取群组的入群验证配置
+func GetGroupCaptcha(c *gin.Context) {
+	var captcha models.GroupCaptcha
+	if err := database.DB.Where("group_id = ?", c.Param("id")).First(&captcha).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "该群组未配置入群验证"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": captcha})
+}
+
+// UpdateGroupCaptcha 创建或更新群组的入群验证配置（未提交的字段保持不变）
+func UpdateGroupCaptcha(c *gin.Context) {
+	var group models.Group
+	if err := database.DB.First(&group, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "群组不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	captcha := models.GroupCaptcha{
+		GroupID:        group.ID,
+		Enabled:        true,
+		Mode:           telegram.CaptchaModeButton,
+		TimeoutSeconds: 120,
+		MaxAttempts:    3,
+	}
+	err := database.DB.Where("group_id = ?", group.ID).First(&captcha).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+	created := err == gorm.ErrRecordNotFound
+
+	updated := captcha
+	if err := c.ShouldBindJSON(&updated); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if err := telegram.ValidateCaptcha(&updated); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "配置无效: " + err.Error()})
+		return
+	}
+
+	// 系统维护的字段不允许修改
+	updated.ID = captcha.ID
+	updated.GroupID = group.ID
+	updated.CreatedAt = captcha.CreatedAt
+
+	if created {
+		if err := database.DB.Create(&updated).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败: " + err.Error()})
+			return
+		}
+		// enabled 有默认值，创建时为 false 会被数据库默认值覆盖
+		if !updated.Enabled {
+			database.DB.Model(&updated).Update("enabled", false)
+		}
+	} else if err := database.DB.Save(&updated).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "入群验证已保存",
+		"data":    updated,
+	})
+}
+
+// DeleteGroupCaptcha 删除群组的入群验证配置（进行中的验证仍会按原配置结束）
+func DeleteGroupCaptcha(c *gin.Context) {
+	if err := database.DB.Where("group_id = ?", c.Param("id")).Delete(&models.GroupCaptcha{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "入群验证已删除"})
+}
+
+// GetCaptchaChallenges 获取入群验证记录，并按结果汇总
+func GetCaptchaChallenges(c *gin.Context) {
+	var challenges []models.CaptchaChallenge
+
+	query := database.DB
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	offset := (page - 1) * pageSize
+
+	if accountID := c.Query("account_id"); accountID != "" {
+		query = query.Where("account_id = ?", accountID)
+	}
+	if groupID := c.Query("group_id"); groupID != "" {
+		query = query.Where("group_id = ?", groupID)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if startDate := c.Query("start_date"); startDate != "" {
+		query = query.Where("created_at >= ?", startDate)
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		query = query.Where("created_at <= ?", endDate)
+	}
+
+	// 同一查询条件用于计数、汇总和分页
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	query.Model(&models.CaptchaChallenge{}).Count(&total)
+
+	var summary []struct {
+		Status string `json:"status"`
+		Count  int64  `json:"count"`
+	}
+	query.Model(&models.CaptchaChallenge{}).Select("status, COUNT(*) AS count").Group("status").Order("count DESC").Scan(&summary)
+
+	if err := query.Preload("Group").Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&challenges).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      challenges,
+		"summary":   summary,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
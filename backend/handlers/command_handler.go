@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"aibot/internal/database"
+	"aibot/internal/telegram"
+	"aibot/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetCommands 获取自定义命令列表
+func GetCommands(c *gin.Context) {
+	var commands []models.GroupCommand
+
+	query := database.DB
+
+	// 支持群组过滤（global=true 只看全局命令）
+	if groupID := c.Query("group_id"); groupID != "" {
+		query = query.Where("group_id = ?", groupID)
+	} else if c.Query("global") == "true" {
+		query = query.Where("group_id IS NULL")
+	}
+	if accountID := c.Query("account_id"); accountID != "" {
+		query = query.Where("account_id = ?", accountID)
+	}
+
+	if err := query.Order("name ASC, id ASC").Find(&commands).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": commands})
+}
+
+// GetGroupCommands 获取群组中实际可用的命令（内置命令与自定义命令合并后的结果）
+func GetGroupCommands(c *gin.Context) {
+	var group models.Group
+	if err := database.DB.First(&group, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "群组不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	accountID, _ := strconv.ParseUint(c.Query("account_id"), 10, 32)
+	c.JSON(http.StatusOK, gin.H{"data": telegram.EffectiveCommands(database.DB, group.ID, uint(accountID))})
+}
+
+// GetCommand 获取单个自定义命令
+func GetCommand(c *gin.Context) {
+	command, ok := findCommand(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": command})
+}
+
+// CreateCommand 创建自定义命令（与内置命令同名时覆盖内置命令）
+func CreateCommand(c *gin.Context) {
+	command := models.GroupCommand{Enabled: true}
+
+	if err := c.ShouldBindJSON(&command); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if err := telegram.ValidateCommand(&command); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "命令无效: " + err.Error()})
+		return
+	}
+
+	// 统计字段由系统维护
+	command.HitCount = 0
+	command.LastHitAt = nil
+
+	if err := database.DB.Create(&command).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败: " + err.Error()})
+		return
+	}
+	// enabled 有默认值，创建时为 false 会被数据库默认值覆盖（停用内置命令时需要）
+	if !command.Enabled {
+		database.DB.Model(&command).Update("enabled", false)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "命令创建成功",
+		"data":    command,
+	})
+}
+
+// UpdateCommand 更新自定义命令
+func UpdateCommand(c *gin.Context) {
+	command, ok := findCommand(c)
+	if !ok {
+		return
+	}
+
+	// 在原命令上绑定，未提交的字段保持不变
+	updated := *command
+	if err := c.ShouldBindJSON(&updated); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if err := telegram.ValidateCommand(&updated); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "命令无效: " + err.Error()})
+		return
+	}
+
+	// 系统维护的字段不允许修改
+	updated.ID = command.ID
+	updated.HitCount = command.HitCount
+	updated.LastHitAt = command.LastHitAt
+	updated.CreatedAt = command.CreatedAt
+
+	if err := database.DB.Save(&updated).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "命令更新成功",
+		"data":    updated,
+	})
+}
+
+// DeleteCommand 删除自定义命令（同名的内置命令恢复可用）
+func DeleteCommand(c *gin.Context) {
+	if err := database.DB.Delete(&models.GroupCommand{}, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "命令删除成功"})
+}
+
+// GetFAQs 获取知识库条目
+func GetFAQs(c *gin.Context) {
+	var entries []models.FAQEntry
+
+	query := database.DB
+
+	if groupID := c.Query("group_id"); groupID != "" {
+		query = query.Where("group_id = ?", groupID)
+	} else if c.Query("global") == "true" {
+		query = query.Where("group_id IS NULL")
+	}
+	if search := c.Query("search"); search != "" {
+		query = query.Where("topic LIKE ? OR keywords LIKE ? OR answer LIKE ?", "%"+search+"%", "%"+search+"%", "%"+search+"%")
+	}
+
+	if err := query.Order("topic ASC, id ASC").Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": entries})
+}
+
+// CreateFAQ 创建知识库条目
+func CreateFAQ(c *gin.Context) {
+	entry := models.FAQEntry{Enabled: true}
+
+	if err := c.ShouldBindJSON(&entry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if err := telegram.ValidateFAQ(&entry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "条目无效: " + err.Error()})
+		return
+	}
+	entry.HitCount = 0
+
+	if err := database.DB.Create(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败: " + err.Error()})
+		return
+	}
+	if !entry.Enabled {
+		database.DB.Model(&entry).Update("enabled", false)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "条目创建成功",
+		"data":    entry,
+	})
+}
+
+// UpdateFAQ 更新知识库条目
+func UpdateFAQ(c *gin.Context) {
+	var entry models.FAQEntry
+	if err := database.DB.First(&entry, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "条目不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	updated := entry
+	if err := c.ShouldBindJSON(&updated); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if err := telegram.ValidateFAQ(&updated); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "条目无效: " + err.Error()})
+		return
+	}
+
+	updated.ID = entry.ID
+	updated.HitCount = entry.HitCount
+	updated.CreatedAt = entry.CreatedAt
+
+	if err := database.DB.Save(&updated).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "条目更新成功",
+		"data":    updated,
+	})
+}
+
+// DeleteFAQ 删除知识库条目
+func DeleteFAQ(c *gin.Context) {
+	if err := database.DB.Delete(&models.FAQEntry{}, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "条目删除成功"})
+}
+
+// findCommand 查找自定义命令，失败时直接写入响应
+func findCommand(c *gin.Context) (*models.GroupCommand, bool) {
+	var command models.GroupCommand
+	if err := database.DB.First(&command, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "命令不存在"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return nil, false
+	}
+	return &command, true
+}
@@ -6,6 +6,7 @@ import (
 
 	"aibot/models"
 	"aibot/internal/database"
+	"aibot/internal/telegram"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -217,3 +218,157 @@ func GetGroupAccounts(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": accounts})
 }
 
+
+// SyncGroups 手动同步群组（完整拉取对话列表），返回本次同步的变更
+func SyncGroups(c *gin.Context) {
+	var request struct {
+		AccountID uint `json:"account_id"` // 为空则使用所有在线账号同步
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+			return
+		}
+	}
+
+	if tgManagerGetter == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Telegram管理器未初始化"})
+		return
+	}
+
+	manager := tgManagerGetter()
+	if manager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法获取Telegram管理器"})
+		return
+	}
+
+	type ManagerInterface interface {
+		SyncAccountGroups(accountID uint) (*telegram.GroupSyncResult, error)
+		SyncAllGroups() ([]*telegram.GroupSyncResult, error)
+	}
+
+	mgr, ok := manager.(ManagerInterface)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "管理器类型不匹配"})
+		return
+	}
+
+	var results []*telegram.GroupSyncResult
+	if request.AccountID != 0 {
+		result, err := mgr.SyncAccountGroups(request.AccountID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "同步群组失败: " + err.Error()})
+			return
+		}
+		results = append(results, result)
+	} else {
+		all, err := mgr.SyncAllGroups()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "同步群组失败: " + err.Error()})
+			return
+		}
+		results = all
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "群组同步完成",
+		"data":    results,
+	})
+}
+
+// GetGroupMemberships 获取群组中各账号的实际成员身份
+func GetGroupMemberships(c *gin.Context) {
+	id := c.Param("id")
+
+	var memberships []models.GroupMembership
+	if err := database.DB.Where("group_id = ?", id).Preload("Account").Find(&memberships).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": memberships})
+}
+
+// GetGroupAssignments 获取群组的账号分配详情（包含启用状态和自动停用原因）
+func GetGroupAssignments(c *gin.Context) {
+	id := c.Param("id")
+
+	var accountGroups []models.AccountGroup
+	if err := database.DB.Where("group_id = ?", id).Preload("Account").Find(&accountGroups).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": accountGroups})
+}
+
+// UpdateGroupAssignment 更新账号在群组中的分配配置（重新启用时清除停用原因）
+func UpdateGroupAssignment(c *gin.Context) {
+	groupID := c.Param("id")
+	accountID := c.Param("account_id")
+
+	var accountGroup models.AccountGroup
+	if err := database.DB.Where("group_id = ? AND account_id = ?", groupID, accountID).First(&accountGroup).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "该账号未分配到此群组"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	var request struct {
+		Enabled          *bool    `json:"enabled"`
+		Priority         *int     `json:"priority"`
+		ReplyProbability *float64 `json:"reply_probability"`
+
+		Topics *[]models.TopicSetting `json:"topics"` // 论坛话题的单独配置（整体替换）
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if request.Topics != nil {
+		if err := telegram.ValidateTopicSettings(*request.Topics); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "话题配置无效: " + err.Error()})
+			return
+		}
+	}
+
+	updates := map[string]interface{}{}
+	if request.Enabled != nil {
+		updates["enabled"] = *request.Enabled
+		if *request.Enabled {
+			updates["disabled_reason"] = ""
+			updates["disabled_at"] = nil
+		}
+	}
+	if request.Priority != nil {
+		updates["priority"] = *request.Priority
+	}
+	if request.ReplyProbability != nil {
+		updates["reply_probability"] = *request.ReplyProbability
+	}
+	if request.Topics != nil {
+		// 序列化字段需要通过模型更新
+		accountGroup.Topics = *request.Topics
+		if err := database.DB.Model(&accountGroup).Select("topics").Updates(&accountGroup).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败: " + err.Error()})
+			return
+		}
+	}
+
+	if len(updates) > 0 {
+		if err := database.DB.Model(&accountGroup).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败: " + err.Error()})
+			return
+		}
+	}
+
+	database.DB.First(&accountGroup, accountGroup.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "分配配置更新成功",
+		"data":    accountGroup,
+	})
+}
@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"aibot/internal/database"
+	"aibot/internal/telegram"
 	"aibot/models"
 
 	"github.com/gin-gonic/gin"
@@ -32,6 +38,11 @@ func GetMessages(c *gin.Context) {
 	if groupID := c.Query("group_id"); groupID != "" {
 		query = query.Where("group_id = ?", groupID)
 	}
+
+	// 支持论坛话题过滤
+	if topicID := c.Query("topic_id"); topicID != "" {
+		query = query.Where("topic_id = ?", topicID)
+	}
 	
 	// 支持时间范围
 	if startTime := c.Query("start_time"); startTime != "" {
@@ -49,6 +60,34 @@ func GetMessages(c *gin.Context) {
 	if search := c.Query("search"); search != "" {
 		query = query.Where("content LIKE ?", "%"+search+"%")
 	}
+
+	// 支持发送状态过滤（sent/failed）
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// 支持按定时公告的执行记录查询
+	if runID := c.Query("schedule_run_id"); runID != "" {
+		query = query.Where("schedule_run_id = ?", runID)
+	}
+
+	// 支持按投票查询
+	if pollID := c.Query("poll_id"); pollID != "" {
+		query = query.Where("poll_id = ?", pollID)
+	}
+
+	// 支持按回复分组查询（同一条回复拆分出的多条消息）
+	if replyGroupID := c.Query("reply_group_id"); replyGroupID != "" {
+		query = query.Where("reply_group_id = ?", replyGroupID)
+	}
+
+	// 支持按被回复消息的状态过滤：edited（触发消息被编辑）/ removed（触发消息被删除）
+	switch c.Query("trigger_status") {
+	case "edited":
+		query = query.Where("EXISTS (?)", triggerSubQuery().Where("inbound_messages.edited_at IS NOT NULL"))
+	case "removed":
+		query = query.Where("EXISTS (?)", triggerSubQuery().Where("inbound_messages.removed_at IS NOT NULL"))
+	}
 	
 	// 按时间倒序
 	query = query.Order("created_at DESC")
@@ -82,16 +121,204 @@ func GetMessage(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
 		return
 	}
+
+	// 引用回复的消息附带被回复消息的存档（可查看其是否已被编辑或删除）
+	var trigger *models.InboundMessage
+	if message.ReplyToMessageID != nil {
+		var inbound models.InboundMessage
+		err := database.DB.
+			Where("account_id = ? AND group_id = ? AND message_id = ?", message.AccountID, message.GroupID, *message.ReplyToMessageID).
+			First(&inbound).Error
+		if err == nil {
+			trigger = &inbound
+		}
+	}
 	
-	c.JSON(http.StatusOK, gin.H{"data": message})
+	c.JSON(http.StatusOK, gin.H{"data": message, "trigger": trigger})
+}
+
+// UpdateMessage 编辑已发送的消息（在 Telegram 中同步修改，媒体消息修改说明文字），编辑前的内容记录在 edit_history
+func UpdateMessage(c *gin.Context) {
+	message, ok := findSentMessage(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Content string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if message.MediaType == "poll" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "投票消息不能编辑"})
+		return
+	}
+	if request.Content == message.Content {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "消息内容没有变化"})
+		return
+	}
+
+	mgr, ok := sentMessageManager(c)
+	if !ok {
+		return
+	}
+	if err := mgr.EditSentMessage(message, request.Content); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "消息已编辑",
+		"data":    message,
+	})
+}
+
+// DeleteMessage 撤回已发送的消息（对所有人删除），发言记录保留并标记撤回时间
+func DeleteMessage(c *gin.Context) {
+	message, ok := findSentMessage(c)
+	if !ok {
+		return
+	}
+
+	mgr, ok := sentMessageManager(c)
+	if !ok {
+		return
+	}
+	if err := mgr.DeleteSentMessage(message); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "消息已撤回",
+		"data":    message,
+	})
+}
+
+// findSentMessage 查找可以编辑或撤回的发言记录，失败时直接写入响应
+func findSentMessage(c *gin.Context) (*models.Message, bool) {
+	var message models.Message
+	if err := database.DB.First(&message, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "消息不存在"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return nil, false
+	}
+
+	switch {
+	case message.Status != "sent" || message.TelegramMessageID == 0:
+		c.JSON(http.StatusConflict, gin.H{"error": "消息未发送成功"})
+		return nil, false
+	case message.RemovedAt != nil:
+		c.JSON(http.StatusConflict, gin.H{"error": "消息已撤回"})
+		return nil, false
+	}
+	return &message, true
+}
+
+// sentMessageOperator 编辑和撤回已发送消息的Telegram管理器方法
+type sentMessageOperator interface {
+	EditSentMessage(message *models.Message, content string) error
+	DeleteSentMessage(message *models.Message) error
+}
+
+// sentMessageManager 获取支持编辑和撤回消息的Telegram管理器，失败时直接写入响应
+func sentMessageManager(c *gin.Context) (sentMessageOperator, bool) {
+	manager, ok := getTGManager(c)
+	if !ok {
+		return nil, false
+	}
+	mgr, ok := manager.(sentMessageOperator)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "管理器类型不匹配"})
+		return nil, false
+	}
+	return mgr, true
+}
+
+// triggerSubQuery 发言记录所回复消息的存档子查询
+func triggerSubQuery() *gorm.DB {
+	return database.DB.Table("inbound_messages").Select("1").
+		Where("inbound_messages.account_id = messages.account_id").
+		Where("inbound_messages.group_id = messages.group_id").
+		Where("inbound_messages.message_id = messages.reply_to_message_id")
+}
+
+// GetInboundMessages 获取收到的群组消息存档
+func GetInboundMessages(c *gin.Context) {
+	var messages []models.InboundMessage
+
+	query := database.DB.Model(&models.InboundMessage{})
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	offset := (page - 1) * pageSize
+
+	if accountID := c.Query("account_id"); accountID != "" {
+		query = query.Where("account_id = ?", accountID)
+	}
+	if groupID := c.Query("group_id"); groupID != "" {
+		query = query.Where("group_id = ?", groupID)
+	}
+	if topicID := c.Query("topic_id"); topicID != "" {
+		query = query.Where("topic_id = ?", topicID)
+	}
+	if senderID := c.Query("sender_id"); senderID != "" {
+		query = query.Where("sender_id = ?", senderID)
+	}
+
+	// 状态过滤：edited（被编辑过）/ removed（已被删除）
+	switch c.Query("status") {
+	case "edited":
+		query = query.Where("edited_at IS NOT NULL")
+	case "removed":
+		query = query.Where("removed_at IS NOT NULL")
+	}
+
+	if search := c.Query("search"); search != "" {
+		query = query.Where("content LIKE ?", "%"+search+"%")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	if err := query.Order("sent_at DESC").Offset(offset).Limit(pageSize).Find(&messages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      messages,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
 }
 
-// SendMessage 手动发送消息
+// SendMessage 手动发送消息（加入发送队列）
+// 支持 JSON（纯文本）和 multipart/form-data（图片/视频/文件 + 说明文字）两种请求
+// 可通过 Idempotency-Key 请求头（或 idempotency_key 字段）避免重复提交
 func SendMessage(c *gin.Context) {
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		sendMediaMessage(c)
+		return
+	}
+
 	var request struct {
-		AccountID uint   `json:"account_id" binding:"required"`
-		GroupID   uint   `json:"group_id" binding:"required"`
-		Content   string `json:"content" binding:"required"`
+		AccountID      uint   `json:"account_id" binding:"required"`
+		GroupID        uint   `json:"group_id" binding:"required"`
+		Content        string `json:"content" binding:"required"`
+		ReplyToMsgID   int    `json:"reply_to_msg_id"`
+		TopicID        int    `json:"topic_id"` // 发送到的论坛话题ID
+		SendAt         string `json:"send_at"`
+		Priority       int    `json:"priority"`
+		IdempotencyKey string `json:"idempotency_key"`
+
+		Buttons models.InlineKeyboard `json:"buttons"` // 内联键盘（仅机器人账号）
 	}
 	
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -100,32 +327,140 @@ func SendMessage(c *gin.Context) {
 	}
 	
 	// 验证账号和群组是否存在
-	var account models.Account
-	if err := database.DB.First(&account, request.AccountID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "账号不存在"})
+	if !checkSendTarget(c, request.AccountID, request.GroupID) {
 		return
 	}
-	
-	var group models.Group
-	if err := database.DB.First(&group, request.GroupID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "群组不存在"})
+	if !checkButtons(c, request.AccountID, request.Buttons) {
+		return
+	}
+
+	sendAt, err := parseSendAt(request.SendAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 调用Telegram管理器发送消息
-	if tgManagerGetter == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Telegram管理器未初始化"})
+	item := &models.OutboundMessage{
+		AccountID:      request.AccountID,
+		GroupID:        request.GroupID,
+		Source:         "manual",
+		Content:        request.Content,
+		Buttons:        request.Buttons,
+		ReplyToMsgID:   request.ReplyToMsgID,
+		TopicID:        request.TopicID,
+		SendAt:         sendAt,
+		Priority:       request.Priority,
+		IdempotencyKey: idempotencyKey(c, request.IdempotencyKey),
+	}
+	enqueueOutbound(c, item, nil)
+}
+
+// sendMediaMessage 手动发送媒体消息（multipart/form-data）
+// 表单字段：account_id、group_id、file、caption（可选）、media_type（可选，photo/video/document，默认按文件类型判断）、
+// reply_to_msg_id、topic_id、send_at、priority、idempotency_key、buttons（内联键盘的 JSON，均可选）
+func sendMediaMessage(c *gin.Context) {
+	var request struct {
+		AccountID      uint   `form:"account_id" binding:"required"`
+		GroupID        uint   `form:"group_id" binding:"required"`
+		Caption        string `form:"caption"`
+		MediaType      string `form:"media_type"`
+		ReplyToMsgID   int    `form:"reply_to_msg_id"`
+		TopicID        int    `form:"topic_id"`
+		SendAt         string `form:"send_at"`
+		Priority       int    `form:"priority"`
+		IdempotencyKey string `form:"idempotency_key"`
+		Buttons        string `form:"buttons"`
+	}
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+
+	var buttons models.InlineKeyboard
+	if request.Buttons != "" {
+		if err := json.Unmarshal([]byte(request.Buttons), &buttons); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "buttons 格式错误: " + err.Error()})
+			return
+		}
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少上传文件: " + err.Error()})
+		return
+	}
+	if fileHeader.Size > telegram.MaxMediaSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("文件过大（上限 %dMB）", telegram.MaxMediaSize>>20)})
+		return
+	}
+
+	if !checkSendTarget(c, request.AccountID, request.GroupID) {
+		return
+	}
+	if !checkButtons(c, request.AccountID, buttons) {
+		return
+	}
+
+	sendAt, err := parseSendAt(request.SendAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	manager := tgManagerGetter()
-	if manager == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法获取Telegram管理器"})
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取上传文件失败: " + err.Error()})
 		return
 	}
+	defer file.Close()
 
+	data, err := io.ReadAll(io.LimitReader(file, telegram.MaxMediaSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取上传文件失败: " + err.Error()})
+		return
+	}
+
+	mimeType := fileHeader.Header.Get("Content-Type")
+	mediaType := request.MediaType
+	if mediaType == "" {
+		mediaType = telegram.DetectMediaType(mimeType, fileHeader.Filename)
+	}
+
+	media := &telegram.MediaFile{
+		Type:     mediaType,
+		FileName: filepath.Base(fileHeader.Filename),
+		MimeType: mimeType,
+		Data:     data,
+		Caption:  request.Caption,
+	}
+	if err := telegram.ValidateMediaFile(media); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "文件无效: " + err.Error()})
+		return
+	}
+
+	item := &models.OutboundMessage{
+		AccountID:      request.AccountID,
+		GroupID:        request.GroupID,
+		Source:         "manual",
+		ReplyToMsgID:   request.ReplyToMsgID,
+		TopicID:        request.TopicID,
+		SendAt:         sendAt,
+		Priority:       request.Priority,
+		IdempotencyKey: idempotencyKey(c, request.IdempotencyKey),
+		Buttons:        buttons,
+	}
+	enqueueOutbound(c, item, media)
+}
+
+// enqueueOutbound 通过Telegram管理器将消息加入发送队列并写入响应
+func enqueueOutbound(c *gin.Context, item *models.OutboundMessage, media *telegram.MediaFile) {
 	type ManagerInterface interface {
-		SendMessageToGroup(accountID uint, groupID uint, text string) error
+		EnqueueOutbound(item *models.OutboundMessage, media *telegram.MediaFile) (*models.OutboundMessage, bool, error)
+	}
+
+	manager, ok := getTGManager(c)
+	if !ok {
+		return
 	}
 
 	mgr, ok := manager.(ManagerInterface)
@@ -134,14 +469,93 @@ func SendMessage(c *gin.Context) {
 		return
 	}
 
-	if err := mgr.SendMessageToGroup(request.AccountID, request.GroupID, request.Content); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "发送消息失败: " + err.Error()})
+	queued, existed, err := mgr.EnqueueOutbound(item, media)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加入发送队列失败: " + err.Error()})
 		return
 	}
 
-	// 发送成功，具体消息记录由Telegram客户端在 saveMessage 中写入
-	c.JSON(http.StatusOK, gin.H{
-		"message": "消息发送请求已提交",
+	if existed {
+		// 幂等键重复：返回已有的队列记录
+		c.JSON(http.StatusOK, gin.H{
+			"message": "重复请求，返回已有的发送记录",
+			"data":    queued,
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "消息已加入发送队列",
+		"data":    queued,
 	})
 }
 
+// idempotencyKey 获取幂等键（优先使用 Idempotency-Key 请求头）
+func idempotencyKey(c *gin.Context, fromBody string) *string {
+	key := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+	if key == "" {
+		key = strings.TrimSpace(fromBody)
+	}
+	if key == "" {
+		return nil
+	}
+	return &key
+}
+
+// parseSendAt 解析发送时间（支持 2006-01-02 15:04:05 和 RFC3339），为空表示立即发送
+func parseSendAt(value string) (*time.Time, error) {
+	return parseTimeIn(value, time.Local)
+}
+
+// parseTimeIn 按指定时区解析时间（不带时区的格式按 loc 解释），为空返回 nil
+func parseTimeIn(value string, loc *time.Location) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", value, loc); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("send_at 格式错误，应为 2006-01-02 15:04:05 或 RFC3339")
+	}
+	return &t, nil
+}
+
+// checkSendTarget 验证账号和群组是否存在，失败时直接写入响应
+func checkSendTarget(c *gin.Context, accountID, groupID uint) bool {
+	var account models.Account
+	if err := database.DB.First(&account, accountID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "账号不存在"})
+		return false
+	}
+	
+	var group models.Group
+	if err := database.DB.First(&group, groupID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "群组不存在"})
+		return false
+	}
+	return true
+}
+
+// checkButtons 校验内联键盘，只有机器人账号可以发送，失败时直接写入响应
+func checkButtons(c *gin.Context, accountID uint, buttons models.InlineKeyboard) bool {
+	if len(buttons) == 0 {
+		return true
+	}
+	if err := telegram.ValidateKeyboard(buttons); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "按钮无效: " + err.Error()})
+		return false
+	}
+
+	var account models.Account
+	if err := database.DB.Select("type").First(&account, accountID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "账号不存在"})
+		return false
+	}
+	if account.Type != telegram.AccountTypeBot {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只有机器人账号可以发送内联键盘"})
+		return false
+	}
+	return true
+}
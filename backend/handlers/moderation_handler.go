@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"aibot/internal/database"
+	"aibot/internal/telegram"
+	"aibot/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetModerationRules 获取群管规则列表
+func GetModerationRules(c *gin.Context) {
+	var rules []models.ModerationRule
+
+	query := database.DB
+
+	// 支持群组过滤（global=true 只看全局规则）
+	if groupID := c.Query("group_id"); groupID != "" {
+		query = query.Where("group_id = ?", groupID)
+	} else if c.Query("global") == "true" {
+		query = query.Where("group_id IS NULL")
+	}
+	if ruleType := c.Query("type"); ruleType != "" {
+		query = query.Where("type = ?", ruleType)
+	}
+
+	if err := query.Order("priority DESC, id ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rules})
+}
+
+// GetModerationRule 获取单个群管规则
+func GetModerationRule(c *gin.Context) {
+	rule, ok := findModerationRule(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rule})
+}
+
+// CreateModerationRule 创建群管规则
+func CreateModerationRule(c *gin.Context) {
+	// 默认同时删除触发消息
+	rule := models.ModerationRule{Enabled: true, DeleteMessage: true}
+
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+
+	if rule.MatchType == "" {
+		rule.MatchType = "keyword"
+	}
+	if err := telegram.ValidateModerationRule(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "规则无效: " + err.Error()})
+		return
+	}
+	if rule.Name == "" {
+		rule.Name = rule.Type
+	}
+
+	// 统计字段由系统维护
+	rule.HitCount = 0
+	rule.LastHitAt = nil
+
+	if err := database.DB.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败: " + err.Error()})
+		return
+	}
+	// enabled 有默认值，创建时为 false 会被数据库默认值覆盖
+	if !rule.Enabled {
+		database.DB.Model(&rule).Update("enabled", false)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "规则创建成功",
+		"data":    rule,
+	})
+}
+
+// UpdateModerationRule 更新群管规则
+func UpdateModerationRule(c *gin.Context) {
+	rule, ok := findModerationRule(c)
+	if !ok {
+		return
+	}
+
+	// 在原规则上绑定，未提交的字段保持不变（支持把 enabled 等字段更新为零值）
+	updated := *rule
+	if err := c.ShouldBindJSON(&updated); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if err := telegram.ValidateModerationRule(&updated); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "规则无效: " + err.Error()})
+		return
+	}
+
+	// 系统维护的字段不允许修改
+	updated.ID = rule.ID
+	updated.HitCount = rule.HitCount
+	updated.LastHitAt = rule.LastHitAt
+	updated.CreatedAt = rule.CreatedAt
+
+	if err := database.DB.Save(&updated).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败: " + err.Error()})
+		return
+	}
+	telegram.EvictModerationRulePattern(rule.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "规则更新成功",
+		"data":    updated,
+	})
+}
+
+// DeleteModerationRule 删除群管规则（已有的动作记录保留）
+func DeleteModerationRule(c *gin.Context) {
+	id := c.Param("id")
+
+	if err := database.DB.Delete(&models.ModerationRule{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败: " + err.Error()})
+		return
+	}
+	if ruleID, err := strconv.ParseUint(id, 10, 64); err == nil {
+		telegram.EvictModerationRulePattern(uint(ruleID))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "规则删除成功"})
+}
+
+// GetModerationActions 获取群管动作记录（申诉复核）
+func GetModerationActions(c *gin.Context) {
+	var actions []models.ModerationAction
+
+	query := database.DB.Preload("Account").Preload("Group")
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	offset := (page - 1) * pageSize
+
+	if groupID := c.Query("group_id"); groupID != "" {
+		query = query.Where("group_id = ?", groupID)
+	}
+	if accountID := c.Query("account_id"); accountID != "" {
+		query = query.Where("account_id = ?", accountID)
+	}
+	if ruleID := c.Query("rule_id"); ruleID != "" {
+		query = query.Where("rule_id = ?", ruleID)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	// review_status=pending 表示尚未复核
+	switch reviewStatus := c.Query("review_status"); reviewStatus {
+	case "":
+	case "pending":
+		query = query.Where("review_status = ? OR review_status IS NULL", "")
+	default:
+		query = query.Where("review_status = ?", reviewStatus)
+	}
+
+	var total int64
+	query.Model(&models.ModerationAction{}).Count(&total)
+
+	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&actions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      actions,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetModerationAction 获取单条群管动作记录
+func GetModerationAction(c *gin.Context) {
+	var action models.ModerationAction
+	if err := database.DB.Preload("Account").Preload("Group").First(&action, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "记录不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": action})
+}
+
+// ReviewModerationAction 复核群管动作：维持（upheld）或撤销（reverted，解除禁言/封禁）
+func ReviewModerationAction(c *gin.Context) {
+	var action models.ModerationAction
+	if err := database.DB.First(&action, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "记录不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	var request struct {
+		Decision string `json:"decision" binding:"required,oneof=upheld reverted"`
+		Note     string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+
+	if action.ReviewStatus == "reverted" {
+		c.JSON(http.StatusConflict, gin.H{"error": "该动作已撤销"})
+		return
+	}
+
+	if request.Decision == "reverted" {
+		manager, ok := getTGManager(c)
+		if !ok {
+			return
+		}
+		type ManagerInterface interface {
+			RevertModeration(record *models.ModerationAction) error
+		}
+		mgr, ok := manager.(ManagerInterface)
+		if !ok {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "管理器类型不匹配"})
+			return
+		}
+		if err := mgr.RevertModeration(&action); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "撤销失败: " + err.Error()})
+			return
+		}
+		action.Status = telegram.ModerationStatusReverted
+	}
+
+	now := time.Now()
+	action.ReviewStatus = request.Decision
+	action.ReviewNote = request.Note
+	action.ReviewedAt = &now
+	if err := database.DB.Save(&action).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "复核完成",
+		"data":    action,
+	})
+}
+
+// findModerationRule 查找群管规则，失败时直接写入响应
+func findModerationRule(c *gin.Context) (*models.ModerationRule, bool) {
+	var rule models.ModerationRule
+	if err := database.DB.First(&rule, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "规则不存在"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return nil, false
+	}
+	return &rule, true
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"aibot/internal/database"
+	"aibot/internal/telegram"
+	"aibot/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetOutbox 获取发送队列
+func GetOutbox(c *gin.Context) {
+	var items []models.OutboundMessage
+
+	query := database.DB.Model(&models.OutboundMessage{})
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	offset := (page - 1) * pageSize
+
+	if accountID := c.Query("account_id"); accountID != "" {
+		query = query.Where("account_id = ?", accountID)
+	}
+	if groupID := c.Query("group_id"); groupID != "" {
+		query = query.Where("group_id = ?", groupID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if source := c.Query("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	if err := query.Order("send_at DESC, id DESC").Offset(offset).Limit(pageSize).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      items,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetOutboxItem 获取单条发送队列记录
+func GetOutboxItem(c *gin.Context) {
+	var item models.OutboundMessage
+	if err := database.DB.First(&item, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "队列记录不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": item})
+}
+
+// CancelOutboxItem 取消待发送的消息
+func CancelOutboxItem(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的队列ID"})
+		return
+	}
+
+	item, err := telegram.CancelOutbound(database.DB, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "队列记录不存在"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "取消失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已取消发送",
+		"data":    item,
+	})
+}
+
+// RescheduleOutboxItem 调整待发送消息的发送时间和优先级（发送失败的消息会重新排队）
+func RescheduleOutboxItem(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的队列ID"})
+		return
+	}
+
+	var request struct {
+		SendAt   string `json:"send_at" binding:"required"`
+		Priority *int   `json:"priority"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+
+	sendAt, err := parseSendAt(request.SendAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	item, err := telegram.RescheduleOutbound(database.DB, uint(id), *sendAt, request.Priority)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "队列记录不存在"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "调整失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "发送时间已调整",
+		"data":    item,
+	})
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"aibot/internal/database"
+	"aibot/internal/telegram"
+	"aibot/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetPolls 获取投票列表
+func GetPolls(c *gin.Context) {
+	var polls []models.Poll
+
+	query := database.DB.Preload("Options", orderPollOptions).Preload("Group")
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	offset := (page - 1) * pageSize
+
+	if accountID := c.Query("account_id"); accountID != "" {
+		query = query.Where("account_id = ?", accountID)
+	}
+	if groupID := c.Query("group_id"); groupID != "" {
+		query = query.Where("group_id = ?", groupID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Model(&models.Poll{}).Count(&total)
+
+	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&polls).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      polls,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetPoll 获取单个投票
+func GetPoll(c *gin.Context) {
+	poll, ok := findPoll(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": poll})
+}
+
+// CreatePoll 创建投票并加入发送队列
+func CreatePoll(c *gin.Context) {
+	var request struct {
+		AccountID      uint     `json:"account_id" binding:"required"`
+		GroupID        uint     `json:"group_id" binding:"required"`
+		Question       string   `json:"question" binding:"required"`
+		Options        []string `json:"options" binding:"required"`
+		Anonymous      *bool    `json:"anonymous"` // 默认匿名
+		MultipleChoice bool     `json:"multiple_choice"`
+		SendAt         string   `json:"send_at"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+
+	if !checkSendTarget(c, request.AccountID, request.GroupID) {
+		return
+	}
+
+	sendAt, err := parseSendAt(request.SendAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	poll := &models.Poll{
+		AccountID:      request.AccountID,
+		GroupID:        request.GroupID,
+		Question:       request.Question,
+		Anonymous:      request.Anonymous == nil || *request.Anonymous,
+		MultipleChoice: request.MultipleChoice,
+	}
+	for _, text := range request.Options {
+		poll.Options = append(poll.Options, models.PollOption{Text: text})
+	}
+	if err := telegram.ValidatePoll(poll); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "投票无效: " + err.Error()})
+		return
+	}
+
+	mgr, ok := pollManager(c)
+	if !ok {
+		return
+	}
+	if err := mgr.CreatePoll(poll, sendAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建投票失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "投票已加入发送队列",
+		"data":    poll,
+	})
+}
+
+// ClosePoll 结束投票
+func ClosePoll(c *gin.Context) {
+	poll, ok := findPoll(c)
+	if !ok {
+		return
+	}
+
+	switch poll.Status {
+	case telegram.PollClosed:
+		c.JSON(http.StatusConflict, gin.H{"error": "投票已结束"})
+		return
+	case telegram.PollPending, telegram.PollFailed:
+		c.JSON(http.StatusConflict, gin.H{"error": "投票尚未发送"})
+		return
+	}
+
+	mgr, ok := pollManager(c)
+	if !ok {
+		return
+	}
+	if err := mgr.ClosePoll(poll); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	database.DB.Preload("Options", orderPollOptions).First(poll, poll.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "投票已结束",
+		"data":    poll,
+	})
+}
+
+// GetPollResults 获取投票结果
+// refresh=true 先从 Telegram 拉取最新结果；voters=true 同时返回公开投票的投票人
+func GetPollResults(c *gin.Context) {
+	poll, ok := findPoll(c)
+	if !ok {
+		return
+	}
+
+	refresh := c.Query("refresh") == "true"
+	withVoters := c.Query("voters") == "true"
+	if withVoters && poll.Anonymous {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "匿名投票无法查看投票人"})
+		return
+	}
+
+	var voters []telegram.PollVoter
+	if refresh || withVoters {
+		if poll.TelegramMessageID == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "投票尚未发送"})
+			return
+		}
+		mgr, ok := pollManager(c)
+		if !ok {
+			return
+		}
+		if refresh {
+			if err := mgr.RefreshPollResults(poll); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			database.DB.Preload("Options", orderPollOptions).First(poll, poll.ID)
+		}
+		if withVoters {
+			var err error
+			if voters, err = mgr.GetPollVoters(poll); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+
+	options := make([]gin.H, 0, len(poll.Options))
+	for _, option := range poll.Options {
+		percent := 0.0
+		if poll.TotalVoters > 0 {
+			percent = float64(option.Voters) * 100 / float64(poll.TotalVoters)
+		}
+		options = append(options, gin.H{
+			"position": option.Position,
+			"text":     option.Text,
+			"voters":   option.Voters,
+			"percent":  percent,
+		})
+	}
+
+	result := gin.H{
+		"poll_id":      poll.ID,
+		"question":     poll.Question,
+		"status":       poll.Status,
+		"total_voters": poll.TotalVoters,
+		"options":      options,
+		"results_at":   poll.ResultsAt,
+	}
+	if withVoters {
+		result["voters"] = voters
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// pollOperator 投票相关的Telegram管理器方法
+type pollOperator interface {
+	CreatePoll(poll *models.Poll, sendAt *time.Time) error
+	ClosePoll(poll *models.Poll) error
+	RefreshPollResults(poll *models.Poll) error
+	GetPollVoters(poll *models.Poll) ([]telegram.PollVoter, error)
+}
+
+// pollManager 获取支持投票操作的Telegram管理器，失败时直接写入响应
+func pollManager(c *gin.Context) (pollOperator, bool) {
+	manager, ok := getTGManager(c)
+	if !ok {
+		return nil, false
+	}
+	mgr, ok := manager.(pollOperator)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "管理器类型不匹配"})
+		return nil, false
+	}
+	return mgr, true
+}
+
+// findPoll 查找投票（含选项），失败时直接写入响应
+func findPoll(c *gin.Context) (*models.Poll, bool) {
+	var poll models.Poll
+	if err := database.DB.Preload("Options", orderPollOptions).First(&poll, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "投票不存在"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return nil, false
+	}
+	return &poll, true
+}
+
+// orderPollOptions 选项按序号排序
+func orderPollOptions(db *gorm.DB) *gorm.DB {
+	return db.Order("position ASC")
+}
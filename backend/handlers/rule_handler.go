@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"aibot/internal/database"
+	"aibot/internal/telegram"
+	"aibot/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetRules 获取回复规则列表
+func GetRules(c *gin.Context) {
+	var rules []models.ReplyRule
+
+	query := database.DB
+
+	// 支持群组过滤（global=true 只看全局规则）
+	if groupID := c.Query("group_id"); groupID != "" {
+		query = query.Where("group_id = ?", groupID)
+	} else if c.Query("global") == "true" {
+		query = query.Where("group_id IS NULL")
+	}
+
+	if err := query.Order("priority DESC, id ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rules})
+}
+
+// GetRule 获取单个回复规则
+func GetRule(c *gin.Context) {
+	id := c.Param("id")
+
+	var rule models.ReplyRule
+	if err := database.DB.First(&rule, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "规则不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rule})
+}
+
+// CreateRule 创建回复规则
+func CreateRule(c *gin.Context) {
+	// 未提交 enabled 时默认启用
+	rule := models.ReplyRule{Enabled: true}
+
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+
+	if rule.MatchType == "" {
+		rule.MatchType = "keyword"
+	}
+	if err := telegram.ValidateRule(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "规则无效: " + err.Error()})
+		return
+	}
+	if rule.Name == "" {
+		rule.Name = rule.Pattern
+	}
+
+	// 统计字段由系统维护
+	rule.HitCount = 0
+	rule.LastHitAt = nil
+
+	if err := database.DB.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败: " + err.Error()})
+		return
+	}
+	// enabled 有默认值，创建时为 false 会被数据库默认值覆盖
+	if !rule.Enabled {
+		database.DB.Model(&rule).Update("enabled", false)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "规则创建成功",
+		"data":    rule,
+	})
+}
+
+// UpdateRule 更新回复规则
+func UpdateRule(c *gin.Context) {
+	id := c.Param("id")
+
+	var rule models.ReplyRule
+	if err := database.DB.First(&rule, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "规则不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	// 在原规则上绑定，未提交的字段保持不变（支持把 enabled 等字段更新为零值）
+	updated := rule
+	if err := c.ShouldBindJSON(&updated); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if err := telegram.ValidateRule(&updated); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "规则无效: " + err.Error()})
+		return
+	}
+
+	// 系统维护的字段不允许修改
+	updated.ID = rule.ID
+	updated.HitCount = rule.HitCount
+	updated.LastHitAt = rule.LastHitAt
+	updated.CreatedAt = rule.CreatedAt
+
+	if err := database.DB.Save(&updated).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败: " + err.Error()})
+		return
+	}
+	telegram.EvictReplyRulePattern(rule.ID)
+	rule = updated
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "规则更新成功",
+		"data":    rule,
+	})
+}
+
+// DeleteRule 删除回复规则
+func DeleteRule(c *gin.Context) {
+	id := c.Param("id")
+
+	if err := database.DB.Delete(&models.ReplyRule{}, id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败: " + err.Error()})
+		return
+	}
+	if ruleID, err := strconv.ParseUint(id, 10, 64); err == nil {
+		telegram.EvictReplyRulePattern(uint(ruleID))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "规则删除成功"})
+}
+
+// GetApprovals 获取审核队列
+func GetApprovals(c *gin.Context) {
+	var items []models.ApprovalItem
+
+	query := database.DB.Preload("Account").Preload("Group")
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	offset := (page - 1) * pageSize
+
+	// 默认只看待审核的
+	status := c.DefaultQuery("status", "pending")
+	if status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	if groupID := c.Query("group_id"); groupID != "" {
+		query = query.Where("group_id = ?", groupID)
+	}
+
+	var total int64
+	query.Model(&models.ApprovalItem{}).Count(&total)
+
+	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      items,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// ApproveReply 审核通过并发送回复（可修改草稿内容）
+func ApproveReply(c *gin.Context) {
+	item, ok := findPendingApproval(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Content string `json:"content"` // 为空则使用AI草稿
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+			return
+		}
+	}
+	content := item.DraftReply
+	if request.Content != "" {
+		content = request.Content
+	}
+
+	if tgManagerGetter == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Telegram管理器未初始化"})
+		return
+	}
+
+	manager := tgManagerGetter()
+	if manager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法获取Telegram管理器"})
+		return
+	}
+
+	type ManagerInterface interface {
+		SendReplyToGroup(accountID uint, groupID uint, topicID int, text string, replyToMsgID int) error
+	}
+
+	mgr, ok := manager.(ManagerInterface)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "管理器类型不匹配"})
+		return
+	}
+
+	now := time.Now()
+	item.ReviewedAt = &now
+	item.DraftReply = content
+
+	if err := mgr.SendReplyToGroup(item.AccountID, item.GroupID, item.TriggerTopicID, content, int(item.TriggerMessageID)); err != nil {
+		item.Status = "failed"
+		item.Error = err.Error()
+		database.DB.Save(item)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "发送消息失败: " + err.Error()})
+		return
+	}
+
+	item.Status = "approved"
+	item.Error = ""
+	database.DB.Save(item)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已审核通过，回复已加入发送队列",
+		"data":    item,
+	})
+}
+
+// RejectReply 驳回回复
+func RejectReply(c *gin.Context) {
+	item, ok := findPendingApproval(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	item.Status = "rejected"
+	item.ReviewedAt = &now
+	if err := database.DB.Save(item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已驳回",
+		"data":    item,
+	})
+}
+
+// findPendingApproval 查找待审核（或发送失败可重试）的条目，失败时直接写入响应
+func findPendingApproval(c *gin.Context) (*models.ApprovalItem, bool) {
+	id := c.Param("id")
+
+	var item models.ApprovalItem
+	if err := database.DB.First(&item, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "审核条目不存在"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return nil, false
+	}
+
+	if item.Status != "pending" && item.Status != "failed" {
+		c.JSON(http.StatusConflict, gin.H{"error": "该条目已处理"})
+		return nil, false
+	}
+
+	return &item, true
+}
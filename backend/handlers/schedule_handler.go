@@ -0,0 +1,390 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"aibot/internal/database"
+	"aibot/internal/telegram"
+	"aibot/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetSchedules 获取定时公告列表
+func GetSchedules(c *gin.Context) {
+	var schedules []models.Schedule
+
+	query := database.DB
+	if accountID := c.Query("account_id"); accountID != "" {
+		query = query.Where("account_id = ?", accountID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Order("id DESC").Find(&schedules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": schedules})
+}
+
+// GetSchedule 获取单个定时公告
+func GetSchedule(c *gin.Context) {
+	schedule, ok := findSchedule(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": schedule})
+}
+
+// CreateSchedule 创建定时公告
+// 支持 JSON（纯文本公告）和 multipart/form-data（媒体公告，文件字段为 file，content 作为说明文字）
+func CreateSchedule(c *gin.Context) {
+	var request struct {
+		Name      string `json:"name" form:"name" binding:"required"`
+		AccountID uint   `json:"account_id" form:"account_id" binding:"required"`
+		GroupIDs  string `json:"group_ids" form:"group_ids" binding:"required"`
+		CronExpr  string `json:"cron_expr" form:"cron_expr"`
+		RunAt     string `json:"run_at" form:"run_at"`
+		Timezone  string `json:"timezone" form:"timezone"`
+		Content   string `json:"content" form:"content"`
+		MediaType string `json:"media_type" form:"media_type"`
+		Priority  int    `json:"priority" form:"priority"`
+	}
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+
+	runAt, err := parseTimeIn(request.RunAt, scheduleTimezone(request.Timezone))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "run_at 格式错误，应为 2006-01-02 15:04:05 或 RFC3339"})
+		return
+	}
+
+	schedule := models.Schedule{
+		Name:      request.Name,
+		AccountID: request.AccountID,
+		GroupIDs:  request.GroupIDs,
+		CronExpr:  strings.TrimSpace(request.CronExpr),
+		RunAt:     runAt,
+		Timezone:  request.Timezone,
+		Content:   request.Content,
+		Priority:  request.Priority,
+		Status:    telegram.ScheduleActive,
+	}
+
+	var media *telegram.MediaFile
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		var ok bool
+		if media, ok = scheduleMediaFromForm(c, request.MediaType, request.Content); !ok {
+			return
+		}
+		schedule.MediaType = media.Type
+		schedule.FileName = media.FileName
+		schedule.MimeType = media.MimeType
+		schedule.FileSize = int64(len(media.Data))
+	}
+
+	if err := telegram.ValidateSchedule(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "公告配置无效: " + err.Error()})
+		return
+	}
+	if !checkScheduleTargets(c, &schedule) {
+		return
+	}
+
+	schedule.NextRunAt = telegram.NextScheduleRun(&schedule, time.Now())
+	if schedule.NextRunAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "发送时间已过，没有可执行的时间"})
+		return
+	}
+
+	if media != nil {
+		path, err := telegram.SaveScheduleMedia(media)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		schedule.MediaPath = path
+	}
+
+	if err := database.DB.Create(&schedule).Error; err != nil {
+		telegram.RemoveScheduleMedia(schedule.MediaPath)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "定时公告已创建",
+		"data":    schedule,
+	})
+}
+
+// UpdateSchedule 更新定时公告（媒体文件不可修改，需要更换时请重新创建）
+func UpdateSchedule(c *gin.Context) {
+	existing, ok := findSchedule(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Name      *string `json:"name"`
+		AccountID *uint   `json:"account_id"`
+		GroupIDs  *string `json:"group_ids"`
+		CronExpr  *string `json:"cron_expr"`
+		RunAt     *string `json:"run_at"`
+		Timezone  *string `json:"timezone"`
+		Content   *string `json:"content"`
+		Priority  *int    `json:"priority"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+
+	schedule := *existing
+	if request.Name != nil {
+		schedule.Name = *request.Name
+	}
+	if request.AccountID != nil {
+		schedule.AccountID = *request.AccountID
+	}
+	if request.GroupIDs != nil {
+		schedule.GroupIDs = *request.GroupIDs
+	}
+	if request.CronExpr != nil {
+		schedule.CronExpr = strings.TrimSpace(*request.CronExpr)
+	}
+	if request.Timezone != nil {
+		schedule.Timezone = *request.Timezone
+	}
+	if request.RunAt != nil {
+		runAt, err := parseTimeIn(*request.RunAt, scheduleTimezone(schedule.Timezone))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "run_at 格式错误，应为 2006-01-02 15:04:05 或 RFC3339"})
+			return
+		}
+		schedule.RunAt = runAt
+	}
+	if request.Content != nil {
+		schedule.Content = *request.Content
+	}
+	if request.Priority != nil {
+		schedule.Priority = *request.Priority
+	}
+
+	if err := telegram.ValidateSchedule(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "公告配置无效: " + err.Error()})
+		return
+	}
+	if !checkScheduleTargets(c, &schedule) {
+		return
+	}
+
+	// 时间规则可能变化，重新计算下一次执行时间；已完成的单次公告改期后重新启用
+	schedule.NextRunAt = telegram.NextScheduleRun(&schedule, time.Now())
+	switch {
+	case schedule.NextRunAt == nil:
+		schedule.Status = telegram.ScheduleCompleted
+	case schedule.Status == telegram.ScheduleCompleted:
+		schedule.Status = telegram.ScheduleActive
+	}
+
+	if err := database.DB.Save(&schedule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "定时公告已更新",
+		"data":    schedule,
+	})
+}
+
+// DeleteSchedule 删除定时公告（保留执行记录和已产生的发言记录）
+func DeleteSchedule(c *gin.Context) {
+	schedule, ok := findSchedule(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Delete(schedule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败: " + err.Error()})
+		return
+	}
+	telegram.RemoveScheduleMedia(schedule.MediaPath)
+
+	c.JSON(http.StatusOK, gin.H{"message": "定时公告已删除"})
+}
+
+// PauseSchedule 暂停定时公告
+func PauseSchedule(c *gin.Context) {
+	schedule, ok := findSchedule(c)
+	if !ok {
+		return
+	}
+	if schedule.Status != telegram.ScheduleActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只能暂停运行中的公告（当前状态: " + schedule.Status + "）"})
+		return
+	}
+
+	schedule.Status = telegram.SchedulePaused
+	if err := database.DB.Model(schedule).Update("status", schedule.Status).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "暂停失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "定时公告已暂停",
+		"data":    schedule,
+	})
+}
+
+// ResumeSchedule 恢复定时公告（从当前时间重新计算下一次执行时间，暂停期间错过的不补发）
+func ResumeSchedule(c *gin.Context) {
+	schedule, ok := findSchedule(c)
+	if !ok {
+		return
+	}
+	if schedule.Status != telegram.SchedulePaused {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只能恢复已暂停的公告（当前状态: " + schedule.Status + "）"})
+		return
+	}
+
+	next := telegram.NextScheduleRun(schedule, time.Now())
+	if next == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "发送时间已过，请先修改发送时间"})
+		return
+	}
+
+	schedule.Status = telegram.ScheduleActive
+	schedule.NextRunAt = next
+	if err := database.DB.Model(schedule).Updates(map[string]interface{}{
+		"status":      schedule.Status,
+		"next_run_at": next,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "定时公告已恢复",
+		"data":    schedule,
+	})
+}
+
+// GetScheduleRuns 获取定时公告的执行记录（附带每次执行入队的消息及发送结果）
+func GetScheduleRuns(c *gin.Context) {
+	schedule, ok := findSchedule(c)
+	if !ok {
+		return
+	}
+
+	var runs []models.ScheduleRun
+	if err := database.DB.Where("schedule_id = ?", schedule.ID).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("group_id ASC") }).
+		Order("scheduled_at DESC").
+		Limit(100).
+		Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": runs})
+}
+
+// findSchedule 按路径参数查找定时公告，失败时直接写入响应
+func findSchedule(c *gin.Context) (*models.Schedule, bool) {
+	var schedule models.Schedule
+	if err := database.DB.First(&schedule, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "定时公告不存在"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return nil, false
+	}
+	return &schedule, true
+}
+
+// scheduleTimezone 公告的时区，为空或无效时使用服务器时区（无效时区由 ValidateSchedule 报错）
+func scheduleTimezone(name string) *time.Location {
+	if name == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// checkScheduleTargets 验证发送账号和目标群组是否存在，失败时直接写入响应
+func checkScheduleTargets(c *gin.Context, schedule *models.Schedule) bool {
+	var account models.Account
+	if err := database.DB.First(&account, schedule.AccountID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "账号不存在"})
+		return false
+	}
+
+	groupIDs := telegram.ParseGroupIDs(schedule.GroupIDs)
+	var count int64
+	database.DB.Model(&models.Group{}).Where("id IN ?", groupIDs).Count(&count)
+	if int(count) != len(groupIDs) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "部分目标群组不存在"})
+		return false
+	}
+	return true
+}
+
+// scheduleMediaFromForm 读取表单中的媒体文件，失败时直接写入响应
+func scheduleMediaFromForm(c *gin.Context, mediaType, caption string) (*telegram.MediaFile, bool) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少上传文件: " + err.Error()})
+		return nil, false
+	}
+	if fileHeader.Size > telegram.MaxMediaSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("文件过大（上限 %dMB）", telegram.MaxMediaSize>>20)})
+		return nil, false
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取上传文件失败: " + err.Error()})
+		return nil, false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, telegram.MaxMediaSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取上传文件失败: " + err.Error()})
+		return nil, false
+	}
+
+	mimeType := fileHeader.Header.Get("Content-Type")
+	if mediaType == "" {
+		mediaType = telegram.DetectMediaType(mimeType, fileHeader.Filename)
+	}
+	media := &telegram.MediaFile{
+		Type:     mediaType,
+		FileName: filepath.Base(fileHeader.Filename),
+		MimeType: mimeType,
+		Data:     data,
+		Caption:  caption,
+	}
+	if err := telegram.ValidateMediaFile(media); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "文件无效: " + err.Error()})
+		return nil, false
+	}
+	return media, true
+}
@@ -8,6 +8,7 @@ import (
 	"aibot/internal/database"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // GetStatistics 获取统计数据
@@ -30,17 +31,22 @@ func GetStatistics(c *gin.Context) {
 	stats["total_groups"] = totalGroups
 	
 	// 今日发言数
-	today := time.Now().Format("2006-01-02")
+	todayStart, tomorrowStart := todayRange()
 	var todayMessages int64
-	database.DB.Model(&models.Message{}).
-		Where("DATE(created_at) = ?", today).
+	sentMessages().
+		Where("created_at >= ? AND created_at < ?", todayStart, tomorrowStart).
 		Count(&todayMessages)
 	stats["today_messages"] = todayMessages
 	
 	// 总发言数
 	var totalMessages int64
-	database.DB.Model(&models.Message{}).Count(&totalMessages)
+	sentMessages().Count(&totalMessages)
 	stats["total_messages"] = totalMessages
+
+	// 发送失败数
+	var failedMessages int64
+	database.DB.Model(&models.Message{}).Where("status = ?", "failed").Count(&failedMessages)
+	stats["failed_messages"] = failedMessages
 	
 	// 最近7天发言趋势
 	var dailyStats []struct {
@@ -49,10 +55,10 @@ func GetStatistics(c *gin.Context) {
 	}
 	
 	sevenDaysAgo := time.Now().AddDate(0, 0, -7)
-	database.DB.Model(&models.Message{}).
-		Select("DATE(created_at) as date, COUNT(*) as count").
+	sentMessages().
+		Select(database.DateExpr("created_at") + " as date, COUNT(*) as count").
 		Where("created_at >= ?", sevenDaysAgo).
-		Group("DATE(created_at)").
+		Group(database.DateExpr("created_at")).
 		Order("date ASC").
 		Scan(&dailyStats)
 	stats["daily_trend"] = dailyStats
@@ -65,7 +71,7 @@ func GetStatistics(c *gin.Context) {
 		Count       int64  `json:"count"`
 	}
 	
-	database.DB.Model(&models.Message{}).
+	sentMessages().
 		Select("account_id, accounts.phone_number, accounts.nickname, COUNT(*) as count").
 		Joins("LEFT JOIN ai_accounts as accounts ON messages.account_id = accounts.id").
 		Group("account_id, accounts.phone_number, accounts.nickname").
@@ -81,7 +87,7 @@ func GetStatistics(c *gin.Context) {
 		Count   int64  `json:"count"`
 	}
 	
-	database.DB.Model(&models.Message{}).
+	sentMessages().
 		Select("group_id, groups.title, COUNT(*) as count").
 		Joins("LEFT JOIN groups ON messages.group_id = groups.id").
 		Group("group_id, groups.title").
@@ -90,6 +96,24 @@ func GetStatistics(c *gin.Context) {
 		Scan(&groupStats)
 	stats["group_ranking"] = groupStats
 	
+	// 规则命中排行（Top 10）
+	var ruleStats []struct {
+		RuleID    uint       `json:"rule_id"`
+		Name      string     `json:"name"`
+		Action    string     `json:"action"`
+		GroupID   *uint      `json:"group_id"`
+		HitCount  int64      `json:"hit_count"`
+		LastHitAt *time.Time `json:"last_hit_at"`
+	}
+	
+	database.DB.Model(&models.ReplyRule{}).
+		Select("id as rule_id, name, action, group_id, hit_count, last_hit_at").
+		Where("hit_count > 0").
+		Order("hit_count DESC").
+		Limit(10).
+		Scan(&ruleStats)
+	stats["rule_ranking"] = ruleStats
+	
 	c.JSON(http.StatusOK, gin.H{"data": stats})
 }
 
@@ -101,22 +125,22 @@ func GetAccountStatistics(c *gin.Context) {
 	
 	// 发言总数
 	var totalMessages int64
-	database.DB.Model(&models.Message{}).
+	sentMessages().
 		Where("account_id = ?", accountID).
 		Count(&totalMessages)
 	stats["total_messages"] = totalMessages
 	
 	// 今日发言数
-	today := time.Now().Format("2006-01-02")
+	todayStart, tomorrowStart := todayRange()
 	var todayMessages int64
-	database.DB.Model(&models.Message{}).
-		Where("account_id = ? AND DATE(created_at) = ?", accountID, today).
+	sentMessages().
+		Where("account_id = ? AND created_at >= ? AND created_at < ?", accountID, todayStart, tomorrowStart).
 		Count(&todayMessages)
 	stats["today_messages"] = todayMessages
 	
 	// 活跃群组数
 	var activeGroups int64
-	database.DB.Model(&models.Message{}).
+	sentMessages().
 		Where("account_id = ?", accountID).
 		Distinct("group_id").
 		Count(&activeGroups)
@@ -129,10 +153,10 @@ func GetAccountStatistics(c *gin.Context) {
 	}
 	
 	sevenDaysAgo := time.Now().AddDate(0, 0, -7)
-	database.DB.Model(&models.Message{}).
-		Select("DATE(created_at) as date, COUNT(*) as count").
+	sentMessages().
+		Select(database.DateExpr("created_at") + " as date, COUNT(*) as count").
 		Where("account_id = ? AND created_at >= ?", accountID, sevenDaysAgo).
-		Group("DATE(created_at)").
+		Group(database.DateExpr("created_at")).
 		Order("date ASC").
 		Scan(&dailyStats)
 	stats["daily_trend"] = dailyStats
@@ -148,27 +172,41 @@ func GetGroupStatistics(c *gin.Context) {
 	
 	// 发言总数
 	var totalMessages int64
-	database.DB.Model(&models.Message{}).
+	sentMessages().
 		Where("group_id = ?", groupID).
 		Count(&totalMessages)
 	stats["total_messages"] = totalMessages
 	
 	// 今日发言数
-	today := time.Now().Format("2006-01-02")
+	todayStart, tomorrowStart := todayRange()
 	var todayMessages int64
-	database.DB.Model(&models.Message{}).
-		Where("group_id = ? AND DATE(created_at) = ?", groupID, today).
+	sentMessages().
+		Where("group_id = ? AND created_at >= ? AND created_at < ?", groupID, todayStart, tomorrowStart).
 		Count(&todayMessages)
 	stats["today_messages"] = todayMessages
 	
 	// 活跃账号数
 	var activeAccounts int64
-	database.DB.Model(&models.Message{}).
+	sentMessages().
 		Where("group_id = ?", groupID).
 		Distinct("account_id").
 		Count(&activeAccounts)
 	stats["active_accounts"] = activeAccounts
 	
+	// 群组规则命中次数
+	var ruleHits []struct {
+		RuleID   uint   `json:"rule_id"`
+		Name     string `json:"name"`
+		Action   string `json:"action"`
+		HitCount int64  `json:"hit_count"`
+	}
+	database.DB.Model(&models.ReplyRule{}).
+		Select("id as rule_id, name, action, hit_count").
+		Where("group_id = ?", groupID).
+		Order("hit_count DESC").
+		Scan(&ruleHits)
+	stats["rule_hits"] = ruleHits
+	
 	// 最近7天发言趋势
 	var dailyStats []struct {
 		Date  string `json:"date"`
@@ -176,10 +214,10 @@ func GetGroupStatistics(c *gin.Context) {
 	}
 	
 	sevenDaysAgo := time.Now().AddDate(0, 0, -7)
-	database.DB.Model(&models.Message{}).
-		Select("DATE(created_at) as date, COUNT(*) as count").
+	sentMessages().
+		Select(database.DateExpr("created_at") + " as date, COUNT(*) as count").
 		Where("group_id = ? AND created_at >= ?", groupID, sevenDaysAgo).
-		Group("DATE(created_at)").
+		Group(database.DateExpr("created_at")).
 		Order("date ASC").
 		Scan(&dailyStats)
 	stats["daily_trend"] = dailyStats
@@ -187,3 +225,14 @@ func GetGroupStatistics(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": stats})
 }
 
+// todayRange 今天（服务器本地时区）的起止时间，用于按时间范围统计（可以使用 created_at 索引，各数据库通用）
+func todayRange() (time.Time, time.Time) {
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+// sentMessages 发送成功的发言记录（不含发送失败的记录）
+func sentMessages() *gorm.DB {
+	return database.DB.Model(&models.Message{}).Where("messages.status = ?", "sent")
+}
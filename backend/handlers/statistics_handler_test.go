@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aibot/internal/config"
+	"aibot/internal/database"
+	"aibot/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupStatisticsDB 内存 SQLite 数据库：一个账号、一个群组，今天和三天前各有发言，另有一条发送失败的记录
+func setupStatisticsDB(t *testing.T) (models.Account, models.Group) {
+	t.Helper()
+	db, err := database.Init(config.DatabaseConfig{Driver: database.DriverSQLite, Path: ":memory:"})
+	if err != nil {
+		t.Fatalf("init database: %v", err)
+	}
+	t.Cleanup(func() { database.Close(db) })
+
+	account := models.Account{PhoneNumber: "+10000000001", Nickname: "tester", Status: "online", APIHash: "hash", SessionFile: "s", AIApiKey: "k"}
+	if err := db.Create(&account).Error; err != nil {
+		t.Fatalf("create account: %v", err)
+	}
+	group := models.Group{ChatID: 1001, Title: "测试群"}
+	if err := db.Create(&group).Error; err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+
+	now := time.Now()
+	messages := []models.Message{
+		{AccountID: account.ID, GroupID: group.ID, Content: "今天 1", Status: "sent", CreatedAt: now},
+		{AccountID: account.ID, GroupID: group.ID, Content: "今天 2", Status: "sent", CreatedAt: now},
+		{AccountID: account.ID, GroupID: group.ID, Content: "三天前", Status: "sent", CreatedAt: now.AddDate(0, 0, -3)},
+		{AccountID: account.ID, GroupID: group.ID, Content: "失败", Status: "failed", CreatedAt: now},
+	}
+	if err := db.Create(&messages).Error; err != nil {
+		t.Fatalf("create messages: %v", err)
+	}
+	return account, group
+}
+
+// getStatistics 调用统计接口并解析 data
+func getStatistics(t *testing.T, route, path string, handler gin.HandlerFunc) map[string]interface{} {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET(route, handler)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d: %s", path, w.Code, w.Body.String())
+	}
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return body.Data
+}
+
+// expectCount 校验计数字段
+func expectCount(t *testing.T, stats map[string]interface{}, field string, want int) {
+	t.Helper()
+	if got, _ := stats[field].(float64); int(got) != want {
+		t.Errorf("%s = %v, want %d", field, stats[field], want)
+	}
+}
+
+// expectTrend 校验最近7天趋势：两天有发言，日期为服务器本地日期
+func expectTrend(t *testing.T, stats map[string]interface{}) {
+	t.Helper()
+	trend, _ := stats["daily_trend"].([]interface{})
+	if len(trend) != 2 {
+		t.Fatalf("daily_trend = %v, want 2 days", stats["daily_trend"])
+	}
+	last, _ := trend[1].(map[string]interface{})
+	if today := time.Now().Format("2006-01-02"); last["date"] != today || last["count"] != float64(2) {
+		t.Errorf("daily_trend today = %v, want %s with 2 messages", last, today)
+	}
+}
+
+func TestGetStatistics(t *testing.T) {
+	setupStatisticsDB(t)
+	stats := getStatistics(t, "/statistics", "/statistics", GetStatistics)
+
+	expectCount(t, stats, "total_accounts", 1)
+	expectCount(t, stats, "online_accounts", 1)
+	expectCount(t, stats, "total_groups", 1)
+	expectCount(t, stats, "today_messages", 2)
+	expectCount(t, stats, "total_messages", 3)
+	expectCount(t, stats, "failed_messages", 1)
+	expectTrend(t, stats)
+
+	ranking, _ := stats["account_ranking"].([]interface{})
+	if len(ranking) != 1 || ranking[0].(map[string]interface{})["nickname"] != "tester" {
+		t.Errorf("account_ranking = %v", stats["account_ranking"])
+	}
+	groups, _ := stats["group_ranking"].([]interface{})
+	if len(groups) != 1 || groups[0].(map[string]interface{})["title"] != "测试群" {
+		t.Errorf("group_ranking = %v", stats["group_ranking"])
+	}
+}
+
+func TestGetAccountStatistics(t *testing.T) {
+	account, _ := setupStatisticsDB(t)
+	stats := getStatistics(t, "/accounts/:id/statistics", fmt.Sprintf("/accounts/%d/statistics", account.ID), GetAccountStatistics)
+
+	expectCount(t, stats, "total_messages", 3)
+	expectCount(t, stats, "today_messages", 2)
+	expectCount(t, stats, "active_groups", 1)
+	expectTrend(t, stats)
+}
+
+func TestGetGroupStatistics(t *testing.T) {
+	_, group := setupStatisticsDB(t)
+	stats := getStatistics(t, "/groups/:id/statistics", fmt.Sprintf("/groups/%d/statistics", group.ID), GetGroupStatistics)
+
+	expectCount(t, stats, "total_messages", 3)
+	expectCount(t, stats, "today_messages", 2)
+	expectCount(t, stats, "active_accounts", 1)
+	expectTrend(t, stats)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+
+	"aibot/internal/database"
+	"aibot/internal/telegram"
+	"aibot/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetGroupWelcome 获取群组的欢迎语配置
+func GetGroupWelcome(c *gin.Context) {
+	var welcome models.GroupWelcome
+	if err := database.DB.Where("group_id = ?", c.Param("id")).First(&welcome).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "该群组未配置欢迎语"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": welcome})
+}
+
+// UpdateGroupWelcome 创建或更新群组的欢迎语配置（未提交的字段保持不变）
+func UpdateGroupWelcome(c *gin.Context) {
+	var group models.Group
+	if err := database.DB.First(&group, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "群组不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+
+	welcome := models.GroupWelcome{GroupID: group.ID, Enabled: true, BatchSeconds: 10}
+	err := database.DB.Where("group_id = ?", group.ID).First(&welcome).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败: " + err.Error()})
+		return
+	}
+	created := err == gorm.ErrRecordNotFound
+
+	updated := welcome
+	if err := c.ShouldBindJSON(&updated); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误: " + err.Error()})
+		return
+	}
+	if err := telegram.ValidateWelcome(&updated); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "配置无效: " + err.Error()})
+		return
+	}
+
+	// 系统维护的字段不允许修改
+	updated.ID = welcome.ID
+	updated.GroupID = group.ID
+	updated.LastSentAt = welcome.LastSentAt
+	updated.CreatedAt = welcome.CreatedAt
+
+	if created {
+		if err := database.DB.Create(&updated).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败: " + err.Error()})
+			return
+		}
+		// enabled 有默认值，创建时为 false 会被数据库默认值覆盖
+		if !updated.Enabled {
+			database.DB.Model(&updated).Update("enabled", false)
+		}
+	} else if err := database.DB.Save(&updated).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "欢迎语已保存",
+		"data":    updated,
+	})
+}
+
+// DeleteGroupWelcome 删除群组的欢迎语配置
+func DeleteGroupWelcome(c *gin.Context) {
+	if err := database.DB.Where("group_id = ?", c.Param("id")).Delete(&models.GroupWelcome{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "欢迎语已删除"})
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
 )
@@ -16,17 +17,22 @@ type Config struct {
 }
 
 type ServerConfig struct {
-	Port string
-	Host string
+	Port       string
+	Host       string
+	InstanceID string // 实例标识（多实例部署时区分账号由哪个实例运行）
 }
 
 type DatabaseConfig struct {
+	Driver   string // postgres/sqlite
 	Host     string
 	Port     string
 	User     string
 	Password string
 	Name     string
 	SSLMode  string
+	Path     string // sqlite 数据库文件路径，:memory: 表示内存数据库
+
+	AutoMigrate bool // 启动时自动执行待执行的迁移，否则数据库结构不是最新时拒绝启动
 }
 
 type TelegramConfig struct {
@@ -44,6 +50,8 @@ type RedisConfig struct {
 	Port     string
 	Password string
 	DB       int
+	Enabled  bool   // 启用后运行状态和账号租约保存在 Redis 中，支持多实例部署
+	Prefix   string // 键前缀
 }
 
 type JWTConfig struct {
@@ -59,16 +67,21 @@ type LogConfig struct {
 func Load() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
-			Host: getEnv("SERVER_HOST", "0.0.0.0"),
+			Port:       getEnv("SERVER_PORT", "8080"),
+			Host:       getEnv("SERVER_HOST", "0.0.0.0"),
+			InstanceID: getEnv("INSTANCE_ID", defaultInstanceID()),
 		},
 		Database: DatabaseConfig{
+			Driver:   getEnv("DB_DRIVER", "postgres"),
 			Host:     getEnv("DB_HOST", "localhost"),
 			Port:     getEnv("DB_PORT", "5432"),
 			User:     getEnv("DB_USER", "postgres"),
 			Password: getEnv("DB_PASSWORD", "postgres"),
 			Name:     getEnv("DB_NAME", "aibot"),
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+			Path:     getEnv("DB_PATH", "data/aibot.db"),
+
+			AutoMigrate: getEnvAsBool("DB_AUTO_MIGRATE", false),
 		},
 		Telegram: TelegramConfig{
 			APIID:   getEnvAsInt("TELEGRAM_API_ID", 0),
@@ -83,6 +96,8 @@ func Load() *Config {
 			Port:     getEnv("REDIS_PORT", "6379"),
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       getEnvAsInt("REDIS_DB", 0),
+			Enabled:  getEnvAsBool("REDIS_ENABLED", false),
+			Prefix:   getEnv("REDIS_PREFIX", "aibot:"),
 		},
 		JWT: JWTConfig{
 			Secret: getEnv("JWT_SECRET", "your-secret-key"),
@@ -114,3 +129,23 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return value
 }
 
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// defaultInstanceID 默认实例标识：主机名-进程号
+func defaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
@@ -3,10 +3,14 @@ package database
 import (
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"aibot/internal/config"
 	"aibot/models"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -14,38 +18,143 @@ import (
 
 var DB *gorm.DB
 
+// Models 数据库中的全部模型（基线迁移的表结构与其 AutoMigrate 的结果一致）
+var Models = []interface{}{
+	&models.Account{},
+	&models.Group{},
+	&models.AccountGroup{},
+	&models.GroupMembership{},
+	&models.Message{},
+	&models.GlobalMainPrompt{},
+	&models.AccountPromptConfig{},
+	&models.AuthSession{},
+	&models.ReplyRule{},
+	&models.ApprovalItem{},
+	&models.InboundMessage{},
+	&models.UpdateState{},
+	&models.ChannelUpdateState{},
+	&models.OutboundMessage{},
+	&models.Schedule{},
+	&models.ScheduleRun{},
+	&models.ModerationRule{},
+	&models.ModerationAction{},
+	&models.GroupWelcome{},
+	&models.Poll{},
+	&models.PollOption{},
+	&models.GroupCommand{},
+	&models.FAQEntry{},
+	&models.CallbackAction{},
+	&models.CallbackClick{},
+	&models.GroupCaptcha{},
+	&models.CaptchaChallenge{},
+}
+
+// 支持的数据库驱动
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// sqliteMemory 内存数据库路径（本地开发和测试使用，进程退出后数据丢失）
+const sqliteMemory = ":memory:"
+
+// Init 连接数据库并检查表结构：有待执行的迁移时，开启自动迁移则执行，否则拒绝启动
 func Init(cfg config.DatabaseConfig) (*gorm.DB, error) {
-	dsn := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Name, cfg.SSLMode,
-	)
+	db, err := Open(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+	// 内存数据库每次启动都是空库，总是自动迁移
+	if cfg.AutoMigrate || (db.Dialector.Name() == DriverSQLite && isMemoryPath(cfg.Path)) {
+		if _, err := MigrateUp(db); err != nil {
+			return nil, fmt.Errorf("数据库迁移失败: %w", err)
+		}
+		log.Println("✅ 数据库迁移完成")
+		return db, nil
+	}
+
+	pending, err := PendingMigrations(db)
+	if err != nil {
+		return nil, fmt.Errorf("检查数据库迁移失败: %w", err)
+	}
+	if len(pending) > 0 {
+		names := make([]string, 0, len(pending))
+		for _, migration := range pending {
+			names = append(names, fmt.Sprintf("%04d_%s", migration.Version, migration.Name))
+		}
+		return nil, fmt.Errorf("数据库结构不是最新，有 %d 个待执行的迁移（%s），请先运行 `go run main.go migrate up`，或设置 DB_AUTO_MIGRATE=true",
+			len(pending), strings.Join(names, ", "))
+	}
+
+	return db, nil
+}
+
+// Open 只连接数据库，不检查表结构（migrate 命令使用）
+func Open(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	dialector, err := openDialector(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("连接数据库失败: %w", err)
 	}
 
+	if dialector.Name() == DriverSQLite {
+		// SQLite 同一时间只允许一个写入；内存数据库每个连接是独立的库，只能使用一个连接
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, fmt.Errorf("获取数据库连接失败: %w", err)
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
 	DB = db
-	log.Println("✅ 数据库连接成功")
+	log.Printf("✅ 数据库连接成功 [%s]", dialector.Name())
+
+	return db, nil
+}
 
-	// 自动迁移
-	if err := db.AutoMigrate(
-		&models.Account{},
-		&models.Group{},
-		&models.AccountGroup{},
-		&models.Message{},
-		&models.GlobalMainPrompt{},
-		&models.AccountPromptConfig{},
-		&models.AuthSession{},
-	); err != nil {
-		return nil, fmt.Errorf("数据库迁移失败: %w", err)
+// openDialector 根据配置的驱动构造数据库连接
+func openDialector(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case "", DriverPostgres:
+		dsn := fmt.Sprintf(
+			"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+			cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Name, cfg.SSLMode,
+		)
+		return postgres.Open(dsn), nil
+
+	case DriverSQLite:
+		path := cfg.Path
+		if isMemoryPath(path) {
+			return sqlite.Open(sqliteMemory), nil
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("创建数据库目录失败: %w", err)
+		}
+		// 等待其他进程的写锁，避免 database is locked
+		return sqlite.Open(path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"), nil
 	}
+	return nil, fmt.Errorf("不支持的数据库驱动: %s（可选 postgres/sqlite）", cfg.Driver)
+}
 
-	log.Println("✅ 数据库迁移完成")
+// isMemoryPath 判断 SQLite 路径是否为内存数据库
+func isMemoryPath(path string) bool {
+	return path == "" || path == sqliteMemory
+}
 
-	return db, nil
+// DateExpr 按日期取值的 SQL 表达式（按服务器本地时区取日期）
+func DateExpr(column string) string {
+	if DB != nil && DB.Dialector.Name() == DriverSQLite {
+		// SQLite 的时间以带时区的文本保存，date() 默认换算为 UTC
+		return fmt.Sprintf("DATE(%s, 'localtime')", column)
+	}
+	return fmt.Sprintf("DATE(%s)", column)
 }
 
 func Close(db *gorm.DB) {
@@ -0,0 +1,322 @@
+package database
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"aibot/models"
+
+	"gorm.io/gorm"
+)
+
+// migrationFiles 版本化迁移脚本，按驱动分目录：migrations/{driver}/{版本}_{名称}.{up|down}.sql
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// baselineVersion 基线迁移版本（与引入迁移前 AutoMigrate 创建的表结构一致）
+const baselineVersion = 1
+
+// Migration 一个版本的迁移脚本
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus 迁移的执行状态
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time // 未执行时为 nil
+}
+
+// SchemaMigration 已执行的迁移记录
+type SchemaMigration struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"not null"`
+	AppliedAt time.Time
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// loadMigrations 读取当前驱动的全部迁移，按版本升序
+func loadMigrations(driver string) ([]Migration, error) {
+	dir := path.Join("migrations", driver)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("没有 %s 的迁移脚本: %w", driver, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("迁移文件名无效: %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration := byVersion[version]
+		if migration == nil {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("迁移版本 %d 重复: %s/%s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("迁移 %04d_%s 缺少 up 或 down 脚本", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// prepareMigrationTable 创建迁移记录表（只在 migrate up 时执行）
+// 引入版本化迁移前由 AutoMigrate 创建的数据库：按基线脚本补齐缺少的表、列和索引，再记为已执行基线
+func prepareMigrationTable(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if migrator.HasTable(&SchemaMigration{}) {
+		return nil
+	}
+	legacy := migrator.HasTable(&models.Account{})
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Migrator().CreateTable(&SchemaMigration{}); err != nil {
+			return fmt.Errorf("创建迁移记录表失败: %w", err)
+		}
+		if !legacy {
+			return nil
+		}
+
+		migrations, err := loadMigrations(db.Dialector.Name())
+		if err != nil {
+			return err
+		}
+		if len(migrations) == 0 || migrations[0].Version != baselineVersion {
+			return fmt.Errorf("缺少基线迁移 %04d", baselineVersion)
+		}
+		baseline := migrations[0]
+
+		log.Println("📦 检测到由 AutoMigrate 创建的数据库，按基线脚本补齐表结构")
+		if err := applyLegacyBaseline(tx, baseline.Up); err != nil {
+			return fmt.Errorf("补齐基线结构失败: %w", err)
+		}
+		return tx.Create(&SchemaMigration{
+			Version:   baseline.Version,
+			Name:      baseline.Name,
+			AppliedAt: time.Now(),
+		}).Error
+	})
+}
+
+// 基线脚本中的建表和建索引语句
+var (
+	createTableStatement = regexp.MustCompile("^CREATE TABLE [`\"](\\w+)[`\"] \\(\n((?s).*)\n\\);?$")
+	createIndexStatement = regexp.MustCompile(`^CREATE (UNIQUE )?INDEX (IF NOT EXISTS )?`)
+	columnDefinition     = regexp.MustCompile("^[`\"](\\w+)[`\"] ")
+)
+
+// applyLegacyBaseline 在已有的数据库上执行基线脚本：表不存在时建表，已存在时只添加缺少的列，索引已存在时跳过
+func applyLegacyBaseline(tx *gorm.DB, script string) error {
+	migrator := tx.Migrator()
+	for _, statement := range scriptStatements(script) {
+		if match := createTableStatement.FindStringSubmatch(statement); match != nil && migrator.HasTable(match[1]) {
+			table := match[1]
+			for _, line := range strings.Split(match[2], "\n") {
+				line = strings.TrimSuffix(strings.TrimSpace(line), ",")
+				column := columnDefinition.FindStringSubmatch(line)
+				if column == nil || migrator.HasColumn(table, column[1]) {
+					continue // 主键、外键约束或已有的列
+				}
+				if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", tx.Statement.Quote(table), line)).Error; err != nil {
+					return fmt.Errorf("添加列 %s.%s 失败: %w", table, column[1], err)
+				}
+				log.Printf("📦 已添加列 %s.%s", table, column[1])
+			}
+			continue
+		}
+
+		if match := createIndexStatement.FindStringSubmatch(statement); match != nil && match[2] == "" {
+			statement = createIndexStatement.ReplaceAllString(statement, "CREATE ${1}INDEX IF NOT EXISTS ")
+		}
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appliedMigrations 已执行的迁移，按版本索引（还没有迁移记录表时视为都未执行，不创建表）
+func appliedMigrations(db *gorm.DB) (map[int]SchemaMigration, error) {
+	applied := make(map[int]SchemaMigration)
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return applied, nil
+	}
+
+	var records []SchemaMigration
+	if err := db.Order("version").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询迁移记录失败: %w", err)
+	}
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// MigrationStatuses 全部迁移及其执行状态
+func MigrationStatuses(db *gorm.DB) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// PendingMigrations 尚未执行的迁移
+func PendingMigrations(db *gorm.DB) ([]Migration, error) {
+	migrations, err := loadMigrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// MigrateUp 按版本顺序执行全部待执行的迁移，返回执行的数量
+func MigrateUp(db *gorm.DB) (int, error) {
+	if err := prepareMigrationTable(db); err != nil {
+		return 0, err
+	}
+	pending, err := PendingMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, migration := range pending {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := execScript(tx, migration.Up); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return i, fmt.Errorf("执行迁移 %04d_%s 失败: %w", migration.Version, migration.Name, err)
+		}
+		log.Printf("⬆️ 已执行迁移 %04d_%s", migration.Version, migration.Name)
+	}
+	return len(pending), nil
+}
+
+// MigrateDown 按版本倒序回滚最近执行的 steps 个迁移，返回回滚的数量
+func MigrateDown(db *gorm.DB, steps int) (int, error) {
+	migrations, err := loadMigrations(db.Dialector.Name())
+	if err != nil {
+		return 0, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+
+	rolledBack := 0
+	for i := len(migrations) - 1; i >= 0 && rolledBack < steps; i-- {
+		migration := migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := execScript(tx, migration.Down); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, migration.Version).Error
+		})
+		if err != nil {
+			return rolledBack, fmt.Errorf("回滚迁移 %04d_%s 失败: %w", migration.Version, migration.Name, err)
+		}
+		log.Printf("⬇️ 已回滚迁移 %04d_%s", migration.Version, migration.Name)
+		rolledBack++
+	}
+	return rolledBack, nil
+}
+
+// execScript 逐条执行迁移脚本
+func execScript(tx *gorm.DB, script string) error {
+	for _, statement := range scriptStatements(script) {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scriptStatements 拆分迁移脚本（语句以行尾的分号结束），去掉注释行和空语句
+func scriptStatements(script string) []string {
+	var statements []string
+	for _, statement := range strings.SplitAfter(script, ";\n") {
+		var lines []string
+		for _, line := range strings.Split(statement, "\n") {
+			if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) > 0 {
+			statements = append(statements, strings.Join(lines, "\n"))
+		}
+	}
+	return statements
+}
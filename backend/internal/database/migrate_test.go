@@ -0,0 +1,218 @@
+package database
+
+import (
+	"testing"
+
+	"aibot/internal/config"
+	"aibot/models"
+
+	"gorm.io/gorm"
+)
+
+// openMemory 只连接内存 SQLite 数据库，不执行迁移
+func openMemory(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := Open(config.DatabaseConfig{Driver: DriverSQLite, Path: ":memory:"})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { Close(db) })
+	return db
+}
+
+// appliedCount 已执行的迁移数量
+func appliedCount(t *testing.T, db *gorm.DB) int {
+	t.Helper()
+	statuses, err := MigrationStatuses(db)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	count := 0
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			count++
+		}
+	}
+	return count
+}
+
+func TestMigrateUpDownStatus(t *testing.T) {
+	db := openMemory(t)
+
+	migrations, err := loadMigrations(DriverSQLite)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if len(migrations) == 0 || migrations[0].Version != baselineVersion {
+		t.Fatalf("first migration should be the baseline, got %+v", migrations)
+	}
+
+	if n := appliedCount(t, db); n != 0 {
+		t.Fatalf("applied before up = %d, want 0", n)
+	}
+
+	applied, err := MigrateUp(db)
+	if err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	if applied != len(migrations) {
+		t.Fatalf("migrate up applied %d, want %d", applied, len(migrations))
+	}
+	for _, model := range Models {
+		if !db.Migrator().HasTable(model) {
+			t.Errorf("table for %T missing after up", model)
+		}
+	}
+	if n := appliedCount(t, db); n != len(migrations) {
+		t.Fatalf("applied after up = %d, want %d", n, len(migrations))
+	}
+	pending, err := PendingMigrations(db)
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("pending after up = %v, %v", pending, err)
+	}
+
+	// 再次执行没有待执行的迁移
+	if applied, err := MigrateUp(db); err != nil || applied != 0 {
+		t.Fatalf("second up = %d, %v", applied, err)
+	}
+
+	rolledBack, err := MigrateDown(db, len(migrations))
+	if err != nil {
+		t.Fatalf("migrate down: %v", err)
+	}
+	if rolledBack != len(migrations) {
+		t.Fatalf("migrate down rolled back %d, want %d", rolledBack, len(migrations))
+	}
+	if db.Migrator().HasTable(&models.Account{}) {
+		t.Error("accounts table still exists after down")
+	}
+	if n := appliedCount(t, db); n != 0 {
+		t.Fatalf("applied after down = %d, want 0", n)
+	}
+
+	// 回滚后可以重新执行
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatalf("migrate up after down: %v", err)
+	}
+}
+
+// 基线迁移创建的表结构与模型一致：AutoMigrate 不应再有任何变更
+func TestBaselineMatchesModels(t *testing.T) {
+	db := openMemory(t)
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	for _, model := range Models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatalf("parse %T: %v", model, err)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !db.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("%s.%s missing from baseline", stmt.Schema.Table, field.DBName)
+			}
+		}
+	}
+}
+
+func TestStatusHasNoSideEffects(t *testing.T) {
+	db := openMemory(t)
+
+	if _, err := MigrationStatuses(db); err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if _, err := PendingMigrations(db); err != nil {
+		t.Fatalf("pending: %v", err)
+	}
+	if _, err := MigrateDown(db, 1); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if db.Migrator().HasTable(&SchemaMigration{}) {
+		t.Fatal("read-only commands created the migration table")
+	}
+}
+
+// 引入迁移前由 AutoMigrate 创建的旧数据库：缺少后来加入的表、列和索引，migrate up 按基线脚本补齐
+func TestMigrateUpLegacyDatabase(t *testing.T) {
+	db := openMemory(t)
+
+	migrations, err := loadMigrations(DriverSQLite)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if err := execScript(db, migrations[0].Up); err != nil {
+		t.Fatalf("create legacy schema: %v", err)
+	}
+	for _, statement := range []string{
+		"DROP INDEX `idx_ai_accounts_type`",
+		"ALTER TABLE `ai_accounts` DROP COLUMN `reply_to_mentions`",
+		"ALTER TABLE `ai_accounts` DROP COLUMN `mention_reply_limit`",
+		"DROP TABLE `reply_rules`",
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+	legacy := models.Account{PhoneNumber: "+10000000002", Nickname: "legacy", APIHash: "hash", SessionFile: "s", AIApiKey: "k"}
+	if err := db.Omit("reply_to_mentions", "mention_reply_limit").Create(&legacy).Error; err != nil {
+		t.Fatalf("insert legacy row: %v", err)
+	}
+
+	// 启动检查提示需要先执行迁移，且不修改数据库
+	pending, err := PendingMigrations(db)
+	if err != nil || len(pending) == 0 || pending[0].Version != baselineVersion {
+		t.Fatalf("pending on legacy database = %v, %v", pending, err)
+	}
+	if db.Migrator().HasTable(&SchemaMigration{}) {
+		t.Fatal("pending check created the migration table")
+	}
+
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	if n := appliedCount(t, db); n != len(migrations) {
+		t.Fatalf("applied after up = %d, want %d", n, len(migrations))
+	}
+	for _, column := range []string{"reply_to_mentions", "mention_reply_limit"} {
+		if !db.Migrator().HasColumn(&models.Account{}, column) {
+			t.Errorf("column ai_accounts.%s not added", column)
+		}
+	}
+	if !db.Migrator().HasTable(&models.ReplyRule{}) {
+		t.Error("missing table not created")
+	}
+	if !db.Migrator().HasIndex(&models.Account{}, "idx_ai_accounts_type") {
+		t.Error("missing index not created")
+	}
+
+	var account models.Account
+	if err := db.Where("phone_number = ?", legacy.PhoneNumber).First(&account).Error; err != nil || account.Nickname != "legacy" {
+		t.Fatalf("legacy row lost: %+v, %v", account, err)
+	}
+	if account.MentionReplyLimit != 10 || !account.ReplyToMentions {
+		t.Errorf("added columns should use baseline defaults, got %d/%v", account.MentionReplyLimit, account.ReplyToMentions)
+	}
+}
+
+// 旧数据库补齐基线时需要识别两个驱动基线脚本中的每条建表语句
+func TestBaselineStatementsRecognized(t *testing.T) {
+	for _, driver := range []string{DriverPostgres, DriverSQLite} {
+		migrations, err := loadMigrations(driver)
+		if err != nil {
+			t.Fatalf("load %s migrations: %v", driver, err)
+		}
+		tables := 0
+		for _, statement := range scriptStatements(migrations[0].Up) {
+			switch {
+			case createTableStatement.MatchString(statement):
+				tables++
+			case createIndexStatement.MatchString(statement):
+			default:
+				t.Errorf("%s: unrecognized baseline statement: %s", driver, statement)
+			}
+		}
+		if tables != len(Models) {
+			t.Errorf("%s: %d tables in baseline, want %d", driver, tables, len(Models))
+		}
+	}
+}
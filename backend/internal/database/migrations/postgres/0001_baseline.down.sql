@@ -0,0 +1,29 @@
+-- 删除基线创建的全部表（按依赖关系倒序）
+
+DROP TABLE IF EXISTS "captcha_challenges";
+DROP TABLE IF EXISTS "group_captchas";
+DROP TABLE IF EXISTS "callback_clicks";
+DROP TABLE IF EXISTS "callback_actions";
+DROP TABLE IF EXISTS "faq_entries";
+DROP TABLE IF EXISTS "group_commands";
+DROP TABLE IF EXISTS "poll_options";
+DROP TABLE IF EXISTS "polls";
+DROP TABLE IF EXISTS "group_welcomes";
+DROP TABLE IF EXISTS "moderation_actions";
+DROP TABLE IF EXISTS "moderation_rules";
+DROP TABLE IF EXISTS "schedule_runs";
+DROP TABLE IF EXISTS "schedules";
+DROP TABLE IF EXISTS "outbound_queue";
+DROP TABLE IF EXISTS "tg_channel_states";
+DROP TABLE IF EXISTS "tg_update_states";
+DROP TABLE IF EXISTS "inbound_messages";
+DROP TABLE IF EXISTS "approval_queue";
+DROP TABLE IF EXISTS "reply_rules";
+DROP TABLE IF EXISTS "auth_sessions";
+DROP TABLE IF EXISTS "account_prompt_configs";
+DROP TABLE IF EXISTS "global_main_prompts";
+DROP TABLE IF EXISTS "messages";
+DROP TABLE IF EXISTS "group_memberships";
+DROP TABLE IF EXISTS "account_groups";
+DROP TABLE IF EXISTS "groups";
+DROP TABLE IF EXISTS "ai_accounts";
@@ -0,0 +1,615 @@
+-- 基线：与引入版本化迁移前 AutoMigrate 创建的表结构一致
+
+CREATE TABLE "ai_accounts" (
+    "id" bigserial,
+    "type" text DEFAULT 'user',
+    "phone_number" text NOT NULL,
+    "bot_token" text,
+    "api_id" bigint NOT NULL,
+    "api_hash" text NOT NULL,
+    "session_file" text NOT NULL,
+    "nickname" text,
+    "status" text DEFAULT 'offline',
+    "priority" bigint DEFAULT 5,
+    "ai_api_key" text NOT NULL,
+    "ai_model" text DEFAULT 'gpt-4o-mini',
+    "system_prompt" text,
+    "reply_interval" bigint DEFAULT 60,
+    "tone" text,
+    "enabled" boolean DEFAULT true,
+    "listen_interval" bigint DEFAULT 5,
+    "buffer_size" bigint DEFAULT 10,
+    "auto_reply" boolean DEFAULT true,
+    "reply_probability" bigint DEFAULT 100,
+    "multi_msg_interval" bigint DEFAULT 5,
+    "split_by_newline" boolean DEFAULT true,
+    "reply_to_mentions" boolean DEFAULT true,
+    "mention_reply_limit" bigint DEFAULT 10,
+    "bot_privacy_mode" boolean,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_ai_accounts_deleted_at" ON "ai_accounts" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_ai_accounts_phone_number" ON "ai_accounts" ("phone_number");
+CREATE INDEX IF NOT EXISTS "idx_ai_accounts_type" ON "ai_accounts" ("type");
+
+CREATE TABLE "groups" (
+    "id" bigserial,
+    "chat_id" bigint NOT NULL,
+    "access_hash" bigint,
+    "username" text,
+    "title" text,
+    "type" text,
+    "status" text DEFAULT 'active',
+    "language" text,
+    "member_count" bigint,
+    "description" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_groups_chat_id" ON "groups" ("chat_id");
+CREATE INDEX IF NOT EXISTS "idx_groups_deleted_at" ON "groups" ("deleted_at");
+
+CREATE TABLE "account_groups" (
+    "id" bigserial,
+    "account_id" bigint NOT NULL,
+    "group_id" bigint NOT NULL,
+    "priority" bigint DEFAULT 5,
+    "reply_probability" decimal DEFAULT 0.3,
+    "enabled" boolean DEFAULT true,
+    "disabled_reason" text,
+    "disabled_at" timestamptz,
+    "topics" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_account_groups_account" FOREIGN KEY ("account_id") REFERENCES "ai_accounts"("id"),
+    CONSTRAINT "fk_account_groups_group" FOREIGN KEY ("group_id") REFERENCES "groups"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_account_groups_account_id" ON "account_groups" ("account_id");
+CREATE INDEX IF NOT EXISTS "idx_account_groups_group_id" ON "account_groups" ("group_id");
+
+CREATE TABLE "group_memberships" (
+    "id" bigserial,
+    "account_id" bigint NOT NULL,
+    "group_id" bigint NOT NULL,
+    "status" text DEFAULT 'member',
+    "role" text DEFAULT 'member',
+    "can_send" boolean,
+    "last_synced_at" timestamptz,
+    "left_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_group_memberships_account" FOREIGN KEY ("account_id") REFERENCES "ai_accounts"("id"),
+    CONSTRAINT "fk_group_memberships_group" FOREIGN KEY ("group_id") REFERENCES "groups"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_group_memberships_group_id" ON "group_memberships" ("group_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_membership_account_group" ON "group_memberships" ("account_id","group_id");
+
+CREATE TABLE "messages" (
+    "id" bigserial,
+    "account_id" bigint NOT NULL,
+    "group_id" bigint NOT NULL,
+    "telegram_message_id" bigint,
+    "content" text NOT NULL,
+    "reply_to_message_id" bigint,
+    "topic_id" bigint,
+    "topic" text,
+    "sentiment" text,
+    "media_type" text,
+    "file_name" text,
+    "file_size" bigint,
+    "reply_group_id" text,
+    "part_index" bigint,
+    "part_count" bigint DEFAULT 1,
+    "status" text DEFAULT 'sent',
+    "error" text,
+    "schedule_run_id" bigint,
+    "poll_id" bigint,
+    "created_at" timestamptz,
+    "deleted_at" timestamptz,
+    "edit_history" text,
+    "edit_count" bigint DEFAULT 0,
+    "edited_at" timestamptz,
+    "removed_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_messages_account" FOREIGN KEY ("account_id") REFERENCES "ai_accounts"("id"),
+    CONSTRAINT "fk_messages_group" FOREIGN KEY ("group_id") REFERENCES "groups"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_messages_created_at" ON "messages" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_messages_poll_id" ON "messages" ("poll_id");
+CREATE INDEX IF NOT EXISTS "idx_messages_schedule_run_id" ON "messages" ("schedule_run_id");
+CREATE INDEX IF NOT EXISTS "idx_messages_reply_group_id" ON "messages" ("reply_group_id");
+CREATE INDEX IF NOT EXISTS "idx_messages_account_id" ON "messages" ("account_id");
+CREATE INDEX IF NOT EXISTS "idx_messages_removed_at" ON "messages" ("removed_at");
+CREATE INDEX IF NOT EXISTS "idx_messages_deleted_at" ON "messages" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_messages_status" ON "messages" ("status");
+CREATE INDEX IF NOT EXISTS "idx_messages_group_id" ON "messages" ("group_id");
+
+CREATE TABLE "global_main_prompts" (
+    "id" bigserial,
+    "version" bigint NOT NULL,
+    "content" text NOT NULL,
+    "description" varchar(500),
+    "enabled" boolean DEFAULT true,
+    "created_by" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_global_main_prompts_version" ON "global_main_prompts" ("version");
+
+CREATE TABLE "account_prompt_configs" (
+    "id" bigserial,
+    "account_id" bigint NOT NULL,
+    "use_global_main_prompt" boolean DEFAULT true,
+    "combine_mode" text DEFAULT 'framework',
+    "account_prompt" text,
+    "combined_prompt" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_account_prompt_configs_account" FOREIGN KEY ("account_id") REFERENCES "ai_accounts"("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_account_prompt_configs_account_id" ON "account_prompt_configs" ("account_id");
+
+CREATE TABLE "auth_sessions" (
+    "id" bigserial,
+    "account_id" bigint NOT NULL,
+    "phone_number" text NOT NULL,
+    "state" text NOT NULL,
+    "code_hash" text,
+    "password" text,
+    "expires_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_auth_sessions_account" FOREIGN KEY ("account_id") REFERENCES "ai_accounts"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_auth_sessions_deleted_at" ON "auth_sessions" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_auth_sessions_account_id" ON "auth_sessions" ("account_id");
+
+CREATE TABLE "reply_rules" (
+    "id" bigserial,
+    "group_id" bigint,
+    "name" text NOT NULL,
+    "priority" bigint DEFAULT 0,
+    "enabled" boolean DEFAULT true,
+    "match_type" text DEFAULT 'keyword',
+    "pattern" text NOT NULL,
+    "senders" text,
+    "message_types" text,
+    "active_from" text,
+    "active_to" text,
+    "action" text NOT NULL,
+    "instruction" text,
+    "template" text,
+    "cooldown_seconds" bigint DEFAULT 0,
+    "buttons" text,
+    "hit_count" bigint DEFAULT 0,
+    "last_hit_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_reply_rules_group_id" ON "reply_rules" ("group_id");
+
+CREATE TABLE "approval_queue" (
+    "id" bigserial,
+    "account_id" bigint NOT NULL,
+    "group_id" bigint NOT NULL,
+    "rule_id" bigint,
+    "trigger_message_id" bigint,
+    "trigger_topic_id" bigint,
+    "trigger_sender" text,
+    "trigger_content" text,
+    "draft_reply" text,
+    "status" text DEFAULT 'pending',
+    "error" text,
+    "reviewed_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_approval_queue_account" FOREIGN KEY ("account_id") REFERENCES "ai_accounts"("id"),
+    CONSTRAINT "fk_approval_queue_group" FOREIGN KEY ("group_id") REFERENCES "groups"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_approval_queue_status" ON "approval_queue" ("status");
+CREATE INDEX IF NOT EXISTS "idx_approval_queue_group_id" ON "approval_queue" ("group_id");
+CREATE INDEX IF NOT EXISTS "idx_approval_queue_account_id" ON "approval_queue" ("account_id");
+
+CREATE TABLE "inbound_messages" (
+    "id" bigserial,
+    "account_id" bigint NOT NULL,
+    "group_id" bigint NOT NULL,
+    "chat_id" bigint NOT NULL,
+    "message_id" bigint NOT NULL,
+    "sender_id" bigint,
+    "sender_name" text,
+    "sender_username" text,
+    "content" text,
+    "message_type" text,
+    "reply_to_msg_id" bigint,
+    "topic_id" bigint,
+    "trigger" text,
+    "source" text,
+    "sent_at" timestamptz,
+    "original_content" text,
+    "edit_count" bigint DEFAULT 0,
+    "edited_at" timestamptz,
+    "removed_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_inbound_messages_account" FOREIGN KEY ("account_id") REFERENCES "ai_accounts"("id"),
+    CONSTRAINT "fk_inbound_messages_group" FOREIGN KEY ("group_id") REFERENCES "groups"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_inbound_messages_sender_id" ON "inbound_messages" ("sender_id");
+CREATE INDEX IF NOT EXISTS "idx_inbound_messages_group_id" ON "inbound_messages" ("group_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_inbound_account_chat_msg" ON "inbound_messages" ("account_id","chat_id","message_id");
+CREATE INDEX IF NOT EXISTS "idx_inbound_messages_removed_at" ON "inbound_messages" ("removed_at");
+CREATE INDEX IF NOT EXISTS "idx_inbound_messages_sent_at" ON "inbound_messages" ("sent_at");
+CREATE INDEX IF NOT EXISTS "idx_inbound_messages_topic_id" ON "inbound_messages" ("topic_id");
+
+CREATE TABLE "tg_update_states" (
+    "user_id" bigint,
+    "account_id" bigint,
+    "pts" bigint,
+    "qts" bigint,
+    "date" bigint,
+    "seq" bigint,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("user_id")
+);
+CREATE INDEX IF NOT EXISTS "idx_tg_update_states_account_id" ON "tg_update_states" ("account_id");
+
+CREATE TABLE "tg_channel_states" (
+    "user_id" bigint,
+    "channel_id" bigint,
+    "pts" bigint,
+    "access_hash" bigint,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("user_id","channel_id")
+);
+
+CREATE TABLE "outbound_queue" (
+    "id" bigserial,
+    "account_id" bigint NOT NULL,
+    "group_id" bigint NOT NULL,
+    "idempotency_key" text,
+    "source" text,
+    "schedule_run_id" bigint,
+    "content" text,
+    "reply_to_msg_id" bigint,
+    "topic_id" bigint,
+    "media_type" text,
+    "file_name" text,
+    "mime_type" text,
+    "file_size" bigint,
+    "media_path" text,
+    "poll_id" bigint,
+    "buttons" text,
+    "reply_group_id" text,
+    "part_index" bigint,
+    "part_count" bigint DEFAULT 1,
+    "send_at" timestamptz,
+    "priority" bigint DEFAULT 0,
+    "status" text DEFAULT 'pending',
+    "attempts" bigint DEFAULT 0,
+    "max_attempts" bigint DEFAULT 3,
+    "last_error" text,
+    "random_id" bigint,
+    "telegram_message_id" bigint,
+    "message_id" bigint,
+    "sent_at" timestamptz,
+    "delete_after" bigint DEFAULT 0,
+    "delete_at" timestamptz,
+    "removed_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_outbound_queue_account" FOREIGN KEY ("account_id") REFERENCES "ai_accounts"("id"),
+    CONSTRAINT "fk_outbound_queue_group" FOREIGN KEY ("group_id") REFERENCES "groups"("id"),
+    CONSTRAINT "fk_schedule_runs_items" FOREIGN KEY ("schedule_run_id") REFERENCES "schedule_runs"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_outbound_queue_source" ON "outbound_queue" ("source");
+CREATE INDEX IF NOT EXISTS "idx_outbound_queue_group_id" ON "outbound_queue" ("group_id");
+CREATE INDEX IF NOT EXISTS "idx_outbound_queue_send_at" ON "outbound_queue" ("send_at");
+CREATE INDEX IF NOT EXISTS "idx_outbound_queue_reply_group_id" ON "outbound_queue" ("reply_group_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_outbound_queue_idempotency_key" ON "outbound_queue" ("idempotency_key");
+CREATE INDEX IF NOT EXISTS "idx_outbound_queue_account_id" ON "outbound_queue" ("account_id");
+CREATE INDEX IF NOT EXISTS "idx_outbound_queue_delete_at" ON "outbound_queue" ("delete_at");
+CREATE INDEX IF NOT EXISTS "idx_outbound_queue_status" ON "outbound_queue" ("status");
+CREATE INDEX IF NOT EXISTS "idx_outbound_queue_poll_id" ON "outbound_queue" ("poll_id");
+CREATE INDEX IF NOT EXISTS "idx_outbound_queue_schedule_run_id" ON "outbound_queue" ("schedule_run_id");
+
+CREATE TABLE "schedules" (
+    "id" bigserial,
+    "name" text NOT NULL,
+    "account_id" bigint NOT NULL,
+    "group_ids" text NOT NULL,
+    "cron_expr" text,
+    "run_at" timestamptz,
+    "timezone" text,
+    "content" text,
+    "media_type" text,
+    "file_name" text,
+    "mime_type" text,
+    "file_size" bigint,
+    "media_path" text,
+    "priority" bigint DEFAULT 0,
+    "status" text DEFAULT 'active',
+    "next_run_at" timestamptz,
+    "last_run_at" timestamptz,
+    "run_count" bigint DEFAULT 0,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_schedules_account" FOREIGN KEY ("account_id") REFERENCES "ai_accounts"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_schedules_next_run_at" ON "schedules" ("next_run_at");
+CREATE INDEX IF NOT EXISTS "idx_schedules_status" ON "schedules" ("status");
+CREATE INDEX IF NOT EXISTS "idx_schedules_account_id" ON "schedules" ("account_id");
+
+CREATE TABLE "schedule_runs" (
+    "id" bigserial,
+    "schedule_id" bigint NOT NULL,
+    "scheduled_at" timestamptz,
+    "status" text,
+    "group_count" bigint,
+    "error" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_schedule_runs_created_at" ON "schedule_runs" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_schedule_runs_schedule_id" ON "schedule_runs" ("schedule_id");
+
+CREATE TABLE "moderation_rules" (
+    "id" bigserial,
+    "group_id" bigint,
+    "name" text NOT NULL,
+    "priority" bigint DEFAULT 0,
+    "enabled" boolean DEFAULT true,
+    "type" text NOT NULL,
+    "match_type" text DEFAULT 'keyword',
+    "pattern" text,
+    "flood_count" bigint,
+    "flood_seconds" bigint,
+    "new_member_hours" bigint,
+    "exempt_senders" text,
+    "action" text NOT NULL,
+    "delete_message" boolean,
+    "duration_seconds" bigint DEFAULT 0,
+    "warn_template" text,
+    "hit_count" bigint DEFAULT 0,
+    "last_hit_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_moderation_rules_group_id" ON "moderation_rules" ("group_id");
+
+CREATE TABLE "moderation_actions" (
+    "id" bigserial,
+    "account_id" bigint NOT NULL,
+    "group_id" bigint NOT NULL,
+    "chat_id" bigint,
+    "rule_id" bigint,
+    "rule_name" text,
+    "action" text,
+    "reason" text,
+    "user_id" bigint,
+    "user_access_hash" bigint,
+    "sender_name" text,
+    "sender_username" text,
+    "message_id" bigint,
+    "content" text,
+    "message_deleted" boolean,
+    "message_sent_at" timestamptz,
+    "status" text DEFAULT 'done',
+    "error" text,
+    "until_date" timestamptz,
+    "review_status" text,
+    "review_note" text,
+    "reviewed_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_moderation_actions_account" FOREIGN KEY ("account_id") REFERENCES "ai_accounts"("id"),
+    CONSTRAINT "fk_moderation_actions_group" FOREIGN KEY ("group_id") REFERENCES "groups"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_moderation_actions_action" ON "moderation_actions" ("action");
+CREATE INDEX IF NOT EXISTS "idx_moderation_actions_rule_id" ON "moderation_actions" ("rule_id");
+CREATE INDEX IF NOT EXISTS "idx_moderation_actions_group_id" ON "moderation_actions" ("group_id");
+CREATE INDEX IF NOT EXISTS "idx_moderation_actions_account_id" ON "moderation_actions" ("account_id");
+CREATE INDEX IF NOT EXISTS "idx_moderation_actions_review_status" ON "moderation_actions" ("review_status");
+CREATE INDEX IF NOT EXISTS "idx_moderation_actions_status" ON "moderation_actions" ("status");
+CREATE INDEX IF NOT EXISTS "idx_moderation_actions_user_id" ON "moderation_actions" ("user_id");
+
+CREATE TABLE "group_welcomes" (
+    "id" bigserial,
+    "group_id" bigint NOT NULL,
+    "enabled" boolean DEFAULT true,
+    "template" text NOT NULL,
+    "rules_link" text,
+    "buttons" text,
+    "batch_seconds" bigint DEFAULT 10,
+    "cooldown_seconds" bigint DEFAULT 0,
+    "delete_after_minutes" bigint DEFAULT 0,
+    "last_sent_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_group_welcomes_group" FOREIGN KEY ("group_id") REFERENCES "groups"("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_group_welcomes_group_id" ON "group_welcomes" ("group_id");
+
+CREATE TABLE "polls" (
+    "id" bigserial,
+    "account_id" bigint NOT NULL,
+    "group_id" bigint NOT NULL,
+    "question" text NOT NULL,
+    "anonymous" boolean,
+    "multiple_choice" boolean,
+    "status" text DEFAULT 'pending',
+    "error" text,
+    "total_voters" bigint,
+    "results_at" timestamptz,
+    "closed_at" timestamptz,
+    "outbound_id" bigint,
+    "message_id" bigint,
+    "telegram_message_id" bigint,
+    "telegram_poll_id" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_polls_account" FOREIGN KEY ("account_id") REFERENCES "ai_accounts"("id"),
+    CONSTRAINT "fk_polls_group" FOREIGN KEY ("group_id") REFERENCES "groups"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_polls_telegram_poll_id" ON "polls" ("telegram_poll_id");
+CREATE INDEX IF NOT EXISTS "idx_polls_message_id" ON "polls" ("message_id");
+CREATE INDEX IF NOT EXISTS "idx_polls_outbound_id" ON "polls" ("outbound_id");
+CREATE INDEX IF NOT EXISTS "idx_polls_status" ON "polls" ("status");
+CREATE INDEX IF NOT EXISTS "idx_polls_group_id" ON "polls" ("group_id");
+CREATE INDEX IF NOT EXISTS "idx_polls_account_id" ON "polls" ("account_id");
+
+CREATE TABLE "poll_options" (
+    "id" bigserial,
+    "poll_id" bigint NOT NULL,
+    "position" bigint,
+    "text" text NOT NULL,
+    "voters" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_polls_options" FOREIGN KEY ("poll_id") REFERENCES "polls"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_poll_options_poll_id" ON "poll_options" ("poll_id");
+
+CREATE TABLE "group_commands" (
+    "id" bigserial,
+    "group_id" bigint,
+    "account_id" bigint,
+    "name" text NOT NULL,
+    "enabled" boolean DEFAULT true,
+    "description" text,
+    "usage" text,
+    "min_args" bigint,
+    "action" text NOT NULL,
+    "instruction" text,
+    "template" text,
+    "buttons" text,
+    "cooldown_seconds" bigint DEFAULT 0,
+    "hit_count" bigint DEFAULT 0,
+    "last_hit_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_group_commands_group_id" ON "group_commands" ("group_id");
+CREATE INDEX IF NOT EXISTS "idx_group_commands_name" ON "group_commands" ("name");
+CREATE INDEX IF NOT EXISTS "idx_group_commands_account_id" ON "group_commands" ("account_id");
+
+CREATE TABLE "faq_entries" (
+    "id" bigserial,
+    "group_id" bigint,
+    "topic" text NOT NULL,
+    "keywords" text,
+    "answer" text NOT NULL,
+    "enabled" boolean DEFAULT true,
+    "hit_count" bigint DEFAULT 0,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_faq_entries_group_id" ON "faq_entries" ("group_id");
+
+CREATE TABLE "callback_actions" (
+    "id" bigserial,
+    "group_id" bigint,
+    "account_id" bigint,
+    "data" text NOT NULL,
+    "enabled" boolean DEFAULT true,
+    "action" text NOT NULL,
+    "text" text,
+    "alert" boolean,
+    "instruction" text,
+    "buttons" text,
+    "hit_count" bigint DEFAULT 0,
+    "last_hit_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_callback_actions_data" ON "callback_actions" ("data");
+CREATE INDEX IF NOT EXISTS "idx_callback_actions_account_id" ON "callback_actions" ("account_id");
+CREATE INDEX IF NOT EXISTS "idx_callback_actions_group_id" ON "callback_actions" ("group_id");
+
+CREATE TABLE "callback_clicks" (
+    "id" bigserial,
+    "account_id" bigint NOT NULL,
+    "group_id" bigint,
+    "callback_action_id" bigint,
+    "telegram_message_id" bigint,
+    "user_id" bigint,
+    "user_name" text,
+    "data" text,
+    "status" text,
+    "error" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_callback_clicks_telegram_message_id" ON "callback_clicks" ("telegram_message_id");
+CREATE INDEX IF NOT EXISTS "idx_callback_clicks_callback_action_id" ON "callback_clicks" ("callback_action_id");
+CREATE INDEX IF NOT EXISTS "idx_callback_clicks_group_id" ON "callback_clicks" ("group_id");
+CREATE INDEX IF NOT EXISTS "idx_callback_clicks_account_id" ON "callback_clicks" ("account_id");
+CREATE INDEX IF NOT EXISTS "idx_callback_clicks_created_at" ON "callback_clicks" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_callback_clicks_data" ON "callback_clicks" ("data");
+CREATE INDEX IF NOT EXISTS "idx_callback_clicks_user_id" ON "callback_clicks" ("user_id");
+
+CREATE TABLE "group_captchas" (
+    "id" bigserial,
+    "group_id" bigint NOT NULL,
+    "enabled" boolean DEFAULT true,
+    "mode" text DEFAULT 'button',
+    "timeout_seconds" bigint DEFAULT 120,
+    "max_attempts" bigint DEFAULT 3,
+    "ban_minutes" bigint DEFAULT 0,
+    "template" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_group_captchas_group" FOREIGN KEY ("group_id") REFERENCES "groups"("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_group_captchas_group_id" ON "group_captchas" ("group_id");
+
+CREATE TABLE "captcha_challenges" (
+    "id" bigserial,
+    "account_id" bigint NOT NULL,
+    "group_id" bigint NOT NULL,
+    "chat_id" bigint,
+    "user_id" bigint,
+    "user_access_hash" bigint,
+    "user_name" text,
+    "mode" text,
+    "question" text,
+    "answer" text,
+    "attempts" bigint,
+    "outbound_id" bigint,
+    "status" text DEFAULT 'pending',
+    "error" text,
+    "expires_at" timestamptz,
+    "resolved_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_captcha_challenges_group" FOREIGN KEY ("group_id") REFERENCES "groups"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_captcha_challenges_account_id" ON "captcha_challenges" ("account_id");
+CREATE INDEX IF NOT EXISTS "idx_captcha_challenges_created_at" ON "captcha_challenges" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_captcha_challenges_expires_at" ON "captcha_challenges" ("expires_at");
+CREATE INDEX IF NOT EXISTS "idx_captcha_challenges_status" ON "captcha_challenges" ("status");
+CREATE INDEX IF NOT EXISTS "idx_captcha_challenges_user_id" ON "captcha_challenges" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_captcha_challenges_group_id" ON "captcha_challenges" ("group_id");
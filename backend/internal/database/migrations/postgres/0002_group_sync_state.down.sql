@@ -0,0 +1,2 @@
+ALTER TABLE "group_memberships" DROP COLUMN IF EXISTS "access_hash";
+ALTER TABLE "groups" DROP COLUMN IF EXISTS "full_info_at";
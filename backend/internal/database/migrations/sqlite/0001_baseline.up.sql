@@ -0,0 +1,590 @@
+-- 基线：与引入版本化迁移前 AutoMigrate 创建的表结构一致
+
+CREATE TABLE `ai_accounts` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `type` text DEFAULT "user",
+    `phone_number` text NOT NULL,
+    `bot_token` text,
+    `api_id` integer NOT NULL,
+    `api_hash` text NOT NULL,
+    `session_file` text NOT NULL,
+    `nickname` text,
+    `status` text DEFAULT "offline",
+    `priority` integer DEFAULT 5,
+    `ai_api_key` text NOT NULL,
+    `ai_model` text DEFAULT "gpt-4o-mini",
+    `system_prompt` text,
+    `reply_interval` integer DEFAULT 60,
+    `tone` text,
+    `enabled` numeric DEFAULT true,
+    `listen_interval` integer DEFAULT 5,
+    `buffer_size` integer DEFAULT 10,
+    `auto_reply` numeric DEFAULT true,
+    `reply_probability` integer DEFAULT 100,
+    `multi_msg_interval` integer DEFAULT 5,
+    `split_by_newline` numeric DEFAULT true,
+    `reply_to_mentions` numeric DEFAULT true,
+    `mention_reply_limit` integer DEFAULT 10,
+    `bot_privacy_mode` numeric,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime
+);
+CREATE INDEX `idx_ai_accounts_deleted_at` ON `ai_accounts`(`deleted_at`);
+CREATE UNIQUE INDEX `idx_ai_accounts_phone_number` ON `ai_accounts`(`phone_number`);
+CREATE INDEX `idx_ai_accounts_type` ON `ai_accounts`(`type`);
+
+CREATE TABLE `groups` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `chat_id` integer NOT NULL,
+    `access_hash` integer,
+    `username` text,
+    `title` text,
+    `type` text,
+    `status` text DEFAULT "active",
+    `language` text,
+    `member_count` integer,
+    `description` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime
+);
+CREATE INDEX `idx_groups_deleted_at` ON `groups`(`deleted_at`);
+CREATE UNIQUE INDEX `idx_groups_chat_id` ON `groups`(`chat_id`);
+
+CREATE TABLE `account_groups` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `account_id` integer NOT NULL,
+    `group_id` integer NOT NULL,
+    `priority` integer DEFAULT 5,
+    `reply_probability` real DEFAULT 0.3,
+    `enabled` numeric DEFAULT true,
+    `disabled_reason` text,
+    `disabled_at` datetime,
+    `topics` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_account_groups_account` FOREIGN KEY (`account_id`) REFERENCES `ai_accounts`(`id`),
+    CONSTRAINT `fk_account_groups_group` FOREIGN KEY (`group_id`) REFERENCES `groups`(`id`)
+);
+CREATE INDEX `idx_account_groups_group_id` ON `account_groups`(`group_id`);
+CREATE INDEX `idx_account_groups_account_id` ON `account_groups`(`account_id`);
+
+CREATE TABLE `group_memberships` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `account_id` integer NOT NULL,
+    `group_id` integer NOT NULL,
+    `status` text DEFAULT "member",
+    `role` text DEFAULT "member",
+    `can_send` numeric,
+    `last_synced_at` datetime,
+    `left_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_group_memberships_account` FOREIGN KEY (`account_id`) REFERENCES `ai_accounts`(`id`),
+    CONSTRAINT `fk_group_memberships_group` FOREIGN KEY (`group_id`) REFERENCES `groups`(`id`)
+);
+CREATE INDEX `idx_group_memberships_group_id` ON `group_memberships`(`group_id`);
+CREATE UNIQUE INDEX `idx_membership_account_group` ON `group_memberships`(`account_id`,`group_id`);
+
+CREATE TABLE `messages` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `account_id` integer NOT NULL,
+    `group_id` integer NOT NULL,
+    `telegram_message_id` integer,
+    `content` text NOT NULL,
+    `reply_to_message_id` integer,
+    `topic_id` integer,
+    `topic` text,
+    `sentiment` text,
+    `media_type` text,
+    `file_name` text,
+    `file_size` integer,
+    `reply_group_id` text,
+    `part_index` integer,
+    `part_count` integer DEFAULT 1,
+    `status` text DEFAULT "sent",
+    `error` text,
+    `schedule_run_id` integer,
+    `poll_id` integer,
+    `created_at` datetime,
+    `deleted_at` datetime,
+    `edit_history` text,
+    `edit_count` integer DEFAULT 0,
+    `edited_at` datetime,
+    `removed_at` datetime,
+    CONSTRAINT `fk_messages_account` FOREIGN KEY (`account_id`) REFERENCES `ai_accounts`(`id`),
+    CONSTRAINT `fk_messages_group` FOREIGN KEY (`group_id`) REFERENCES `groups`(`id`)
+);
+CREATE INDEX `idx_messages_reply_group_id` ON `messages`(`reply_group_id`);
+CREATE INDEX `idx_messages_group_id` ON `messages`(`group_id`);
+CREATE INDEX `idx_messages_account_id` ON `messages`(`account_id`);
+CREATE INDEX `idx_messages_deleted_at` ON `messages`(`deleted_at`);
+CREATE INDEX `idx_messages_created_at` ON `messages`(`created_at`);
+CREATE INDEX `idx_messages_poll_id` ON `messages`(`poll_id`);
+CREATE INDEX `idx_messages_status` ON `messages`(`status`);
+CREATE INDEX `idx_messages_removed_at` ON `messages`(`removed_at`);
+CREATE INDEX `idx_messages_schedule_run_id` ON `messages`(`schedule_run_id`);
+
+CREATE TABLE `global_main_prompts` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `version` integer NOT NULL,
+    `content` text NOT NULL,
+    `description` varchar(500),
+    `enabled` numeric DEFAULT true,
+    `created_by` integer,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX `idx_global_main_prompts_version` ON `global_main_prompts`(`version`);
+
+CREATE TABLE `account_prompt_configs` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `account_id` integer NOT NULL,
+    `use_global_main_prompt` numeric DEFAULT true,
+    `combine_mode` text DEFAULT "framework",
+    `account_prompt` text,
+    `combined_prompt` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_account_prompt_configs_account` FOREIGN KEY (`account_id`) REFERENCES `ai_accounts`(`id`)
+);
+CREATE UNIQUE INDEX `idx_account_prompt_configs_account_id` ON `account_prompt_configs`(`account_id`);
+
+CREATE TABLE `auth_sessions` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `account_id` integer NOT NULL,
+    `phone_number` text NOT NULL,
+    `state` text NOT NULL,
+    `code_hash` text,
+    `password` text,
+    `expires_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    CONSTRAINT `fk_auth_sessions_account` FOREIGN KEY (`account_id`) REFERENCES `ai_accounts`(`id`)
+);
+CREATE INDEX `idx_auth_sessions_deleted_at` ON `auth_sessions`(`deleted_at`);
+CREATE INDEX `idx_auth_sessions_account_id` ON `auth_sessions`(`account_id`);
+
+CREATE TABLE `reply_rules` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `group_id` integer,
+    `name` text NOT NULL,
+    `priority` integer DEFAULT 0,
+    `enabled` numeric DEFAULT true,
+    `match_type` text DEFAULT "keyword",
+    `pattern` text NOT NULL,
+    `senders` text,
+    `message_types` text,
+    `active_from` text,
+    `active_to` text,
+    `action` text NOT NULL,
+    `instruction` text,
+    `template` text,
+    `cooldown_seconds` integer DEFAULT 0,
+    `buttons` text,
+    `hit_count` integer DEFAULT 0,
+    `last_hit_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX `idx_reply_rules_group_id` ON `reply_rules`(`group_id`);
+
+CREATE TABLE `approval_queue` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `account_id` integer NOT NULL,
+    `group_id` integer NOT NULL,
+    `rule_id` integer,
+    `trigger_message_id` integer,
+    `trigger_topic_id` integer,
+    `trigger_sender` text,
+    `trigger_content` text,
+    `draft_reply` text,
+    `status` text DEFAULT "pending",
+    `error` text,
+    `reviewed_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_approval_queue_account` FOREIGN KEY (`account_id`) REFERENCES `ai_accounts`(`id`),
+    CONSTRAINT `fk_approval_queue_group` FOREIGN KEY (`group_id`) REFERENCES `groups`(`id`)
+);
+CREATE INDEX `idx_approval_queue_status` ON `approval_queue`(`status`);
+CREATE INDEX `idx_approval_queue_group_id` ON `approval_queue`(`group_id`);
+CREATE INDEX `idx_approval_queue_account_id` ON `approval_queue`(`account_id`);
+
+CREATE TABLE `inbound_messages` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `account_id` integer NOT NULL,
+    `group_id` integer NOT NULL,
+    `chat_id` integer NOT NULL,
+    `message_id` integer NOT NULL,
+    `sender_id` integer,
+    `sender_name` text,
+    `sender_username` text,
+    `content` text,
+    `message_type` text,
+    `reply_to_msg_id` integer,
+    `topic_id` integer,
+    `trigger` text,
+    `source` text,
+    `sent_at` datetime,
+    `original_content` text,
+    `edit_count` integer DEFAULT 0,
+    `edited_at` datetime,
+    `removed_at` datetime,
+    `created_at` datetime,
+    CONSTRAINT `fk_inbound_messages_account` FOREIGN KEY (`account_id`) REFERENCES `ai_accounts`(`id`),
+    CONSTRAINT `fk_inbound_messages_group` FOREIGN KEY (`group_id`) REFERENCES `groups`(`id`)
+);
+CREATE INDEX `idx_inbound_messages_group_id` ON `inbound_messages`(`group_id`);
+CREATE UNIQUE INDEX `idx_inbound_account_chat_msg` ON `inbound_messages`(`account_id`,`chat_id`,`message_id`);
+CREATE INDEX `idx_inbound_messages_removed_at` ON `inbound_messages`(`removed_at`);
+CREATE INDEX `idx_inbound_messages_sent_at` ON `inbound_messages`(`sent_at`);
+CREATE INDEX `idx_inbound_messages_topic_id` ON `inbound_messages`(`topic_id`);
+CREATE INDEX `idx_inbound_messages_sender_id` ON `inbound_messages`(`sender_id`);
+
+CREATE TABLE `tg_update_states` (
+    `user_id` integer,
+    `account_id` integer,
+    `pts` integer,
+    `qts` integer,
+    `date` integer,
+    `seq` integer,
+    `updated_at` datetime,
+    PRIMARY KEY (`user_id`)
+);
+CREATE INDEX `idx_tg_update_states_account_id` ON `tg_update_states`(`account_id`);
+
+CREATE TABLE `tg_channel_states` (
+    `user_id` integer,
+    `channel_id` integer,
+    `pts` integer,
+    `access_hash` integer,
+    `updated_at` datetime,
+    PRIMARY KEY (`user_id`,`channel_id`)
+);
+
+CREATE TABLE `outbound_queue` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `account_id` integer NOT NULL,
+    `group_id` integer NOT NULL,
+    `idempotency_key` text,
+    `source` text,
+    `schedule_run_id` integer,
+    `content` text,
+    `reply_to_msg_id` integer,
+    `topic_id` integer,
+    `media_type` text,
+    `file_name` text,
+    `mime_type` text,
+    `file_size` integer,
+    `media_path` text,
+    `poll_id` integer,
+    `buttons` text,
+    `reply_group_id` text,
+    `part_index` integer,
+    `part_count` integer DEFAULT 1,
+    `send_at` datetime,
+    `priority` integer DEFAULT 0,
+    `status` text DEFAULT "pending",
+    `attempts` integer DEFAULT 0,
+    `max_attempts` integer DEFAULT 3,
+    `last_error` text,
+    `random_id` integer,
+    `telegram_message_id` integer,
+    `message_id` integer,
+    `sent_at` datetime,
+    `delete_after` integer DEFAULT 0,
+    `delete_at` datetime,
+    `removed_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_outbound_queue_account` FOREIGN KEY (`account_id`) REFERENCES `ai_accounts`(`id`),
+    CONSTRAINT `fk_outbound_queue_group` FOREIGN KEY (`group_id`) REFERENCES `groups`(`id`),
+    CONSTRAINT `fk_schedule_runs_items` FOREIGN KEY (`schedule_run_id`) REFERENCES `schedule_runs`(`id`)
+);
+CREATE INDEX `idx_outbound_queue_delete_at` ON `outbound_queue`(`delete_at`);
+CREATE INDEX `idx_outbound_queue_reply_group_id` ON `outbound_queue`(`reply_group_id`);
+CREATE INDEX `idx_outbound_queue_source` ON `outbound_queue`(`source`);
+CREATE INDEX `idx_outbound_queue_status` ON `outbound_queue`(`status`);
+CREATE INDEX `idx_outbound_queue_send_at` ON `outbound_queue`(`send_at`);
+CREATE INDEX `idx_outbound_queue_poll_id` ON `outbound_queue`(`poll_id`);
+CREATE INDEX `idx_outbound_queue_schedule_run_id` ON `outbound_queue`(`schedule_run_id`);
+CREATE UNIQUE INDEX `idx_outbound_queue_idempotency_key` ON `outbound_queue`(`idempotency_key`);
+CREATE INDEX `idx_outbound_queue_group_id` ON `outbound_queue`(`group_id`);
+CREATE INDEX `idx_outbound_queue_account_id` ON `outbound_queue`(`account_id`);
+
+CREATE TABLE `schedules` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `name` text NOT NULL,
+    `account_id` integer NOT NULL,
+    `group_ids` text NOT NULL,
+    `cron_expr` text,
+    `run_at` datetime,
+    `timezone` text,
+    `content` text,
+    `media_type` text,
+    `file_name` text,
+    `mime_type` text,
+    `file_size` integer,
+    `media_path` text,
+    `priority` integer DEFAULT 0,
+    `status` text DEFAULT "active",
+    `next_run_at` datetime,
+    `last_run_at` datetime,
+    `run_count` integer DEFAULT 0,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_schedules_account` FOREIGN KEY (`account_id`) REFERENCES `ai_accounts`(`id`)
+);
+CREATE INDEX `idx_schedules_next_run_at` ON `schedules`(`next_run_at`);
+CREATE INDEX `idx_schedules_status` ON `schedules`(`status`);
+CREATE INDEX `idx_schedules_account_id` ON `schedules`(`account_id`);
+
+CREATE TABLE `schedule_runs` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `schedule_id` integer NOT NULL,
+    `scheduled_at` datetime,
+    `status` text,
+    `group_count` integer,
+    `error` text,
+    `created_at` datetime
+);
+CREATE INDEX `idx_schedule_runs_created_at` ON `schedule_runs`(`created_at`);
+CREATE INDEX `idx_schedule_runs_schedule_id` ON `schedule_runs`(`schedule_id`);
+
+CREATE TABLE `moderation_rules` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `group_id` integer,
+    `name` text NOT NULL,
+    `priority` integer DEFAULT 0,
+    `enabled` numeric DEFAULT true,
+    `type` text NOT NULL,
+    `match_type` text DEFAULT "keyword",
+    `pattern` text,
+    `flood_count` integer,
+    `flood_seconds` integer,
+    `new_member_hours` integer,
+    `exempt_senders` text,
+    `action` text NOT NULL,
+    `delete_message` numeric,
+    `duration_seconds` integer DEFAULT 0,
+    `warn_template` text,
+    `hit_count` integer DEFAULT 0,
+    `last_hit_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX `idx_moderation_rules_group_id` ON `moderation_rules`(`group_id`);
+
+CREATE TABLE `moderation_actions` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `account_id` integer NOT NULL,
+    `group_id` integer NOT NULL,
+    `chat_id` integer,
+    `rule_id` integer,
+    `rule_name` text,
+    `action` text,
+    `reason` text,
+    `user_id` integer,
+    `user_access_hash` integer,
+    `sender_name` text,
+    `sender_username` text,
+    `message_id` integer,
+    `content` text,
+    `message_deleted` numeric,
+    `message_sent_at` datetime,
+    `status` text DEFAULT "done",
+    `error` text,
+    `until_date` datetime,
+    `review_status` text,
+    `review_note` text,
+    `reviewed_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_moderation_actions_account` FOREIGN KEY (`account_id`) REFERENCES `ai_accounts`(`id`),
+    CONSTRAINT `fk_moderation_actions_group` FOREIGN KEY (`group_id`) REFERENCES `groups`(`id`)
+);
+CREATE INDEX `idx_moderation_actions_rule_id` ON `moderation_actions`(`rule_id`);
+CREATE INDEX `idx_moderation_actions_group_id` ON `moderation_actions`(`group_id`);
+CREATE INDEX `idx_moderation_actions_account_id` ON `moderation_actions`(`account_id`);
+CREATE INDEX `idx_moderation_actions_review_status` ON `moderation_actions`(`review_status`);
+CREATE INDEX `idx_moderation_actions_status` ON `moderation_actions`(`status`);
+CREATE INDEX `idx_moderation_actions_user_id` ON `moderation_actions`(`user_id`);
+CREATE INDEX `idx_moderation_actions_action` ON `moderation_actions`(`action`);
+
+CREATE TABLE `group_welcomes` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `group_id` integer NOT NULL,
+    `enabled` numeric DEFAULT true,
+    `template` text NOT NULL,
+    `rules_link` text,
+    `buttons` text,
+    `batch_seconds` integer DEFAULT 10,
+    `cooldown_seconds` integer DEFAULT 0,
+    `delete_after_minutes` integer DEFAULT 0,
+    `last_sent_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_group_welcomes_group` FOREIGN KEY (`group_id`) REFERENCES `groups`(`id`)
+);
+CREATE UNIQUE INDEX `idx_group_welcomes_group_id` ON `group_welcomes`(`group_id`);
+
+CREATE TABLE `polls` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `account_id` integer NOT NULL,
+    `group_id` integer NOT NULL,
+    `question` text NOT NULL,
+    `anonymous` numeric,
+    `multiple_choice` numeric,
+    `status` text DEFAULT "pending",
+    `error` text,
+    `total_voters` integer,
+    `results_at` datetime,
+    `closed_at` datetime,
+    `outbound_id` integer,
+    `message_id` integer,
+    `telegram_message_id` integer,
+    `telegram_poll_id` integer,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_polls_account` FOREIGN KEY (`account_id`) REFERENCES `ai_accounts`(`id`),
+    CONSTRAINT `fk_polls_group` FOREIGN KEY (`group_id`) REFERENCES `groups`(`id`)
+);
+CREATE INDEX `idx_polls_telegram_poll_id` ON `polls`(`telegram_poll_id`);
+CREATE INDEX `idx_polls_message_id` ON `polls`(`message_id`);
+CREATE INDEX `idx_polls_outbound_id` ON `polls`(`outbound_id`);
+CREATE INDEX `idx_polls_status` ON `polls`(`status`);
+CREATE INDEX `idx_polls_group_id` ON `polls`(`group_id`);
+CREATE INDEX `idx_polls_account_id` ON `polls`(`account_id`);
+
+CREATE TABLE `poll_options` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `poll_id` integer NOT NULL,
+    `position` integer,
+    `text` text NOT NULL,
+    `voters` integer,
+    CONSTRAINT `fk_polls_options` FOREIGN KEY (`poll_id`) REFERENCES `polls`(`id`)
+);
+CREATE INDEX `idx_poll_options_poll_id` ON `poll_options`(`poll_id`);
+
+CREATE TABLE `group_commands` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `group_id` integer,
+    `account_id` integer,
+    `name` text NOT NULL,
+    `enabled` numeric DEFAULT true,
+    `description` text,
+    `usage` text,
+    `min_args` integer,
+    `action` text NOT NULL,
+    `instruction` text,
+    `template` text,
+    `buttons` text,
+    `cooldown_seconds` integer DEFAULT 0,
+    `hit_count` integer DEFAULT 0,
+    `last_hit_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX `idx_group_commands_name` ON `group_commands`(`name`);
+CREATE INDEX `idx_group_commands_account_id` ON `group_commands`(`account_id`);
+CREATE INDEX `idx_group_commands_group_id` ON `group_commands`(`group_id`);
+
+CREATE TABLE `faq_entries` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `group_id` integer,
+    `topic` text NOT NULL,
+    `keywords` text,
+    `answer` text NOT NULL,
+    `enabled` numeric DEFAULT true,
+    `hit_count` integer DEFAULT 0,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX `idx_faq_entries_group_id` ON `faq_entries`(`group_id`);
+
+CREATE TABLE `callback_actions` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `group_id` integer,
+    `account_id` integer,
+    `data` text NOT NULL,
+    `enabled` numeric DEFAULT true,
+    `action` text NOT NULL,
+    `text` text,
+    `alert` numeric,
+    `instruction` text,
+    `buttons` text,
+    `hit_count` integer DEFAULT 0,
+    `last_hit_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX `idx_callback_actions_data` ON `callback_actions`(`data`);
+CREATE INDEX `idx_callback_actions_account_id` ON `callback_actions`(`account_id`);
+CREATE INDEX `idx_callback_actions_group_id` ON `callback_actions`(`group_id`);
+
+CREATE TABLE `callback_clicks` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `account_id` integer NOT NULL,
+    `group_id` integer,
+    `callback_action_id` integer,
+    `telegram_message_id` integer,
+    `user_id` integer,
+    `user_name` text,
+    `data` text,
+    `status` text,
+    `error` text,
+    `created_at` datetime
+);
+CREATE INDEX `idx_callback_clicks_account_id` ON `callback_clicks`(`account_id`);
+CREATE INDEX `idx_callback_clicks_created_at` ON `callback_clicks`(`created_at`);
+CREATE INDEX `idx_callback_clicks_data` ON `callback_clicks`(`data`);
+CREATE INDEX `idx_callback_clicks_user_id` ON `callback_clicks`(`user_id`);
+CREATE INDEX `idx_callback_clicks_telegram_message_id` ON `callback_clicks`(`telegram_message_id`);
+CREATE INDEX `idx_callback_clicks_callback_action_id` ON `callback_clicks`(`callback_action_id`);
+CREATE INDEX `idx_callback_clicks_group_id` ON `callback_clicks`(`group_id`);
+
+CREATE TABLE `group_captchas` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `group_id` integer NOT NULL,
+    `enabled` numeric DEFAULT true,
+    `mode` text DEFAULT "button",
+    `timeout_seconds` integer DEFAULT 120,
+    `max_attempts` integer DEFAULT 3,
+    `ban_minutes` integer DEFAULT 0,
+    `template` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_group_captchas_group` FOREIGN KEY (`group_id`) REFERENCES `groups`(`id`)
+);
+CREATE UNIQUE INDEX `idx_group_captchas_group_id` ON `group_captchas`(`group_id`);
+
+CREATE TABLE `captcha_challenges` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `account_id` integer NOT NULL,
+    `group_id` integer NOT NULL,
+    `chat_id` integer,
+    `user_id` integer,
+    `user_access_hash` integer,
+    `user_name` text,
+    `mode` text,
+    `question` text,
+    `answer` text,
+    `attempts` integer,
+    `outbound_id` integer,
+    `status` text DEFAULT "pending",
+    `error` text,
+    `expires_at` datetime,
+    `resolved_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_captcha_challenges_group` FOREIGN KEY (`group_id`) REFERENCES `groups`(`id`)
+);
+CREATE INDEX `idx_captcha_challenges_status` ON `captcha_challenges`(`status`);
+CREATE INDEX `idx_captcha_challenges_user_id` ON `captcha_challenges`(`user_id`);
+CREATE INDEX `idx_captcha_challenges_group_id` ON `captcha_challenges`(`group_id`);
+CREATE INDEX `idx_captcha_challenges_account_id` ON `captcha_challenges`(`account_id`);
+CREATE INDEX `idx_captcha_challenges_created_at` ON `captcha_challenges`(`created_at`);
+CREATE INDEX `idx_captcha_challenges_expires_at` ON `captcha_challenges`(`expires_at`);
@@ -0,0 +1,2 @@
+ALTER TABLE `group_memberships` DROP COLUMN `access_hash`;
+ALTER TABLE `groups` DROP COLUMN `full_info_at`;
@@ -0,0 +1,4 @@
+-- 群组完整信息（描述）的拉取时间；每个账号自己的频道 AccessHash
+
+ALTER TABLE `groups` ADD COLUMN `full_info_at` datetime;
+ALTER TABLE `group_memberships` ADD COLUMN `access_hash` integer;
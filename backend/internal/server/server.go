@@ -48,20 +48,100 @@ func New(cfg *config.Config, db *gorm.DB, tgManager interface{}) *Server {
 		api.PUT("/accounts/:id", handlers.UpdateAccount)
 		api.DELETE("/accounts/:id", handlers.DeleteAccount)
 		api.POST("/accounts/:id/login", handlers.LoginAccount)
+		api.GET("/accounts/:id/runtime", handlers.GetAccountRuntime)
 
 		// 群组管理
 		api.GET("/groups", handlers.GetGroups)
+		api.POST("/groups/sync", handlers.SyncGroups)
 		api.GET("/groups/:id", handlers.GetGroup)
 		api.POST("/groups", handlers.CreateGroup)
 		api.PUT("/groups/:id", handlers.UpdateGroup)
 		api.DELETE("/groups/:id", handlers.DeleteGroup)
 		api.POST("/groups/:id/assign-accounts", handlers.AssignAccounts)
 		api.GET("/groups/:id/accounts", handlers.GetGroupAccounts)
+		api.GET("/groups/:id/memberships", handlers.GetGroupMemberships)
+		api.GET("/groups/:id/assignments", handlers.GetGroupAssignments)
+		api.PUT("/groups/:id/assignments/:account_id", handlers.UpdateGroupAssignment)
+		api.GET("/groups/:id/welcome", handlers.GetGroupWelcome)
+		api.PUT("/groups/:id/welcome", handlers.UpdateGroupWelcome)
+		api.DELETE("/groups/:id/welcome", handlers.DeleteGroupWelcome)
+		api.GET("/groups/:id/captcha", handlers.GetGroupCaptcha)
+		api.PUT("/groups/:id/captcha", handlers.UpdateGroupCaptcha)
+		api.DELETE("/groups/:id/captcha", handlers.DeleteGroupCaptcha)
+		api.GET("/groups/:id/commands", handlers.GetGroupCommands)
 
 		// 消息管理
 		api.GET("/messages", handlers.GetMessages)
 		api.GET("/messages/:id", handlers.GetMessage)
+		api.PUT("/messages/:id", handlers.UpdateMessage)
+		api.DELETE("/messages/:id", handlers.DeleteMessage)
 		api.POST("/messages/send", handlers.SendMessage)
+		api.GET("/inbound-messages", handlers.GetInboundMessages)
+
+		// 发送队列
+		api.GET("/outbox", handlers.GetOutbox)
+		api.GET("/outbox/:id", handlers.GetOutboxItem)
+		api.POST("/outbox/:id/cancel", handlers.CancelOutboxItem)
+		api.POST("/outbox/:id/reschedule", handlers.RescheduleOutboxItem)
+
+		// 定时公告
+		api.GET("/schedules", handlers.GetSchedules)
+		api.GET("/schedules/:id", handlers.GetSchedule)
+		api.POST("/schedules", handlers.CreateSchedule)
+		api.PUT("/schedules/:id", handlers.UpdateSchedule)
+		api.DELETE("/schedules/:id", handlers.DeleteSchedule)
+		api.POST("/schedules/:id/pause", handlers.PauseSchedule)
+		api.POST("/schedules/:id/resume", handlers.ResumeSchedule)
+		api.GET("/schedules/:id/runs", handlers.GetScheduleRuns)
+
+		// 回复规则
+		api.GET("/rules", handlers.GetRules)
+		api.GET("/rules/:id", handlers.GetRule)
+		api.POST("/rules", handlers.CreateRule)
+		api.PUT("/rules/:id", handlers.UpdateRule)
+		api.DELETE("/rules/:id", handlers.DeleteRule)
+
+		// 审核队列
+		api.GET("/approvals", handlers.GetApprovals)
+		api.POST("/approvals/:id/approve", handlers.ApproveReply)
+		api.POST("/approvals/:id/reject", handlers.RejectReply)
+
+		// 群管（账号是群管理员时执行）
+		api.GET("/moderation/rules", handlers.GetModerationRules)
+		api.GET("/moderation/rules/:id", handlers.GetModerationRule)
+		api.POST("/moderation/rules", handlers.CreateModerationRule)
+		api.PUT("/moderation/rules/:id", handlers.UpdateModerationRule)
+		api.DELETE("/moderation/rules/:id", handlers.DeleteModerationRule)
+		api.GET("/moderation/actions", handlers.GetModerationActions)
+		api.GET("/moderation/actions/:id", handlers.GetModerationAction)
+		api.POST("/moderation/actions/:id/review", handlers.ReviewModerationAction)
+		api.GET("/captcha/challenges", handlers.GetCaptchaChallenges)
+
+		// 斜杠命令与知识库
+		api.GET("/commands", handlers.GetCommands)
+		api.GET("/commands/:id", handlers.GetCommand)
+		api.POST("/commands", handlers.CreateCommand)
+		api.PUT("/commands/:id", handlers.UpdateCommand)
+		api.DELETE("/commands/:id", handlers.DeleteCommand)
+		api.GET("/faqs", handlers.GetFAQs)
+		api.POST("/faqs", handlers.CreateFAQ)
+		api.PUT("/faqs/:id", handlers.UpdateFAQ)
+		api.DELETE("/faqs/:id", handlers.DeleteFAQ)
+
+		// 内联键盘按钮回调（机器人账号）
+		api.GET("/callback-actions", handlers.GetCallbackActions)
+		api.GET("/callback-actions/:id", handlers.GetCallbackAction)
+		api.POST("/callback-actions", handlers.CreateCallbackAction)
+		api.PUT("/callback-actions/:id", handlers.UpdateCallbackAction)
+		api.DELETE("/callback-actions/:id", handlers.DeleteCallbackAction)
+		api.GET("/callback-clicks", handlers.GetCallbackClicks)
+
+		// 投票
+		api.GET("/polls", handlers.GetPolls)
+		api.GET("/polls/:id", handlers.GetPoll)
+		api.POST("/polls", handlers.CreatePoll)
+		api.POST("/polls/:id/close", handlers.ClosePoll)
+		api.GET("/polls/:id/results", handlers.GetPollResults)
 
 		// 统计
 		api.GET("/statistics", handlers.GetStatistics)
@@ -0,0 +1,74 @@
+// Package sessionlock 会话文件锁：同一份 Telegram 会话同一时间只能被一个进程使用
+// 两个进程同时用同一份会话连接会触发 AUTH_KEY_DUPLICATED，会话可能因此被吊销
+package sessionlock
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrLocked 会话文件正在被其他进程使用
+var ErrLocked = errors.New("会话文件正在被其他进程使用")
+
+// errHeld 平台实现返回：锁已被其他进程持有
+var errHeld = errors.New("lock held")
+
+// Lock 会话文件锁，进程退出时由系统自动释放
+type Lock struct {
+	file *os.File
+}
+
+// Acquire 获取会话文件锁（不等待），已被占用时返回 ErrLocked 并说明占用的进程
+func Acquire(sessionPath string) (*Lock, error) {
+	lockPath := sessionPath + ".lock"
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+		return nil, fmt.Errorf("创建会话目录失败: %w", err)
+	}
+
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("打开会话锁文件失败: %w", err)
+	}
+
+	if err := lockFile(file); err != nil {
+		holder := readHolder(file)
+		file.Close()
+		if errors.Is(err, errHeld) {
+			return nil, fmt.Errorf("%w: %s（%s）", ErrLocked, sessionPath, holder)
+		}
+		return nil, fmt.Errorf("锁定会话文件失败: %w", err)
+	}
+
+	// 记录持有者，其他进程获取失败时用于提示
+	holder := fmt.Sprintf("进程 %d [%s]，%s 起", os.Getpid(), filepath.Base(os.Args[0]), time.Now().Format("2006-01-02 15:04:05"))
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(holder), 0)
+	}
+
+	return &Lock{file: file}, nil
+}
+
+// Release 释放会话文件锁（锁文件保留，删除锁文件会让其他进程锁住不同的文件）
+func (l *Lock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	err := unlockFile(l.file)
+	l.file.Close()
+	l.file = nil
+	return err
+}
+
+// readHolder 读取锁文件中记录的持有者
+func readHolder(file *os.File) string {
+	data, err := io.ReadAll(io.NewSectionReader(file, 0, 256))
+	if err != nil || len(data) == 0 {
+		return "持有者未知"
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,63 @@
+package sessionlock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAcquireLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions", "+10000000000.session")
+
+	lock, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	defer lock.Release()
+
+	// 同一份会话再次获取失败，错误中带上持有者
+	_, err = Acquire(path)
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("second Acquire = %v, want ErrLocked", err)
+	}
+	if holder := fmt.Sprintf("进程 %d", os.Getpid()); !strings.Contains(err.Error(), holder) {
+		t.Fatalf("error %q does not name the holder %q", err, holder)
+	}
+
+	// 其他会话不受影响
+	other, err := Acquire(filepath.Join(filepath.Dir(path), "+10000000001.session"))
+	if err != nil {
+		t.Fatalf("Acquire other session: %v", err)
+	}
+	other.Release()
+}
+
+func TestAcquireAfterRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "+10000000000.session")
+
+	lock, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	// 重复释放无副作用
+	if err := lock.Release(); err != nil {
+		t.Fatalf("second Release: %v", err)
+	}
+
+	again, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire after Release: %v", err)
+	}
+	defer again.Release()
+
+	// 锁文件保留
+	if _, err := os.Stat(path + ".lock"); err != nil {
+		t.Fatalf("lock file: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"aibot/internal/config"
+)
+
+// ErrNotFound 键不存在或已过期
+var ErrNotFound = errors.New("键不存在")
+
+// Store 运行时状态存储（消息缓冲区、冷却时间、回复状态、账号租约）
+// 单实例部署使用内存存储；多实例部署使用 Redis，账号切换到其他实例后可以恢复状态
+type Store interface {
+	// Get 读取键的值，不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set 写入键的值，ttl 为 0 表示不过期
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// SetNX 键不存在时写入，返回是否写入成功（用于冷却时间）
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// Take 读取并删除键的值，不存在时返回 ErrNotFound
+	Take(ctx context.Context, key string) ([]byte, error)
+	// Delete 删除键
+	Delete(ctx context.Context, key string) error
+	// Keys 列出指定前缀的全部键
+	Keys(ctx context.Context, prefix string) ([]string, error)
+
+	// Acquire 获取或续期租约：租约空闲或已由 owner 持有时设置为 owner 并刷新过期时间，返回是否持有
+	Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error)
+	// Release 释放 owner 持有的租约（已被其他持有者获取时不做任何事）
+	Release(ctx context.Context, key, owner string) error
+	// Owner 查询租约当前的持有者，空闲时返回空字符串
+	Owner(ctx context.Context, key string) (string, error)
+
+	Close() error
+}
+
+// New 根据配置创建状态存储：未启用 Redis 时使用内存存储
+func New(cfg config.RedisConfig) (Store, error) {
+	if !cfg.Enabled {
+		log.Println("🗃️ 运行状态保存在内存中（单实例）")
+		return NewMemoryStore(), nil
+	}
+
+	store, err := NewRedisStore(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("连接 Redis 失败: %w", err)
+	}
+	log.Printf("🗃️ 运行状态保存在 Redis [%s:%s/%d]", cfg.Host, cfg.Port, cfg.DB)
+	return store, nil
+}
@@ -0,0 +1,199 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"aibot/internal/config"
+)
+
+// shortTTL 过期测试使用的有效期
+const shortTTL = 100 * time.Millisecond
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+// TestRedisStore 使用 REDIS_* 环境变量连接 Redis，未设置 REDIS_ENABLED=true 时跳过
+func TestRedisStore(t *testing.T) {
+	if enabled, _ := strconv.ParseBool(os.Getenv("REDIS_ENABLED")); !enabled {
+		t.Skip("未配置 Redis（REDIS_ENABLED=true）")
+	}
+	cfg := config.Load().Redis
+	// 每次测试使用独立前缀，不影响已有数据
+	cfg.Prefix = fmt.Sprintf("%stest:%d:", cfg.Prefix, time.Now().UnixNano())
+
+	store, err := NewRedisStore(cfg)
+	if err != nil {
+		t.Fatalf("connect redis: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx := context.Background()
+		keys, _ := store.Keys(ctx, "")
+		for _, key := range keys {
+			store.Delete(ctx, key)
+		}
+		store.Close()
+	})
+	testStore(t, store)
+}
+
+// testStore 存储实现的公共测试
+func testStore(t *testing.T, store Store) {
+	t.Run("GetSetTake", func(t *testing.T) { testGetSetTake(t, store) })
+	t.Run("SetNX", func(t *testing.T) { testSetNX(t, store) })
+	t.Run("Keys", func(t *testing.T) { testKeys(t, store) })
+	t.Run("Lease", func(t *testing.T) { testLease(t, store) })
+	t.Run("LeaseExpiry", func(t *testing.T) { testLeaseExpiry(t, store) })
+}
+
+func testGetSetTake(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	if _, err := store.Get(ctx, "kv:missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get missing = %v, want ErrNotFound", err)
+	}
+	if err := store.Set(ctx, "kv:a", []byte("1"), 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if value, err := store.Get(ctx, "kv:a"); err != nil || string(value) != "1" {
+		t.Fatalf("Get = %q, %v, want 1", value, err)
+	}
+
+	if value, err := store.Take(ctx, "kv:a"); err != nil || string(value) != "1" {
+		t.Fatalf("Take = %q, %v, want 1", value, err)
+	}
+	if _, err := store.Take(ctx, "kv:a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Take after take = %v, want ErrNotFound", err)
+	}
+
+	store.Set(ctx, "kv:b", []byte("2"), 0)
+	if err := store.Delete(ctx, "kv:b"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "kv:b"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after delete = %v, want ErrNotFound", err)
+	}
+
+	store.Set(ctx, "kv:ttl", []byte("3"), shortTTL)
+	time.Sleep(2 * shortTTL)
+	if _, err := store.Get(ctx, "kv:ttl"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after ttl = %v, want ErrNotFound", err)
+	}
+}
+
+func testSetNX(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	if ok, err := store.SetNX(ctx, "cooldown:1", []byte("a"), shortTTL); err != nil || !ok {
+		t.Fatalf("first SetNX = %v, %v, want true", ok, err)
+	}
+	if ok, err := store.SetNX(ctx, "cooldown:1", []byte("b"), shortTTL); err != nil || ok {
+		t.Fatalf("second SetNX = %v, %v, want false", ok, err)
+	}
+	if value, _ := store.Get(ctx, "cooldown:1"); string(value) != "a" {
+		t.Fatalf("value = %q, SetNX must not overwrite", value)
+	}
+
+	// 过期后可以再次写入
+	time.Sleep(2 * shortTTL)
+	if ok, err := store.SetNX(ctx, "cooldown:1", []byte("c"), shortTTL); err != nil || !ok {
+		t.Fatalf("SetNX after expiry = %v, %v, want true", ok, err)
+	}
+}
+
+func testKeys(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	for _, key := range []string{"buffer:1:a", "buffer:1:b", "buffer:10:c", "other:buffer:1:d"} {
+		store.Set(ctx, key, []byte("x"), 0)
+	}
+	store.Set(ctx, "buffer:1:expired", []byte("x"), shortTTL)
+	time.Sleep(2 * shortTTL)
+
+	keys, err := store.Keys(ctx, "buffer:1:")
+	if err != nil {
+		t.Fatalf("Keys: %v", err)
+	}
+	sort.Strings(keys)
+	if want := []string{"buffer:1:a", "buffer:1:b"}; fmt.Sprint(keys) != fmt.Sprint(want) {
+		t.Fatalf("Keys = %v, want %v", keys, want)
+	}
+
+	if keys, _ := store.Keys(ctx, "none:"); len(keys) != 0 {
+		t.Fatalf("Keys with unknown prefix = %v, want none", keys)
+	}
+}
+
+func testLease(t *testing.T, store Store) {
+	ctx := context.Background()
+	const key = "lease:account:1"
+
+	expectOwner := func(want string) {
+		t.Helper()
+		if owner, err := store.Owner(ctx, key); err != nil || owner != want {
+			t.Fatalf("Owner = %q, %v, want %q", owner, err, want)
+		}
+	}
+
+	expectOwner("")
+	if ok, err := store.Acquire(ctx, key, "a", time.Minute); err != nil || !ok {
+		t.Fatalf("Acquire a = %v, %v, want true", ok, err)
+	}
+	// 持有者可以续期，其他实例不能获取
+	if ok, err := store.Acquire(ctx, key, "a", time.Minute); err != nil || !ok {
+		t.Fatalf("renew a = %v, %v, want true", ok, err)
+	}
+	if ok, err := store.Acquire(ctx, key, "b", time.Minute); err != nil || ok {
+		t.Fatalf("Acquire b = %v, %v, want false", ok, err)
+	}
+	expectOwner("a")
+
+	// 只有持有者可以释放
+	if err := store.Release(ctx, key, "b"); err != nil {
+		t.Fatalf("Release b: %v", err)
+	}
+	expectOwner("a")
+	if err := store.Release(ctx, key, "a"); err != nil {
+		t.Fatalf("Release a: %v", err)
+	}
+	expectOwner("")
+
+	if ok, err := store.Acquire(ctx, key, "b", time.Minute); err != nil || !ok {
+		t.Fatalf("Acquire b after release = %v, %v, want true", ok, err)
+	}
+	expectOwner("b")
+}
+
+func testLeaseExpiry(t *testing.T, store Store) {
+	ctx := context.Background()
+	const key = "lease:account:2"
+
+	if ok, _ := store.Acquire(ctx, key, "a", shortTTL); !ok {
+		t.Fatal("Acquire a failed")
+	}
+	// 续期刷新过期时间
+	time.Sleep(shortTTL / 2)
+	if ok, _ := store.Acquire(ctx, key, "a", shortTTL); !ok {
+		t.Fatal("renew a failed")
+	}
+	time.Sleep(shortTTL * 3 / 4)
+	if ok, _ := store.Acquire(ctx, key, "b", shortTTL); ok {
+		t.Fatal("b acquired a renewed lease")
+	}
+
+	// 持有者停止续期后其他实例可以接管
+	time.Sleep(2 * shortTTL)
+	if owner, _ := store.Owner(ctx, key); owner != "" {
+		t.Fatalf("Owner after expiry = %q, want empty", owner)
+	}
+	if ok, _ := store.Acquire(ctx, key, "b", shortTTL); !ok {
+		t.Fatal("b could not take over an expired lease")
+	}
+}
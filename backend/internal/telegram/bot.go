@@ -0,0 +1,215 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"aibot/models"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"gorm.io/gorm"
+)
+
+// 账号类型
+const (
+	AccountTypeUser = "user" // 手机号登录的用户账号
+	AccountTypeBot  = "bot"  // Bot Token 登录的官方机器人
+)
+
+// botGroupSendInterval 机器人在同一群组两次发送的最小间隔（Telegram 限制机器人每个群组每分钟约20条消息）
+const botGroupSendInterval = 3 * time.Second
+
+// botTokenPattern Bot Token 格式：{机器人ID}:{密钥}
+var botTokenPattern = regexp.MustCompile(`^(\d+):[A-Za-z0-9_-]{30,}$`)
+
+// BotAccountPhone 校验 Bot Token，返回机器人账号的唯一标识（bot{机器人ID}，保存在 phone_number 中）
+func BotAccountPhone(token string) (string, error) {
+	match := botTokenPattern.FindStringSubmatch(token)
+	if match == nil {
+		return "", fmt.Errorf("Bot Token 格式错误，应为 123456789:ABC... 形式")
+	}
+	return "bot" + match[1], nil
+}
+
+// authenticateBot 使用 Bot Token 登录（已有会话时直接复用）
+func (c *ClientV2) authenticateBot(ctx context.Context) error {
+	status, err := c.TGClient.Auth().Status(ctx)
+	if err != nil {
+		return fmt.Errorf("检查登录状态失败: %w", err)
+	}
+	if status.Authorized {
+		return nil
+	}
+
+	log.Printf("🤖 使用 Bot Token 登录 [账号ID: %d]", c.Account.ID)
+	if _, err := c.TGClient.Auth().Bot(ctx, c.accountSettings().BotToken); err != nil {
+		log.Printf("❌ 机器人登录失败: %v", err)
+		c.saveAccountStatus("error", "")
+		if tgerr.Is(err, "ACCESS_TOKEN_INVALID", "ACCESS_TOKEN_EXPIRED") {
+			return fmt.Errorf("Bot Token 无效: %v: %w", err, ErrNeedsLogin)
+		}
+		return fmt.Errorf("机器人登录失败: %w", err)
+	}
+
+	log.Printf("✅ 机器人登录成功，会话已保存")
+	return nil
+}
+
+// saveBotPrivacy 记录机器人是否开启了隐私模式（在 @BotFather 中通过 /setprivacy 设置）
+func (c *ClientV2) saveBotPrivacy(self *tg.User) {
+	privacy := !self.BotChatHistory
+
+	c.accountLock.Lock()
+	c.Account.BotPrivacyMode = privacy
+	c.accountLock.Unlock()
+	c.DB.Model(&models.Account{}).Where("id = ?", c.Account.ID).Update("bot_privacy_mode", privacy)
+
+	if privacy {
+		log.Printf("🤖 机器人已开启隐私模式：在非管理员群组中只能收到命令、@提及和回复机器人的消息")
+	}
+}
+
+// groupLimiter 获取机器人在群组中的发送限流器
+func (c *ClientV2) groupLimiter(chatID int64) *RateLimiter {
+	c.groupLimitersLock.Lock()
+	defer c.groupLimitersLock.Unlock()
+
+	limiter, ok := c.groupLimiters[chatID]
+	if !ok {
+		limiter = NewRateLimiter(botGroupSendInterval)
+		c.groupLimiters[chatID] = limiter
+	}
+	return limiter
+}
+
+// SyncBotGroups 同步机器人账号的群组信息
+// 机器人无法拉取对话列表，只刷新已记录的群组；新加入的群组在收到更新时记录（见 learnBotChats）
+func SyncBotGroups(ctx context.Context, api *tg.Client, db *gorm.DB, accountID uint, selfID int64, limiter *RateLimiter) (*GroupSyncResult, error) {
+	log.Printf("🔄 开始同步机器人群组信息 [账号ID: %d]", accountID)
+
+	var memberships []models.GroupMembership
+	if err := db.Preload("Group").Where("account_id = ?", accountID).Find(&memberships).Error; err != nil {
+		return nil, fmt.Errorf("查询成员身份失败: %w", err)
+	}
+
+	result := &GroupSyncResult{
+		AccountID: accountID,
+		SyncedAt:  time.Now(),
+	}
+
+	privacy := accountBotPrivacy(db, accountID)
+	for _, membership := range memberships {
+		group := membership.Group
+		if group.ID == 0 {
+			continue
+		}
+
+		var chats tg.MessagesChatsClass
+		var err error
+		if group.Type == "supergroup" || group.Type == "channel" {
+			chats, err = api.ChannelsGetChannels(ctx, []tg.InputChannelClass{
+				&tg.InputChannel{ChannelID: group.ChatID, AccessHash: channelAccessHash(db, accountID, selfID, &group)},
+			})
+		} else {
+			chats, err = api.MessagesGetChats(ctx, []int64{group.ChatID})
+		}
+		if err != nil {
+			log.Printf("⚠️ 获取群组信息失败 [群组: %s, ID: %d]: %v", group.Title, group.ChatID, err)
+			continue
+		}
+
+		for _, chat := range chats.GetChats() {
+			synced, ok := parseSyncedChat(chat)
+			if !ok || synced.group.ChatID != group.ChatID {
+				continue
+			}
+			applySyncedChat(ctx, api, db, limiter, accountID, synced, result)
+			warnBotPrivacy(privacy, synced)
+		}
+	}
+
+	refreshGroupStatuses(db, accountID, result)
+
+	log.Printf("✅ 机器人群组同步完成 [账号ID: %d, 群组: %d, 退出: %d]", accountID, result.Total, len(result.Left))
+	return result, nil
+}
+
+// learnBotChats 从更新附带的群组信息中记录机器人所在的群组
+// force 为 false 时只处理尚未记录的群组；机器人自身的成员变动（被拉入、被踢出、权限变化）传 true
+func (c *ClientV2) learnBotChats(ctx context.Context, e tg.Entities, force bool) {
+	if !c.bot {
+		return
+	}
+
+	chats := make([]tg.ChatClass, 0, len(e.Chats)+len(e.Channels))
+	for _, chat := range e.Chats {
+		chats = append(chats, chat)
+	}
+	for _, channel := range e.Channels {
+		chats = append(chats, channel)
+	}
+
+	for _, chat := range chats {
+		synced, ok := parseSyncedChat(chat)
+		if !ok {
+			continue
+		}
+
+		c.knownChatsLock.Lock()
+		known := c.knownChats[synced.group.ChatID]
+		c.knownChats[synced.group.ChatID] = true
+		c.knownChatsLock.Unlock()
+		if known && !force {
+			continue
+		}
+
+		result := &GroupSyncResult{AccountID: c.Account.ID, SyncedAt: time.Now()}
+		applySyncedChat(ctx, c.TGClient.API(), c.DB, c.limiter, c.Account.ID, synced, result)
+		if len(result.Joined)+len(result.Left)+len(result.RoleChanged) == 0 {
+			continue
+		}
+		refreshGroupStatuses(c.DB, c.Account.ID, result)
+
+		for _, change := range result.Joined {
+			log.Printf("🤖 机器人已加入群组: %s [ID: %d, 角色: %s]", change.Title, change.ChatID, change.Role)
+		}
+		for _, change := range result.Left {
+			log.Printf("🤖 机器人已离开群组: %s [ID: %d]", change.Title, change.ChatID)
+		}
+		warnBotPrivacy(c.accountSettings().BotPrivacyMode, synced)
+	}
+}
+
+// channelAccessHash 获取账号自己的频道 AccessHash（更新状态或成员身份中的），都没有记录时使用群组中保存的
+func channelAccessHash(db *gorm.DB, accountID uint, selfID int64, group *models.Group) int64 {
+	var state models.ChannelUpdateState
+	if selfID != 0 && db.Where("user_id = ? AND channel_id = ? AND access_hash <> 0", selfID, group.ChatID).First(&state).Error == nil {
+		return state.AccessHash
+	}
+	var membership models.GroupMembership
+	if db.Where("account_id = ? AND group_id = ? AND access_hash <> 0", accountID, group.ID).First(&membership).Error == nil {
+		return membership.AccessHash
+	}
+	return group.AccessHash
+}
+
+// accountBotPrivacy 读取机器人账号的隐私模式设置
+func accountBotPrivacy(db *gorm.DB, accountID uint) bool {
+	var account models.Account
+	if err := db.Select("bot_privacy_mode").First(&account, accountID).Error; err != nil {
+		return false
+	}
+	return account.BotPrivacyMode
+}
+
+// warnBotPrivacy 隐私模式下，机器人在非管理员群组中收不到普通消息，只能响应命令、@提及和回复
+func warnBotPrivacy(privacy bool, synced *syncedChat) {
+	if !privacy || synced.status != "member" || synced.role == "creator" || synced.role == "admin" {
+		return
+	}
+	log.Printf("⚠️ 机器人在群组 %s [ID: %d] 不是管理员且开启了隐私模式，只能收到命令、@提及和回复机器人的消息", synced.group.Title, synced.group.ChatID)
+}
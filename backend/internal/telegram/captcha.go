@@ -0,0 +1,453 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"aibot/models"
+
+	"github.com/gotd/td/tg"
+	"gorm.io/gorm"
+)
+
+// 入群验证方式
+const (
+	CaptchaModeButton = "button" // 点击按钮（仅机器人账号）
+	CaptchaModeMath   = "math"   // 发送算式答案
+)
+
+// 入群验证状态
+const (
+	CaptchaPending = "pending"
+	CaptchaPassed  = "passed"
+	CaptchaFailed  = "failed"  // 答错次数过多
+	CaptchaTimeout = "timeout" // 超时未验证
+	CaptchaError   = "error"   // 无法限制成员，未进行验证
+)
+
+// 验证时间的范围（秒）
+const (
+	minCaptchaTimeout = 30
+	maxCaptchaTimeout = 3600
+)
+
+// captchaCallbackPrefix 验证按钮的回调数据前缀（captcha:{验证记录ID}）
+const captchaCallbackPrefix = "captcha:"
+
+// captchaPriority 验证提示在发送队列中优先发送
+const captchaPriority = 100
+
+// defaultCaptchaTemplates 未配置提示时使用的默认验证提示
+var defaultCaptchaTemplates = map[string]string{
+	CaptchaModeButton: "{name}，欢迎加入 {group}！请在 {timeout} 秒内点击下方按钮完成验证，否则将被移出群组。",
+	CaptchaModeMath:   "{name}，欢迎加入 {group}！请在 {timeout} 秒内直接发送下面算式的答案完成验证，否则将被移出群组：\n{question} = ?",
+}
+
+// ValidateCaptcha 校验入群验证配置
+func ValidateCaptcha(captcha *models.GroupCaptcha) error {
+	switch captcha.Mode {
+	case CaptchaModeButton, CaptchaModeMath:
+	default:
+		return fmt.Errorf("不支持的验证方式: %s", captcha.Mode)
+	}
+	if captcha.TimeoutSeconds < minCaptchaTimeout || captcha.TimeoutSeconds > maxCaptchaTimeout {
+		return fmt.Errorf("验证时间应在 %d-%d 秒之间", minCaptchaTimeout, maxCaptchaTimeout)
+	}
+	if captcha.MaxAttempts <= 0 {
+		return fmt.Errorf("答题次数至少为1")
+	}
+	if captcha.BanMinutes < 0 {
+		return fmt.Errorf("封禁时间不能为负数")
+	}
+	return nil
+}
+
+// captchaRights 验证期间对新成员的限制：按钮验证禁止发言；算式验证只允许发送文字（用于回答）
+func captchaRights(mode string) tg.ChatBannedRights {
+	rights := tg.ChatBannedRights{
+		SendMedia:    true,
+		SendStickers: true,
+		SendGifs:     true,
+		SendGames:    true,
+		SendInline:   true,
+		EmbedLinks:   true,
+		SendPolls:    true,
+	}
+	if mode == CaptchaModeButton {
+		rights.SendMessages = true
+	}
+	return rights
+}
+
+// newMathQuestion 生成一道简单的算式，返回算式和答案
+func newMathQuestion() (string, string) {
+	a, b := rand.Intn(20)+1, rand.Intn(20)+1
+	switch rand.Intn(3) {
+	case 0:
+		return fmt.Sprintf("%d + %d", a, b), strconv.Itoa(a + b)
+	case 1:
+		if a < b {
+			a, b = b, a
+		}
+		return fmt.Sprintf("%d - %d", a, b), strconv.Itoa(a - b)
+	default:
+		a, b = a%10+1, b%10+1
+		return fmt.Sprintf("%d × %d", a, b), strconv.Itoa(a * b)
+	}
+}
+
+// loadCaptcha 加载群组已启用的入群验证配置
+func (c *ClientV2) loadCaptcha(groupID uint) (*models.GroupCaptcha, bool) {
+	var captcha models.GroupCaptcha
+	if err := c.DB.Where("group_id = ? AND enabled = ?", groupID, true).First(&captcha).Error; err != nil {
+		return nil, false
+	}
+	return &captcha, true
+}
+
+// captchaIssuer 选出发起验证的账号：已启用分配、在线且是群管理员的账号中优先级最高的（按钮验证只能由机器人发起）
+func (c *ClientV2) captchaIssuer(groupID uint, mode string) (uint, bool) {
+	query := c.DB.Model(&models.AccountGroup{}).
+		Joins("JOIN ai_accounts ON ai_accounts.id = account_groups.account_id AND ai_accounts.deleted_at IS NULL").
+		Joins("JOIN group_memberships ON group_memberships.account_id = account_groups.account_id AND group_memberships.group_id = account_groups.group_id").
+		Where("account_groups.group_id = ? AND account_groups.enabled = ? AND ai_accounts.status = ?", groupID, true, "online").
+		Where("group_memberships.status = ? AND group_memberships.role IN ?", "member", []string{"creator", "admin"})
+	if mode == CaptchaModeButton {
+		query = query.Where("ai_accounts.type = ?", AccountTypeBot)
+	}
+
+	var assignment models.AccountGroup
+	if err := query.Order("account_groups.priority DESC, account_groups.account_id ASC").First(&assignment).Error; err != nil {
+		return 0, false
+	}
+	return assignment.AccountID, true
+}
+
+// requireCaptcha 新成员是否需要先完成入群验证（需要时由发起验证的账号处理，通过后再欢迎）
+func (c *ClientV2) requireCaptcha(chatID int64, groupID uint, userID int64, user *tg.User) bool {
+	captcha, ok := c.loadCaptcha(groupID)
+	if !ok {
+		return false
+	}
+
+	var group models.Group
+	if err := c.DB.Select("type").First(&group, groupID).Error; err != nil || group.Type != "supergroup" {
+		log.Printf("⚠️ 群组 [%d] 不是超级群组，无法限制新成员，跳过入群验证", chatID)
+		return false
+	}
+
+	issuer, ok := c.captchaIssuer(groupID, captcha.Mode)
+	if !ok {
+		log.Printf("⚠️ 群组 [%d] 开启了入群验证，但没有可以发起验证的在线管理员账号，跳过验证", chatID)
+		return false
+	}
+	if issuer == c.Account.ID {
+		go c.startCaptcha(chatID, groupID, captcha, userID, user)
+	}
+	return true
+}
+
+// startCaptcha 限制新成员并发送验证提示
+func (c *ClientV2) startCaptcha(chatID int64, groupID uint, captcha *models.GroupCaptcha, userID int64, user *tg.User) {
+	challenge := &models.CaptchaChallenge{
+		AccountID: c.Account.ID,
+		GroupID:   groupID,
+		ChatID:    chatID,
+		UserID:    userID,
+		UserName:  welcomeName(userID, user),
+		Mode:      captcha.Mode,
+		Status:    CaptchaPending,
+		ExpiresAt: time.Now().Add(time.Duration(captcha.TimeoutSeconds) * time.Second),
+	}
+	if user != nil {
+		challenge.UserAccessHash = user.AccessHash
+	}
+	if captcha.Mode == CaptchaModeMath {
+		challenge.Question, challenge.Answer = newMathQuestion()
+	}
+
+	// 服务消息和成员变动更新可能同时到达，已有进行中的验证时跳过
+	c.captchaLock.Lock()
+	var count int64
+	c.DB.Model(&models.CaptchaChallenge{}).
+		Where("group_id = ? AND user_id = ? AND status = ?", groupID, userID, CaptchaPending).
+		Count(&count)
+	if count > 0 {
+		c.captchaLock.Unlock()
+		return
+	}
+	err := c.DB.Create(challenge).Error
+	c.captchaLock.Unlock()
+	if err != nil {
+		log.Printf("❌ 保存入群验证记录失败: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context, 30*time.Second)
+	defer cancel()
+
+	channel, err := c.captchaChannel(ctx, chatID)
+	if err == nil {
+		err = c.editBanned(ctx, channel, userID, challenge.UserAccessHash, captchaRights(captcha.Mode))
+	}
+	if err != nil {
+		log.Printf("❌ 限制新成员失败，跳过入群验证 [群组ID: %d, 用户: %s]: %v", chatID, challenge.UserName, err)
+		c.finishCaptcha(challenge, CaptchaError, "限制成员失败: "+err.Error())
+		return
+	}
+
+	template := captcha.Template
+	if strings.TrimSpace(template) == "" {
+		template = defaultCaptchaTemplates[captcha.Mode]
+	}
+	item := &models.OutboundMessage{
+		AccountID: c.Account.ID,
+		GroupID:   groupID,
+		Source:    "captcha",
+		Content: strings.NewReplacer(
+			"{name}", challenge.UserName,
+			"{group}", c.groupTitle(groupID),
+			"{question}", challenge.Question,
+			"{timeout}", strconv.Itoa(captcha.TimeoutSeconds),
+		).Replace(template),
+		Priority: captchaPriority,
+		// 验证结束时会删除提示，这里兜底
+		DeleteAfter: captcha.TimeoutSeconds,
+	}
+	if captcha.Mode == CaptchaModeButton {
+		item.Buttons = models.InlineKeyboard{{{Text: "✅ 我不是机器人", Data: fmt.Sprintf("%s%d", captchaCallbackPrefix, challenge.ID)}}}
+	}
+	if _, _, err := EnqueueOutbound(c.DB, item, nil); err != nil {
+		log.Printf("❌ 验证提示加入发送队列失败: %v", err)
+	} else {
+		challenge.OutboundID = &item.ID
+		c.DB.Model(challenge).Update("outbound_id", item.ID)
+		c.wakeOutbox()
+	}
+
+	time.AfterFunc(time.Duration(captcha.TimeoutSeconds)*time.Second, func() { c.expireCaptcha(challenge.ID) })
+	log.Printf("🧩 新成员需要完成入群验证 [群组ID: %d, 用户: %s, 方式: %s]", chatID, challenge.UserName, captcha.Mode)
+}
+
+// resumeCaptchas 恢复上次运行时未结束的验证（已超时的立即处理）
+func (c *ClientV2) resumeCaptchas() {
+	var challenges []models.CaptchaChallenge
+	if err := c.DB.Where("account_id = ? AND status = ?", c.Account.ID, CaptchaPending).Find(&challenges).Error; err != nil {
+		log.Printf("⚠️ 查询未完成的入群验证失败: %v", err)
+		return
+	}
+	for _, challenge := range challenges {
+		id := challenge.ID
+		wait := time.Until(challenge.ExpiresAt)
+		if wait < 0 {
+			wait = 0
+		}
+		time.AfterFunc(wait, func() { c.expireCaptcha(id) })
+	}
+	if len(challenges) > 0 {
+		log.Printf("🧩 恢复 %d 个未完成的入群验证", len(challenges))
+	}
+}
+
+// checkCaptchaAnswer 处理待验证成员的消息，返回消息是否已被验证流程处理（不再进入后续流程）
+func (c *ClientV2) checkCaptchaAnswer(groupID uint, message *tg.Message, buffered BufferedMessage) bool {
+	if buffered.SenderID == 0 {
+		return false
+	}
+
+	var challenge models.CaptchaChallenge
+	if err := c.DB.Where("group_id = ? AND user_id = ? AND status = ?", groupID, buffered.SenderID, CaptchaPending).
+		First(&challenge).Error; err != nil {
+		return false
+	}
+	// 由发起验证的账号处理
+	if challenge.AccountID == c.Account.ID {
+		go c.answerCaptcha(&challenge, message.ID, strings.TrimSpace(message.Message))
+	}
+	return true
+}
+
+// answerCaptcha 检查算式答案：答对解除限制，答错删除消息，次数用完后移出
+func (c *ClientV2) answerCaptcha(challenge *models.CaptchaChallenge, msgID int, text string) {
+	if challenge.Mode == CaptchaModeMath && text == challenge.Answer {
+		c.passCaptcha(challenge, msgID)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context, 30*time.Second)
+	defer cancel()
+	if err := c.deleteGroupMessages(ctx, challenge.ChatID, []int{msgID}); err != nil {
+		log.Printf("⚠️ 删除待验证成员的消息失败: %v", err)
+	}
+	if challenge.Mode != CaptchaModeMath {
+		return
+	}
+
+	c.DB.Model(&models.CaptchaChallenge{}).Where("id = ? AND status = ?", challenge.ID, CaptchaPending).
+		UpdateColumn("attempts", gorm.Expr("attempts + ?", 1))
+	c.DB.Select("attempts").First(challenge, challenge.ID)
+
+	maxAttempts := 3
+	if captcha, ok := c.loadCaptcha(challenge.GroupID); ok {
+		maxAttempts = captcha.MaxAttempts
+	}
+	log.Printf("🧩 入群验证答案错误 [群组ID: %d, 用户: %s, %d/%d]", challenge.ChatID, challenge.UserName, challenge.Attempts, maxAttempts)
+	if challenge.Attempts >= maxAttempts && c.finishCaptcha(challenge, CaptchaFailed, "") {
+		c.rejectMember(challenge)
+	}
+}
+
+// handleCaptchaCallback 处理验证按钮的点击
+func (c *ClientV2) handleCaptchaCallback(queryID int64, click *models.CallbackClick) {
+	ctx, cancel := context.WithTimeout(c.Context, 30*time.Second)
+	defer cancel()
+
+	id, _ := strconv.ParseUint(strings.TrimPrefix(click.Data, captchaCallbackPrefix), 10, 64)
+	var challenge models.CaptchaChallenge
+	if err := c.DB.First(&challenge, id).Error; err != nil || challenge.Status != CaptchaPending {
+		c.answerCallback(ctx, queryID, "验证已结束", false)
+		return
+	}
+	if challenge.UserID != click.UserID {
+		c.answerCallback(ctx, queryID, "这不是你的验证", false)
+		return
+	}
+
+	c.answerCallback(ctx, queryID, "✅ 验证通过", false)
+	c.passCaptcha(&challenge, 0)
+}
+
+// passCaptcha 验证通过：解除限制、删除验证提示，并按配置欢迎
+func (c *ClientV2) passCaptcha(challenge *models.CaptchaChallenge, answerMsgID int) {
+	if !c.finishCaptcha(challenge, CaptchaPassed, "") {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context, 30*time.Second)
+	defer cancel()
+
+	channel, err := c.captchaChannel(ctx, challenge.ChatID)
+	if err == nil {
+		err = c.editBanned(ctx, channel, challenge.UserID, challenge.UserAccessHash, tg.ChatBannedRights{})
+	}
+	if err != nil {
+		log.Printf("❌ 解除新成员限制失败 [群组ID: %d, 用户: %s]: %v", challenge.ChatID, challenge.UserName, err)
+		c.DB.Model(challenge).Update("error", "解除限制失败: "+err.Error())
+	}
+	if answerMsgID > 0 {
+		c.deleteGroupMessages(ctx, challenge.ChatID, []int{answerMsgID})
+	}
+
+	log.Printf("✅ 新成员已通过入群验证 [群组ID: %d, 用户: %s]", challenge.ChatID, challenge.UserName)
+	c.queueWelcome(challenge.ChatID, challenge.GroupID, challenge.UserID, challenge.UserName)
+}
+
+// expireCaptcha 验证超时：移出成员
+func (c *ClientV2) expireCaptcha(id uint) {
+	// 客户端已停止，重新启动后恢复
+	if c.Context.Err() != nil {
+		return
+	}
+
+	var challenge models.CaptchaChallenge
+	if err := c.DB.First(&challenge, id).Error; err != nil {
+		return
+	}
+	if !c.finishCaptcha(&challenge, CaptchaTimeout, "") {
+		return
+	}
+	log.Printf("⏰ 新成员未在规定时间内完成入群验证 [群组ID: %d, 用户: %s]", challenge.ChatID, challenge.UserName)
+	c.rejectMember(&challenge)
+}
+
+// finishCaptcha 结束验证并删除验证提示；验证已结束（如同时超时和通过）时返回 false
+func (c *ClientV2) finishCaptcha(challenge *models.CaptchaChallenge, status, errText string) bool {
+	now := time.Now()
+	result := c.DB.Model(&models.CaptchaChallenge{}).
+		Where("id = ? AND status = ?", challenge.ID, CaptchaPending).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"error":       errText,
+			"resolved_at": now,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+	challenge.Status = status
+	challenge.ResolvedAt = &now
+
+	c.removeCaptchaPrompt(challenge)
+	return true
+}
+
+// removeCaptchaPrompt 删除验证提示（未发送的取消发送）
+func (c *ClientV2) removeCaptchaPrompt(challenge *models.CaptchaChallenge) {
+	if challenge.OutboundID == nil {
+		return
+	}
+	var item models.OutboundMessage
+	if err := c.DB.First(&item, *challenge.OutboundID).Error; err != nil {
+		return
+	}
+
+	switch {
+	case item.Status == OutboundPending:
+		CancelOutbound(c.DB, item.ID)
+	case item.Status == OutboundSent && item.RemovedAt == nil:
+		ctx, cancel := context.WithTimeout(c.Context, 30*time.Second)
+		defer cancel()
+		if err := c.deleteGroupMessages(ctx, challenge.ChatID, []int{int(item.TelegramMessageID)}); err != nil {
+			log.Printf("⚠️ 删除验证提示失败: %v", err)
+			return
+		}
+		c.DB.Model(&item).Update("removed_at", time.Now())
+	}
+}
+
+// rejectMember 移出未通过验证的成员（配置了封禁时间时封禁）
+func (c *ClientV2) rejectMember(challenge *models.CaptchaChallenge) {
+	ctx, cancel := context.WithTimeout(c.Context, 30*time.Second)
+	defer cancel()
+
+	banMinutes := 0
+	if captcha, ok := c.loadCaptcha(challenge.GroupID); ok {
+		banMinutes = captcha.BanMinutes
+	}
+
+	channel, err := c.captchaChannel(ctx, challenge.ChatID)
+	if err == nil {
+		rights := tg.ChatBannedRights{ViewMessages: true}
+		if banMinutes > 0 {
+			rights.UntilDate = int(time.Now().Add(time.Duration(banMinutes) * time.Minute).Unix())
+		}
+		err = c.editBanned(ctx, channel, challenge.UserID, challenge.UserAccessHash, rights)
+		if err == nil && banMinutes == 0 {
+			// 解除封禁，成员可以重新加入
+			err = c.editBanned(ctx, channel, challenge.UserID, challenge.UserAccessHash, tg.ChatBannedRights{})
+		}
+	}
+	if err != nil {
+		log.Printf("❌ 移出未通过验证的成员失败 [群组ID: %d, 用户: %s]: %v", challenge.ChatID, challenge.UserName, err)
+		c.DB.Model(challenge).Update("error", "移出成员失败: "+err.Error())
+		return
+	}
+	log.Printf("🚪 已移出未通过验证的成员 [群组ID: %d, 用户: %s]", challenge.ChatID, challenge.UserName)
+}
+
+// captchaChannel 获取超级群组的 InputPeer（只有超级群组支持限制成员）
+func (c *ClientV2) captchaChannel(ctx context.Context, chatID int64) (*tg.InputPeerChannel, error) {
+	peer, err := c.resolvePeer(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	channel, ok := peer.(*tg.InputPeerChannel)
+	if !ok {
+		return nil, fmt.Errorf("普通群组不支持限制成员，请升级为超级群组")
+	}
+	return channel, nil
+}
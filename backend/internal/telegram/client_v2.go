@@ -2,6 +2,9 @@ package telegram
 
 import (
 	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
@@ -12,32 +15,96 @@ import (
 	"time"
 
 	"aibot/internal/ai"
+	"aibot/internal/sessionlock"
+	"aibot/internal/state"
 	"aibot/models"
 
 	"github.com/gotd/td/telegram"
 	"github.com/gotd/td/telegram/updates"
 	"github.com/gotd/td/tg"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ReplyGenerator AI回复生成（由 ai.Service 实现，测试中可替换）
+type ReplyGenerator interface {
+	GenerateReply(ctx context.Context, apiKey, model, systemPrompt, message string, contextMessages []ai.ChatMessage) (string, error)
+}
+
 // ClientV2 改进的Telegram客户端
 type ClientV2 struct {
 	ID             uint
 	Account        *models.Account
 	TGClient       *telegram.Client
 	DB             *gorm.DB
-	AIService      *ai.Service
+	AIService      ReplyGenerator
 	Context        context.Context
 	Cancel         context.CancelFunc
-	LastReplyTime  map[int64]time.Time
-	MessageContext map[int64][]MessageContext
 	SessionPath    string
 	AuthHelper     *AuthHelper // 认证助手
 	Logger         *Logger     // 日志记录器
 
-	// 消息缓冲区：每个群组的最近消息
-	messageBuffer     map[int64][]BufferedMessage
+	// 当前登录账号的 Telegram 身份（用于识别 @提及 和回复）
+	SelfID       int64
+	SelfUsername string
+
+	// 已发送消息的ID（每个群组保留最近若干条，用于识别"回复我的消息"）
+	ownMessageIDs     map[int64][]int
+	ownMessageIDsLock sync.Mutex
+
+	// 账号配置会被热更新，读写 Account 时加锁（处理协程使用 accountSettings 获取快照）
+	accountLock sync.RWMutex
+
+	// 群组处理协程（每个群组/话题一个，空闲或取消分配后退出）
+	groupWorkers     map[bufferKey]*groupWorker
+	groupWorkersLock sync.Mutex
+	// 同时处理的群组数量限制
+	groupSlots chan struct{}
+
+	// 发送限流器（处理 FLOOD_WAIT）
+	limiter *RateLimiter
+
+	// 机器人账号（Bot Token 登录）
+	bot bool
+	// 机器人每个群组的发送限流器
+	groupLimiters     map[int64]*RateLimiter
+	groupLimitersLock sync.Mutex
+	// 机器人已记录的群组（机器人无法拉取对话列表，收到更新时记录）
+	knownChats     map[int64]bool
+	knownChatsLock sync.Mutex
+
+	// 唤醒发送队列协程（有新消息入队时）
+	outboxWake chan struct{}
+
+	// 连接成功并开始接收更新时回调（由客户端守护设置）
+	onRunning func()
+
+	// 消息缓冲区：每个群组（开启话题时每个话题）的最近消息，修改后延迟保存到状态存储
+	messageBuffer     map[bufferKey][]BufferedMessage
 	messageBufferLock sync.Mutex
+	// 待保存的缓冲区（由 messageBufferLock 保护），保存时按顺序写入状态存储
+	bufferDirty    map[bufferKey]bool
+	bufferSaveLock sync.Mutex
+
+	// 运行状态存储（缓冲消息、回复状态、冷却时间；由管理器设置为共享存储）
+	store state.Store
+
+	// 群管刷屏检测
+	flood floodTracker
+
+	// 发起入群验证时去重（服务消息和成员变动更新可能同时到达）
+	captchaLock sync.Mutex
+
+	// 待发送的欢迎语（同一群组短时间内入群的成员合并欢迎）
+	welcomeBatches     map[int64]*welcomeBatch
+	welcomeBatchesLock sync.Mutex
+
+	// 更新管理器（pts/qts/seq 持久化在数据库中，重启后自动补齐离线期间的更新）
+	gaps *updates.Manager
+
+	// 每个频道最近一次收到实时推送的时间（长时间没有推送的频道才由轮询器兜底拉取）
+	lastPushAt     map[int64]time.Time
+	lastPushAtLock sync.Mutex
 }
 
 // MessageContext 消息上下文（用于构建AI对话历史）
@@ -48,10 +115,33 @@ type MessageContext struct {
 
 // BufferedMessage 缓冲的消息
 type BufferedMessage struct {
-	Content   string
-	Timestamp time.Time
+	Content      string
+	Timestamp    time.Time
+	MessageID    int    // Telegram 消息ID
+	SenderID       int64  // 发送者用户ID
+	SenderName     string // 发送者名称（可能为空）
+	SenderUsername string // 发送者用户名（不含@）
+	MessageType    string // 消息类型：text/photo/video/document/sticker/voice/other
+	ReplyToMsgID int    // 该消息回复的消息ID
+	TopicID      int    // 所在的论坛话题ID，0 表示 General 或未开启话题的群组
+	Trigger      string // 触发类型：mention/reply，空表示普通消息
 }
 
+// ownMessageIDsLimit 每个群组保留的已发送消息ID数量
+const ownMessageIDsLimit = 200
+
+// minSendInterval 同一账号两次发送之间的最小间隔
+const minSendInterval = time.Second
+
+// maxInboundAge 超过该时长的消息（如离线后补齐的历史消息）只存档，不再触发回复
+const maxInboundAge = 10 * time.Minute
+
+// sessionLockWait 获取会话文件锁的最长等待时间
+const sessionLockWait = 10 * time.Second
+
+// pollFallbackAfter 频道超过该时长没有实时推送时，由轮询器兜底拉取
+const pollFallbackAfter = 5 * time.Minute
+
 // NewClientV2 创建新的客户端（改进版）
 func NewClientV2(account *models.Account, db *gorm.DB, aiService *ai.Service) (*ClientV2, error) {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -59,6 +149,7 @@ func NewClientV2(account *models.Account, db *gorm.DB, aiService *ai.Service) (*
 	// 确保会话目录存在
 	sessionDir := filepath.Join("data", "sessions")
 	if err := os.MkdirAll(sessionDir, 0755); err != nil {
+		cancel()
 		return nil, fmt.Errorf("创建会话目录失败: %w", err)
 	}
 
@@ -72,10 +163,21 @@ func NewClientV2(account *models.Account, db *gorm.DB, aiService *ai.Service) (*
 		AIService:      aiService,
 		Context:        ctx,
 		Cancel:         cancel,
-		LastReplyTime:  make(map[int64]time.Time),
-		MessageContext: make(map[int64][]MessageContext),
 		SessionPath:    sessionPath,
-		messageBuffer:  make(map[int64][]BufferedMessage),
+		messageBuffer:  make(map[bufferKey][]BufferedMessage),
+		bufferDirty:    make(map[bufferKey]bool),
+
+		ownMessageIDs:     make(map[int64][]int),
+		groupWorkers:      make(map[bufferKey]*groupWorker),
+		welcomeBatches:    make(map[int64]*welcomeBatch),
+		store:             state.NewMemoryStore(),
+		groupSlots:        make(chan struct{}, maxConcurrentGroups),
+		limiter:           NewRateLimiter(minSendInterval),
+		lastPushAt:        make(map[int64]time.Time),
+		outboxWake:        make(chan struct{}, 1),
+		bot:               account.Type == AccountTypeBot,
+		groupLimiters:     make(map[int64]*RateLimiter),
+		knownChats:        make(map[int64]bool),
 	}
 
 	// 设置更新处理器（dispatcher）
@@ -86,7 +188,8 @@ func NewClientV2(account *models.Account, db *gorm.DB, aiService *ai.Service) (*
 		if msg, ok := u.Message.(*tg.Message); ok {
 			log.Printf("🔔 OnNewMessage: message_id=%d peer=%T content=%s", msg.ID, msg.PeerID, truncateStr(msg.Message, 50))
 		}
-		return clientV2.bufferMessage(u.Message)
+		clientV2.learnBotChats(ctx, e, false)
+		return clientV2.bufferMessage(u.Message, e.Users, "push")
 	})
 
 	// 处理频道 / 超级群的新消息
@@ -94,13 +197,71 @@ func NewClientV2(account *models.Account, db *gorm.DB, aiService *ai.Service) (*
 		if msg, ok := u.Message.(*tg.Message); ok {
 			log.Printf("🔔 OnNewChannelMessage: message_id=%d peer=%T content=%s", msg.ID, msg.PeerID, truncateStr(msg.Message, 50))
 		}
-		return clientV2.bufferMessage(u.Message)
+		clientV2.learnBotChats(ctx, e, false)
+		return clientV2.bufferMessage(u.Message, e.Users, "push")
 	})
 
-	// 创建 updates.Manager 并配置
+	// 处理消息编辑（普通群 / 超级群）
+	dispatcher.OnEditMessage(func(ctx context.Context, e tg.Entities, u *tg.UpdateEditMessage) error {
+		return clientV2.handleEditedMessage(u.Message)
+	})
+	dispatcher.OnEditChannelMessage(func(ctx context.Context, e tg.Entities, u *tg.UpdateEditChannelMessage) error {
+		return clientV2.handleEditedMessage(u.Message)
+	})
+
+	// 处理消息删除（普通群 / 超级群）
+	dispatcher.OnDeleteMessages(func(ctx context.Context, e tg.Entities, u *tg.UpdateDeleteMessages) error {
+		return clientV2.handleDeletedMessages(u.Messages)
+	})
+	dispatcher.OnDeleteChannelMessages(func(ctx context.Context, e tg.Entities, u *tg.UpdateDeleteChannelMessages) error {
+		return clientV2.handleDeletedChannelMessages(u.ChannelID, u.Messages)
+	})
+
+	// 处理成员入群（服务消息在大群中可能被隐藏，成员变动更新作为补充）
+	dispatcher.OnChannelParticipant(func(ctx context.Context, e tg.Entities, u *tg.UpdateChannelParticipant) error {
+		if u.UserID == clientV2.SelfID {
+			// 机器人自身被拉入、踢出或调整权限
+			clientV2.learnBotChats(ctx, e, true)
+			return nil
+		}
+		if _, ok := u.GetPrevParticipant(); ok {
+			return nil
+		}
+		if _, ok := u.GetNewParticipant(); ok {
+			clientV2.memberJoined(u.ChannelID, u.UserID, e.Users, time.Unix(int64(u.Date), 0))
+		}
+		return nil
+	})
+	dispatcher.OnChatParticipantAdd(func(ctx context.Context, e tg.Entities, u *tg.UpdateChatParticipantAdd) error {
+		clientV2.memberJoined(u.ChatID, u.UserID, e.Users, time.Unix(int64(u.Date), 0))
+		return nil
+	})
+
+	// 处理内联键盘的按钮回调（仅机器人账号）
+	dispatcher.OnBotCallbackQuery(func(ctx context.Context, e tg.Entities, u *tg.UpdateBotCallbackQuery) error {
+		clientV2.handleCallbackQuery(u, e.Users)
+		return nil
+	})
+
+	// 处理投票结果变化
+	dispatcher.OnMessagePoll(func(ctx context.Context, e tg.Entities, u *tg.UpdateMessagePoll) error {
+		clientV2.handlePollUpdate(u)
+		return nil
+	})
+
+	// 创建 updates.Manager 并配置（更新状态持久化到数据库）
+	stateStorage := NewUpdateStateStorage(db, account.ID)
 	gaps := updates.New(updates.Config{
-		Handler: dispatcher,
+		Handler:      dispatcher,
+		Storage:      stateStorage,
+		AccessHasher: stateStorage,
+		OnChannelTooLong: func(channelID int64) {
+			// 频道积压的更新过多无法补齐，交给轮询器拉取
+			log.Printf("⚠️ 频道 [%d] 更新积压过多，改由轮询拉取", channelID)
+			clientV2.clearPushTime(channelID)
+		},
 	})
+	clientV2.gaps = gaps
 
 	// 创建Telegram客户端，使用 UpdateHandler
 	client := telegram.NewClient(
@@ -143,10 +304,21 @@ func truncateStr(s string, n int) string {
 func (c *ClientV2) Start() error {
 	log.Printf("🚀 启动Telegram客户端 [账号ID: %d, 手机号: %s]", c.Account.ID, c.Account.PhoneNumber)
 
+	// 会话文件同一时间只能被一个进程使用（调试脚本等占用时等待重启）
+	lock, err := c.lockSession()
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
 	return c.TGClient.Run(c.Context, func(ctx context.Context) error {
-		// 检查是否已有会话
-		if _, err := os.Stat(c.SessionPath); os.IsNotExist(err) {
-			// 需要认证
+		if c.bot {
+			// 机器人使用 Bot Token 登录，无需验证码
+			if err := c.authenticateBot(ctx); err != nil {
+				return err
+			}
+		} else if _, err := os.Stat(c.SessionPath); os.IsNotExist(err) {
+			// 没有会话，需要认证
 			log.Printf("📱 首次登录，需要认证 [手机号: %s]", c.Account.PhoneNumber)
 
 			// 使用认证助手执行完整认证流程（验证码 / 2FA 密码）
@@ -156,9 +328,8 @@ func (c *ClientV2) Start() error {
 
 			if err := c.AuthHelper.Authenticate(ctx); err != nil {
 				log.Printf("❌ 认证失败: %v", err)
-				c.Account.Status = "error"
-				c.DB.Save(c.Account)
-				return fmt.Errorf("认证失败: %w", err)
+				c.saveAccountStatus("error", "")
+				return fmt.Errorf("认证失败: %v: %w", err, ErrNeedsLogin)
 			}
 
 			log.Printf("✅ 认证成功，会话已保存")
@@ -179,15 +350,25 @@ func (c *ClientV2) Start() error {
 
 		if user, ok := me[0].(*tg.User); ok {
 			log.Printf("✅ 登录成功: %s (@%s)", user.FirstName, user.Username)
+			c.SelfID = user.ID
+			c.SelfUsername = user.Username
 			
 			// 更新账号信息
-			c.Account.Nickname = user.FirstName
-			c.Account.Status = "online"
-			c.DB.Save(c.Account)
+			c.saveAccountStatus("online", user.FirstName)
+			if c.bot {
+				c.saveBotPrivacy(user)
+				go c.syncBotCommands(ctx)
+			}
 			
 			// 同步群组信息
 			go func() {
-				if err := SyncGroups(ctx, api, c.DB, c.Account.ID); err != nil {
+				var err error
+				if c.bot {
+					_, err = SyncBotGroups(ctx, api, c.DB, c.Account.ID, c.SelfID, c.limiter)
+				} else {
+					_, err = SyncGroups(ctx, api, c.DB, c.Account.ID, c.limiter)
+				}
+				if err != nil {
 					log.Printf("⚠️ 同步群组失败: %v", err)
 				}
 			}()
@@ -197,89 +378,357 @@ func (c *ClientV2) Start() error {
 
 		log.Printf("📡 开始监听消息... (UpdateHandler 已在客户端创建时设置)")
 
-		// 主动获取一次更新状态，确保更新流正确初始化
-		state, err := api.UpdatesGetState(ctx)
-		if err != nil {
-			log.Printf("⚠️ 获取更新状态失败: %v", err)
-		} else {
-			log.Printf("✅ 更新状态: pts=%d, qts=%d, seq=%d, date=%d", state.Pts, state.Qts, state.Seq, state.Date)
-		}
+		// 运行更新管理器：从数据库恢复更新状态，并通过 getDifference 补齐离线期间的更新
+		// 阻塞直到 ctx 结束
+		return c.gaps.Run(ctx, api, c.SelfID, updates.AuthOptions{
+			IsBot: c.bot,
+			OnStart: func(ctx context.Context) {
+				log.Printf("✅ 更新状态已恢复，开始接收更新")
+				if c.onRunning != nil {
+					c.onRunning()
+				}
 
-		// 启动消息处理定时器
-		go c.startMessageProcessor(ctx)
+				// 启动消息处理定时器
+				go c.startMessageProcessor(ctx)
 
-		// 启动轮询器（主动拉取大型群组的消息）
-		go c.startGroupPoller(ctx, api)
+				// 启动轮询器（兜底拉取没有实时推送的频道；机器人无法拉取历史消息）
+				if !c.bot {
+					go c.startGroupPoller(ctx, api)
+				}
+
+				// 启动发送队列协程
+				go c.startOutboxWorker(ctx)
 
-		// 阻塞等待 ctx 结束（UpdateHandler 已在 NewClientV2 中通过 telegram.Options 设置）
-		<-ctx.Done()
-		return ctx.Err()
+				// 恢复未完成的入群验证
+				go c.resumeCaptchas()
+			},
+		})
 	})
 }
 
-// bufferMessage 将消息添加到缓冲区
-func (c *ClientV2) bufferMessage(msg tg.MessageClass) error {
+// lockSession 获取会话文件锁；重启时上一个客户端可能还在退出，短暂等待其释放
+func (c *ClientV2) lockSession() (*sessionlock.Lock, error) {
+	deadline := time.Now().Add(sessionLockWait)
+	for {
+		lock, err := sessionlock.Acquire(c.SessionPath)
+		if err == nil || !errors.Is(err, sessionlock.ErrLocked) || time.Now().After(deadline) {
+			return lock, err
+		}
+
+		select {
+		case <-c.Context.Done():
+			return nil, c.Context.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// bufferMessage 将推送的消息添加到缓冲区
+func (c *ClientV2) bufferMessage(msg tg.MessageClass, users map[int64]*tg.User, source string) error {
+	// 服务消息（成员入群等）
+	if service, ok := msg.(*tg.MessageService); ok {
+		c.handleServiceMessage(service, users)
+		return nil
+	}
+
 	message, ok := msg.(*tg.Message)
 	if !ok {
 		return nil
 	}
 
+	// 获取群组ID（私聊消息暂不处理）
+	chatID, ok := messageChatID(message)
+	if !ok {
+		return nil
+	}
+	if _, isChannel := message.PeerID.(*tg.PeerChannel); isChannel {
+		c.recordPushTime(chatID)
+	}
+
+	c.ingestMessage(chatID, message, users, source)
+	return nil
+}
+
+// ingestMessage 存档并缓冲一条群组消息
+// 同一条消息（群组ID + 消息ID）可能同时来自实时推送和轮询，只有首次存档成功的才会进入缓冲区
+func (c *ClientV2) ingestMessage(chatID int64, message *tg.Message, users map[int64]*tg.User, source string) {
 	// 跳过自己的消息
 	if message.Out {
-		return nil
+		return
 	}
 
-	// 获取消息文本
-	messageText := message.Message
-	if messageText == "" {
-		return nil
+	// 获取消息文本（无文字的媒体消息也会缓冲，用于规则匹配消息类型）
+	if message.Message == "" && messageMediaType(message.Media) == "text" {
+		return
 	}
 
-	// 获取群组ID
-	peer := message.PeerID
-	var chatID int64
-	switch p := peer.(type) {
-	case *tg.PeerChannel:
-		chatID = int64(p.ChannelID)
-	case *tg.PeerChat:
-		chatID = int64(p.ChatID)
-	case *tg.PeerUser:
-		return nil // 私聊消息暂不处理
-	default:
-		return nil
+	// 只处理分配给当前账号的群组
+	accountGroup, ok := c.getGroupAssignment(chatID)
+	if !ok {
+		return
+	}
+
+	buffered := c.newBufferedMessage(chatID, message, users)
+	if !c.archiveInbound(chatID, accountGroup.GroupID, message, buffered, source) {
+		return
+	}
+
+	// 离线期间补齐的旧消息只存档，不再回复
+	sentAt := time.Unix(int64(message.Date), 0)
+	if time.Since(sentAt) > maxInboundAge {
+		log.Printf("🗄️ 消息已过期，仅存档 [群组ID: %d, 消息ID: %d, 发送时间: %s]", chatID, message.ID, sentAt.Format("2006-01-02 15:04:05"))
+		return
 	}
 
-	// 添加到缓冲区
+	// 待验证成员的消息由入群验证处理
+	if c.checkCaptchaAnswer(accountGroup.GroupID, message, buffered) {
+		return
+	}
+
+	// 账号是群管理员时执行群管规则，可能被删除的消息由群管处理后决定是否回复
+	if c.moderate(chatID, accountGroup, message, buffered, users) {
+		return
+	}
+
+	c.dispatchInbound(chatID, accountGroup, buffered)
+}
+
+// dispatchInbound 斜杠命令单独处理，其余消息进入缓冲区等待回复
+func (c *ClientV2) dispatchInbound(chatID int64, accountGroup *models.AccountGroup, buffered BufferedMessage) {
+	if c.handleCommand(chatID, accountGroup, buffered) {
+		return
+	}
+	c.appendToBuffer(chatID, buffered)
+}
+
+// archiveInbound 存档收到的消息，返回是否为首次收到
+func (c *ClientV2) archiveInbound(chatID int64, groupID uint, message *tg.Message, buffered BufferedMessage, source string) bool {
+	record := models.InboundMessage{
+		AccountID:      c.Account.ID,
+		GroupID:        groupID,
+		ChatID:         chatID,
+		MessageID:      message.ID,
+		SenderID:       buffered.SenderID,
+		SenderName:     buffered.SenderName,
+		SenderUsername: buffered.SenderUsername,
+		Content:        message.Message,
+		MessageType:    buffered.MessageType,
+		ReplyToMsgID:   buffered.ReplyToMsgID,
+		TopicID:        buffered.TopicID,
+		Trigger:        buffered.Trigger,
+		Source:         source,
+		SentAt:         time.Unix(int64(message.Date), 0),
+	}
+
+	result := c.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		// 存档失败时不丢消息，由缓冲区按消息ID去重
+		log.Printf("⚠️ 存档消息失败 [群组ID: %d, 消息ID: %d]: %v", chatID, message.ID, result.Error)
+		return true
+	}
+	return result.RowsAffected > 0
+}
+
+// recordPushTime 记录频道最近一次收到实时推送的时间
+func (c *ClientV2) recordPushTime(channelID int64) {
+	c.lastPushAtLock.Lock()
+	defer c.lastPushAtLock.Unlock()
+	c.lastPushAt[channelID] = time.Now()
+}
+
+// clearPushTime 清除频道的推送时间，使其在下一轮由轮询器拉取
+func (c *ClientV2) clearPushTime(channelID int64) {
+	c.lastPushAtLock.Lock()
+	defer c.lastPushAtLock.Unlock()
+	delete(c.lastPushAt, channelID)
+}
+
+// receivesPush 判断频道最近是否收到过实时推送
+func (c *ClientV2) receivesPush(channelID int64) bool {
+	c.lastPushAtLock.Lock()
+	defer c.lastPushAtLock.Unlock()
+	last, ok := c.lastPushAt[channelID]
+	return ok && time.Since(last) < pollFallbackAfter
+}
+
+// newBufferedMessage 构造缓冲消息，并识别是否 @提及 或回复了当前账号
+func (c *ClientV2) newBufferedMessage(chatID int64, message *tg.Message, users map[int64]*tg.User) BufferedMessage {
+	buffered := BufferedMessage{
+		Content:     message.Message,
+		Timestamp:   time.Now(),
+		MessageID:   message.ID,
+		MessageType: messageMediaType(message.Media),
+	}
+	if buffered.Content == "" {
+		buffered.Content = mediaPlaceholder(buffered.MessageType)
+	}
+
+	if from, ok := message.FromID.(*tg.PeerUser); ok {
+		buffered.SenderID = from.UserID
+		if user, ok := users[from.UserID]; ok {
+			buffered.SenderName = strings.TrimSpace(user.FirstName + " " + user.LastName)
+			buffered.SenderUsername = user.Username
+		}
+	}
+
+	if header, ok := message.ReplyTo.(*tg.MessageReplyHeader); ok {
+		buffered.TopicID, buffered.ReplyToMsgID = messageTopic(header)
+	}
+
+	switch {
+	case buffered.ReplyToMsgID > 0 && c.isOwnMessage(chatID, buffered.ReplyToMsgID):
+		buffered.Trigger = "reply"
+	case c.mentionsSelf(message):
+		buffered.Trigger = "mention"
+	case message.Mentioned && buffered.ReplyToMsgID > 0:
+		// 服务端标记了 mentioned 且是一条回复：回复的是我们更早发送（已不在缓存中）的消息
+		buffered.Trigger = "reply"
+	}
+
+	return buffered
+}
+
+// mentionsSelf 判断消息是否 @提及 了当前账号（实体或用户名）
+func (c *ClientV2) mentionsSelf(message *tg.Message) bool {
+	if message.Mentioned && message.ReplyTo == nil {
+		return true
+	}
+
+	for _, entity := range message.Entities {
+		if e, ok := entity.(*tg.MessageEntityMentionName); ok && c.SelfID != 0 && e.UserID == c.SelfID {
+			return true
+		}
+	}
+
+	if c.SelfUsername != "" {
+		return strings.Contains(strings.ToLower(message.Message), "@"+strings.ToLower(c.SelfUsername))
+	}
+	return false
+}
+
+// appendToBuffer 将消息追加到群组（话题）缓冲区，超出缓冲数量时丢弃最早的普通消息
+func (c *ClientV2) appendToBuffer(chatID int64, buffered BufferedMessage) {
 	c.messageBufferLock.Lock()
 	defer c.messageBufferLock.Unlock()
 
-	if c.messageBuffer[chatID] == nil {
-		c.messageBuffer[chatID] = make([]BufferedMessage, 0)
+	key := bufferKey{chatID: chatID, topicID: buffered.TopicID}
+	if c.messageBuffer[key] == nil {
+		c.messageBuffer[key] = make([]BufferedMessage, 0)
 	}
 
-	c.messageBuffer[chatID] = append(c.messageBuffer[chatID], BufferedMessage{
-		Content:   messageText,
-		Timestamp: time.Now(),
-	})
+	// 同一条消息已在缓冲区中（存档不可用时的兜底去重）
+	for _, msg := range c.messageBuffer[key] {
+		if buffered.MessageID > 0 && msg.MessageID == buffered.MessageID {
+			return
+		}
+	}
+
+	c.messageBuffer[key] = append(c.messageBuffer[key], buffered)
 
 	// 只保留最近N条消息（使用账号配置的缓冲数量）
-	bufferSize := c.Account.BufferSize
+	bufferSize := c.accountSettings().BufferSize
 	if bufferSize <= 0 {
 		bufferSize = 10 // 默认10条
 	}
-	if len(c.messageBuffer[chatID]) > bufferSize {
-		c.messageBuffer[chatID] = c.messageBuffer[chatID][len(c.messageBuffer[chatID])-bufferSize:]
+	c.messageBuffer[key] = trimBuffer(c.messageBuffer[key], bufferSize)
+	c.saveBuffer(key)
+
+	if buffered.Trigger != "" {
+		log.Printf("📣 收到%s [群组ID: %s, 消息ID: %d]: %s", triggerLabel(buffered.Trigger), key, buffered.MessageID, truncateStr(buffered.Content, 50))
+	} else {
+		log.Printf("📥 消息已缓冲 [群组ID: %s, 缓冲数量: %d]: %s", key, len(c.messageBuffer[key]), truncateStr(buffered.Content, 50))
 	}
+}
 
-	log.Printf("📥 消息已缓冲 [群组ID: %d, 缓冲数量: %d]: %s", chatID, len(c.messageBuffer[chatID]), truncateStr(messageText, 50))
+// trimBuffer 裁剪缓冲区到指定数量，优先丢弃最早的普通消息，触发消息（@提及/回复我）不会被挤掉
+func trimBuffer(messages []BufferedMessage, size int) []BufferedMessage {
+	for len(messages) > size {
+		dropped := false
+		for i, msg := range messages {
+			if msg.Trigger == "" {
+				messages = append(messages[:i], messages[i+1:]...)
+				dropped = true
+				break
+			}
+		}
+		if !dropped {
+			messages = messages[len(messages)-size:]
+		}
+	}
+	return messages
+}
 
-	return nil
+// triggerLabel 触发类型的中文描述
+func triggerLabel(trigger string) string {
+	if trigger == "reply" {
+		return "回复我的消息"
+	}
+	return "@提及"
 }
 
-// startGroupPoller 启动群组消息轮询器（用于大型群组）
+// rememberOwnMessage 记录已发送消息的ID
+func (c *ClientV2) rememberOwnMessage(chatID int64, msgID int) {
+	if msgID <= 0 {
+		return
+	}
+	c.ownMessageIDsLock.Lock()
+	defer c.ownMessageIDsLock.Unlock()
+
+	ids := append(c.ownMessageIDs[chatID], msgID)
+	if len(ids) > ownMessageIDsLimit {
+		ids = ids[len(ids)-ownMessageIDsLimit:]
+	}
+	c.ownMessageIDs[chatID] = ids
+}
+
+// isOwnMessage 判断消息ID是否为当前账号最近发送的消息
+func (c *ClientV2) isOwnMessage(chatID int64, msgID int) bool {
+	c.ownMessageIDsLock.Lock()
+	defer c.ownMessageIDsLock.Unlock()
+
+	for _, id := range c.ownMessageIDs[chatID] {
+		if id == msgID {
+			return true
+		}
+	}
+	return false
+}
+
+// sentMessageID 从发送结果中提取新消息的ID
+func sentMessageID(updates tg.UpdatesClass) int {
+	switch u := updates.(type) {
+	case *tg.UpdateShortSentMessage:
+		return u.ID
+	case *tg.Updates:
+		return sentMessageIDFromUpdates(u.Updates)
+	case *tg.UpdatesCombined:
+		return sentMessageIDFromUpdates(u.Updates)
+	}
+	return 0
+}
+
+// sentMessageIDFromUpdates 从更新列表中查找新发送的消息ID
+func sentMessageIDFromUpdates(updates []tg.UpdateClass) int {
+	for _, update := range updates {
+		switch u := update.(type) {
+		case *tg.UpdateNewChannelMessage:
+			return u.Message.GetID()
+		case *tg.UpdateNewMessage:
+			return u.Message.GetID()
+		}
+	}
+	for _, update := range updates {
+		if u, ok := update.(*tg.UpdateMessageID); ok {
+			return u.ID
+		}
+	}
+	return 0
+}
+
+// startGroupPoller 启动群组消息轮询器（兜底拉取没有实时推送的超级群组）
 func (c *ClientV2) startGroupPoller(ctx context.Context, api *tg.Client) {
 	// 轮询间隔（使用监听间隔配置）
-	pollInterval := c.Account.ListenInterval
+	pollInterval := c.accountSettings().ListenInterval
 	if pollInterval <= 0 {
 		pollInterval = 30
 	}
@@ -323,10 +772,15 @@ func (c *ClientV2) pollAssignedGroups(ctx context.Context, api *tg.Client, lastM
 			continue
 		}
 
-		// 构造 Peer
+		// 最近有实时推送的频道无需轮询
+		if c.receivesPush(group.ChatID) {
+			continue
+		}
+
+		// 构造 Peer（AccessHash 因账号而异）
 		peer := &tg.InputPeerChannel{
 			ChannelID:  group.ChatID,
-			AccessHash: group.AccessHash,
+			AccessHash: channelAccessHash(c.DB, c.ID, c.SelfID, &group),
 		}
 
 		// 获取最近消息
@@ -340,6 +794,7 @@ func (c *ClientV2) pollAssignedGroups(ctx context.Context, api *tg.Client, lastM
 		}
 
 		var messages []*tg.Message
+		users := make(map[int64]*tg.User)
 		switch h := history.(type) {
 		case *tg.MessagesChannelMessages:
 			for _, msg := range h.Messages {
@@ -347,57 +802,59 @@ func (c *ClientV2) pollAssignedGroups(ctx context.Context, api *tg.Client, lastM
 					messages = append(messages, m)
 				}
 			}
+			for _, u := range h.Users {
+				if user, ok := u.(*tg.User); ok {
+					users[user.ID] = user
+				}
+			}
+		}
+
+		// 首次轮询该群组：从存档中恢复最后的消息ID；没有存档时以当前最新消息为起点，不处理历史消息
+		lastID, polled := lastMsgIDs[group.ChatID]
+		if !polled {
+			lastID = c.lastArchivedMessageID(group.ChatID)
+			if lastID == 0 {
+				for _, msg := range messages {
+					if msg.ID > lastID {
+						lastID = msg.ID
+					}
+				}
+			}
+			lastMsgIDs[group.ChatID] = lastID
 		}
 
 		// 处理新消息
-		lastID := lastMsgIDs[group.ChatID]
 		for _, msg := range messages {
 			// 跳过已处理的消息
 			if msg.ID <= lastID {
 				continue
 			}
-			// 跳过自己的消息
-			if msg.Out {
-				continue
-			}
-			// 跳过空消息
-			if msg.Message == "" {
-				continue
-			}
-
 			// 更新最后消息ID
 			if msg.ID > lastMsgIDs[group.ChatID] {
 				lastMsgIDs[group.ChatID] = msg.ID
 			}
 
-			// 添加到缓冲区
-			c.messageBufferLock.Lock()
-			if c.messageBuffer[group.ChatID] == nil {
-				c.messageBuffer[group.ChatID] = make([]BufferedMessage, 0)
-			}
-			c.messageBuffer[group.ChatID] = append(c.messageBuffer[group.ChatID], BufferedMessage{
-				Content:   msg.Message,
-				Timestamp: time.Now(),
-			})
-			// 限制缓冲区大小
-			bufferSize := c.Account.BufferSize
-			if bufferSize <= 0 {
-				bufferSize = 10
-			}
-			if len(c.messageBuffer[group.ChatID]) > bufferSize {
-				c.messageBuffer[group.ChatID] = c.messageBuffer[group.ChatID][len(c.messageBuffer[group.ChatID])-bufferSize:]
-			}
-			c.messageBufferLock.Unlock()
-
-			log.Printf("📥 [轮询] 消息已缓冲 [%s, ID: %d]: %s", group.Title, msg.ID, truncateStr(msg.Message, 50))
+			// 存档并添加到缓冲区（已通过推送收到的消息会被跳过）
+			log.Printf("📥 [轮询] 拉取到新消息 [%s, ID: %d]", group.Title, msg.ID)
+			c.ingestMessage(group.ChatID, msg, users, "poll")
 		}
 	}
 }
 
+// lastArchivedMessageID 获取群组已存档的最大消息ID
+func (c *ClientV2) lastArchivedMessageID(chatID int64) int {
+	var lastID int
+	c.DB.Model(&models.InboundMessage{}).
+		Where("account_id = ? AND chat_id = ?", c.Account.ID, chatID).
+		Select("COALESCE(MAX(message_id), 0)").
+		Scan(&lastID)
+	return lastID
+}
+
 // startMessageProcessor 启动消息处理定时器
 func (c *ClientV2) startMessageProcessor(ctx context.Context) {
 	// 使用账号配置的监听间隔
-	listenInterval := c.Account.ListenInterval
+	listenInterval := c.accountSettings().ListenInterval
 	if listenInterval <= 0 {
 		listenInterval = 5 // 默认5秒
 	}
@@ -413,7 +870,9 @@ func (c *ClientV2) startMessageProcessor(ctx context.Context) {
 			log.Printf("⏰ 消息处理定时器已停止")
 			return
 		case <-ticker.C:
-			c.processBufferedMessages(ctx)
+			// 🔄 热更新：每次处理前重新加载账号配置
+			c.reloadAccountConfig()
+			c.dispatchBufferedGroups(ctx)
 		}
 	}
 }
@@ -426,6 +885,8 @@ func (c *ClientV2) reloadAccountConfig() {
 		return
 	}
 	// 更新配置（保留运行时状态如 Status）
+	c.accountLock.Lock()
+	defer c.accountLock.Unlock()
 	c.Account.SystemPrompt = account.SystemPrompt
 	c.Account.AIApiKey = account.AIApiKey
 	c.Account.AIModel = account.AIModel
@@ -439,129 +900,202 @@ func (c *ClientV2) reloadAccountConfig() {
 	c.Account.Enabled = account.Enabled
 	c.Account.Priority = account.Priority
 	c.Account.Tone = account.Tone
+	c.Account.ReplyToMentions = account.ReplyToMentions
+	c.Account.MentionReplyLimit = account.MentionReplyLimit
 }
 
-// processBufferedMessages 处理缓冲区中的消息
-func (c *ClientV2) processBufferedMessages(ctx context.Context) {
-	// 🔄 热更新：每次处理前重新加载账号配置
-	c.reloadAccountConfig()
+// processGroupMessages 处理一个群组的缓冲消息（在该群组的处理协程中执行）
+func (c *ClientV2) processGroupMessages(ctx context.Context, w *groupWorker, messages []BufferedMessage) {
+	chatID := w.chatID
 
-	c.messageBufferLock.Lock()
-	// 复制一份缓冲区数据，然后清空
-	buffersToProcess := make(map[int64][]BufferedMessage)
-	for chatID, messages := range c.messageBuffer {
-		if len(messages) > 0 {
-			buffersToProcess[chatID] = messages
-			c.messageBuffer[chatID] = make([]BufferedMessage, 0)
-		}
+	// 🔒 关键检查：验证这个群组是否被分配给当前账号，并获取群组配置
+	accountGroup, ok := c.getGroupAssignment(chatID)
+	if !ok {
+		// 不打印日志，避免刷屏（因为会有很多未分配的群）
+		return
 	}
-	c.messageBufferLock.Unlock()
 
-	// 处理每个群组的消息
-	for chatID, messages := range buffersToProcess {
-		if len(messages) == 0 {
-			continue
-		}
+	// 检查群组级别是否启用
+	if !accountGroup.Enabled {
+		return
+	}
 
-		// 🔒 关键检查：验证这个群组是否被分配给当前账号，并获取群组配置
-		accountGroup, ok := c.getGroupAssignment(chatID)
-		if !ok {
-			// 不打印日志，避免刷屏（因为会有很多未分配的群）
-			continue
-		}
+	// 检查话题级别是否启用
+	topic := topicSetting(accountGroup, w.topicID)
+	if topic != nil && !topic.Enabled {
+		return
+	}
 
-		// 检查群组级别是否启用
-		if !accountGroup.Enabled {
-			continue
-		}
+	// 检查是否启用自动回复（账号级别）
+	if !w.account.AutoReply {
+		log.Printf("⏸️ 群组 [%s] 自动回复已关闭，跳过", w.key())
+		return
+	}
 
-		// 检查是否启用自动回复（账号级别）
-		if !c.Account.AutoReply {
-			log.Printf("⏸️ 群组 [%d] 自动回复已关闭，跳过", chatID)
-			continue
-		}
+	// 先执行回复规则（关键词/正则触发的固定回复、AI回复、忽略、人工审核）
+	messages = c.applyRules(ctx, w, accountGroup.GroupID, messages)
+	if len(messages) == 0 {
+		return
+	}
 
-		// 检查发言间隔
-		replyInterval := c.Account.ReplyInterval
-		if replyInterval <= 0 {
-			replyInterval = 60 // 默认60秒
-		}
-		if lastTime, ok := c.LastReplyTime[chatID]; ok {
-			if time.Since(lastTime).Seconds() < float64(replyInterval) {
-				log.Printf("⏳ 群组 [%d] 发言间隔未到（需要%d秒），跳过", chatID, replyInterval)
-				continue
-			}
+	// 优先处理 @提及 和回复我的消息（不受发言间隔和概率限制）
+	messages = c.processTriggeredMessages(ctx, w, messages)
+	if len(messages) == 0 {
+		return
+	}
+
+	// 检查发言间隔
+	replyInterval := w.account.ReplyInterval
+	if replyInterval <= 0 {
+		replyInterval = 60 // 默认60秒
+	}
+	if !w.lastReplyTime.IsZero() && time.Since(w.lastReplyTime).Seconds() < float64(replyInterval) {
+		log.Printf("⏳ 群组 [%s] 发言间隔未到（需要%d秒），跳过", w.key(), replyInterval)
+		return
+	}
+
+	// 检查回复概率（优先使用话题级别、群组级别配置，否则使用账号级别配置）
+	replyProbability := int(accountGroup.ReplyProbability * 100) // 群组配置是0-1的小数
+	if topic != nil && topic.ReplyProbability > 0 {
+		replyProbability = int(topic.ReplyProbability * 100)
+	}
+	if replyProbability <= 0 {
+		replyProbability = w.account.ReplyProbability // 回退到账号级别配置
+	}
+	if replyProbability <= 0 {
+		replyProbability = 100 // 默认100%
+	}
+	if rand.Intn(100) >= replyProbability {
+		log.Printf("🎲 群组 [%s] 概率判定不回复（概率%d%%），跳过", w.key(), replyProbability)
+		return
+	}
+
+	// 合并所有消息内容
+	var allMessages []string
+	for _, msg := range messages {
+		allMessages = append(allMessages, msg.Content)
+	}
+	combinedContent := strings.Join(allMessages, "\n---\n")
+
+	log.Printf("🔄 处理群组 [%s] 的 %d 条消息", w.key(), len(messages))
+
+	// 生成AI回复（基于所有最近消息）
+	reply, err := c.AIService.GenerateReply(
+		ctx,
+		w.account.AIApiKey,
+		w.account.AIModel,
+		w.account.SystemPrompt,
+		fmt.Sprintf("以下是群里最近的聊天内容，请根据这些内容发表你的观点或参与讨论（直接输出你想说的话，不要引用或回复特定消息）：\n\n%s", combinedContent),
+		w.chatHistory(),
+	)
+	if err != nil {
+		log.Printf("❌ 生成回复失败: %v", err)
+		return
+	}
+
+	if reply == "" {
+		log.Printf("⚠️ AI未生成回复内容")
+		return
+	}
+
+	// 加入发送队列（支持拆分多条）
+	if err := c.enqueueReply(chatID, w.topicID, reply, 0, "auto"); err != nil {
+		log.Printf("❌ 发送消息失败: %v", err)
+		return
+	}
+
+	// 更新状态
+	w.lastReplyTime = time.Now()
+	w.addHistory(combinedContent, reply)
+
+	log.Printf("✅ 观点已加入发送队列: %s", truncateStr(reply, 100))
+}
+
+// processTriggeredMessages 回复 @提及 和回复我的消息，返回剩余的普通消息
+func (c *ClientV2) processTriggeredMessages(ctx context.Context, w *groupWorker, messages []BufferedMessage) []BufferedMessage {
+	chatID := w.chatID
+	var triggered, normal []BufferedMessage
+	for _, msg := range messages {
+		if msg.Trigger != "" && msg.MessageID > 0 {
+			triggered = append(triggered, msg)
+		} else {
+			normal = append(normal, msg)
 		}
+	}
+	if len(triggered) == 0 {
+		return normal
+	}
+	if !w.account.ReplyToMentions {
+		// 未开启优先回复时，触发消息按普通消息处理
+		return messages
+	}
 
-		// 检查回复概率（优先使用群组级别配置，否则使用账号级别配置）
-		replyProbability := int(accountGroup.ReplyProbability * 100) // 群组配置是0-1的小数
-		if replyProbability <= 0 {
-			replyProbability = c.Account.ReplyProbability // 回退到账号级别配置
+	for _, msg := range triggered {
+		if !w.allowTriggerReply() {
+			log.Printf("⏳ 群组 [%s] 本小时触发回复已达上限（%d条），跳过消息 [ID: %d]", w.key(), w.mentionReplyLimit(), msg.MessageID)
+			continue
 		}
-		if replyProbability <= 0 {
-			replyProbability = 100 // 默认100%
+
+		sender := msg.SenderName
+		if sender == "" {
+			sender = "群友"
 		}
-		if rand.Intn(100) >= replyProbability {
-			log.Printf("🎲 群组 [%d] 概率判定不回复（概率%d%%），跳过", chatID, replyProbability)
-			continue
+		recent := make([]string, 0, len(normal))
+		for _, m := range normal {
+			recent = append(recent, m.Content)
 		}
 
-		// 合并所有消息内容
-		var allMessages []string
-		for _, msg := range messages {
-			allMessages = append(allMessages, msg.Content)
+		prompt := fmt.Sprintf("群里有人%s，请直接回复TA（直接输出你想说的话）：\n\n%s：%s", triggerPromptLabel(msg.Trigger), sender, msg.Content)
+		if len(recent) > 0 {
+			prompt += fmt.Sprintf("\n\n以下是群里最近的其他聊天内容，仅供参考：\n\n%s", strings.Join(recent, "\n---\n"))
 		}
-		combinedContent := strings.Join(allMessages, "\n---\n")
 
-		log.Printf("🔄 处理群组 [%d] 的 %d 条消息", chatID, len(messages))
+		log.Printf("📣 回复%s [群组ID: %s, 消息ID: %d]", triggerLabel(msg.Trigger), w.key(), msg.MessageID)
 
-		// 生成AI回复（基于所有最近消息）
 		reply, err := c.AIService.GenerateReply(
 			ctx,
-			c.Account.AIApiKey,
-			c.Account.AIModel,
-			c.Account.SystemPrompt,
-			fmt.Sprintf("以下是群里最近的聊天内容，请根据这些内容发表你的观点或参与讨论（直接输出你想说的话，不要引用或回复特定消息）：\n\n%s", combinedContent),
-			c.getMessageContext(chatID),
+			w.account.AIApiKey,
+			w.account.AIModel,
+			w.account.SystemPrompt,
+			prompt,
+			w.chatHistory(),
 		)
 		if err != nil {
 			log.Printf("❌ 生成回复失败: %v", err)
 			continue
 		}
-
 		if reply == "" {
 			log.Printf("⚠️ AI未生成回复内容")
 			continue
 		}
 
-		// 发送消息（支持拆分多条）
-		if err := c.sendReplyWithSplit(ctx, chatID, reply); err != nil {
+		if err := c.enqueueReply(chatID, w.topicID, reply, msg.MessageID, "trigger"); err != nil {
 			log.Printf("❌ 发送消息失败: %v", err)
 			continue
 		}
 
-		// 更新状态
-		c.LastReplyTime[chatID] = time.Now()
-		c.addMessageContext(chatID, combinedContent, reply)
-		c.saveMessageDirect(chatID, reply)
+		w.triggerReplyTimes = append(w.triggerReplyTimes, time.Now())
+		w.addHistory(fmt.Sprintf("%s：%s", sender, msg.Content), reply)
 
-		log.Printf("✅ 已发送观点: %s", truncateStr(reply, 100))
+		log.Printf("✅ %s的回复已加入发送队列: %s", triggerLabel(msg.Trigger), truncateStr(reply, 100))
 	}
+
+	return normal
 }
 
-// sendReplyWithSplit 发送回复（支持按换行拆分成多条消息）
-func (c *ClientV2) sendReplyWithSplit(ctx context.Context, chatID int64, reply string) error {
-	// 检查是否启用拆分
-	if !c.Account.SplitByNewline {
-		// 不拆分，直接发送
-		return c.sendMessageDirect(ctx, chatID, reply)
+// triggerPromptLabel 触发类型在提示词中的描述
+func triggerPromptLabel(trigger string) string {
+	if trigger == "reply" {
+		return "回复了你之前发的消息"
 	}
+	return "@了你"
+}
 
-	// 按换行符拆分消息
+// splitReply 按换行拆分回复，合并空行和过短的行
+func splitReply(reply string) []string {
 	lines := strings.Split(reply, "\n")
 	var messageParts []string
 
-	// 合并空行和过短的行
 	var currentPart string
 	for _, line := range lines {
 		trimmed := strings.TrimSpace(line)
@@ -581,173 +1115,167 @@ func (c *ClientV2) sendReplyWithSplit(ctx context.Context, chatID int64, reply s
 	if currentPart != "" {
 		messageParts = append(messageParts, currentPart)
 	}
+	return messageParts
+}
 
-	// 如果只有一条消息，直接发送
-	if len(messageParts) <= 1 {
-		return c.sendMessageDirect(ctx, chatID, reply)
-	}
+// resolvePeer 根据 ChatID 构造发送用的 InputPeer
+func (c *ClientV2) resolvePeer(ctx context.Context, chatID int64) (tg.InputPeerClass, error) {
+	api := c.TGClient.API()
 
-	// 获取多消息发送间隔
-	interval := c.Account.MultiMsgInterval
-	if interval <= 0 {
-		interval = 5 // 默认5秒
+	// 从数据库获取群组信息（优先用于区分普通群/频道以及AccessHash）
+	var group models.Group
+	if err := c.DB.Where("chat_id = ?", chatID).First(&group).Error; err != nil {
+		// 数据库中没有群组记录，回退为按 ChatID 直接发送（适用于普通群）
+		log.Printf("⚠️ 未在数据库找到群组 [ID: %d]，尝试按普通群直接发送", chatID)
+		return &tg.InputPeerChat{ChatID: chatID}, nil
 	}
 
-	log.Printf("📤 将发送 %d 条拆分消息，间隔 %d 秒", len(messageParts), interval)
-
-	// 逐条发送
-	for i, part := range messageParts {
-		if err := c.sendMessageDirect(ctx, chatID, part); err != nil {
-			log.Printf("❌ 发送第 %d 条消息失败: %v", i+1, err)
-			return err
+	// 根据群组类型构造Peer
+	if group.Type == "channel" || group.Type == "supergroup" {
+		// Channel或Supergroup需要AccessHash（因账号而异，优先使用当前账号自己的）
+		group.AccessHash = channelAccessHash(c.DB, c.ID, c.SelfID, &group)
+		if group.AccessHash == 0 {
+			log.Printf("⚠️ 群组 [ID: %d] 缺少AccessHash，尝试获取", chatID)
+			// 尝试获取AccessHash
+			accessHash, err := GetGroupAccessHash(ctx, api, chatID)
+			if err != nil {
+				log.Printf("⚠️ 无法获取AccessHash: %v", err)
+				return nil, fmt.Errorf("需要AccessHash才能发送消息到Channel/Supergroup")
+			}
+			group.AccessHash = accessHash
+			c.DB.Save(&group)
 		}
-		log.Printf("📨 已发送第 %d/%d 条: %s", i+1, len(messageParts), truncateStr(part, 50))
 
-		// 如果不是最后一条，等待间隔
-		if i < len(messageParts)-1 {
-			time.Sleep(time.Duration(interval) * time.Second)
+		// ChannelID 在 Telegram 中为正整数，这里做一次绝对值转换，兼容数据库中可能保存的负数ID
+		channelID := chatID
+		if channelID < 0 {
+			channelID = -channelID
 		}
+		return &tg.InputPeerChannel{
+			ChannelID:  channelID,
+			AccessHash: group.AccessHash,
+		}, nil
 	}
 
-	return nil
+	// 普通群组
+	chat := chatID
+	if chat < 0 {
+		chat = -chat
+	}
+	return &tg.InputPeerChat{
+		ChatID: chat,
+	}, nil
 }
 
-// sendMessageDirect 直接发送消息（不引用任何消息）
-func (c *ClientV2) sendMessageDirect(ctx context.Context, chatID int64, text string) error {
+// sendMessage 发送消息（带重试机制），返回新消息的 Telegram 消息ID
+// randomID 为0时自动生成；队列重发时复用同一个 randomID，由 Telegram 识别重复发送
+// topicID 大于0时发送到该论坛话题；markup 不为空时附带按钮
+func (c *ClientV2) sendMessage(ctx context.Context, chatID int64, text string, replyToMsgID int64, topicID int, randomID int64, markup tg.ReplyMarkupClass) (int, error) {
 	api := c.TGClient.API()
 
-	// 从数据库获取群组信息
-	var group models.Group
-	var peer tg.InputPeerClass
-
-	if err := c.DB.Where("chat_id = ?", chatID).First(&group).Error; err != nil {
-		log.Printf("⚠️ 未在数据库找到群组 [ID: %d]，尝试按普通群直接发送", chatID)
-		peer = &tg.InputPeerChat{ChatID: chatID}
-	} else {
-		if group.Type == "channel" || group.Type == "supergroup" {
-			if group.AccessHash == 0 {
-				log.Printf("⚠️ 群组 [ID: %d] 缺少AccessHash，尝试获取", chatID)
-				accessHash, err := GetGroupAccessHash(ctx, api, chatID)
-				if err != nil {
-					log.Printf("⚠️ 无法获取AccessHash: %v", err)
-					return fmt.Errorf("需要AccessHash才能发送消息到Channel/Supergroup")
-				}
-				group.AccessHash = accessHash
-				c.DB.Save(&group)
-			}
+	peer, err := c.resolvePeer(ctx, chatID)
+	if err != nil {
+		return 0, err
+	}
 
-			channelID := chatID
-			if channelID < 0 {
-				channelID = -channelID
-			}
-			peer = &tg.InputPeerChannel{
-				ChannelID:  channelID,
-				AccessHash: group.AccessHash,
-			}
-		} else {
-			chat := chatID
-			if chat < 0 {
-				chat = -chat
-			}
-			peer = &tg.InputPeerChat{
-				ChatID: chat,
-			}
-		}
+	if randomID == 0 {
+		randomID = rand.Int63() // 必填随机ID，避免 RANDOM_ID_EMPTY
 	}
 
-	// 发送消息（不带引用）
+	// 使用重试机制发送消息
+	var msgID int
 	sendFn := func() error {
 		req := &tg.MessagesSendMessageRequest{
 			Peer:     peer,
 			Message:  text,
-			RandomID: rand.Int63(),
+			RandomID: randomID,
 		}
-		// 不设置 ReplyTo，直接发送
-		_, err := api.MessagesSendMessage(ctx, req)
-		return err
+		// 如果有回复消息ID或话题，添加回复信息
+		if replyTo := inputReplyTo(replyToMsgID, topicID); replyTo != nil {
+			req.ReplyTo = replyTo
+		}
+		if markup != nil {
+			req.SetReplyMarkup(markup)
+		}
+
+		updates, err := api.MessagesSendMessage(ctx, req)
+		if err != nil {
+			return err
+		}
+		msgID = sentMessageID(updates)
+		c.rememberOwnMessage(chatID, msgID)
+		return nil
 	}
 
-	if err := RetryWithBackoff(ctx, sendFn); err != nil {
-		log.Printf("❌ 发送消息失败（已重试）: %v", err)
-		return err
+	if err := c.retrySend(ctx, chatID, sendFn); err != nil {
+		log.Printf("❌ 发送消息失败: %v", err)
+		return 0, err
 	}
 
-	return nil
+	return msgID, nil
 }
 
-// sendMessage 发送消息（带重试机制，保留用于手动发送）
-func (c *ClientV2) sendMessage(ctx context.Context, chatID int64, text string, replyToMsgID int64) error {
-	api := c.TGClient.API()
-
-	// 从数据库获取群组信息（优先用于区分普通群/频道以及AccessHash）
-	var group models.Group
-	var peer tg.InputPeerClass
-
-	if err := c.DB.Where("chat_id = ?", chatID).First(&group).Error; err != nil {
-		// 数据库中没有群组记录，回退为按 ChatID 直接发送（适用于普通群）
-		log.Printf("⚠️ 未在数据库找到群组 [ID: %d]，尝试按普通群直接发送", chatID)
-		peer = &tg.InputPeerChat{ChatID: chatID}
-	} else {
-		// 根据群组类型构造Peer
-		if group.Type == "channel" || group.Type == "supergroup" {
-			// Channel或Supergroup需要AccessHash
-			if group.AccessHash == 0 {
-				log.Printf("⚠️ 群组 [ID: %d] 缺少AccessHash，尝试获取", chatID)
-				// 尝试获取AccessHash
-				accessHash, err := GetGroupAccessHash(ctx, api, chatID)
-				if err != nil {
-					log.Printf("⚠️ 无法获取AccessHash: %v", err)
-					return fmt.Errorf("需要AccessHash才能发送消息到Channel/Supergroup")
-				}
-				group.AccessHash = accessHash
-				c.DB.Save(&group)
-			}
+// retrySend 使用账号限流器执行发送；遇到永久错误时自动停用该群组的分配
+func (c *ClientV2) retrySend(ctx context.Context, chatID int64, fn func() error) error {
+	err := c.retry(ctx, chatID, fn)
+	if sendErr, ok := IsPermanentError(err); ok {
+		c.disableGroupAssignment(chatID, sendErr)
+	}
+	return err
+}
 
-			// ChannelID 在 Telegram 中为正整数，这里做一次绝对值转换，兼容数据库中可能保存的负数ID
-			channelID := chatID
-			if channelID < 0 {
-				channelID = -channelID
-			}
-			peer = &tg.InputPeerChannel{
-				ChannelID:  channelID,
-				AccessHash: group.AccessHash,
-			}
-		} else {
-			// 普通群组
-			chat := chatID
-			if chat < 0 {
-				chat = -chat
-			}
-			peer = &tg.InputPeerChat{
-				ChatID: chat,
+// retry 使用账号限流器执行群组内的操作（编辑消息等），失败时不停用群组
+func (c *ClientV2) retry(ctx context.Context, chatID int64, fn func() error) error {
+	config := DefaultRetryConfig()
+	config.Limiter = c.limiter
+
+	if c.bot {
+		// 机器人在同一群组的发送频率有限制
+		limiter, send := c.groupLimiter(chatID), fn
+		fn = func() error {
+			if err := limiter.Wait(ctx, 0); err != nil {
+				return err
 			}
+			return send()
 		}
 	}
 
-	// 使用重试机制发送消息
-	sendFn := func() error {
-		req := &tg.MessagesSendMessageRequest{
-			Peer:     peer,
-			Message:  text,
-			RandomID: rand.Int63(), // 必填随机ID，避免 RANDOM_ID_EMPTY
-		}
-		// 如果有回复消息ID，添加回复信息
-		if replyToMsgID > 0 {
-			req.ReplyTo = &tg.InputReplyToMessage{
-				ReplyToMsgID: int(replyToMsgID),
-			}
-		}
+	return Retry(ctx, fn, config)
+}
 
-		_, err := api.MessagesSendMessage(ctx, req)
-		return err
+// disableGroupAssignment 停用账号在群组中的分配，并记录原因
+func (c *ClientV2) disableGroupAssignment(chatID int64, sendErr *SendError) {
+	var group models.Group
+	if err := c.DB.Where("chat_id = ?", chatID).First(&group).Error; err != nil {
+		return
 	}
 
-	if err := RetryWithBackoff(ctx, sendFn); err != nil {
-		log.Printf("❌ 发送消息失败（已重试）: %v", err)
-		return err
+	now := time.Now()
+	reason := sendErr.Type
+	if reason == "" {
+		reason = sendErr.Error()
 	}
 
-	return nil
+	result := c.DB.Model(&models.AccountGroup{}).
+		Where("account_id = ? AND group_id = ?", c.Account.ID, group.ID).
+		Updates(map[string]interface{}{
+			"enabled":         false,
+			"disabled_reason": reason,
+			"disabled_at":     now,
+		})
+	if result.Error != nil {
+		log.Printf("⚠️ 停用群组分配失败: %v", result.Error)
+		return
+	}
+
+	// 同步更新成员身份中的发言权限
+	c.DB.Model(&models.GroupMembership{}).
+		Where("account_id = ? AND group_id = ?", c.Account.ID, group.ID).
+		Update("can_send", false)
+
+	if result.RowsAffected > 0 {
+		log.Printf("⛔ 账号在群组中已无法发言，已自动停用分配 [账号ID: %d, 群组: %s, 原因: %s]", c.Account.ID, group.Title, reason)
+	}
 }
 
 // getGroupAssignment 获取群组分配信息（包含群组级别的配置）
@@ -798,71 +1326,60 @@ func (c *ClientV2) isGroupAssignedOld(chatID int64) bool {
 	return true
 }
 
-// getMessageContext 获取消息上下文
-func (c *ClientV2) getMessageContext(chatID int64) []ai.ChatMessage {
-	context := c.MessageContext[chatID]
-	messages := make([]ai.ChatMessage, 0, len(context))
-	
-	for _, msg := range context {
-		messages = append(messages, ai.ChatMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-		})
-	}
-	
-	return messages
-}
-
-// addMessageContext 添加消息上下文
-func (c *ClientV2) addMessageContext(chatID int64, userMsg, aiReply string) {
-	if c.MessageContext[chatID] == nil {
-		c.MessageContext[chatID] = make([]MessageContext, 0)
-	}
-
-	c.MessageContext[chatID] = append(c.MessageContext[chatID],
-		MessageContext{Role: "user", Content: userMsg},
-		MessageContext{Role: "assistant", Content: aiReply},
-	)
-
-	// 保持上下文在合理范围内
-	if len(c.MessageContext[chatID]) > 10 {
-		c.MessageContext[chatID] = c.MessageContext[chatID][len(c.MessageContext[chatID])-10:]
-	}
-}
-
-// saveMessageDirect 保存消息记录（不带回复ID）
-func (c *ClientV2) saveMessageDirect(chatID int64, content string) {
+// recordSend 保存发言记录：成功时记录 Telegram 消息ID，失败时记录错误原因
+// message 只需填写内容相关字段，账号、群组和发送状态在这里补齐
+func (c *ClientV2) recordSend(chatID int64, message *models.Message, msgID int, sendErr error) {
 	var group models.Group
 	if err := c.DB.Where("chat_id = ?", chatID).First(&group).Error; err != nil {
 		log.Printf("⚠️ 未找到群组 [ID: %d]", chatID)
 		return
 	}
 
-	message := models.Message{
-		AccountID: c.Account.ID,
-		GroupID:   group.ID,
-		Content:   content,
+	message.AccountID = c.Account.ID
+	message.GroupID = group.ID
+	message.TelegramMessageID = int64(msgID)
+	message.Status = "sent"
+	if sendErr != nil {
+		message.Status = "failed"
+		message.Error = sendErr.Error()
+	}
+	if message.ReplyGroupID == "" {
+		message.ReplyGroupID = newReplyGroupID()
+	}
+	if message.PartCount == 0 {
+		message.PartCount = 1
 	}
 
-	c.DB.Create(&message)
+	if err := c.DB.Create(message).Error; err != nil {
+		log.Printf("⚠️ 保存发言记录失败: %v", err)
+	}
 }
 
-// saveMessage 保存消息记录（保留用于手动发送）
-func (c *ClientV2) saveMessage(chatID int64, content string, replyToID int64) {
-	var group models.Group
-	if err := c.DB.Where("chat_id = ?", chatID).First(&group).Error; err != nil {
-		log.Printf("⚠️ 未找到群组 [ID: %d]", chatID)
-		return
+// newReplyGroupID 生成回复分组ID（同一条回复拆分出的多条消息共用）
+func newReplyGroupID() string {
+	b := make([]byte, 8)
+	if _, err := crand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
 	}
+	return hex.EncodeToString(b)
+}
 
-	message := models.Message{
-		AccountID:         c.Account.ID,
-		GroupID:           group.ID,
-		TelegramMessageID: replyToID,
-		Content:           content,
-	}
+// accountSettings 获取账号配置快照（配置会被热更新，并发读取时使用快照）
+func (c *ClientV2) accountSettings() models.Account {
+	c.accountLock.RLock()
+	defer c.accountLock.RUnlock()
+	return *c.Account
+}
 
-	c.DB.Create(&message)
+// saveAccountStatus 更新并保存账号状态，nickname 为空时不修改昵称
+func (c *ClientV2) saveAccountStatus(status, nickname string) {
+	c.accountLock.Lock()
+	defer c.accountLock.Unlock()
+	c.Account.Status = status
+	if nickname != "" {
+		c.Account.Nickname = nickname
+	}
+	c.DB.Save(c.Account)
 }
 
 // Stop 停止客户端
@@ -873,9 +1390,9 @@ func (c *ClientV2) Stop() {
 	}
 	
 	log.Printf("🛑 停止Telegram客户端 [账号ID: %d]", c.Account.ID)
-	c.Account.Status = "offline"
-	c.DB.Save(c.Account)
+	c.saveAccountStatus("offline", "")
 	c.Cancel()
+	c.flushBuffers()
 }
 
 // min 函数已在 client.go 中定义，这里不需要重复定义
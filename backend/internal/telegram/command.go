@@ -0,0 +1,416 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"aibot/models"
+
+	"github.com/gotd/td/tg"
+	"gorm.io/gorm"
+)
+
+// 命令动作
+const (
+	CommandActionHelp     = "help"     // 列出可用命令
+	CommandActionFAQ      = "faq"      // 查询知识库
+	CommandActionAI       = "ai"       // 调用AI回答（附带命中的知识库条目）
+	CommandActionTemplate = "template" // 固定回复
+)
+
+// maxFAQReferences /ask 调用AI时最多附带的知识库条目数
+const maxFAQReferences = 3
+
+// commandTimeout 单个命令的处理超时
+const commandTimeout = 2 * time.Minute
+
+// commandNamePattern 命令名：小写字母、数字、下划线（Telegram 机器人命令的限制）
+var commandNamePattern = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// builtinCommands 内置命令，可被同名的自定义命令覆盖或停用
+var builtinCommands = []models.GroupCommand{
+	{Name: "help", Enabled: true, Description: "查看可用命令", Action: CommandActionHelp, CooldownSeconds: 30},
+	{Name: "faq", Enabled: true, Description: "查询常见问题（不带主题时列出全部主题）", Usage: "<主题>", Action: CommandActionFAQ, CooldownSeconds: 10},
+	{Name: "ask", Enabled: true, Description: "向AI提问", Usage: "<问题>", MinArgs: 1, Action: CommandActionAI, CooldownSeconds: 60},
+}
+
+// parsedCommand 解析后的命令消息
+type parsedCommand struct {
+	name   string // 命令名（小写）
+	target string // /命令@用户名 中指定的账号用户名
+	args   string
+}
+
+// ValidateCommand 校验并规范化命令配置
+func ValidateCommand(command *models.GroupCommand) error {
+	command.Name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(command.Name), "/"))
+	if !commandNamePattern.MatchString(command.Name) {
+		return fmt.Errorf("命令名只能包含小写字母、数字和下划线（最多32个字符）")
+	}
+	switch command.Action {
+	case "":
+		// 只用于停用同名命令时可以不指定动作
+		if command.Enabled {
+			return fmt.Errorf("命令动作不能为空")
+		}
+	case CommandActionHelp, CommandActionFAQ, CommandActionAI:
+	case CommandActionTemplate:
+		if strings.TrimSpace(command.Template) == "" {
+			return fmt.Errorf("固定回复内容不能为空")
+		}
+	default:
+		return fmt.Errorf("不支持的命令动作: %s", command.Action)
+	}
+	if command.MinArgs < 0 || command.CooldownSeconds < 0 {
+		return fmt.Errorf("参数个数和冷却时间不能为负数")
+	}
+	return ValidateKeyboard(command.Buttons)
+}
+
+// ValidateFAQ 校验知识库条目
+func ValidateFAQ(entry *models.FAQEntry) error {
+	entry.Topic = strings.TrimSpace(entry.Topic)
+	if entry.Topic == "" {
+		return fmt.Errorf("主题不能为空")
+	}
+	if strings.TrimSpace(entry.Answer) == "" {
+		return fmt.Errorf("答案不能为空")
+	}
+	return nil
+}
+
+// EffectiveCommands 获取账号在群组中可用的命令（内置命令 < 全局命令 < 群组命令，账号专属的优先）
+// groupID 为0时只计算全局命令，accountID 为0时不包含账号专属命令
+func EffectiveCommands(db *gorm.DB, groupID, accountID uint) []models.GroupCommand {
+	var records []models.GroupCommand
+	if err := db.Where("(group_id IS NULL OR group_id = ?) AND (account_id IS NULL OR account_id = ?)", groupID, accountID).
+		Find(&records).Error; err != nil {
+		log.Printf("⚠️ 加载命令失败: %v", err)
+	}
+
+	// 范围越具体越后覆盖
+	sort.SliceStable(records, func(i, j int) bool {
+		return commandScope(&records[i]) < commandScope(&records[j])
+	})
+
+	byName := make(map[string]models.GroupCommand)
+	for _, command := range builtinCommands {
+		byName[command.Name] = command
+	}
+	for _, command := range records {
+		byName[command.Name] = command
+	}
+
+	commands := make([]models.GroupCommand, 0, len(byName))
+	for _, command := range byName {
+		if command.Enabled {
+			commands = append(commands, command)
+		}
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+	return commands
+}
+
+// commandScope 命令的作用范围（数值越大越具体）
+func commandScope(command *models.GroupCommand) int {
+	scope := 0
+	if command.GroupID != nil {
+		scope += 2
+	}
+	if command.AccountID != nil {
+		scope++
+	}
+	return scope
+}
+
+// parseCommand 解析 /命令[@用户名] [参数]
+func parseCommand(text string) (parsedCommand, bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return parsedCommand{}, false
+	}
+
+	head, args := text[1:], ""
+	if i := strings.IndexFunc(head, unicode.IsSpace); i >= 0 {
+		head, args = head[:i], strings.TrimSpace(head[i:])
+	}
+	name, target, _ := strings.Cut(head, "@")
+	name = strings.ToLower(name)
+	if !commandNamePattern.MatchString(name) {
+		return parsedCommand{}, false
+	}
+	return parsedCommand{name: name, target: target, args: args}, true
+}
+
+// handleCommand 处理群成员的斜杠命令，返回是否为命令消息（命令消息不进入缓冲区，不参与自动回复）
+func (c *ClientV2) handleCommand(chatID int64, accountGroup *models.AccountGroup, msg BufferedMessage) bool {
+	parsed, ok := parseCommand(msg.Content)
+	if !ok {
+		return false
+	}
+	if !accountGroup.Enabled || !topicEnabled(accountGroup, msg.TopicID) {
+		return true
+	}
+
+	// 指定了账号的命令只由该账号响应；未指定时由群组中优先级最高的在线账号响应，避免多个账号重复回复
+	if parsed.target != "" {
+		if c.SelfUsername == "" || !strings.EqualFold(parsed.target, c.SelfUsername) {
+			return true
+		}
+	} else if !c.isCommandResponder(accountGroup.GroupID) {
+		return true
+	}
+
+	var command *models.GroupCommand
+	for _, candidate := range EffectiveCommands(c.DB, accountGroup.GroupID, c.Account.ID) {
+		if candidate.Name == parsed.name {
+			command = &candidate
+			break
+		}
+	}
+	if command == nil {
+		return true
+	}
+
+	if !c.commandCooldownPassed(accountGroup.GroupID, command, msg.SenderID) {
+		log.Printf("⏳ 命令 /%s 冷却中，跳过 [群组ID: %d, 用户ID: %d]", command.Name, chatID, msg.SenderID)
+		return true
+	}
+
+	log.Printf("⌨️ 收到命令 /%s [群组ID: %d, 用户: %s]: %s", command.Name, chatID, msg.SenderName, truncateStr(parsed.args, 50))
+	go c.runCommand(chatID, accountGroup.GroupID, *command, parsed.args, msg)
+	return true
+}
+
+// isCommandResponder 判断当前账号是否为群组中响应命令的账号（已启用分配中优先级最高的在线账号）
+func (c *ClientV2) isCommandResponder(groupID uint) bool {
+	var assignment models.AccountGroup
+	err := c.DB.Model(&models.AccountGroup{}).
+		Joins("JOIN ai_accounts ON ai_accounts.id = account_groups.account_id AND ai_accounts.deleted_at IS NULL").
+		Where("account_groups.group_id = ? AND account_groups.enabled = ? AND ai_accounts.status = ?", groupID, true, "online").
+		Order("account_groups.priority DESC, account_groups.account_id ASC").
+		First(&assignment).Error
+	return err != nil || assignment.AccountID == c.Account.ID
+}
+
+// commandCooldownPassed 检查并记录用户调用命令的时间（同一用户在冷却时间内不能重复调用同一命令）
+func (c *ClientV2) commandCooldownPassed(groupID uint, command *models.GroupCommand, userID int64) bool {
+	if command.CooldownSeconds <= 0 {
+		return true
+	}
+
+	key := c.stateKey("cooldown:command:%d:%s:%d", groupID, command.Name, userID)
+	return c.tryCooldown(key, time.Duration(command.CooldownSeconds)*time.Second)
+}
+
+// runCommand 执行命令并将回复加入发送队列
+func (c *ClientV2) runCommand(chatID int64, groupID uint, command models.GroupCommand, args string, msg BufferedMessage) {
+	ctx, cancel := context.WithTimeout(c.Context, commandTimeout)
+	defer cancel()
+
+	var reply string
+	var err error
+	buttons := command.Buttons
+	if len(strings.Fields(args)) < command.MinArgs {
+		reply = fmt.Sprintf("用法：/%s %s", command.Name, command.Usage)
+		buttons = nil
+	} else {
+		switch command.Action {
+		case CommandActionHelp:
+			reply = renderCommandHelp(EffectiveCommands(c.DB, groupID, c.Account.ID))
+		case CommandActionFAQ:
+			reply = c.answerFAQ(groupID, args)
+		case CommandActionAI:
+			reply, err = c.answerWithAI(ctx, groupID, &command, args, msg)
+		case CommandActionTemplate:
+			reply = renderCommandTemplate(command.Template, msg, c.groupTitle(groupID), args)
+		}
+	}
+	if err != nil {
+		log.Printf("❌ 执行命令失败 /%s: %v", command.Name, err)
+		reply = "⚠️ 暂时无法处理该命令，请稍后再试"
+		buttons = nil
+	}
+	if strings.TrimSpace(reply) == "" {
+		return
+	}
+
+	if err := c.enqueueReplyWithButtons(chatID, msg.TopicID, reply, msg.MessageID, "command", buttons); err != nil {
+		log.Printf("❌ 命令回复加入发送队列失败: %v", err)
+		return
+	}
+	if command.ID > 0 {
+		c.DB.Model(&models.GroupCommand{}).Where("id = ?", command.ID).UpdateColumns(map[string]interface{}{
+			"hit_count":   gorm.Expr("hit_count + ?", 1),
+			"last_hit_at": time.Now(),
+		})
+	}
+	log.Printf("✅ 命令回复已加入发送队列 /%s: %s", command.Name, truncateStr(reply, 100))
+}
+
+// answerFAQ 查询知识库；没有主题或未找到时列出可用主题
+func (c *ClientV2) answerFAQ(groupID uint, topic string) string {
+	entries := c.loadFAQ(groupID)
+	if len(entries) == 0 {
+		return "暂无常见问题"
+	}
+
+	if topic != "" {
+		if matched := matchFAQ(entries, topic, 1); len(matched) > 0 {
+			c.DB.Model(&models.FAQEntry{}).Where("id = ?", matched[0].ID).UpdateColumn("hit_count", gorm.Expr("hit_count + ?", 1))
+			return matched[0].Answer
+		}
+	}
+
+	topics := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		topics = append(topics, "• "+entry.Topic)
+	}
+	list := strings.Join(topics, "\n")
+	if topic != "" {
+		return fmt.Sprintf("没有找到「%s」相关的问题，可查询的主题：\n%s", topic, list)
+	}
+	return fmt.Sprintf("可查询的主题（使用 /faq <主题> 查看）：\n%s", list)
+}
+
+// answerWithAI 调用AI回答，命中的知识库条目作为参考资料
+func (c *ClientV2) answerWithAI(ctx context.Context, groupID uint, command *models.GroupCommand, question string, msg BufferedMessage) (string, error) {
+	sender := msg.SenderName
+	if sender == "" {
+		sender = "群友"
+	}
+
+	prompt := fmt.Sprintf("%s 在群里使用 /%s 命令提问，请直接回答（直接输出你的回答）：\n\n%s", sender, command.Name, question)
+	if references := matchFAQ(c.loadFAQ(groupID), question, maxFAQReferences); len(references) > 0 {
+		var b strings.Builder
+		for _, entry := range references {
+			fmt.Fprintf(&b, "\n【%s】%s", entry.Topic, entry.Answer)
+		}
+		prompt += "\n\n参考资料（请优先依据以下内容回答）：" + b.String()
+	}
+	if strings.TrimSpace(command.Instruction) != "" {
+		prompt += fmt.Sprintf("\n\n回答要求：%s", command.Instruction)
+	}
+
+	account := c.accountSettings()
+	reply, err := c.AIService.GenerateReply(ctx, account.AIApiKey, account.AIModel, account.SystemPrompt, prompt, nil)
+	if err != nil {
+		return "", err
+	}
+	if reply == "" {
+		return "", fmt.Errorf("AI未生成回复内容")
+	}
+	return reply, nil
+}
+
+// loadFAQ 加载对群组生效的知识库条目（群组条目覆盖同主题的全局条目）
+func (c *ClientV2) loadFAQ(groupID uint) []models.FAQEntry {
+	var records []models.FAQEntry
+	if err := c.DB.Where("enabled = ? AND (group_id IS NULL OR group_id = ?)", true, groupID).
+		Order("topic ASC, id ASC").
+		Find(&records).Error; err != nil {
+		log.Printf("⚠️ 加载知识库失败: %v", err)
+		return nil
+	}
+
+	entries := make([]models.FAQEntry, 0, len(records))
+	index := make(map[string]int)
+	for _, entry := range records {
+		key := strings.ToLower(entry.Topic)
+		if i, ok := index[key]; ok {
+			if entry.GroupID != nil {
+				entries[i] = entry
+			}
+			continue
+		}
+		index[key] = len(entries)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// matchFAQ 查找与内容相关的知识库条目：主题完全相同的优先，其次是内容包含主题或关键词的
+func matchFAQ(entries []models.FAQEntry, text string, limit int) []models.FAQEntry {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if text == "" {
+		return nil
+	}
+
+	var exact, related []models.FAQEntry
+	for _, entry := range entries {
+		topic := strings.ToLower(entry.Topic)
+		switch {
+		case topic == text:
+			exact = append(exact, entry)
+		case strings.Contains(text, topic) || faqKeywordMatches(entry.Keywords, text):
+			related = append(related, entry)
+		}
+	}
+
+	matched := append(exact, related...)
+	if len(matched) > limit {
+		matched = matched[:limit]
+	}
+	return matched
+}
+
+// faqKeywordMatches 内容是否包含任一关键词
+func faqKeywordMatches(keywords, text string) bool {
+	for _, keyword := range strings.Split(keywords, ",") {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if keyword != "" && strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderCommandHelp 渲染可用命令列表
+func renderCommandHelp(commands []models.GroupCommand) string {
+	var b strings.Builder
+	b.WriteString("可用命令：")
+	for _, command := range commands {
+		b.WriteString("\n/" + command.Name)
+		if command.Usage != "" {
+			b.WriteString(" " + command.Usage)
+		}
+		if command.Description != "" {
+			b.WriteString(" - " + command.Description)
+		}
+	}
+	return b.String()
+}
+
+// renderCommandTemplate 渲染命令的固定回复
+func renderCommandTemplate(template string, msg BufferedMessage, groupTitle, args string) string {
+	return strings.NewReplacer("{args}", args).Replace(renderRuleTemplate(template, msg, groupTitle))
+}
+
+// syncBotCommands 将全局命令同步为机器人的命令菜单（输入 / 时显示）
+func (c *ClientV2) syncBotCommands(ctx context.Context) {
+	commands := EffectiveCommands(c.DB, 0, c.Account.ID)
+	botCommands := make([]tg.BotCommand, 0, len(commands))
+	for _, command := range commands {
+		description := command.Description
+		if description == "" {
+			description = command.Name
+		}
+		botCommands = append(botCommands, tg.BotCommand{Command: command.Name, Description: description})
+	}
+
+	_, err := c.TGClient.API().BotsSetBotCommands(ctx, &tg.BotsSetBotCommandsRequest{
+		Scope:    &tg.BotCommandScopeDefault{},
+		Commands: botCommands,
+	})
+	if err != nil {
+		log.Printf("⚠️ 同步机器人命令菜单失败: %v", err)
+		return
+	}
+	log.Printf("⌨️ 已同步机器人命令菜单（%d 个命令）", len(botCommands))
+}
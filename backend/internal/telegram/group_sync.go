@@ -4,100 +4,616 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"aibot/models"
 
 	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
 	"gorm.io/gorm"
 )
 
-// SyncGroups 同步群组信息
-func SyncGroups(ctx context.Context, api *tg.Client, db *gorm.DB, accountID uint) error {
+// dialogsPageSize 每页拉取的对话数量（Telegram 上限为100）
+const dialogsPageSize = 100
+
+// fullInfoMaxWait 拉取群组完整信息时等待限流器的最长时间，超过则本次同步不再拉取
+const fullInfoMaxWait = time.Minute
+
+// fullInfoTTL 群组完整信息的有效期，过期后下次同步重新拉取（对话列表不包含描述，频道通常也不包含成员数）
+const fullInfoTTL = 24 * time.Hour
+
+// GroupSyncResult 一次群组同步的变更结果
+type GroupSyncResult struct {
+	AccountID   uint          `json:"account_id"`
+	Total       int           `json:"total"`        // 本次同步看到的群组数量
+	Created     []GroupChange `json:"created"`      // 新发现的群组
+	Updated     []GroupChange `json:"updated"`      // 信息有变化的群组
+	Joined      []GroupChange `json:"joined"`       // 新加入（或重新加入）的群组
+	Left        []GroupChange `json:"left"`         // 已退出或被踢出的群组
+	RoleChanged []GroupChange `json:"role_changed"` // 角色或发言权限有变化的群组
+	Deactivated []GroupChange `json:"deactivated"`  // 因失去访问而标记为 inactive 的群组
+	Reactivated []GroupChange `json:"reactivated"`  // 重新恢复为 active 的群组
+	SyncedAt    time.Time     `json:"synced_at"`
+}
+
+// GroupChange 单个群组的变更描述
+type GroupChange struct {
+	GroupID uint     `json:"group_id"`
+	ChatID  int64    `json:"chat_id"`
+	Title   string   `json:"title"`
+	Fields  []string `json:"fields,omitempty"` // 变化的字段
+	Role    string   `json:"role,omitempty"`
+	Status  string   `json:"status,omitempty"`
+	CanSend bool     `json:"can_send"`
+}
+
+// syncedChat 从对话列表中解析出的群组及当前账号的成员身份
+type syncedChat struct {
+	group   models.Group
+	status  string // member/left/kicked
+	role    string // creator/admin/member/restricted
+	canSend bool
+}
+
+// SyncGroups 同步群组信息（完整分页拉取对话，并记录成员身份）
+// 群组完整信息只对新群组或信息有变化的群组拉取，并通过账号限流器控制频率
+func SyncGroups(ctx context.Context, api *tg.Client, db *gorm.DB, accountID uint, limiter *RateLimiter) (*GroupSyncResult, error) {
 	log.Printf("🔄 开始同步群组信息 [账号ID: %d]", accountID)
 
-	// 获取所有对话
-	dialogs, err := api.MessagesGetDialogs(ctx, &tg.MessagesGetDialogsRequest{
-		Limit:      100,
-		OffsetDate: 0,
-		OffsetID:   0,
-		// 为避免 offset_peer 为 nil，引入一个空的 InputPeer
-		OffsetPeer: &tg.InputPeerEmpty{},
-	})
+	chats, err := fetchAllDialogChats(ctx, api)
 	if err != nil {
-		return fmt.Errorf("获取对话列表失败: %w", err)
-	}
-
-	// 处理对话 - 需要根据实际返回类型处理
-	var allChats []tg.ChatClass
-	
-	// 类型断言获取chats
-	switch d := dialogs.(type) {
-	case *tg.MessagesDialogs:
-		allChats = d.Chats
-	case *tg.MessagesDialogsSlice:
-		allChats = d.Chats
-	default:
-		log.Printf("⚠️ 未知的对话类型，跳过群组同步")
-		return nil
-	}
-
-	// 处理所有聊天（包括群组和频道）
-	for _, chat := range allChats {
-		switch c := chat.(type) {
-		case *tg.Chat:
-			// 普通群组
-			group := models.Group{
-				ChatID:      int64(c.ID),
-				Title:       c.Title,
-				Type:        "group",
-				MemberCount: int(c.ParticipantsCount),
+		return nil, err
+	}
+
+	result := &GroupSyncResult{
+		AccountID: accountID,
+		SyncedAt:  time.Now(),
+	}
+
+	seenGroupIDs := make(map[uint]bool)
+	for _, chat := range chats {
+		synced, ok := parseSyncedChat(chat)
+		if !ok {
+			continue
+		}
+		if groupID, ok := applySyncedChat(ctx, api, db, limiter, accountID, synced, result); ok {
+			seenGroupIDs[groupID] = true
+		}
+	}
+
+	// 对话列表中已不存在的群组：账号已退出
+	markMissingMemberships(db, accountID, seenGroupIDs, result)
+
+	// 根据所有账号的成员身份更新群组状态
+	refreshGroupStatuses(db, accountID, result)
+
+	log.Printf("✅ 群组同步完成 [账号ID: %d, 群组: %d, 新增: %d, 退出: %d, 停用: %d]",
+		accountID, result.Total, len(result.Created), len(result.Left), len(result.Deactivated))
+	return result, nil
+}
+
+// applySyncedChat 保存一个群组及账号在其中的成员身份，返回群组ID
+func applySyncedChat(ctx context.Context, api *tg.Client, db *gorm.DB, limiter *RateLimiter, accountID uint, synced *syncedChat, result *GroupSyncResult) (uint, bool) {
+	if synced.status == "member" {
+		// 拉取群组描述和更准确的成员数（仅对仍可访问、且是新群组或信息有变化的群组）
+		if needsFullInfo(db, &synced.group) {
+			fetchGroupFullInfo(ctx, api, limiter, &synced.group)
+		}
+	} else {
+		// 已退出/被踢出且从未记录过的群组，无需入库
+		var count int64
+		db.Model(&models.Group{}).Where("chat_id = ?", synced.group.ChatID).Count(&count)
+		if count == 0 {
+			return 0, false
+		}
+	}
+
+	group, change, created := saveOrUpdateGroup(db, &synced.group)
+	if group == nil {
+		return 0, false
+	}
+	if created {
+		result.Created = append(result.Created, change)
+	} else if len(change.Fields) > 0 {
+		result.Updated = append(result.Updated, change)
+	}
+
+	saveMembership(db, accountID, group, synced, result)
+	result.Total++
+	return group.ID, true
+}
+
+// fetchAllDialogChats 分页拉取全部对话（包括归档文件夹），返回去重后的聊天列表
+func fetchAllDialogChats(ctx context.Context, api *tg.Client) ([]tg.ChatClass, error) {
+	seen := make(map[int64]bool)
+	var all []tg.ChatClass
+
+	// 0 为主列表，1 为归档
+	for _, folderID := range []int{0, 1} {
+		req := &tg.MessagesGetDialogsRequest{
+			Limit:      dialogsPageSize,
+			OffsetPeer: &tg.InputPeerEmpty{},
+		}
+		req.SetFolderID(folderID)
+
+		for page := 0; ; page++ {
+			dialogs, err := api.MessagesGetDialogs(ctx, req)
+			if err != nil {
+				return nil, fmt.Errorf("获取对话列表失败 [文件夹: %d, 第%d页]: %w", folderID, page+1, err)
+			}
+
+			var (
+				dialogList []tg.DialogClass
+				messages   []tg.MessageClass
+				chats      []tg.ChatClass
+				users      []tg.UserClass
+				lastPage   bool
+			)
+			switch d := dialogs.(type) {
+			case *tg.MessagesDialogs:
+				dialogList, messages, chats, users = d.Dialogs, d.Messages, d.Chats, d.Users
+				lastPage = true
+			case *tg.MessagesDialogsSlice:
+				dialogList, messages, chats, users = d.Dialogs, d.Messages, d.Chats, d.Users
+			default:
+				lastPage = true
+			}
+
+			// chats 中还包含消息引用到的其他聊天（如转发来源），只保留真正出现在对话列表中的
+			dialogPeers := make(map[int64]bool, len(dialogList))
+			for _, dialog := range dialogList {
+				switch p := dialog.GetPeer().(type) {
+				case *tg.PeerChat:
+					dialogPeers[p.ChatID] = true
+				case *tg.PeerChannel:
+					dialogPeers[p.ChannelID] = true
+				}
 			}
-			saveOrUpdateGroup(db, &group)
-			
-		case *tg.Channel:
-			// 频道或超级群组
-			groupType := "channel"
-			if !c.Broadcast {
-				groupType = "supergroup"
+			for _, chat := range chats {
+				if !dialogPeers[chat.GetID()] || seen[chat.GetID()] {
+					continue
+				}
+				seen[chat.GetID()] = true
+				all = append(all, chat)
 			}
-			
-			group := models.Group{
-				ChatID:      int64(c.ID),
-				AccessHash:  c.AccessHash,
+
+			if lastPage || len(dialogList) < dialogsPageSize {
+				break
+			}
+
+			// 以本页最后一个对话作为下一页的偏移
+			next, ok := nextDialogsOffset(dialogList, messages, chats, users)
+			if !ok || (next.OffsetID == req.OffsetID && next.OffsetDate == req.OffsetDate) {
+				break
+			}
+			req.OffsetDate = next.OffsetDate
+			req.OffsetID = next.OffsetID
+			req.OffsetPeer = next.OffsetPeer
+		}
+	}
+
+	return all, nil
+}
+
+// nextDialogsOffset 根据本页最后一个对话计算下一页的偏移参数
+func nextDialogsOffset(dialogs []tg.DialogClass, messages []tg.MessageClass, chats []tg.ChatClass, users []tg.UserClass) (*tg.MessagesGetDialogsRequest, bool) {
+	var last *tg.Dialog
+	for i := len(dialogs) - 1; i >= 0; i-- {
+		if d, ok := dialogs[i].(*tg.Dialog); ok {
+			last = d
+			break
+		}
+	}
+	if last == nil {
+		return nil, false
+	}
+
+	next := &tg.MessagesGetDialogsRequest{OffsetID: last.TopMessage}
+	for _, msg := range messages {
+		if msg.GetID() != last.TopMessage {
+			continue
+		}
+		var peer tg.PeerClass
+		var date int
+		switch m := msg.(type) {
+		case *tg.Message:
+			peer, date = m.PeerID, m.Date
+		case *tg.MessageService:
+			peer, date = m.PeerID, m.Date
+		default:
+			continue
+		}
+		if samePeer(peer, last.Peer) {
+			next.OffsetDate = date
+			break
+		}
+	}
+
+	switch p := last.Peer.(type) {
+	case *tg.PeerUser:
+		for _, u := range users {
+			if user, ok := u.(*tg.User); ok && user.ID == p.UserID {
+				next.OffsetPeer = &tg.InputPeerUser{UserID: user.ID, AccessHash: user.AccessHash}
+			}
+		}
+	case *tg.PeerChat:
+		next.OffsetPeer = &tg.InputPeerChat{ChatID: p.ChatID}
+	case *tg.PeerChannel:
+		for _, c := range chats {
+			if channel, ok := c.(*tg.Channel); ok && channel.ID == p.ChannelID {
+				next.OffsetPeer = &tg.InputPeerChannel{ChannelID: channel.ID, AccessHash: channel.AccessHash}
+			}
+		}
+	}
+	if next.OffsetPeer == nil {
+		next.OffsetPeer = &tg.InputPeerEmpty{}
+	}
+
+	return next, true
+}
+
+// samePeer 判断两个 Peer 是否指向同一个对话
+func samePeer(a, b tg.PeerClass) bool {
+	switch pa := a.(type) {
+	case *tg.PeerUser:
+		pb, ok := b.(*tg.PeerUser)
+		return ok && pa.UserID == pb.UserID
+	case *tg.PeerChat:
+		pb, ok := b.(*tg.PeerChat)
+		return ok && pa.ChatID == pb.ChatID
+	case *tg.PeerChannel:
+		pb, ok := b.(*tg.PeerChannel)
+		return ok && pa.ChannelID == pb.ChannelID
+	}
+	return false
+}
+
+// parseSyncedChat 解析聊天对象，得到群组信息和当前账号的成员身份
+func parseSyncedChat(chat tg.ChatClass) (*syncedChat, bool) {
+	switch c := chat.(type) {
+	case *tg.Chat:
+		// 普通群组
+		synced := &syncedChat{
+			group: models.Group{
+				ChatID:      c.ID,
 				Title:       c.Title,
-				Username:    c.Username,
-				Type:        groupType,
-				MemberCount: int(c.ParticipantsCount),
+				Type:        "group",
+				MemberCount: c.ParticipantsCount,
+			},
+			status: "member",
+			role:   "member",
+		}
+		_, isAdmin := c.GetAdminRights()
+		switch {
+		case c.Creator:
+			synced.role = "creator"
+		case isAdmin:
+			synced.role = "admin"
+		}
+		if c.Left || c.Deactivated {
+			// 已退出，或群组已升级为超级群（旧群失效）
+			synced.status = "left"
+		}
+		synced.canSend = synced.status == "member"
+		if banned, ok := c.GetDefaultBannedRights(); ok && banned.SendMessages && synced.role == "member" {
+			synced.canSend = false
+		}
+		return synced, true
+
+	case *tg.ChatForbidden:
+		return &syncedChat{
+			group: models.Group{
+				ChatID: c.ID,
+				Title:  c.Title,
+				Type:   "group",
+			},
+			status: "kicked",
+			role:   "member",
+		}, true
+
+	case *tg.Channel:
+		// 频道或超级群组
+		groupType := "channel"
+		if !c.Broadcast {
+			groupType = "supergroup"
+		}
+		synced := &syncedChat{
+			group: models.Group{
+				ChatID:     c.ID,
+				AccessHash: c.AccessHash,
+				Title:      c.Title,
+				Username:   c.Username,
+				Type:       groupType,
+			},
+			status: "member",
+			role:   "member",
+		}
+		if count, ok := c.GetParticipantsCount(); ok {
+			synced.group.MemberCount = count
+		}
+
+		adminRights, isAdmin := c.GetAdminRights()
+		switch {
+		case c.Creator:
+			synced.role = "creator"
+		case isAdmin:
+			synced.role = "admin"
+		}
+		if c.Left {
+			synced.status = "left"
+		}
+
+		synced.canSend = synced.status == "member"
+		if banned, ok := c.GetBannedRights(); ok && synced.role == "member" {
+			if banned.ViewMessages {
+				synced.status = "kicked"
+				synced.canSend = false
+			} else if banned.SendMessages {
+				synced.role = "restricted"
+				synced.canSend = false
 			}
-			saveOrUpdateGroup(db, &group)
 		}
+		if banned, ok := c.GetDefaultBannedRights(); ok && banned.SendMessages && synced.role == "member" {
+			synced.canSend = false
+		}
+		// 广播频道只有创建者或有发帖权限的管理员可以发言
+		if c.Broadcast && !c.Creator && !(isAdmin && adminRights.PostMessages) {
+			synced.canSend = false
+		}
+		return synced, true
+
+	case *tg.ChannelForbidden:
+		groupType := "channel"
+		if c.Megagroup {
+			groupType = "supergroup"
+		}
+		return &syncedChat{
+			group: models.Group{
+				ChatID:     c.ID,
+				AccessHash: c.AccessHash,
+				Title:      c.Title,
+				Type:       groupType,
+			},
+			status: "kicked",
+			role:   "member",
+		}, true
+	}
+
+	return nil, false
+}
+
+// needsFullInfo 判断是否需要拉取群组完整信息：新群组、从未拉取过或已过期，或标题、成员数与已保存的不一致
+func needsFullInfo(db *gorm.DB, group *models.Group) bool {
+	var existing models.Group
+	if err := db.Where("chat_id = ?", group.ChatID).First(&existing).Error; err != nil {
+		return true
+	}
+	if existing.FullInfoAt == nil || time.Since(*existing.FullInfoAt) > fullInfoTTL {
+		return true
+	}
+	if existing.Title != group.Title {
+		return true
+	}
+	return group.MemberCount > 0 && existing.MemberCount != group.MemberCount
+}
+
+// fetchGroupFullInfo 拉取群组完整信息（描述、成员数），与发送共用账号限流器
+func fetchGroupFullInfo(ctx context.Context, api *tg.Client, limiter *RateLimiter, group *models.Group) {
+	if limiter != nil {
+		if err := limiter.Wait(ctx, fullInfoMaxWait); err != nil {
+			log.Printf("⚠️ 跳过群组完整信息 [%s, ID: %d]: %v", group.Title, group.ChatID, err)
+			return
+		}
+	}
+
+	var full *tg.MessagesChatFull
+	var err error
+
+	if group.Type == "group" {
+		full, err = api.MessagesGetFullChat(ctx, group.ChatID)
+	} else {
+		full, err = api.ChannelsGetFullChannel(ctx, &tg.InputChannel{
+			ChannelID:  group.ChatID,
+			AccessHash: group.AccessHash,
+		})
+	}
+	if err != nil {
+		if d, ok := tgerr.AsFloodWait(err); ok && limiter != nil {
+			limiter.Block(d)
+		}
+		log.Printf("⚠️ 获取群组完整信息失败 [%s, ID: %d]: %v", group.Title, group.ChatID, err)
+		return
 	}
 
-	log.Printf("✅ 群组同步完成 [账号ID: %d]", accountID)
-	return nil
+	switch f := full.FullChat.(type) {
+	case *tg.ChannelFull:
+		group.Description = f.About
+		if count, ok := f.GetParticipantsCount(); ok && count > 0 {
+			group.MemberCount = count
+		}
+	case *tg.ChatFull:
+		group.Description = f.About
+	}
+	now := time.Now()
+	group.FullInfoAt = &now
 }
 
-// saveOrUpdateGroup 保存或更新群组
-func saveOrUpdateGroup(db *gorm.DB, group *models.Group) {
+// saveOrUpdateGroup 保存或更新群组，返回数据库中的群组及变更描述
+func saveOrUpdateGroup(db *gorm.DB, group *models.Group) (*models.Group, GroupChange, bool) {
+	change := GroupChange{ChatID: group.ChatID, Title: group.Title}
+
 	var existing models.Group
 	if err := db.Where("chat_id = ?", group.ChatID).First(&existing).Error; err != nil {
 		// 不存在，创建
 		if err := db.Create(group).Error; err != nil {
 			log.Printf("⚠️ 创建群组失败 [ID: %d]: %v", group.ChatID, err)
-		} else {
-			log.Printf("✅ 创建群组: %s [ID: %d]", group.Title, group.ChatID)
+			return nil, change, false
 		}
-	} else {
-		// 存在，更新
+		log.Printf("✅ 创建群组: %s [ID: %d]", group.Title, group.ChatID)
+		change.GroupID = group.ID
+		return group, change, true
+	}
+
+	change.GroupID = existing.ID
+
+	// AccessHash 因账号而异（各账号的保存在成员身份中），群组中只在没有时补上，不算作群组变化
+	dirty := false
+	if existing.AccessHash == 0 && group.AccessHash != 0 {
 		existing.AccessHash = group.AccessHash
+		dirty = true
+	}
+	if group.FullInfoAt != nil {
+		existing.FullInfoAt = group.FullInfoAt
+		dirty = true
+	}
+
+	// 被踢出的群组拿到的信息不完整，只更新有值的字段
+	if group.Title != "" && existing.Title != group.Title {
 		existing.Title = group.Title
+		change.Fields = append(change.Fields, "title")
+	}
+	if group.Username != "" && existing.Username != group.Username {
 		existing.Username = group.Username
+		change.Fields = append(change.Fields, "username")
+	}
+	if group.Type != "" && existing.Type != group.Type {
 		existing.Type = group.Type
+		change.Fields = append(change.Fields, "type")
+	}
+	if group.MemberCount > 0 && existing.MemberCount != group.MemberCount {
 		existing.MemberCount = group.MemberCount
+		change.Fields = append(change.Fields, "member_count")
+	}
+	if group.Description != "" && existing.Description != group.Description {
+		existing.Description = group.Description
+		change.Fields = append(change.Fields, "description")
+	}
+
+	if len(change.Fields) > 0 || dirty {
 		if err := db.Save(&existing).Error; err != nil {
 			log.Printf("⚠️ 更新群组失败 [ID: %d]: %v", group.ChatID, err)
+		} else if len(change.Fields) > 0 {
+			log.Printf("🔄 更新群组: %s [ID: %d]", existing.Title, existing.ChatID)
+		}
+	}
+
+	return &existing, change, false
+}
+
+// saveMembership 保存账号在群组中的成员身份，并记录变化
+func saveMembership(db *gorm.DB, accountID uint, group *models.Group, synced *syncedChat, result *GroupSyncResult) {
+	now := time.Now()
+	change := GroupChange{
+		GroupID: group.ID,
+		ChatID:  group.ChatID,
+		Title:   group.Title,
+		Role:    synced.role,
+		Status:  synced.status,
+		CanSend: synced.canSend,
+	}
+
+	var membership models.GroupMembership
+	err := db.Where("account_id = ? AND group_id = ?", accountID, group.ID).First(&membership).Error
+	if err != nil {
+		membership = models.GroupMembership{
+			AccountID: accountID,
+			GroupID:   group.ID,
+		}
+		if synced.status == "member" {
+			result.Joined = append(result.Joined, change)
 		} else {
-			log.Printf("🔄 更新群组: %s [ID: %d]", group.Title, group.ChatID)
+			result.Left = append(result.Left, change)
+		}
+	} else {
+		switch {
+		case membership.Status != "member" && synced.status == "member":
+			result.Joined = append(result.Joined, change)
+		case membership.Status == "member" && synced.status != "member":
+			result.Left = append(result.Left, change)
+		case membership.Role != synced.role || membership.CanSend != synced.canSend:
+			result.RoleChanged = append(result.RoleChanged, change)
+		}
+	}
+
+	if synced.status == "member" {
+		membership.LeftAt = nil
+	} else if membership.Status == "member" || membership.LeftAt == nil {
+		membership.LeftAt = &now
+	}
+	membership.Status = synced.status
+	membership.Role = synced.role
+	membership.CanSend = synced.canSend
+	membership.LastSyncedAt = now
+	if synced.group.AccessHash != 0 {
+		membership.AccessHash = synced.group.AccessHash
+	}
+
+	if err := db.Save(&membership).Error; err != nil {
+		log.Printf("⚠️ 保存成员身份失败 [账号ID: %d, 群组ID: %d]: %v", accountID, group.ID, err)
+	}
+}
+
+// markMissingMemberships 将本次对话列表中不存在的群组标记为已退出
+func markMissingMemberships(db *gorm.DB, accountID uint, seenGroupIDs map[uint]bool, result *GroupSyncResult) {
+	var memberships []models.GroupMembership
+	if err := db.Preload("Group").Where("account_id = ? AND status = ?", accountID, "member").Find(&memberships).Error; err != nil {
+		log.Printf("⚠️ 查询成员身份失败 [账号ID: %d]: %v", accountID, err)
+		return
+	}
+
+	now := time.Now()
+	for _, membership := range memberships {
+		if seenGroupIDs[membership.GroupID] {
+			continue
+		}
+		membership.Status = "left"
+		membership.CanSend = false
+		membership.LeftAt = &now
+		membership.LastSyncedAt = now
+		if err := db.Omit("Account", "Group").Save(&membership).Error; err != nil {
+			log.Printf("⚠️ 更新成员身份失败 [账号ID: %d, 群组ID: %d]: %v", accountID, membership.GroupID, err)
+			continue
+		}
+		result.Left = append(result.Left, GroupChange{
+			GroupID: membership.GroupID,
+			ChatID:  membership.Group.ChatID,
+			Title:   membership.Group.Title,
+			Role:    membership.Role,
+			Status:  membership.Status,
+		})
+	}
+}
+
+// refreshGroupStatuses 根据成员身份更新群组状态：没有任何账号仍在群内的群组标记为 inactive
+func refreshGroupStatuses(db *gorm.DB, accountID uint, result *GroupSyncResult) {
+	var memberships []models.GroupMembership
+	if err := db.Preload("Group").Where("account_id = ?", accountID).Find(&memberships).Error; err != nil {
+		log.Printf("⚠️ 查询成员身份失败 [账号ID: %d]: %v", accountID, err)
+		return
+	}
+
+	for _, membership := range memberships {
+		group := membership.Group
+		if group.ID == 0 {
+			continue
+		}
+
+		var activeCount int64
+		db.Model(&models.GroupMembership{}).
+			Where("group_id = ? AND status = ?", group.ID, "member").
+			Count(&activeCount)
+
+		change := GroupChange{GroupID: group.ID, ChatID: group.ChatID, Title: group.Title}
+		switch {
+		case activeCount == 0 && group.Status != "inactive":
+			db.Model(&group).Update("status", "inactive")
+			change.Status = "inactive"
+			result.Deactivated = append(result.Deactivated, change)
+			log.Printf("⛔ 群组已无可用账号，标记为 inactive: %s [ID: %d]", group.Title, group.ChatID)
+		case activeCount > 0 && group.Status == "inactive":
+			db.Model(&group).Update("status", "active")
+			change.Status = "active"
+			result.Reactivated = append(result.Reactivated, change)
 		}
 	}
 }
@@ -106,9 +622,8 @@ func saveOrUpdateGroup(db *gorm.DB, group *models.Group) {
 func GetGroupAccessHash(ctx context.Context, api *tg.Client, chatID int64) (int64, error) {
 	// 尝试通过ResolveUsername获取（如果有用户名）
 	// 或通过GetFullChannel获取
-	
+
 	// 这里简化处理，实际需要根据群组类型调用不同的API
 	// 暂时返回0，表示需要从数据库获取
 	return 0, fmt.Errorf("需要从数据库获取AccessHash")
 }
-
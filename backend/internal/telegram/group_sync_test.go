@@ -0,0 +1,82 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"aibot/models"
+)
+
+func TestNeedsFullInfo(t *testing.T) {
+	db := testDB(t)
+	fresh := time.Now().Add(-time.Hour)
+	stale := time.Now().Add(-2 * fullInfoTTL)
+	db.Create(&[]models.Group{
+		{ChatID: 1, Title: "fresh", MemberCount: 10, FullInfoAt: &fresh},
+		{ChatID: 2, Title: "never fetched"},
+		{ChatID: 3, Title: "stale", FullInfoAt: &stale},
+	})
+
+	tests := []struct {
+		name  string
+		group models.Group
+		want  bool
+	}{
+		{"new group", models.Group{ChatID: 99, Title: "new"}, true},
+		{"unchanged without member count", models.Group{ChatID: 1, Title: "fresh"}, false},
+		{"unchanged member count", models.Group{ChatID: 1, Title: "fresh", MemberCount: 10}, false},
+		{"title changed", models.Group{ChatID: 1, Title: "renamed"}, true},
+		{"member count changed", models.Group{ChatID: 1, Title: "fresh", MemberCount: 11}, true},
+		{"never fetched", models.Group{ChatID: 2, Title: "never fetched"}, true},
+		{"expired", models.Group{ChatID: 3, Title: "stale"}, true},
+	}
+	for _, tt := range tests {
+		if got := needsFullInfo(db, &tt.group); got != tt.want {
+			t.Errorf("%s: needsFullInfo = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSaveOrUpdateGroupKeepsAccessHash(t *testing.T) {
+	db := testDB(t)
+
+	created, _, ok := saveOrUpdateGroup(db, &models.Group{ChatID: 1, AccessHash: 111, Title: "群组", Type: "supergroup"})
+	if !ok {
+		t.Fatal("group not created")
+	}
+
+	// 另一个账号的 AccessHash 不覆盖群组中保存的，也不算作变化
+	group, change, _ := saveOrUpdateGroup(db, &models.Group{ChatID: 1, AccessHash: 222, Title: "群组", Type: "supergroup"})
+	if len(change.Fields) != 0 {
+		t.Fatalf("fields = %v, want none", change.Fields)
+	}
+	if group.AccessHash != 111 {
+		t.Fatalf("access hash = %d, want 111", group.AccessHash)
+	}
+
+	// 拉取完整信息的时间只记录，不算作变化
+	now := time.Now()
+	_, change, _ = saveOrUpdateGroup(db, &models.Group{ChatID: 1, Title: "群组", FullInfoAt: &now, Description: "群规"})
+	if len(change.Fields) != 1 || change.Fields[0] != "description" {
+		t.Fatalf("fields = %v, want [description]", change.Fields)
+	}
+	var saved models.Group
+	db.First(&saved, created.ID)
+	if saved.FullInfoAt == nil || saved.Description != "群规" {
+		t.Fatalf("full info not saved: %+v", saved)
+	}
+}
+
+func TestChannelAccessHashPerAccount(t *testing.T) {
+	db := testDB(t)
+	group := models.Group{ChatID: 1, AccessHash: 111, Type: "supergroup"}
+	db.Create(&group)
+	db.Create(&models.GroupMembership{AccountID: 2, GroupID: group.ID, Status: "member", AccessHash: 222})
+
+	if got := channelAccessHash(db, 2, 0, &group); got != 222 {
+		t.Errorf("account 2 access hash = %d, want 222", got)
+	}
+	if got := channelAccessHash(db, 3, 0, &group); got != 111 {
+		t.Errorf("account without membership access hash = %d, want group's 111", got)
+	}
+}
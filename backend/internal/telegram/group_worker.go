@@ -0,0 +1,246 @@
+package telegram
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"aibot/internal/ai"
+	"aibot/models"
+)
+
+// maxConcurrentGroups 每个账号同时处理的群组数量上限（AI 生成较慢，单个群组不会阻塞其他群组，同时限制并发请求数）
+const maxConcurrentGroups = 4
+
+// maxChatHistory 每个群组保留的对话上下文条数
+const maxChatHistory = 10
+
+// groupWorkerIdleTimeout 群组处理协程空闲超过该时长后退出（回复状态已保存，再次收到消息时重新创建）
+const groupWorkerIdleTimeout = 10 * time.Minute
+
+// groupWorker 群组处理协程：每个群组一个（开启话题的群组每个话题一个），独占该群组（话题）的回复状态
+// 以下字段只在该群组的处理协程中读写，不需要加锁；回复状态在每轮处理后保存到状态存储
+type groupWorker struct {
+	chatID  int64
+	topicID int // 论坛话题ID，0 表示 General 或未开启话题的群组
+	wake    chan struct{}
+	stop    chan struct{} // 群组取消分配时关闭
+
+	account           models.Account     // 本次处理使用的账号配置快照
+	lastReplyTime     time.Time          // 最近一次发言时间
+	history           []MessageContext   // 对话上下文（用于构建AI对话历史）
+	triggerReplyTimes []time.Time        // 最近一小时内触发回复（@提及/回复我）的时间
+	ruleLastActions   map[uint]time.Time // 规则最近一次执行动作的时间
+}
+
+// newGroupWorker 创建群组处理协程的状态
+func newGroupWorker(key bufferKey) *groupWorker {
+	return &groupWorker{
+		chatID:          key.chatID,
+		topicID:         key.topicID,
+		wake:            make(chan struct{}, 1),
+		stop:            make(chan struct{}),
+		ruleLastActions: make(map[uint]time.Time),
+	}
+}
+
+// dispatchBufferedGroups 唤醒有缓冲消息的群组处理协程（首次出现的群组会创建处理协程）
+func (c *ClientV2) dispatchBufferedGroups(ctx context.Context) {
+	assigned, err := c.assignedChatIDs()
+	if err != nil {
+		log.Printf("⚠️ 获取分配群组失败: %v", err)
+		return
+	}
+	c.stopUnassignedWorkers(assigned)
+
+	c.messageBufferLock.Lock()
+	var keys []bufferKey
+	for key, messages := range c.messageBuffer {
+		if !assigned[key.chatID] {
+			// 群组已取消分配，丢弃缓冲消息
+			delete(c.messageBuffer, key)
+			c.saveBuffer(key)
+			continue
+		}
+		if len(messages) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	c.messageBufferLock.Unlock()
+
+	for _, key := range keys {
+		c.groupWorkersLock.Lock()
+		w, ok := c.groupWorkers[key]
+		if !ok {
+			w = newGroupWorker(key)
+			c.groupWorkers[key] = w
+		}
+		c.groupWorkersLock.Unlock()
+		if !ok {
+			c.loadWorkerState(w)
+			go c.runGroupWorker(ctx, w)
+		}
+
+		// 处理协程正忙时不重复唤醒，新消息留在缓冲区等待下一轮
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// runGroupWorker 群组处理协程：被唤醒后取出该群组的缓冲消息并处理，空闲超时或群组取消分配后退出
+func (c *ClientV2) runGroupWorker(ctx context.Context, w *groupWorker) {
+	defer c.removeGroupWorker(w)
+
+	idle := time.NewTimer(groupWorkerIdleTimeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.stop:
+			return
+		case <-idle.C:
+			return
+		case <-w.wake:
+		}
+
+		// 限制同时处理的群组数量
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.stop:
+			return
+		case c.groupSlots <- struct{}{}:
+		}
+
+		if messages := c.takeBuffered(w.key()); len(messages) > 0 {
+			w.account = c.accountSettings()
+			c.processGroupMessages(ctx, w, messages)
+			c.saveWorkerState(w)
+		}
+
+		<-c.groupSlots
+
+		if !idle.Stop() {
+			select {
+			case <-idle.C:
+			default:
+			}
+		}
+		idle.Reset(groupWorkerIdleTimeout)
+	}
+}
+
+// removeGroupWorker 处理协程退出时从映射中移除（已被新的处理协程替换时保留）
+func (c *ClientV2) removeGroupWorker(w *groupWorker) {
+	c.groupWorkersLock.Lock()
+	defer c.groupWorkersLock.Unlock()
+
+	if c.groupWorkers[w.key()] == w {
+		delete(c.groupWorkers, w.key())
+	}
+}
+
+// stopUnassignedWorkers 停止已取消分配（或停用）的群组的处理协程
+func (c *ClientV2) stopUnassignedWorkers(assigned map[int64]bool) {
+	c.groupWorkersLock.Lock()
+	defer c.groupWorkersLock.Unlock()
+
+	for key, w := range c.groupWorkers {
+		if !assigned[key.chatID] {
+			close(w.stop)
+			delete(c.groupWorkers, key)
+		}
+	}
+}
+
+// assignedChatIDs 获取当前账号启用中的分配群组
+func (c *ClientV2) assignedChatIDs() (map[int64]bool, error) {
+	var chatIDs []int64
+	err := c.DB.Model(&models.Group{}).
+		Joins("JOIN account_groups ON account_groups.group_id = groups.id").
+		Where("account_groups.account_id = ? AND account_groups.enabled = ?", c.ID, true).
+		Pluck("groups.chat_id", &chatIDs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	assigned := make(map[int64]bool, len(chatIDs))
+	for _, chatID := range chatIDs {
+		assigned[chatID] = true
+	}
+	return assigned, nil
+}
+
+// takeBuffered 取出并清空群组（话题）的缓冲消息
+func (c *ClientV2) takeBuffered(key bufferKey) []BufferedMessage {
+	c.messageBufferLock.Lock()
+	defer c.messageBufferLock.Unlock()
+
+	messages := c.messageBuffer[key]
+	c.messageBuffer[key] = make([]BufferedMessage, 0)
+	c.saveBuffer(key)
+	return messages
+}
+
+// key 处理协程对应的缓冲区键
+func (w *groupWorker) key() bufferKey {
+	return bufferKey{chatID: w.chatID, topicID: w.topicID}
+}
+
+// chatHistory 获取对话上下文
+func (w *groupWorker) chatHistory() []ai.ChatMessage {
+	messages := make([]ai.ChatMessage, 0, len(w.history))
+	for _, msg := range w.history {
+		messages = append(messages, ai.ChatMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		})
+	}
+	return messages
+}
+
+// addHistory 添加对话上下文
+func (w *groupWorker) addHistory(userMsg, aiReply string) {
+	w.history = append(w.history,
+		MessageContext{Role: "user", Content: userMsg},
+		MessageContext{Role: "assistant", Content: aiReply},
+	)
+
+	// 保持上下文在合理范围内
+	if len(w.history) > maxChatHistory {
+		w.history = w.history[len(w.history)-maxChatHistory:]
+	}
+}
+
+// allowTriggerReply 检查最近一小时的触发回复数量是否未达上限
+func (w *groupWorker) allowTriggerReply() bool {
+	cutoff := time.Now().Add(-time.Hour)
+	recent := w.triggerReplyTimes[:0]
+	for _, t := range w.triggerReplyTimes {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	w.triggerReplyTimes = recent
+
+	limit := w.mentionReplyLimit()
+	return limit <= 0 || len(recent) < limit
+}
+
+// mentionReplyLimit 每个群组每小时触发回复的上限，0 表示不限制
+func (w *groupWorker) mentionReplyLimit() int {
+	return w.account.MentionReplyLimit
+}
+
+// ruleCooldownPassed 检查规则在该群组的冷却时间是否已过
+func (w *groupWorker) ruleCooldownPassed(rule *models.ReplyRule) bool {
+	if rule.CooldownSeconds <= 0 {
+		return true
+	}
+	last, ok := w.ruleLastActions[rule.ID]
+	return !ok || time.Since(last) >= time.Duration(rule.CooldownSeconds)*time.Second
+}
@@ -0,0 +1,228 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"aibot/internal/config"
+	"aibot/internal/database"
+	"aibot/internal/state"
+	"aibot/models"
+
+	"gorm.io/gorm"
+)
+
+// testDB 内存 SQLite 数据库（已执行迁移）
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := database.Init(config.DatabaseConfig{Driver: database.DriverSQLite, Path: ":memory:"})
+	if err != nil {
+		t.Fatalf("init database: %v", err)
+	}
+	t.Cleanup(func() { database.Close(db) })
+	return db
+}
+
+// testClient 不连接 Telegram 的客户端，自动回复关闭（处理协程取出消息后直接返回）
+func testClient(t *testing.T, db *gorm.DB) *ClientV2 {
+	t.Helper()
+	account := &models.Account{PhoneNumber: "+10000000000", AutoReply: false, BufferSize: 10}
+	if err := db.Create(account).Error; err != nil {
+		t.Fatalf("create account: %v", err)
+	}
+	db.Model(account).Update("auto_reply", false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return &ClientV2{
+		ID:            account.ID,
+		Account:       account,
+		DB:            db,
+		Context:       ctx,
+		Cancel:        cancel,
+		messageBuffer: make(map[bufferKey][]BufferedMessage),
+		bufferDirty:   make(map[bufferKey]bool),
+		groupWorkers:  make(map[bufferKey]*groupWorker),
+		groupSlots:    make(chan struct{}, maxConcurrentGroups),
+		store:         state.NewMemoryStore(),
+	}
+}
+
+// assignGroup 把群组分配给账号
+func assignGroup(t *testing.T, c *ClientV2, chatID int64) models.AccountGroup {
+	t.Helper()
+	group := models.Group{ChatID: chatID, Title: fmt.Sprintf("group %d", chatID), Type: "supergroup"}
+	if err := c.DB.Create(&group).Error; err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	ag := models.AccountGroup{AccountID: c.ID, GroupID: group.ID, Enabled: true}
+	if err := c.DB.Create(&ag).Error; err != nil {
+		t.Fatalf("assign group: %v", err)
+	}
+	return ag
+}
+
+func (c *ClientV2) bufferedCount(key bufferKey) int {
+	c.messageBufferLock.Lock()
+	defer c.messageBufferLock.Unlock()
+	return len(c.messageBuffer[key])
+}
+
+func (c *ClientV2) workerCount() int {
+	c.groupWorkersLock.Lock()
+	defer c.groupWorkersLock.Unlock()
+	return len(c.groupWorkers)
+}
+
+// waitFor 等待条件成立
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAppendAndTakeBufferConcurrent(t *testing.T) {
+	c := testClient(t, testDB(t))
+	key := bufferKey{chatID: 100}
+
+	var wg sync.WaitGroup
+	var taken sync.Map
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				c.appendToBuffer(key.chatID, BufferedMessage{MessageID: i*1000 + j + 1, Content: "hi"})
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				for _, msg := range c.takeBuffered(key) {
+					if _, dup := taken.LoadOrStore(msg.MessageID, true); dup {
+						t.Errorf("message %d taken twice", msg.MessageID)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := c.bufferedCount(key); n > 10 {
+		t.Fatalf("buffer size = %d, want <= 10", n)
+	}
+}
+
+func TestDispatchTakesBufferedMessages(t *testing.T) {
+	c := testClient(t, testDB(t))
+	assignGroup(t, c, 100)
+	assignGroup(t, c, 200)
+
+	for _, chatID := range []int64{100, 200} {
+		c.appendToBuffer(chatID, BufferedMessage{MessageID: 1, Content: "hello"})
+	}
+	// 未分配的群组不创建处理协程，缓冲消息被丢弃
+	c.appendToBuffer(300, BufferedMessage{MessageID: 1, Content: "hello"})
+
+	c.dispatchBufferedGroups(c.Context)
+
+	waitFor(t, "buffers taken", func() bool {
+		return c.bufferedCount(bufferKey{chatID: 100}) == 0 && c.bufferedCount(bufferKey{chatID: 200}) == 0
+	})
+	if n := c.bufferedCount(bufferKey{chatID: 300}); n != 0 {
+		t.Fatalf("unassigned group buffer = %d, want 0", n)
+	}
+	if n := c.workerCount(); n != 2 {
+		t.Fatalf("workers = %d, want 2", n)
+	}
+}
+
+func TestDispatchRespectsGroupSlots(t *testing.T) {
+	c := testClient(t, testDB(t))
+	assignGroup(t, c, 100)
+
+	// 占满并发名额，处理协程只能等待
+	for i := 0; i < maxConcurrentGroups; i++ {
+		c.groupSlots <- struct{}{}
+	}
+
+	key := bufferKey{chatID: 100}
+	c.appendToBuffer(key.chatID, BufferedMessage{MessageID: 1, Content: "hello"})
+	c.dispatchBufferedGroups(c.Context)
+
+	time.Sleep(100 * time.Millisecond)
+	if n := c.bufferedCount(key); n != 1 {
+		t.Fatalf("buffer = %d while slots are full, want 1", n)
+	}
+
+	<-c.groupSlots
+	waitFor(t, "buffer taken after a slot is released", func() bool { return c.bufferedCount(key) == 0 })
+	waitFor(t, "slot released", func() bool { return len(c.groupSlots) == maxConcurrentGroups-1 })
+}
+
+func TestUnassignedGroupWorkerStops(t *testing.T) {
+	c := testClient(t, testDB(t))
+	ag := assignGroup(t, c, 100)
+
+	key := bufferKey{chatID: 100}
+	c.appendToBuffer(key.chatID, BufferedMessage{MessageID: 1, Content: "hello"})
+	c.dispatchBufferedGroups(c.Context)
+	waitFor(t, "worker started", func() bool { return c.workerCount() == 1 })
+
+	c.groupWorkersLock.Lock()
+	w := c.groupWorkers[key]
+	c.groupWorkersLock.Unlock()
+
+	c.DB.Model(&ag).Update("enabled", false)
+	c.dispatchBufferedGroups(c.Context)
+
+	if n := c.workerCount(); n != 0 {
+		t.Fatalf("workers = %d after unassign, want 0", n)
+	}
+	select {
+	case <-w.stop:
+	default:
+		t.Fatal("worker was not stopped")
+	}
+}
+
+func TestGetAllClientsConcurrent(t *testing.T) {
+	m := NewManager(config.TelegramConfig{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(id uint) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				m.mu.Lock()
+				m.clients[id] = &ClientV2{ID: id}
+				m.mu.Unlock()
+				m.SetAuthHelper(id, nil)
+			}
+		}(uint(i + 1))
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for id, client := range m.GetAllClients() {
+					if client.(*ClientV2).ID != id {
+						t.Errorf("client %d mapped to %d", client.(*ClientV2).ID, id)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := len(m.GetAllClients()); n != 8 {
+		t.Fatalf("clients = %d, want 8", n)
+	}
+}
@@ -0,0 +1,159 @@
+package telegram
+
+import (
+	"log"
+	"time"
+
+	"aibot/models"
+
+	"github.com/gotd/td/tg"
+)
+
+// messageChatID 获取群组消息所在的群组ID，私聊消息返回 false
+func messageChatID(message *tg.Message) (int64, bool) {
+	switch p := message.PeerID.(type) {
+	case *tg.PeerChannel:
+		return p.ChannelID, true
+	case *tg.PeerChat:
+		return p.ChatID, true
+	}
+	return 0, false
+}
+
+// handleEditedMessage 群友编辑了消息：更新存档，并同步缓冲区中尚未处理的内容
+func (c *ClientV2) handleEditedMessage(msg tg.MessageClass) error {
+	message, ok := msg.(*tg.Message)
+	if !ok || message.Out {
+		return nil
+	}
+	chatID, ok := messageChatID(message)
+	if !ok {
+		return nil
+	}
+
+	var record models.InboundMessage
+	if err := c.DB.Where("account_id = ? AND chat_id = ? AND message_id = ?", c.Account.ID, chatID, message.ID).
+		First(&record).Error; err != nil {
+		// 未存档的消息（未分配的群组或存档前的历史消息）不处理
+		return nil
+	}
+
+	// 反应、置顶等也会产生编辑更新，内容没有变化时忽略
+	if record.Content == message.Message {
+		return nil
+	}
+
+	editedAt := time.Now()
+	if date, ok := message.GetEditDate(); ok {
+		editedAt = time.Unix(int64(date), 0)
+	}
+	original := record.OriginalContent
+	if record.EditCount == 0 {
+		original = record.Content
+	}
+
+	if err := c.DB.Model(&record).Updates(map[string]interface{}{
+		"content":          message.Message,
+		"original_content": original,
+		"edit_count":       record.EditCount + 1,
+		"edited_at":        editedAt,
+	}).Error; err != nil {
+		log.Printf("⚠️ 更新消息存档失败 [群组ID: %d, 消息ID: %d]: %v", chatID, message.ID, err)
+		return nil
+	}
+
+	c.updateBufferedContent(bufferKey{chatID: chatID, topicID: record.TopicID}, message.ID, message.Message)
+
+	if c.repliedTo(record.GroupID, []int{message.ID}) > 0 {
+		log.Printf("✏️ 已回复的消息被编辑 [群组ID: %d, 消息ID: %d]: %s -> %s", chatID, message.ID, truncateStr(record.Content, 50), truncateStr(message.Message, 50))
+	} else {
+		log.Printf("✏️ 消息被编辑 [群组ID: %d, 消息ID: %d]", chatID, message.ID)
+	}
+	return nil
+}
+
+// handleDeletedChannelMessages 超级群 / 频道中的消息被删除
+func (c *ClientV2) handleDeletedChannelMessages(channelID int64, msgIDs []int) error {
+	var records []models.InboundMessage
+	c.DB.Where("account_id = ? AND chat_id = ? AND message_id IN ? AND removed_at IS NULL", c.Account.ID, channelID, msgIDs).
+		Find(&records)
+	c.markRemoved(records)
+	return nil
+}
+
+// handleDeletedMessages 普通群中的消息被删除
+// 普通群和私聊的消息ID在账号内唯一，更新中不包含群组ID，只能按消息ID在普通群的存档中查找
+func (c *ClientV2) handleDeletedMessages(msgIDs []int) error {
+	basicGroups := c.DB.Model(&models.Group{}).Select("chat_id").Where("type = ?", "group")
+
+	var records []models.InboundMessage
+	c.DB.Where("account_id = ? AND message_id IN ? AND removed_at IS NULL AND chat_id IN (?)", c.Account.ID, msgIDs, basicGroups).
+		Find(&records)
+	c.markRemoved(records)
+	return nil
+}
+
+// markRemoved 标记存档消息已被删除，并从缓冲区中移除
+func (c *ClientV2) markRemoved(records []models.InboundMessage) {
+	if len(records) == 0 {
+		return
+	}
+
+	ids := make([]uint, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+	if err := c.DB.Model(&models.InboundMessage{}).Where("id IN ?", ids).Update("removed_at", time.Now()).Error; err != nil {
+		log.Printf("⚠️ 标记消息删除失败: %v", err)
+		return
+	}
+
+	for _, record := range records {
+		c.removeBuffered(bufferKey{chatID: record.ChatID, topicID: record.TopicID}, record.MessageID)
+		if c.repliedTo(record.GroupID, []int{record.MessageID}) > 0 {
+			log.Printf("🗑️ 已回复的消息被删除 [群组ID: %d, 消息ID: %d]: %s", record.ChatID, record.MessageID, truncateStr(record.Content, 50))
+		}
+	}
+	log.Printf("🗑️ %d 条消息被删除", len(records))
+}
+
+// repliedTo 统计当前账号在群组中引用回复过这些消息的发言数量
+func (c *ClientV2) repliedTo(groupID uint, msgIDs []int) int64 {
+	var count int64
+	c.DB.Model(&models.Message{}).
+		Where("account_id = ? AND group_id = ? AND reply_to_message_id IN ?", c.Account.ID, groupID, msgIDs).
+		Count(&count)
+	return count
+}
+
+// updateBufferedContent 同步缓冲区中被编辑消息的内容
+func (c *ClientV2) updateBufferedContent(key bufferKey, msgID int, content string) {
+	c.messageBufferLock.Lock()
+	defer c.messageBufferLock.Unlock()
+
+	for i, msg := range c.messageBuffer[key] {
+		if msg.MessageID == msgID {
+			if content == "" {
+				content = mediaPlaceholder(msg.MessageType)
+			}
+			c.messageBuffer[key][i].Content = content
+			c.saveBuffer(key)
+			return
+		}
+	}
+}
+
+// removeBuffered 从缓冲区中移除已被删除的消息
+func (c *ClientV2) removeBuffered(key bufferKey, msgID int) {
+	c.messageBufferLock.Lock()
+	defer c.messageBufferLock.Unlock()
+
+	messages := c.messageBuffer[key]
+	for i, msg := range messages {
+		if msg.MessageID == msgID {
+			c.messageBuffer[key] = append(messages[:i], messages[i+1:]...)
+			c.saveBuffer(key)
+			return
+		}
+	}
+}
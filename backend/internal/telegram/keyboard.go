@@ -0,0 +1,387 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"aibot/models"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"gorm.io/gorm"
+)
+
+// 回调动作
+const (
+	CallbackActionAnswer = "answer" // 弹出提示
+	CallbackActionEdit   = "edit"   // 编辑按钮所在的消息
+	CallbackActionAI     = "ai"     // 调用AI回复按钮所在的消息
+)
+
+// 内联键盘限制（Telegram 的限制）
+const (
+	maxKeyboardButtons = 100 // 每条消息最多的按钮数
+	maxRowButtons      = 8   // 每行最多的按钮数
+	maxCallbackData    = 64  // 回调数据最大字节数
+	maxCallbackAnswer  = 200 // 回调提示最大字符数
+)
+
+// callbackTimeout 单次回调的处理超时（提示需在约15秒内返回，AI回复通过发送队列发送）
+const callbackTimeout = 2 * time.Minute
+
+// callbackAICooldown 同一用户在同一条消息上重复点击AI按钮的冷却时间
+const callbackAICooldown = time.Minute
+
+// 按钮点击处理结果
+const (
+	CallbackClickHandled   = "handled"
+	CallbackClickUnmatched = "unmatched"
+	CallbackClickFailed    = "failed"
+)
+
+// ValidateKeyboard 校验内联键盘
+func ValidateKeyboard(keyboard models.InlineKeyboard) error {
+	total := 0
+	for i, row := range keyboard {
+		if len(row) == 0 {
+			return fmt.Errorf("第 %d 行按钮为空", i+1)
+		}
+		if len(row) > maxRowButtons {
+			return fmt.Errorf("每行最多 %d 个按钮", maxRowButtons)
+		}
+		for _, button := range row {
+			if strings.TrimSpace(button.Text) == "" {
+				return fmt.Errorf("按钮文字不能为空")
+			}
+			switch {
+			case button.URL != "" && button.Data != "":
+				return fmt.Errorf("按钮 %s 只能设置 url 或 data 之一", button.Text)
+			case button.URL != "":
+				if !strings.HasPrefix(button.URL, "https://") && !strings.HasPrefix(button.URL, "http://") && !strings.HasPrefix(button.URL, "tg://") {
+					return fmt.Errorf("按钮 %s 的链接无效: %s", button.Text, button.URL)
+				}
+			case button.Data != "":
+				if len(button.Data) > maxCallbackData {
+					return fmt.Errorf("按钮 %s 的回调数据超过 %d 字节", button.Text, maxCallbackData)
+				}
+			default:
+				return fmt.Errorf("按钮 %s 需要设置 url 或 data", button.Text)
+			}
+		}
+		total += len(row)
+	}
+	if total > maxKeyboardButtons {
+		return fmt.Errorf("按钮总数不能超过 %d 个", maxKeyboardButtons)
+	}
+	return nil
+}
+
+// ValidateCallbackAction 校验回调动作配置
+func ValidateCallbackAction(action *models.CallbackAction) error {
+	action.Data = strings.TrimSpace(action.Data)
+	if action.Data == "" {
+		return fmt.Errorf("回调数据不能为空")
+	}
+	if len(strings.TrimSuffix(action.Data, "*")) > maxCallbackData {
+		return fmt.Errorf("回调数据超过 %d 字节", maxCallbackData)
+	}
+	switch action.Action {
+	case CallbackActionAnswer:
+		if utf8.RuneCountInString(action.Text) > maxCallbackAnswer {
+			return fmt.Errorf("提示内容最多 %d 个字符", maxCallbackAnswer)
+		}
+	case CallbackActionEdit:
+		if strings.TrimSpace(action.Text) == "" {
+			return fmt.Errorf("新的消息内容不能为空")
+		}
+	case CallbackActionAI:
+	default:
+		return fmt.Errorf("不支持的回调动作: %s", action.Action)
+	}
+	return ValidateKeyboard(action.Buttons)
+}
+
+// inlineMarkup 将内联键盘转换为 Telegram 的按钮结构
+func inlineMarkup(keyboard models.InlineKeyboard) tg.ReplyMarkupClass {
+	if len(keyboard) == 0 {
+		return nil
+	}
+	rows := make([]tg.KeyboardButtonRow, 0, len(keyboard))
+	for _, row := range keyboard {
+		buttons := make([]tg.KeyboardButtonClass, 0, len(row))
+		for _, button := range row {
+			if button.URL != "" {
+				buttons = append(buttons, &tg.KeyboardButtonURL{Text: button.Text, URL: button.URL})
+			} else {
+				buttons = append(buttons, &tg.KeyboardButtonCallback{Text: button.Text, Data: []byte(button.Data)})
+			}
+		}
+		rows = append(rows, tg.KeyboardButtonRow{Buttons: buttons})
+	}
+	return &tg.ReplyInlineMarkup{Rows: rows}
+}
+
+// replyMarkup 构造发送消息时附带的按钮，用户账号不能发送内联键盘
+func (c *ClientV2) replyMarkup(keyboard models.InlineKeyboard) tg.ReplyMarkupClass {
+	if len(keyboard) == 0 {
+		return nil
+	}
+	if !c.bot {
+		log.Printf("⚠️ 用户账号不能发送内联键盘，已忽略按钮 [账号ID: %d]", c.Account.ID)
+		return nil
+	}
+	return inlineMarkup(keyboard)
+}
+
+// FindCallbackAction 查找与回调数据匹配的动作：完全匹配优先于前缀匹配，前缀越长越优先，范围越具体越优先
+// 匹配到的动作已停用时返回 false（可用于在群组中停用全局动作）
+func FindCallbackAction(db *gorm.DB, groupID, accountID uint, data string) (*models.CallbackAction, bool) {
+	var actions []models.CallbackAction
+	if err := db.Where("(group_id IS NULL OR group_id = ?) AND (account_id IS NULL OR account_id = ?)", groupID, accountID).
+		Find(&actions).Error; err != nil {
+		log.Printf("⚠️ 加载回调动作失败: %v", err)
+		return nil, false
+	}
+
+	var best *models.CallbackAction
+	bestRank := -1
+	for i := range actions {
+		action := &actions[i]
+		var rank int
+		if prefix, ok := strings.CutSuffix(action.Data, "*"); ok {
+			if !strings.HasPrefix(data, prefix) {
+				continue
+			}
+			rank = len(prefix) * 4
+		} else if action.Data == data {
+			rank = (maxCallbackData + 1) * 4
+		} else {
+			continue
+		}
+		rank += callbackScope(action)
+		if rank > bestRank {
+			best, bestRank = action, rank
+		}
+	}
+	if best == nil || !best.Enabled {
+		return nil, false
+	}
+	return best, true
+}
+
+// callbackScope 回调动作的作用范围（数值越大越具体）
+func callbackScope(action *models.CallbackAction) int {
+	scope := 0
+	if action.GroupID != nil {
+		scope += 2
+	}
+	if action.AccountID != nil {
+		scope++
+	}
+	return scope
+}
+
+// handleCallbackQuery 处理群成员点击回调按钮
+func (c *ClientV2) handleCallbackQuery(update *tg.UpdateBotCallbackQuery, users map[int64]*tg.User) {
+	var chatID int64
+	switch p := update.Peer.(type) {
+	case *tg.PeerChannel:
+		chatID = p.ChannelID
+	case *tg.PeerChat:
+		chatID = p.ChatID
+	default:
+		// 私聊中的按钮暂不处理，只结束客户端的加载状态
+		go c.answerCallback(c.Context, update.QueryID, "", false)
+		return
+	}
+
+	click := &models.CallbackClick{
+		AccountID:         c.Account.ID,
+		TelegramMessageID: int64(update.MsgID),
+		UserID:            update.UserID,
+		Data:              string(update.Data),
+	}
+	if user, ok := users[update.UserID]; ok {
+		click.UserName = strings.TrimSpace(user.FirstName + " " + user.LastName)
+		if user.Username != "" {
+			click.UserName = "@" + user.Username
+		}
+	}
+
+	var group models.Group
+	if err := c.DB.Where("chat_id = ?", chatID).First(&group).Error; err != nil {
+		go c.answerCallback(c.Context, update.QueryID, "", false)
+		return
+	}
+	click.GroupID = group.ID
+
+	// 入群验证按钮
+	if strings.HasPrefix(click.Data, captchaCallbackPrefix) {
+		go c.handleCaptchaCallback(update.QueryID, click)
+		return
+	}
+
+	log.Printf("🔘 收到按钮回调 [群组ID: %d, 用户: %s, 数据: %s]", chatID, click.UserName, click.Data)
+	go c.runCallback(chatID, update.QueryID, click)
+}
+
+// runCallback 执行回调动作并记录点击
+func (c *ClientV2) runCallback(chatID int64, queryID int64, click *models.CallbackClick) {
+	ctx, cancel := context.WithTimeout(c.Context, callbackTimeout)
+	defer cancel()
+
+	action, ok := FindCallbackAction(c.DB, click.GroupID, c.Account.ID, click.Data)
+	if !ok {
+		c.answerCallback(ctx, queryID, "", false)
+		click.Status = CallbackClickUnmatched
+		c.DB.Create(click)
+		return
+	}
+	click.CallbackActionID = &action.ID
+
+	err := c.executeCallbackAction(ctx, chatID, queryID, action, click)
+	if err != nil {
+		log.Printf("❌ 处理按钮回调失败 [数据: %s]: %v", click.Data, err)
+		click.Status = CallbackClickFailed
+		click.Error = err.Error()
+	} else {
+		click.Status = CallbackClickHandled
+	}
+	c.DB.Create(click)
+	c.DB.Model(&models.CallbackAction{}).Where("id = ?", action.ID).UpdateColumns(map[string]interface{}{
+		"hit_count":   gorm.Expr("hit_count + ?", 1),
+		"last_hit_at": time.Now(),
+	})
+}
+
+// executeCallbackAction 执行回调动作
+func (c *ClientV2) executeCallbackAction(ctx context.Context, chatID int64, queryID int64, action *models.CallbackAction, click *models.CallbackClick) error {
+	text := renderCallbackTemplate(action.Text, click, c.groupTitle(click.GroupID))
+
+	switch action.Action {
+	case CallbackActionAnswer:
+		return c.answerCallback(ctx, queryID, text, action.Alert)
+
+	case CallbackActionEdit:
+		c.answerCallback(ctx, queryID, "", false)
+		err := c.editMessage(ctx, chatID, int(click.TelegramMessageID), text, inlineMarkup(action.Buttons))
+		if err != nil && tgerr.Is(err, "MESSAGE_NOT_MODIFIED") {
+			// 重复点击，消息内容没有变化
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		c.DB.Model(&models.Message{}).
+			Where("account_id = ? AND group_id = ? AND telegram_message_id = ?", c.Account.ID, click.GroupID, click.TelegramMessageID).
+			Update("content", text)
+		log.Printf("✏️ 已编辑按钮所在的消息 [群组ID: %d, 消息ID: %d]", chatID, click.TelegramMessageID)
+		return nil
+
+	case CallbackActionAI:
+		if !c.callbackCooldownPassed(click) {
+			return c.answerCallback(ctx, queryID, "⏳ 请稍后再试", false)
+		}
+		c.answerCallback(ctx, queryID, text, false)
+		reply, err := c.answerCallbackWithAI(ctx, action, click)
+		if err != nil {
+			return err
+		}
+		topicID := c.sentMessageTopic(click.GroupID, click.TelegramMessageID)
+		if err := c.enqueueReplyWithButtons(chatID, topicID, reply, int(click.TelegramMessageID), "callback", action.Buttons); err != nil {
+			return err
+		}
+		log.Printf("✅ 按钮回调的AI回复已加入发送队列: %s", truncateStr(reply, 100))
+		return nil
+	}
+	return fmt.Errorf("不支持的回调动作: %s", action.Action)
+}
+
+// callbackCooldownPassed 检查并记录用户点击AI按钮的时间（同一用户在同一条消息上冷却期内不重复调用AI）
+func (c *ClientV2) callbackCooldownPassed(click *models.CallbackClick) bool {
+	key := c.stateKey("cooldown:callback:%d:%d:%d:%s", click.GroupID, click.TelegramMessageID, click.UserID, click.Data)
+	return c.tryCooldown(key, callbackAICooldown)
+}
+
+// answerCallbackWithAI 调用AI回复按钮所在的消息
+func (c *ClientV2) answerCallbackWithAI(ctx context.Context, action *models.CallbackAction, click *models.CallbackClick) (string, error) {
+	sender := click.UserName
+	if sender == "" {
+		sender = "群友"
+	}
+
+	var message models.Message
+	c.DB.Where("account_id = ? AND group_id = ? AND telegram_message_id = ?", c.Account.ID, click.GroupID, click.TelegramMessageID).
+		First(&message)
+
+	prompt := fmt.Sprintf("%s 点击了你发送的消息下方的按钮（%s），请直接回复（直接输出你的回答）。", sender, click.Data)
+	if message.Content != "" {
+		prompt += fmt.Sprintf("\n\n你发送的消息：\n%s", message.Content)
+	}
+	if strings.TrimSpace(action.Instruction) != "" {
+		prompt += fmt.Sprintf("\n\n回复要求：%s", action.Instruction)
+	}
+
+	account := c.accountSettings()
+	reply, err := c.AIService.GenerateReply(ctx, account.AIApiKey, account.AIModel, account.SystemPrompt, prompt, nil)
+	if err != nil {
+		return "", err
+	}
+	if reply == "" {
+		return "", fmt.Errorf("AI未生成回复内容")
+	}
+	return reply, nil
+}
+
+// answerCallback 结束按钮的加载状态，text 不为空时显示提示
+func (c *ClientV2) answerCallback(ctx context.Context, queryID int64, text string, alert bool) error {
+	_, err := c.TGClient.API().MessagesSetBotCallbackAnswer(ctx, &tg.MessagesSetBotCallbackAnswerRequest{
+		QueryID: queryID,
+		Message: text,
+		Alert:   alert,
+	})
+	if err != nil {
+		// 超时未回应的回调会失效，不影响后续动作
+		log.Printf("⚠️ 回应按钮回调失败: %v", err)
+	}
+	return err
+}
+
+// editMessage 编辑账号发送的消息（markup 为空时移除按钮）
+func (c *ClientV2) editMessage(ctx context.Context, chatID int64, msgID int, text string, markup tg.ReplyMarkupClass) error {
+	peer, err := c.resolvePeer(ctx, chatID)
+	if err != nil {
+		return err
+	}
+
+	req := &tg.MessagesEditMessageRequest{
+		Peer:    peer,
+		ID:      msgID,
+		Message: text,
+	}
+	if markup != nil {
+		req.SetReplyMarkup(markup)
+	}
+
+	return c.retry(ctx, chatID, func() error {
+		_, err := c.TGClient.API().MessagesEditMessage(ctx, req)
+		return err
+	})
+}
+
+// renderCallbackTemplate 渲染回调动作的提示或新消息内容
+func renderCallbackTemplate(template string, click *models.CallbackClick, groupTitle string) string {
+	sender := click.UserName
+	if sender == "" {
+		sender = "群友"
+	}
+	return strings.NewReplacer(
+		"{sender}", sender,
+		"{group}", groupTitle,
+		"{data}", click.Data,
+	).Replace(template)
+}
@@ -0,0 +1,144 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"aibot/models"
+)
+
+// 账号租约：每个账号的客户端只在持有租约的实例上运行，实例停止续期（宕机）后由其他实例接管
+const (
+	accountLeaseTTL   = 30 * time.Second
+	accountLeaseRenew = 10 * time.Second
+)
+
+// ErrAccountOwned 账号正在其他实例上运行
+var ErrAccountOwned = errors.New("账号正在其他实例上运行")
+
+// accountLeaseKey 账号租约在状态存储中的键
+func accountLeaseKey(accountID uint) string {
+	return fmt.Sprintf("lease:account:%d", accountID)
+}
+
+// acquireAccount 获取或续期账号租约，已被其他实例持有时返回 ErrAccountOwned
+func (m *Manager) acquireAccount(accountID uint) error {
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+
+	key := accountLeaseKey(accountID)
+	acquired, err := m.store.Acquire(ctx, key, m.instanceID, accountLeaseTTL)
+	if err != nil {
+		return fmt.Errorf("获取账号租约失败: %w", err)
+	}
+	if !acquired {
+		owner, _ := m.store.Owner(ctx, key)
+		return fmt.Errorf("%w（实例: %s）", ErrAccountOwned, owner)
+	}
+	return nil
+}
+
+// releaseAccount 释放账号租约，其他实例可以立即接管
+func (m *Manager) releaseAccount(accountID uint) {
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+
+	if err := m.store.Release(ctx, accountLeaseKey(accountID), m.instanceID); err != nil {
+		log.Printf("⚠️ 释放账号租约失败 [ID: %d]: %v", accountID, err)
+	}
+}
+
+// clientNotFound 本实例没有账号的客户端时的错误（账号在其他实例上运行时提示所在实例）
+func (m *Manager) clientNotFound(accountID uint) error {
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+
+	if owner, err := m.store.Owner(ctx, accountLeaseKey(accountID)); err == nil && owner != "" && owner != m.instanceID {
+		return fmt.Errorf("%w（实例: %s），请在该实例上操作 [account_id=%d]", ErrAccountOwned, owner, accountID)
+	}
+	return fmt.Errorf("未找到账号对应的Telegram客户端 [account_id=%d]", accountID)
+}
+
+// startLeaseKeeper 定期续期本实例运行的账号租约，并接管没有实例运行的账号
+func (m *Manager) startLeaseKeeper() {
+	ticker := time.NewTicker(accountLeaseRenew)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		m.renewLeases()
+		m.takeOverAccounts()
+	}
+}
+
+// renewLeases 续期本实例运行的账号租约：租约已被其他实例接管（如本实例长时间卡顿）或账号已被删除时停止客户端
+func (m *Manager) renewLeases() {
+	m.mu.RLock()
+	accountIDs := make([]uint, 0, len(m.supervisors))
+	for id := range m.supervisors {
+		accountIDs = append(accountIDs, id)
+	}
+	m.mu.RUnlock()
+	if len(accountIDs) == 0 {
+		return
+	}
+
+	// 账号可能在其他实例上被删除
+	var existing []uint
+	if err := m.db.Model(&models.Account{}).Where("id IN ?", accountIDs).Pluck("id", &existing).Error; err != nil {
+		log.Printf("⚠️ 查询账号失败: %v", err)
+		return
+	}
+	exists := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		exists[id] = true
+	}
+
+	for _, id := range accountIDs {
+		if !exists[id] {
+			log.Printf("🗑️ 账号 [ID: %d] 已被删除，停止客户端", id)
+			m.RemoveClient(id)
+			continue
+		}
+
+		err := m.acquireAccount(id)
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrAccountOwned):
+			log.Printf("⚠️ 账号 [ID: %d] 已由其他实例接管，停止本实例的客户端: %v", id, err)
+			m.stopClient(id)
+		default:
+			// 状态存储不可用时其他实例同样无法获取租约，继续运行
+			log.Printf("⚠️ 续期账号租约失败 [ID: %d]: %v", id, err)
+		}
+	}
+}
+
+// takeOverAccounts 启动没有任何实例运行的启用账号（原实例宕机、租约过期后）
+func (m *Manager) takeOverAccounts() {
+	var accounts []models.Account
+	if err := m.db.Where("enabled = ?", true).Find(&accounts).Error; err != nil {
+		log.Printf("⚠️ 查询账号失败: %v", err)
+		return
+	}
+
+	for i := range accounts {
+		account := &accounts[i]
+		m.mu.RLock()
+		_, running := m.supervisors[account.ID]
+		m.mu.RUnlock()
+		if running {
+			continue
+		}
+
+		if err := m.AddClient(account); err != nil {
+			if !errors.Is(err, ErrAccountOwned) {
+				log.Printf("❌ 接管账号 [ID: %d] 失败: %v", account.ID, err)
+			}
+			continue
+		}
+		log.Printf("🔁 已接管账号 [ID: %d]，客户端在本实例 [%s] 上启动", account.ID, m.instanceID)
+	}
+}
@@ -0,0 +1,47 @@
+package telegram
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"aibot/internal/config"
+	"aibot/internal/state"
+	"aibot/models"
+)
+
+func TestAddClientReleasesLeaseOnFailure(t *testing.T) {
+	// 会话目录无法创建（data 是文件），客户端创建失败
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	if err := os.WriteFile("data", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := state.NewMemoryStore()
+	m := NewManager(config.TelegramConfig{})
+	m.SetDB(testDB(t))
+	m.SetStateStore(store, "instance-a")
+
+	account := &models.Account{ID: 1, PhoneNumber: "+10000000000"}
+	if err := m.AddClient(account); err == nil {
+		t.Fatal("AddClient should fail when the session directory cannot be created")
+	}
+
+	if owner, _ := store.Owner(context.Background(), accountLeaseKey(account.ID)); owner != "" {
+		t.Fatalf("lease owner = %q after failed AddClient, want released", owner)
+	}
+	// 其他实例可以立即接管
+	other := NewManager(config.TelegramConfig{})
+	other.SetStateStore(store, "instance-b")
+	if err := other.acquireAccount(account.ID); err != nil {
+		t.Fatalf("acquire from another instance: %v", err)
+	}
+}
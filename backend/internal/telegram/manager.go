@@ -1,13 +1,14 @@
 package telegram
 
 import (
-	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
 
 	"aibot/internal/ai"
 	"aibot/internal/config"
+	"aibot/internal/state"
 	"aibot/models"
 
 	"gorm.io/gorm"
@@ -18,9 +19,14 @@ type Manager struct {
 	config      config.TelegramConfig
 	clients     map[uint]ClientInterface
 	authHelpers map[uint]*AuthHelper // 认证助手映射
+	supervisors map[uint]*supervisor // 客户端守护（负责自动重启）
 	aiService   *ai.Service
 	db          *gorm.DB
 	mu          sync.RWMutex
+
+	// 运行状态存储和本实例标识（多实例部署时通过账号租约保证每个账号只在一个实例上运行）
+	store      state.Store
+	instanceID string
 }
 
 // ClientInterface 客户端接口
@@ -35,7 +41,10 @@ func NewManager(cfg config.TelegramConfig) *Manager {
 		config:      cfg,
 		clients:     make(map[uint]ClientInterface),
 		authHelpers: make(map[uint]*AuthHelper),
+		supervisors: make(map[uint]*supervisor),
 		aiService:   ai.NewService(),
+		store:       state.NewMemoryStore(),
+		instanceID:  "local",
 	}
 }
 
@@ -44,6 +53,12 @@ func (m *Manager) SetDB(db *gorm.DB) {
 	m.db = db
 }
 
+// SetStateStore 设置运行状态存储和本实例标识
+func (m *Manager) SetStateStore(store state.Store, instanceID string) {
+	m.store = store
+	m.instanceID = instanceID
+}
+
 // Start 启动管理器
 func (m *Manager) Start() error {
 	log.Println("📱 Telegram客户端管理器启动中...")
@@ -54,31 +69,53 @@ func (m *Manager) Start() error {
 		return err
 	}
 
-	// 为每个账号启动客户端
+	// 为每个账号启动客户端（已由其他实例运行的账号跳过）
 	for _, account := range accounts {
 		if err := m.AddClient(&account); err != nil {
+			if errors.Is(err, ErrAccountOwned) {
+				log.Printf("⏭️ 跳过账号 [ID: %d]: %v", account.ID, err)
+				continue
+			}
 			log.Printf("❌ 启动账号 [ID: %d] 失败: %v", account.ID, err)
 			continue
 		}
 	}
 
-	log.Printf("✅ 已启动 %d 个Telegram客户端", len(m.clients))
+	m.mu.RLock()
+	started := len(m.clients)
+	m.mu.RUnlock()
+	log.Printf("✅ 已启动 %d 个Telegram客户端 [实例: %s]", started, m.instanceID)
+
+	// 续期账号租约，并接管其他实例停止运行的账号
+	go m.startLeaseKeeper()
+
+	// 启动定时公告调度
+	go m.startScheduler()
 	return nil
 }
 
-// AddClient 添加客户端
+// AddClient 添加客户端（账号正在其他实例上运行时返回 ErrAccountOwned）
 func (m *Manager) AddClient(account *models.Account) error {
+	if err := m.acquireAccount(account.ID); err != nil {
+		return err
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// 如果客户端已存在，先停止
-	if client, ok := m.clients[account.ID]; ok {
+	// 如果客户端已存在，先停止（包括其守护，避免旧客户端被自动重启）
+	if sup, ok := m.supervisors[account.ID]; ok {
+		sup.stop()
+		delete(m.supervisors, account.ID)
+	} else if client, ok := m.clients[account.ID]; ok {
 		client.Stop()
 	}
 
 	// 创建新客户端（使用改进版）
-	client, err := NewClientV2(account, m.db, m.aiService)
+	client, err := m.newClient(account)
 	if err != nil {
+		// 客户端未能创建，释放租约，让账号可以在其他实例上运行
+		m.releaseAccount(account.ID)
 		return err
 	}
 
@@ -88,30 +125,55 @@ func (m *Manager) AddClient(account *models.Account) error {
 		m.authHelpers[account.ID] = client.AuthHelper
 	}
 
-	// 启动客户端（异步）
-	go func() {
-		if err := client.Start(); err != nil {
-			log.Printf("❌ 客户端 [ID: %d] 运行失败: %v", account.ID, err)
-			// 更新账号状态为错误
-			account.Status = "error"
-			m.db.Save(account)
-		}
-	}()
+	// 启动客户端（异步，运行失败时由守护按退避策略自动重启）
+	sup := newSupervisor(m, account)
+	m.supervisors[account.ID] = sup
+	go sup.run(client)
 
 	return nil
 }
 
-// RemoveClient 移除客户端
+// RemoveClient 移除客户端，并释放账号租约
 func (m *Manager) RemoveClient(accountID uint) error {
+	m.stopClient(accountID)
+	m.releaseAccount(accountID)
+	return nil
+}
+
+// stopClient 停止并移除本实例上的客户端
+func (m *Manager) stopClient(accountID uint) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if client, ok := m.clients[accountID]; ok {
+	if sup, ok := m.supervisors[accountID]; ok {
+		sup.stop()
+		delete(m.supervisors, accountID)
+	} else if client, ok := m.clients[accountID]; ok {
 		client.Stop()
-		delete(m.clients, accountID)
 	}
+	delete(m.clients, accountID)
+}
 
-	return nil
+// newClient 创建客户端，使用共享的状态存储并恢复缓冲消息
+func (m *Manager) newClient(account *models.Account) (*ClientV2, error) {
+	client, err := NewClientV2(account, m.db, m.aiService)
+	if err != nil {
+		return nil, err
+	}
+	client.store = m.store
+	client.restoreBuffers()
+	return client, nil
+}
+
+// GetClientRuntime 获取客户端运行时状态，没有运行中的客户端时返回 stopped
+func (m *Manager) GetClientRuntime(accountID uint) ClientRuntime {
+	m.mu.RLock()
+	sup, ok := m.supervisors[accountID]
+	m.mu.RUnlock()
+	if !ok {
+		return ClientRuntime{AccountID: accountID, State: RuntimeStopped}
+	}
+	return sup.snapshot()
 }
 
 // GetClient 获取客户端
@@ -126,7 +188,13 @@ func (m *Manager) GetClient(accountID uint) (ClientInterface, bool) {
 func (m *Manager) GetAllClients() map[uint]ClientInterface {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return m.clients
+
+	// 返回副本，避免调用方在锁外遍历内部映射
+	clients := make(map[uint]ClientInterface, len(m.clients))
+	for id, client := range m.clients {
+		clients[id] = client
+	}
+	return clients
 }
 
 // GetAuthHelper 获取认证助手
@@ -144,32 +212,113 @@ func (m *Manager) SetAuthHelper(accountID uint, helper *AuthHelper) {
 	m.authHelpers[accountID] = helper
 }
 
-// SendMessageToGroup 通过指定账号向指定群组发送一条消息
-func (m *Manager) SendMessageToGroup(accountID uint, groupID uint, text string) error {
+// EnqueueOutbound 将手动发送的消息（文本或媒体）加入发送队列，并唤醒账号的发送协程
+// 账号当前不在线时消息保留在队列中，上线后再发送
+func (m *Manager) EnqueueOutbound(item *models.OutboundMessage, media *MediaFile) (*models.OutboundMessage, bool, error) {
+	queued, existed, err := EnqueueOutbound(m.db, item, media)
+	if err != nil || existed {
+		return queued, existed, err
+	}
+
+	log.Printf("✉️ 消息已加入发送队列 [队列ID: %d, 账号ID: %d, 群组ID: %d]", queued.ID, queued.AccountID, queued.GroupID)
+	m.wakeOutbox(queued.AccountID)
+	return queued, false, nil
+}
+
+// wakeOutbox 唤醒账号的发送协程
+func (m *Manager) wakeOutbox(accountID uint) {
 	m.mu.RLock()
 	clientIface, ok := m.clients[accountID]
 	m.mu.RUnlock()
 	if !ok {
-		return fmt.Errorf("未找到账号对应的Telegram客户端 [account_id=%d]", accountID)
+		return
+	}
+	if client, ok := clientIface.(*ClientV2); ok {
+		client.wakeOutbox()
+	}
+}
+
+// clientForGroup 获取账号的客户端以及目标群组
+func (m *Manager) clientForGroup(accountID uint, groupID uint) (*ClientV2, *models.Group, error) {
+	m.mu.RLock()
+	clientIface, ok := m.clients[accountID]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, nil, m.clientNotFound(accountID)
 	}
 
 	// 查询群组获取 chat_id
 	var group models.Group
 	if err := m.db.First(&group, groupID).Error; err != nil {
-		return fmt.Errorf("群组不存在或查询失败: %w", err)
+		return nil, nil, fmt.Errorf("群组不存在或查询失败: %w", err)
+	}
+
+	client, ok := clientIface.(*ClientV2)
+	if !ok {
+		return nil, nil, fmt.Errorf("客户端类型不支持手动发送消息")
+	}
+
+	return client, &group, nil
+}
+
+// SyncAccountGroups 通过指定账号同步群组信息，返回本次同步的变更
+func (m *Manager) SyncAccountGroups(accountID uint) (*GroupSyncResult, error) {
+	m.mu.RLock()
+	clientIface, ok := m.clients[accountID]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, m.clientNotFound(accountID)
 	}
 
 	client, ok := clientIface.(*ClientV2)
 	if !ok {
-		return fmt.Errorf("客户端类型不支持手动发送消息")
+		return nil, fmt.Errorf("客户端类型不支持群组同步")
 	}
+	if client.accountSettings().Status != "online" {
+		return nil, fmt.Errorf("账号未在线，无法同步群组 [account_id=%d]", accountID)
+	}
+
+	log.Printf("🔄 手动同步群组 [账号ID: %d]", accountID)
+	if client.bot {
+		return SyncBotGroups(client.Context, client.TGClient.API(), m.db, accountID, client.SelfID, client.limiter)
+	}
+	return SyncGroups(client.Context, client.TGClient.API(), m.db, accountID, client.limiter)
+}
+
+// SyncAllGroups 通过所有在线账号同步群组信息
+func (m *Manager) SyncAllGroups() ([]*GroupSyncResult, error) {
+	m.mu.RLock()
+	accountIDs := make([]uint, 0, len(m.clients))
+	for id, clientIface := range m.clients {
+		if client, ok := clientIface.(*ClientV2); ok && client.accountSettings().Status == "online" {
+			accountIDs = append(accountIDs, id)
+		}
+	}
+	m.mu.RUnlock()
 
-	ctx := client.Context
-	if ctx == nil {
-		ctx = context.Background()
+	if len(accountIDs) == 0 {
+		return nil, fmt.Errorf("没有在线的账号可用于同步群组")
 	}
 
-	log.Printf("✉️ 手动发送消息 [账号ID: %d, 群组ID: %d, ChatID: %d]", accountID, groupID, group.ChatID)
-	return client.sendMessage(ctx, group.ChatID, text, 0)
+	results := make([]*GroupSyncResult, 0, len(accountIDs))
+	for _, id := range accountIDs {
+		result, err := m.SyncAccountGroups(id)
+		if err != nil {
+			log.Printf("⚠️ 同步群组失败 [账号ID: %d]: %v", id, err)
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, nil
 }
 
+// SendReplyToGroup 通过指定账号在群组（topicID 大于0时为该论坛话题）中引用某条消息回复（加入发送队列）
+func (m *Manager) SendReplyToGroup(accountID uint, groupID uint, topicID int, text string, replyToMsgID int) error {
+	client, group, err := m.clientForGroup(accountID, groupID)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("✉️ 审核通过的回复加入发送队列 [账号ID: %d, 群组ID: %d, 引用消息: %d]", accountID, groupID, replyToMsgID)
+	return client.enqueueReply(group.ChatID, topicID, text, replyToMsgID, "approval")
+}
@@ -0,0 +1,149 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"github.com/gotd/td/telegram/uploader"
+	"github.com/gotd/td/tg"
+)
+
+// MaxMediaSize 手动发送媒体的大小上限（50MB）
+const MaxMediaSize = 50 << 20
+
+// MediaFile 待发送的媒体文件
+type MediaFile struct {
+	Type     string // photo/video/document
+	FileName string
+	MimeType string
+	Data     []byte
+	Caption  string
+}
+
+// DetectMediaType 根据 MIME 类型推断媒体类型
+func DetectMediaType(mimeType, fileName string) string {
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(fileName))); byExt != "" {
+			mimeType = byExt
+		}
+	}
+	switch {
+	case mimeType == "image/gif":
+		// GIF 作为照片发送会丢失动画
+		return "document"
+	case strings.HasPrefix(mimeType, "image/"):
+		return "photo"
+	case strings.HasPrefix(mimeType, "video/"):
+		return "video"
+	}
+	return "document"
+}
+
+// ValidateMediaFile 校验媒体文件
+func ValidateMediaFile(media *MediaFile) error {
+	if len(media.Data) == 0 {
+		return fmt.Errorf("文件内容为空")
+	}
+	if len(media.Data) > MaxMediaSize {
+		return fmt.Errorf("文件过大（上限 %dMB）", MaxMediaSize>>20)
+	}
+	switch media.Type {
+	case "photo", "video", "document":
+	default:
+		return fmt.Errorf("不支持的媒体类型: %s", media.Type)
+	}
+	return nil
+}
+
+// inputMedia 上传文件并构造 InputMedia
+func (c *ClientV2) inputMedia(ctx context.Context, media *MediaFile) (tg.InputMediaClass, error) {
+	api := c.TGClient.API()
+
+	file, err := uploader.NewUploader(api).FromReader(ctx, media.FileName, bytes.NewReader(media.Data))
+	if err != nil {
+		return nil, fmt.Errorf("上传文件失败: %w", err)
+	}
+
+	if media.Type == "photo" {
+		return &tg.InputMediaUploadedPhoto{File: file}, nil
+	}
+
+	mimeType := media.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	doc := &tg.InputMediaUploadedDocument{
+		File:     file,
+		MimeType: mimeType,
+		Attributes: []tg.DocumentAttributeClass{
+			&tg.DocumentAttributeFilename{FileName: media.FileName},
+		},
+	}
+	switch media.Type {
+	case "video":
+		doc.Attributes = append(doc.Attributes, &tg.DocumentAttributeVideo{SupportsStreaming: true})
+	case "document":
+		doc.ForceFile = true
+	}
+	return doc, nil
+}
+
+// sendMedia 发送媒体消息（带重试机制），返回新消息的 Telegram 消息ID
+// randomID 为0时自动生成；队列重发时复用同一个 randomID，由 Telegram 识别重复发送
+// topicID 大于0时发送到该论坛话题；markup 不为空时附带按钮
+func (c *ClientV2) sendMedia(ctx context.Context, chatID int64, media *MediaFile, replyToMsgID int64, topicID int, randomID int64, markup tg.ReplyMarkupClass) (int, error) {
+	api := c.TGClient.API()
+
+	peer, err := c.resolvePeer(ctx, chatID)
+	if err != nil {
+		return 0, err
+	}
+
+	// 文件只上传一次，重试时复用
+	inputMedia, err := c.inputMedia(ctx, media)
+	if err != nil {
+		return 0, err
+	}
+
+	if randomID == 0 {
+		randomID = rand.Int63()
+	}
+
+	var msgID int
+	sendFn := func() error {
+		req := &tg.MessagesSendMediaRequest{
+			Peer:     peer,
+			Media:    inputMedia,
+			Message:  media.Caption,
+			RandomID: randomID,
+		}
+		if replyTo := inputReplyTo(replyToMsgID, topicID); replyTo != nil {
+			req.ReplyTo = replyTo
+		}
+		if markup != nil {
+			req.SetReplyMarkup(markup)
+		}
+
+		updates, err := api.MessagesSendMedia(ctx, req)
+		if err != nil {
+			return err
+		}
+		msgID = sentMessageID(updates)
+		c.rememberOwnMessage(chatID, msgID)
+		return nil
+	}
+
+	if err := c.retrySend(ctx, chatID, sendFn); err != nil {
+		log.Printf("❌ 发送媒体失败: %v", err)
+		return 0, err
+	}
+
+	log.Printf("🖼️ 已发送%s [群组ID: %d, 文件: %s, 大小: %d]", media.Type, chatID, media.FileName, len(media.Data))
+	return msgID, nil
+}
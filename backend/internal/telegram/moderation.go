@@ -0,0 +1,684 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"aibot/models"
+
+	"github.com/gotd/td/tg"
+	"gorm.io/gorm"
+)
+
+// 群管规则类型
+const (
+	ModerationLink          = "link"            // 发送链接
+	ModerationBannedWords   = "banned_words"    // 违禁词
+	ModerationFlood         = "flood"           // 刷屏
+	ModerationNewMemberLink = "new_member_link" // 新成员发送链接
+)
+
+// 群管动作
+const (
+	ModerationDelete   = "delete"
+	ModerationRestrict = "restrict"
+	ModerationBan      = "ban"
+	ModerationWarn     = "warn"
+)
+
+// 群管动作记录状态
+const (
+	ModerationStatusDone     = "done"
+	ModerationStatusFailed   = "failed"
+	ModerationStatusReverted = "reverted"
+)
+
+// floodHistoryLimit 刷屏检测保留的时间窗口上限
+const floodHistoryLimit = time.Hour
+
+// linkPattern 识别文本中的链接（实体中的链接另外识别）
+var linkPattern = regexp.MustCompile(`(?i)(?:https?://|www\.|t\.me/|telegram\.me/)[^\s]+`)
+
+// ValidateModerationRule 校验群管规则配置
+func ValidateModerationRule(rule *models.ModerationRule) error {
+	switch rule.Type {
+	case ModerationLink:
+	case ModerationBannedWords:
+		if strings.TrimSpace(rule.Pattern) == "" {
+			return fmt.Errorf("违禁词不能为空")
+		}
+		switch rule.MatchType {
+		case "", "keyword":
+		case "regex":
+			if _, err := regexp.Compile(rule.Pattern); err != nil {
+				return fmt.Errorf("正则表达式无效: %w", err)
+			}
+		default:
+			return fmt.Errorf("不支持的匹配类型: %s", rule.MatchType)
+		}
+	case ModerationFlood:
+		if rule.FloodCount <= 0 || rule.FloodSeconds <= 0 {
+			return fmt.Errorf("刷屏规则需要设置消息数上限和时间窗口")
+		}
+		if time.Duration(rule.FloodSeconds)*time.Second > floodHistoryLimit {
+			return fmt.Errorf("刷屏时间窗口不能超过 %s", floodHistoryLimit)
+		}
+	case ModerationNewMemberLink:
+		if rule.NewMemberHours <= 0 {
+			return fmt.Errorf("新成员规则需要设置入群时长（小时）")
+		}
+	default:
+		return fmt.Errorf("不支持的规则类型: %s", rule.Type)
+	}
+
+	switch rule.Action {
+	case ModerationDelete, ModerationRestrict, ModerationBan:
+	case ModerationWarn:
+		if strings.TrimSpace(rule.WarnTemplate) == "" {
+			return fmt.Errorf("警告内容不能为空")
+		}
+	default:
+		return fmt.Errorf("不支持的动作: %s", rule.Action)
+	}
+	if rule.DurationSeconds < 0 {
+		return fmt.Errorf("持续时间不能为负数")
+	}
+	return nil
+}
+
+// floodTracker 记录每个群组中每个用户最近的发言时间（刷屏检测）
+type floodTracker struct {
+	mu    sync.Mutex
+	times map[string][]time.Time
+}
+
+// record 记录一次发言，返回该用户最近一小时内的发言时间
+func (f *floodTracker) record(chatID, userID int64, at time.Time) []time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.times == nil {
+		f.times = make(map[string][]time.Time)
+	}
+	key := fmt.Sprintf("%d:%d", chatID, userID)
+
+	recent := f.times[key][:0]
+	for _, t := range f.times[key] {
+		if at.Sub(t) < floodHistoryLimit {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, at)
+	f.times[key] = recent
+
+	return append([]time.Time(nil), recent...)
+}
+
+// countSince 统计 at 之前 window 内的发言次数
+func countSince(times []time.Time, at time.Time, window time.Duration) int {
+	count := 0
+	for _, t := range times {
+		if at.Sub(t) < window {
+			count++
+		}
+	}
+	return count
+}
+
+// moderationHit 规则命中结果
+type moderationHit struct {
+	rule   *models.ModerationRule
+	reason string
+}
+
+// moderationCandidate 本地判定命中的规则；新成员链接规则还需查询入群时间才能确定
+type moderationCandidate struct {
+	moderationHit
+	link string // 新成员链接规则命中的链接
+}
+
+// deletesMessage 规则动作是否删除消息
+func (h *moderationHit) deletesMessage() bool {
+	return h.rule.Action == ModerationDelete || h.rule.DeleteMessage
+}
+
+// moderate 对新消息执行群管规则（同一群组只由一个管理员账号执行），返回消息是否交由群管处理
+// 更新分发中只用本地数据判定（规则、刷屏记录、链接和违禁词），查询成员身份和执行动作在后台进行，不阻塞更新处理；
+// 可能被删除的消息先不进入回复流程，后台确认不处理（发送者是管理员、不是新成员或删除失败）后再交回
+func (c *ClientV2) moderate(chatID int64, accountGroup *models.AccountGroup, message *tg.Message, buffered BufferedMessage, users map[int64]*tg.User) bool {
+	// 频道身份或匿名管理员发送的消息不处理
+	if buffered.SenderID == 0 {
+		return false
+	}
+	groupID := accountGroup.GroupID
+	if executor, ok := c.moderationExecutor(groupID); !ok || executor != c.Account.ID {
+		return false
+	}
+
+	rules := c.loadModerationRules(groupID)
+	if len(rules) == 0 {
+		return false
+	}
+
+	candidates := c.moderationCandidates(chatID, rules, message, buffered)
+	if len(candidates) == 0 {
+		return false
+	}
+
+	held := false
+	for i := range candidates {
+		if candidates[i].deletesMessage() {
+			held = true
+			break
+		}
+	}
+
+	go func() {
+		deleted := c.resolveModeration(chatID, groupID, candidates, message, buffered, users)
+		if held && !deleted {
+			c.dispatchInbound(chatID, accountGroup, buffered)
+		}
+	}()
+	return held
+}
+
+// moderationCandidates 按优先级用本地数据匹配群管规则
+// 返回到第一条确定命中的规则为止；之前的新成员链接规则需要后台查询入群时间再确定
+func (c *ClientV2) moderationCandidates(chatID int64, rules []models.ModerationRule, message *tg.Message, buffered BufferedMessage) []moderationCandidate {
+	sentAt := time.Unix(int64(message.Date), 0)
+	var floodTimes []time.Time
+	links := messageLinks(message)
+
+	var candidates []moderationCandidate
+	for i := range rules {
+		rule := &rules[i]
+		if strings.TrimSpace(rule.ExemptSenders) != "" && ruleSenderMatches(rule.ExemptSenders, buffered) {
+			continue
+		}
+
+		var hit *moderationHit
+		switch rule.Type {
+		case ModerationLink:
+			if link, ok := disallowedLink(links, rule.Pattern); ok {
+				hit = &moderationHit{rule: rule, reason: "包含链接 " + link}
+			}
+		case ModerationBannedWords:
+			if word, ok := bannedWordMatch(rule, message.Message); ok {
+				hit = &moderationHit{rule: rule, reason: "包含违禁词 " + word}
+			}
+		case ModerationFlood:
+			// 同一条消息只记录一次
+			if floodTimes == nil {
+				floodTimes = c.flood.record(chatID, buffered.SenderID, sentAt)
+			}
+			if floodCount := countSince(floodTimes, sentAt, time.Duration(rule.FloodSeconds)*time.Second); floodCount > rule.FloodCount {
+				hit = &moderationHit{rule: rule, reason: fmt.Sprintf("%d 秒内发送 %d 条消息", rule.FloodSeconds, floodCount)}
+			}
+		case ModerationNewMemberLink:
+			if link, ok := disallowedLink(links, rule.Pattern); ok {
+				candidates = append(candidates, moderationCandidate{moderationHit: moderationHit{rule: rule}, link: link})
+			}
+		}
+		if hit != nil {
+			return append(candidates, moderationCandidate{moderationHit: *hit})
+		}
+	}
+	return candidates
+}
+
+// resolveModeration 确定命中的规则并执行群管动作（在后台执行），返回消息是否已被删除
+func (c *ClientV2) resolveModeration(chatID int64, groupID uint, candidates []moderationCandidate, message *tg.Message, buffered BufferedMessage, users map[int64]*tg.User) bool {
+	var hit *moderationHit
+	var joinedAt *time.Time
+	for i := range candidates {
+		candidate := &candidates[i]
+		if candidate.rule.Type != ModerationNewMemberLink {
+			hit = &candidate.moderationHit
+			break
+		}
+
+		// 入群时间只查询一次
+		if joinedAt == nil {
+			at, err := c.memberJoinedAt(chatID, buffered.SenderID, users)
+			if err != nil {
+				log.Printf("⚠️ 查询成员入群时间失败 [群组ID: %d, 用户ID: %d]: %v", chatID, buffered.SenderID, err)
+				continue
+			}
+			joinedAt = &at
+		}
+		if time.Since(*joinedAt) < time.Duration(candidate.rule.NewMemberHours)*time.Hour {
+			candidate.reason = fmt.Sprintf("入群不满 %d 小时发送链接 %s", candidate.rule.NewMemberHours, candidate.link)
+			hit = &candidate.moderationHit
+			break
+		}
+	}
+	if hit == nil {
+		return false
+	}
+
+	// 群主和管理员的消息不处理
+	if isAdmin, err := c.senderIsAdmin(chatID, buffered.SenderID, users); err != nil {
+		log.Printf("⚠️ 查询发送者身份失败，跳过群管动作 [群组ID: %d, 用户ID: %d]: %v", chatID, buffered.SenderID, err)
+		return false
+	} else if isAdmin {
+		return false
+	}
+
+	log.Printf("🛡️ 群管规则命中 [%s, 动作: %s, 群组ID: %d, 用户ID: %d]: %s", hit.rule.Name, hit.rule.Action, chatID, buffered.SenderID, hit.reason)
+	c.recordModerationHit(hit.rule.ID)
+
+	record := c.executeModeration(chatID, groupID, hit, message, buffered, users)
+	return record.MessageDeleted
+}
+
+// moderationExecutor 选出执行群管动作的账号：已启用分配、在线且是群管理员的账号中优先级最高的
+// 多个管理员账号收到同一条消息时只由该账号处理，避免重复删除、重复警告
+func (c *ClientV2) moderationExecutor(groupID uint) (uint, bool) {
+	var assignment models.AccountGroup
+	err := c.DB.Model(&models.AccountGroup{}).
+		Joins("JOIN ai_accounts ON ai_accounts.id = account_groups.account_id AND ai_accounts.deleted_at IS NULL").
+		Joins("JOIN group_memberships ON group_memberships.account_id = account_groups.account_id AND group_memberships.group_id = account_groups.group_id").
+		Where("account_groups.group_id = ? AND account_groups.enabled = ? AND ai_accounts.status = ?", groupID, true, "online").
+		Where("group_memberships.status = ? AND group_memberships.role IN ?", "member", []string{"creator", "admin"}).
+		Order("account_groups.priority DESC, account_groups.account_id ASC").
+		First(&assignment).Error
+	if err != nil {
+		return 0, false
+	}
+	return assignment.AccountID, true
+}
+
+// loadModerationRules 加载对群组生效的群管规则（全局规则 + 群组规则），按优先级排序
+func (c *ClientV2) loadModerationRules(groupID uint) []models.ModerationRule {
+	var rules []models.ModerationRule
+	if err := c.DB.Where("enabled = ? AND (group_id IS NULL OR group_id = ?)", true, groupID).
+		Order("priority DESC, id ASC").
+		Find(&rules).Error; err != nil {
+		log.Printf("⚠️ 加载群管规则失败: %v", err)
+		return nil
+	}
+	return rules
+}
+
+// recordModerationHit 记录群管规则命中次数
+func (c *ClientV2) recordModerationHit(ruleID uint) {
+	now := time.Now()
+	c.DB.Model(&models.ModerationRule{}).Where("id = ?", ruleID).UpdateColumns(map[string]interface{}{
+		"hit_count":   gorm.Expr("hit_count + ?", 1),
+		"last_hit_at": now,
+	})
+}
+
+// executeModeration 执行群管动作并记录
+func (c *ClientV2) executeModeration(chatID int64, groupID uint, hit *moderationHit, message *tg.Message, buffered BufferedMessage, users map[int64]*tg.User) *models.ModerationAction {
+	rule := hit.rule
+	ruleID := rule.ID
+	record := &models.ModerationAction{
+		AccountID:      c.Account.ID,
+		GroupID:        groupID,
+		ChatID:         chatID,
+		RuleID:         &ruleID,
+		RuleName:       rule.Name,
+		Action:         rule.Action,
+		Reason:         hit.reason,
+		UserID:         buffered.SenderID,
+		SenderName:     buffered.SenderName,
+		SenderUsername: buffered.SenderUsername,
+		MessageID:      message.ID,
+		Content:        message.Message,
+		MessageSentAt:  time.Unix(int64(message.Date), 0),
+		Status:         ModerationStatusDone,
+	}
+	if user, ok := users[buffered.SenderID]; ok {
+		record.UserAccessHash = user.AccessHash
+	}
+	if rule.DurationSeconds > 0 && (rule.Action == ModerationRestrict || rule.Action == ModerationBan) {
+		until := time.Now().Add(time.Duration(rule.DurationSeconds) * time.Second)
+		record.UntilDate = &until
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context, 30*time.Second)
+	defer cancel()
+
+	var errs []string
+	if rule.Action == ModerationDelete || rule.DeleteMessage {
+		if err := c.deleteGroupMessages(ctx, chatID, []int{message.ID}); err != nil {
+			errs = append(errs, fmt.Sprintf("删除消息失败: %v", err))
+		} else {
+			record.MessageDeleted = true
+			c.removeBuffered(bufferKey{chatID: chatID, topicID: buffered.TopicID}, message.ID)
+		}
+	}
+
+	switch rule.Action {
+	case ModerationRestrict, ModerationBan:
+		if err := c.editMemberRights(ctx, chatID, record, rule.Action); err != nil {
+			errs = append(errs, fmt.Sprintf("%s失败: %v", moderationActionLabel(rule.Action), err))
+		}
+	case ModerationWarn:
+		sender := buffered.SenderName
+		if buffered.SenderUsername != "" {
+			sender = "@" + buffered.SenderUsername
+		}
+		warning := strings.NewReplacer(
+			"{sender}", sender,
+			"{group}", c.groupTitle(groupID),
+			"{reason}", hit.reason,
+		).Replace(rule.WarnTemplate)
+		replyTo := message.ID
+		if record.MessageDeleted {
+			replyTo = 0
+		}
+		if err := c.enqueueReply(chatID, buffered.TopicID, warning, replyTo, "moderation"); err != nil {
+			errs = append(errs, fmt.Sprintf("发送警告失败: %v", err))
+		}
+	}
+
+	if len(errs) > 0 {
+		record.Status = ModerationStatusFailed
+		record.Error = strings.Join(errs, "; ")
+		log.Printf("❌ 群管动作执行失败 [%s]: %s", rule.Name, record.Error)
+	}
+	if err := c.DB.Create(record).Error; err != nil {
+		log.Printf("⚠️ 保存群管记录失败: %v", err)
+	}
+	return record
+}
+
+// deleteGroupMessages 删除群组中的消息（需要管理员权限）
+func (c *ClientV2) deleteGroupMessages(ctx context.Context, chatID int64, msgIDs []int) error {
+	peer, err := c.resolvePeer(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	api := c.TGClient.API()
+
+	if channel, ok := peer.(*tg.InputPeerChannel); ok {
+		_, err = api.ChannelsDeleteMessages(ctx, &tg.ChannelsDeleteMessagesRequest{
+			Channel: &tg.InputChannel{ChannelID: channel.ChannelID, AccessHash: channel.AccessHash},
+			ID:      msgIDs,
+		})
+		return err
+	}
+	_, err = api.MessagesDeleteMessages(ctx, &tg.MessagesDeleteMessagesRequest{
+		Revoke: true,
+		ID:     msgIDs,
+	})
+	return err
+}
+
+// editMemberRights 限制、封禁或解除限制群成员（action 为空表示解除）
+func (c *ClientV2) editMemberRights(ctx context.Context, chatID int64, record *models.ModerationAction, action string) error {
+	peer, err := c.resolvePeer(ctx, chatID)
+	if err != nil {
+		return err
+	}
+
+	channel, ok := peer.(*tg.InputPeerChannel)
+	if !ok {
+		// 普通群组只能移出成员，不支持限制和解除
+		if action != ModerationBan {
+			return fmt.Errorf("普通群组不支持%s，请升级为超级群组", moderationActionLabel(action))
+		}
+		chat := peer.(*tg.InputPeerChat)
+		_, err = c.TGClient.API().MessagesDeleteChatUser(ctx, &tg.MessagesDeleteChatUserRequest{
+			ChatID: chat.ChatID,
+			UserID: &tg.InputUser{UserID: record.UserID, AccessHash: record.UserAccessHash},
+		})
+		return err
+	}
+
+	rights := tg.ChatBannedRights{}
+	switch action {
+	case ModerationRestrict:
+		rights.SendMessages = true
+		rights.SendMedia = true
+		rights.SendStickers = true
+		rights.SendGifs = true
+		rights.SendGames = true
+		rights.SendInline = true
+		rights.EmbedLinks = true
+		rights.SendPolls = true
+	case ModerationBan:
+		rights.ViewMessages = true
+	}
+	if action != "" && record.UntilDate != nil {
+		rights.UntilDate = int(record.UntilDate.Unix())
+	}
+
+	return c.editBanned(ctx, channel, record.UserID, record.UserAccessHash, rights)
+}
+
+// editBanned 修改超级群组成员的限制（rights 为空表示解除限制）
+func (c *ClientV2) editBanned(ctx context.Context, channel *tg.InputPeerChannel, userID, accessHash int64, rights tg.ChatBannedRights) error {
+	_, err := c.TGClient.API().ChannelsEditBanned(ctx, &tg.ChannelsEditBannedRequest{
+		Channel:      &tg.InputChannel{ChannelID: channel.ChannelID, AccessHash: channel.AccessHash},
+		Participant:  &tg.InputPeerUser{UserID: userID, AccessHash: accessHash},
+		BannedRights: rights,
+	})
+	return err
+}
+
+// memberJoinedAt 查询成员的入群时间（仅超级群组支持）
+func (c *ClientV2) memberJoinedAt(chatID, userID int64, users map[int64]*tg.User) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(c.Context, 15*time.Second)
+	defer cancel()
+
+	peer, err := c.resolvePeer(ctx, chatID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	channel, ok := peer.(*tg.InputPeerChannel)
+	if !ok {
+		return time.Time{}, fmt.Errorf("普通群组无法查询入群时间")
+	}
+
+	participant, err := c.channelParticipant(ctx, channel, userID, users)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	switch p := participant.(type) {
+	case *tg.ChannelParticipant:
+		return time.Unix(int64(p.Date), 0), nil
+	case *tg.ChannelParticipantSelf:
+		return time.Unix(int64(p.Date), 0), nil
+	case *tg.ChannelParticipantBanned:
+		return time.Unix(int64(p.Date), 0), nil
+	}
+	// 群主和管理员不视为新成员
+	return time.Time{}, nil
+}
+
+// channelParticipant 查询超级群组中成员的身份
+func (c *ClientV2) channelParticipant(ctx context.Context, channel *tg.InputPeerChannel, userID int64, users map[int64]*tg.User) (tg.ChannelParticipantClass, error) {
+	participant := &tg.InputPeerUser{UserID: userID}
+	if user, ok := users[userID]; ok {
+		participant.AccessHash = user.AccessHash
+	}
+	result, err := c.TGClient.API().ChannelsGetParticipant(ctx, &tg.ChannelsGetParticipantRequest{
+		Channel:     &tg.InputChannel{ChannelID: channel.ChannelID, AccessHash: channel.AccessHash},
+		Participant: participant,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.Participant, nil
+}
+
+// senderIsAdmin 发送者是否为群主或管理员
+func (c *ClientV2) senderIsAdmin(chatID, userID int64, users map[int64]*tg.User) (bool, error) {
+	ctx, cancel := context.WithTimeout(c.Context, 15*time.Second)
+	defer cancel()
+
+	peer, err := c.resolvePeer(ctx, chatID)
+	if err != nil {
+		return false, err
+	}
+
+	switch p := peer.(type) {
+	case *tg.InputPeerChannel:
+		participant, err := c.channelParticipant(ctx, p, userID, users)
+		if err != nil {
+			return false, err
+		}
+		switch participant.(type) {
+		case *tg.ChannelParticipantCreator, *tg.ChannelParticipantAdmin:
+			return true, nil
+		}
+		return false, nil
+
+	case *tg.InputPeerChat:
+		full, err := c.TGClient.API().MessagesGetFullChat(ctx, p.ChatID)
+		if err != nil {
+			return false, err
+		}
+		chatFull, ok := full.FullChat.(*tg.ChatFull)
+		if !ok {
+			return false, nil
+		}
+		participants, ok := chatFull.Participants.(*tg.ChatParticipants)
+		if !ok {
+			return false, nil
+		}
+		for _, participant := range participants.Participants {
+			if participant.GetUserID() != userID {
+				continue
+			}
+			switch participant.(type) {
+			case *tg.ChatParticipantCreator, *tg.ChatParticipantAdmin:
+				return true, nil
+			}
+			return false, nil
+		}
+	}
+	return false, nil
+}
+
+// RevertModeration 撤销群管动作（解除限制或封禁），用于申诉复核
+func (m *Manager) RevertModeration(record *models.ModerationAction) error {
+	if record.Action != ModerationRestrict && record.Action != ModerationBan {
+		return nil
+	}
+
+	client, _, err := m.clientForGroup(record.AccountID, record.GroupID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(client.Context, 30*time.Second)
+	defer cancel()
+	if err := client.editMemberRights(ctx, record.ChatID, record, ""); err != nil {
+		return fmt.Errorf("解除%s失败: %w", moderationActionLabel(record.Action), err)
+	}
+	log.Printf("🛡️ 已撤销群管动作 [记录ID: %d, 用户ID: %d]", record.ID, record.UserID)
+	return nil
+}
+
+// messageLinks 提取消息中的链接（文本和实体）
+func messageLinks(message *tg.Message) []string {
+	links := linkPattern.FindAllString(message.Message, -1)
+	for _, entity := range message.Entities {
+		switch e := entity.(type) {
+		case *tg.MessageEntityTextURL:
+			links = append(links, e.URL)
+		case *tg.MessageEntityURL:
+			if text := entityText(message.Message, e.Offset, e.Length); text != "" {
+				links = append(links, text)
+			}
+		}
+	}
+	return links
+}
+
+// entityText 按 UTF-16 偏移截取实体文本
+func entityText(text string, offset, length int) string {
+	units := utf16.Encode([]rune(text))
+	if offset < 0 || length <= 0 || offset+length > len(units) {
+		return ""
+	}
+	return string(utf16.Decode(units[offset : offset+length]))
+}
+
+// disallowedLink 返回第一个不在允许域名中的链接
+func disallowedLink(links []string, allowed string) (string, bool) {
+	for _, link := range links {
+		if !linkAllowed(link, allowed) {
+			return link, true
+		}
+	}
+	return "", false
+}
+
+// linkAllowed 判断链接域名是否在允许列表中（子域名同样允许）
+func linkAllowed(link, allowed string) bool {
+	if strings.TrimSpace(allowed) == "" {
+		return false
+	}
+	raw := link
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(strings.TrimPrefix(u.Hostname(), "www."))
+
+	for _, domain := range strings.Split(allowed, ",") {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain == "" {
+			continue
+		}
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// bannedWordMatch 违禁词匹配，返回命中的内容
+func bannedWordMatch(rule *models.ModerationRule, text string) (string, bool) {
+	if rule.MatchType == "regex" {
+		re, err := compileRulePattern(ruleKindModeration, rule.ID, rule.UpdatedAt, rule.Pattern)
+		if err != nil {
+			log.Printf("⚠️ 群管规则 [%d] 正则无效: %v", rule.ID, err)
+			return "", false
+		}
+		if match := re.FindString(text); match != "" {
+			return match, true
+		}
+		return "", false
+	}
+
+	lower := strings.ToLower(text)
+	for _, word := range strings.Split(rule.Pattern, ",") {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" && strings.Contains(lower, word) {
+			return word, true
+		}
+	}
+	return "", false
+}
+
+// moderationActionLabel 群管动作的中文名称
+func moderationActionLabel(action string) string {
+	switch action {
+	case ModerationDelete:
+		return "删除"
+	case ModerationRestrict:
+		return "禁言"
+	case ModerationBan:
+		return "封禁"
+	case ModerationWarn:
+		return "警告"
+	}
+	return "解除限制"
+}
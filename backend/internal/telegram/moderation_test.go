@@ -0,0 +1,71 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"aibot/models"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestModerationCandidates(t *testing.T) {
+	c := testClient(t, testDB(t))
+	now := int(time.Now().Unix())
+
+	newMember := models.ModerationRule{ID: 1, Type: ModerationNewMemberLink, NewMemberHours: 24, Action: ModerationBan, DeleteMessage: true}
+	banned := models.ModerationRule{ID: 2, Type: ModerationBannedWords, Pattern: "casino", Action: ModerationWarn, WarnTemplate: "warn"}
+	link := models.ModerationRule{ID: 3, Type: ModerationLink, Pattern: "example.com", Action: ModerationDelete}
+	flood := models.ModerationRule{ID: 4, Type: ModerationFlood, FloodCount: 1, FloodSeconds: 60, Action: ModerationRestrict}
+
+	tests := []struct {
+		name   string
+		rules  []models.ModerationRule
+		text   string
+		sender BufferedMessage
+		want   []uint
+		held   bool
+	}{
+		{"未命中", []models.ModerationRule{banned, link}, "hello", BufferedMessage{SenderID: 10}, nil, false},
+		{"允许的域名", []models.ModerationRule{link}, "see https://docs.example.com/a", BufferedMessage{SenderID: 10}, nil, false},
+		{"确定命中后不再匹配", []models.ModerationRule{banned, link}, "casino https://spam.io", BufferedMessage{SenderID: 10}, []uint{2}, false},
+		{"删除消息的规则", []models.ModerationRule{link, banned}, "casino https://spam.io", BufferedMessage{SenderID: 10}, []uint{3}, true},
+		{"新成员规则待后台确定", []models.ModerationRule{newMember, banned}, "casino https://spam.io", BufferedMessage{SenderID: 10}, []uint{1, 2}, true},
+		{"只有新成员规则", []models.ModerationRule{newMember}, "https://spam.io", BufferedMessage{SenderID: 10}, []uint{1}, true},
+		{"豁免发送者", []models.ModerationRule{{ID: 5, Type: ModerationBannedWords, Pattern: "casino", Action: ModerationDelete, ExemptSenders: "@alice"}},
+			"casino", BufferedMessage{SenderID: 10, SenderUsername: "alice"}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := &tg.Message{ID: 1, Date: now, Message: tt.text}
+			candidates := c.moderationCandidates(100, tt.rules, message, tt.sender)
+
+			var got []uint
+			held := false
+			for i := range candidates {
+				got = append(got, candidates[i].rule.ID)
+				held = held || candidates[i].deletesMessage()
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("candidates = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("candidates = %v, want %v", got, tt.want)
+				}
+			}
+			if held != tt.held {
+				t.Fatalf("held = %v, want %v", held, tt.held)
+			}
+		})
+	}
+
+	// 刷屏：同一条消息只记录一次，第二条消息超过上限
+	for i, want := range []int{0, 1} {
+		message := &tg.Message{ID: 10 + i, Date: now, Message: "hi"}
+		candidates := c.moderationCandidates(200, []models.ModerationRule{flood, flood}, message, BufferedMessage{SenderID: 20})
+		if len(candidates) != want {
+			t.Fatalf("flood message %d: candidates = %d, want %d", i+1, len(candidates), want)
+		}
+	}
+}
@@ -0,0 +1,554 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	"aibot/models"
+
+	"github.com/gotd/td/tgerr"
+	"gorm.io/gorm"
+)
+
+// 发送队列状态
+const (
+	OutboundPending   = "pending"
+	OutboundSending   = "sending"
+	OutboundSent      = "sent"
+	OutboundFailed    = "failed"
+	OutboundCancelled = "cancelled"
+)
+
+// outboxPollInterval 发送协程检查队列的间隔（入队时会立即唤醒）
+const outboxPollInterval = 2 * time.Second
+
+// outboxRetryDelay 临时错误后重新发送的基础等待时间（按尝试次数递增）
+const outboxRetryDelay = 30 * time.Second
+
+// outboxMediaDir 待发送媒体文件的暂存目录
+var outboxMediaDir = filepath.Join("data", "outbox")
+
+// errPartSkipped 前一条拆分消息发送失败，后续部分未发送
+var errPartSkipped = errors.New("前一条消息发送失败，未发送")
+
+// EnqueueOutbound 将消息加入发送队列
+// 设置了幂等键且已存在相同键的记录时，直接返回已有记录（existed 为 true），不会重复入队
+func EnqueueOutbound(db *gorm.DB, item *models.OutboundMessage, media *MediaFile) (*models.OutboundMessage, bool, error) {
+	if item.IdempotencyKey != nil {
+		if existing, ok := findByIdempotencyKey(db, *item.IdempotencyKey); ok {
+			return existing, true, nil
+		}
+	}
+
+	if media != nil {
+		if err := ValidateMediaFile(media); err != nil {
+			return nil, false, err
+		}
+		path, err := saveOutboxMedia(media)
+		if err != nil {
+			return nil, false, err
+		}
+		item.Content = media.Caption
+		item.MediaType = media.Type
+		item.FileName = media.FileName
+		item.MimeType = media.MimeType
+		item.FileSize = int64(len(media.Data))
+		item.MediaPath = path
+	} else if item.Content == "" {
+		return nil, false, fmt.Errorf("消息内容不能为空")
+	}
+
+	prepareOutbound(item)
+	if err := db.Create(item).Error; err != nil {
+		removeOutboxMedia(item.MediaPath)
+		// 并发提交相同幂等键时，唯一索引冲突，返回先入队的记录
+		if item.IdempotencyKey != nil {
+			if existing, ok := findByIdempotencyKey(db, *item.IdempotencyKey); ok {
+				return existing, true, nil
+			}
+		}
+		return nil, false, fmt.Errorf("加入发送队列失败: %w", err)
+	}
+	return item, false, nil
+}
+
+// CancelOutbound 取消尚未发送的消息（拆分回复中后续未发送的部分一并取消）
+func CancelOutbound(db *gorm.DB, id uint) (*models.OutboundMessage, error) {
+	var item models.OutboundMessage
+	if err := db.First(&item, id).Error; err != nil {
+		return nil, err
+	}
+	if item.Status != OutboundPending {
+		return nil, fmt.Errorf("只能取消待发送的消息（当前状态: %s）", item.Status)
+	}
+
+	var items []models.OutboundMessage
+	db.Where("reply_group_id = ? AND part_index >= ? AND status = ?", item.ReplyGroupID, item.PartIndex, OutboundPending).
+		Find(&items)
+	for _, it := range items {
+		result := db.Model(&models.OutboundMessage{}).
+			Where("id = ? AND status = ?", it.ID, OutboundPending).
+			Update("status", OutboundCancelled)
+		if result.Error == nil && result.RowsAffected > 0 {
+			removeOutboxMedia(it.MediaPath)
+		}
+	}
+
+	db.First(&item, id)
+	return &item, nil
+}
+
+// RescheduleOutbound 修改待发送或发送失败消息的发送时间和优先级，失败的消息会重新排队
+func RescheduleOutbound(db *gorm.DB, id uint, sendAt time.Time, priority *int) (*models.OutboundMessage, error) {
+	var item models.OutboundMessage
+	if err := db.First(&item, id).Error; err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"send_at": sendAt,
+		"status":  OutboundPending,
+	}
+	switch item.Status {
+	case OutboundPending:
+	case OutboundFailed:
+		if item.MediaType != "" {
+			if _, err := os.Stat(item.MediaPath); err != nil {
+				return nil, fmt.Errorf("媒体文件已清理，无法重新发送")
+			}
+		}
+		updates["attempts"] = 0
+		updates["last_error"] = ""
+	default:
+		return nil, fmt.Errorf("只能调整待发送或发送失败的消息（当前状态: %s）", item.Status)
+	}
+	if priority != nil {
+		updates["priority"] = *priority
+	}
+
+	result := db.Model(&models.OutboundMessage{}).Where("id = ? AND status = ?", id, item.Status).Updates(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("消息状态已变化，请刷新后重试")
+	}
+
+	db.First(&item, id)
+	return &item, nil
+}
+
+// findByIdempotencyKey 按幂等键查找队列记录
+func findByIdempotencyKey(db *gorm.DB, key string) (*models.OutboundMessage, bool) {
+	var existing models.OutboundMessage
+	if err := db.Where("idempotency_key = ?", key).First(&existing).Error; err != nil {
+		return nil, false
+	}
+	return &existing, true
+}
+
+// prepareOutbound 补齐入队记录的默认值
+func prepareOutbound(item *models.OutboundMessage) {
+	item.Status = OutboundPending
+	if item.SendAt == nil {
+		now := time.Now()
+		item.SendAt = &now
+	}
+	if item.ReplyGroupID == "" {
+		item.ReplyGroupID = newReplyGroupID()
+	}
+	if item.PartCount == 0 {
+		item.PartCount = 1
+	}
+	if item.MaxAttempts <= 0 {
+		item.MaxAttempts = 3
+	}
+	if item.RandomID == 0 {
+		item.RandomID = rand.Int63()
+	}
+}
+
+// saveOutboxMedia 暂存待发送的媒体文件
+func saveOutboxMedia(media *MediaFile) (string, error) {
+	if err := os.MkdirAll(outboxMediaDir, 0755); err != nil {
+		return "", fmt.Errorf("创建媒体暂存目录失败: %w", err)
+	}
+	path := filepath.Join(outboxMediaDir, fmt.Sprintf("%d_%s", time.Now().UnixNano(), filepath.Base(media.FileName)))
+	if err := os.WriteFile(path, media.Data, 0644); err != nil {
+		return "", fmt.Errorf("保存媒体文件失败: %w", err)
+	}
+	return path, nil
+}
+
+// removeOutboxMedia 删除暂存的媒体文件
+func removeOutboxMedia(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("⚠️ 删除媒体暂存文件失败: %v", err)
+	}
+}
+
+// enqueueReply 将回复加入发送队列（按账号配置拆分成多条，依次间隔发送）
+// topicID 大于0时发送到该论坛话题；replyToMsgID 大于0时，第一条消息会引用该消息
+func (c *ClientV2) enqueueReply(chatID int64, topicID int, reply string, replyToMsgID int, source string) error {
+	return c.enqueueReplyWithButtons(chatID, topicID, reply, replyToMsgID, source, nil)
+}
+
+// enqueueReplyWithButtons 将附带内联键盘的回复加入发送队列，按钮附在最后一条消息上
+func (c *ClientV2) enqueueReplyWithButtons(chatID int64, topicID int, reply string, replyToMsgID int, source string, buttons models.InlineKeyboard) error {
+	var group models.Group
+	if err := c.DB.Where("chat_id = ?", chatID).First(&group).Error; err != nil {
+		return fmt.Errorf("未找到群组 [ID: %d]: %w", chatID, err)
+	}
+
+	messageParts := []string{reply}
+	account := c.accountSettings()
+	if account.SplitByNewline {
+		// 按换行符拆分消息；只有一条时按原文发送
+		if parts := splitReply(reply); len(parts) > 1 {
+			messageParts = parts
+		}
+	}
+
+	// 获取多消息发送间隔
+	interval := account.MultiMsgInterval
+	if interval <= 0 {
+		interval = 5 // 默认5秒
+	}
+
+	now := time.Now()
+	replyGroupID := newReplyGroupID()
+	err := c.DB.Transaction(func(tx *gorm.DB) error {
+		for i, part := range messageParts {
+			sendAt := now.Add(time.Duration(i*interval) * time.Second)
+			item := &models.OutboundMessage{
+				AccountID:    c.Account.ID,
+				GroupID:      group.ID,
+				Source:       source,
+				Content:      part,
+				TopicID:      topicID,
+				ReplyGroupID: replyGroupID,
+				PartIndex:    i,
+				PartCount:    len(messageParts),
+				SendAt:       &sendAt,
+			}
+			// 只有第一条引用原消息
+			if i == 0 {
+				item.ReplyToMsgID = replyToMsgID
+			}
+			if i == len(messageParts)-1 {
+				item.Buttons = buttons
+			}
+			prepareOutbound(item)
+			if err := tx.Create(item).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("加入发送队列失败: %w", err)
+	}
+
+	if len(messageParts) > 1 {
+		log.Printf("📤 回复已入队，将拆分为 %d 条消息，间隔 %d 秒", len(messageParts), interval)
+	}
+	c.wakeOutbox()
+	return nil
+}
+
+// wakeOutbox 唤醒发送协程立即检查队列
+func (c *ClientV2) wakeOutbox() {
+	select {
+	case c.outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// startOutboxWorker 启动账号的发送协程，依次发送队列中到期的消息
+func (c *ClientV2) startOutboxWorker(ctx context.Context) {
+	// 上次运行中断时正在发送的消息重新排队（复用 random_id，不会重复发送）
+	result := c.DB.Model(&models.OutboundMessage{}).
+		Where("account_id = ? AND status = ?", c.Account.ID, OutboundSending).
+		Update("status", OutboundPending)
+	if result.RowsAffected > 0 {
+		log.Printf("📤 恢复 %d 条中断的待发送消息", result.RowsAffected)
+	}
+
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	log.Printf("📤 发送队列协程已启动")
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("📤 发送队列协程已停止")
+			return
+		case <-ticker.C:
+			c.removeExpiredOutbound(ctx)
+		case <-c.outboxWake:
+		}
+		c.drainOutbox(ctx)
+	}
+}
+
+// removeExpiredOutbound 删除已到自动删除时间的消息（如欢迎语）
+func (c *ClientV2) removeExpiredOutbound(ctx context.Context) {
+	var items []models.OutboundMessage
+	if err := c.DB.Preload("Group").
+		Where("account_id = ? AND status = ? AND delete_at <= ? AND removed_at IS NULL", c.Account.ID, OutboundSent, time.Now()).
+		Find(&items).Error; err != nil {
+		log.Printf("⚠️ 查询待删除消息失败: %v", err)
+		return
+	}
+
+	for _, item := range items {
+		if ctx.Err() != nil {
+			return
+		}
+		updates := map[string]interface{}{"removed_at": time.Now()}
+		if err := c.deleteGroupMessages(ctx, item.Group.ChatID, []int{int(item.TelegramMessageID)}); err != nil {
+			// 删除失败不再重试（如消息已被管理员删除、超过可删除时限）
+			log.Printf("⚠️ 自动删除消息失败 [队列ID: %d]: %v", item.ID, err)
+			updates["last_error"] = "自动删除失败: " + err.Error()
+		} else {
+			log.Printf("🧹 已自动删除消息 [队列ID: %d, 消息ID: %d]", item.ID, item.TelegramMessageID)
+		}
+		c.DB.Model(&models.OutboundMessage{}).Where("id = ?", item.ID).Updates(updates)
+	}
+}
+
+// drainOutbox 发送所有已到期的消息
+func (c *ClientV2) drainOutbox(ctx context.Context) {
+	for ctx.Err() == nil {
+		item, ok := c.claimOutbound()
+		if !ok {
+			return
+		}
+		c.deliverOutbound(ctx, item)
+	}
+}
+
+// claimOutbound 领取下一条到期的消息（优先级高的先发；拆分回复中前一条未发送完成时不领取后续部分）
+func (c *ClientV2) claimOutbound() (*models.OutboundMessage, bool) {
+	for {
+		earlierParts := c.DB.Table("outbound_queue AS prev").Select("1").
+			Where("prev.reply_group_id = outbound_queue.reply_group_id").
+			Where("prev.part_index < outbound_queue.part_index").
+			Where("prev.status IN ?", []string{OutboundPending, OutboundSending})
+
+		var item models.OutboundMessage
+		err := c.DB.
+			Where("account_id = ? AND status = ? AND send_at <= ?", c.Account.ID, OutboundPending, time.Now()).
+			Where("NOT EXISTS (?)", earlierParts).
+			Order("priority DESC, send_at ASC, id ASC").
+			First(&item).Error
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				log.Printf("⚠️ 查询发送队列失败: %v", err)
+			}
+			return nil, false
+		}
+
+		result := c.DB.Model(&models.OutboundMessage{}).
+			Where("id = ? AND status = ?", item.ID, OutboundPending).
+			Updates(map[string]interface{}{
+				"status":   OutboundSending,
+				"attempts": gorm.Expr("attempts + 1"),
+			})
+		if result.Error != nil {
+			log.Printf("⚠️ 领取待发送消息失败: %v", result.Error)
+			return nil, false
+		}
+		if result.RowsAffected == 0 {
+			continue // 已被取消或调整，重新查询
+		}
+		item.Status = OutboundSending
+		item.Attempts++
+		return &item, true
+	}
+}
+
+// deliverOutbound 发送一条队列消息并记录结果
+func (c *ClientV2) deliverOutbound(ctx context.Context, item *models.OutboundMessage) {
+	var group models.Group
+	if err := c.DB.First(&group, item.GroupID).Error; err != nil {
+		c.failOutbound(item, fmt.Errorf("群组不存在: %w", err))
+		return
+	}
+
+	var msgID int
+	var pollID int64
+	var err error
+	if item.PollID != nil {
+		var poll models.Poll
+		if err := c.DB.Preload("Options", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position ASC")
+		}).First(&poll, *item.PollID).Error; err != nil {
+			c.failOutbound(item, fmt.Errorf("投票不存在: %w", err))
+			return
+		}
+		msgID, pollID, err = c.sendPoll(ctx, group.ChatID, &poll, item.TopicID, item.RandomID)
+	} else if item.MediaType != "" {
+		var data []byte
+		data, err = os.ReadFile(item.MediaPath)
+		if err != nil {
+			c.failOutbound(item, fmt.Errorf("读取媒体文件失败: %w", err))
+			return
+		}
+		media := &MediaFile{
+			Type:     item.MediaType,
+			FileName: item.FileName,
+			MimeType: item.MimeType,
+			Data:     data,
+			Caption:  item.Content,
+		}
+		msgID, err = c.sendMedia(ctx, group.ChatID, media, int64(item.ReplyToMsgID), item.TopicID, item.RandomID, c.replyMarkup(item.Buttons))
+	} else {
+		msgID, err = c.sendMessage(ctx, group.ChatID, item.Content, int64(item.ReplyToMsgID), item.TopicID, item.RandomID, c.replyMarkup(item.Buttons))
+	}
+
+	// 中断前已发送成功、重启后重发的消息会被 Telegram 识别为重复
+	if err != nil && tgerr.Is(err, "RANDOM_ID_DUPLICATE") {
+		log.Printf("📤 消息已发送过，跳过重复发送 [队列ID: %d]", item.ID)
+		err = nil
+	}
+
+	if err != nil {
+		if ctx.Err() != nil {
+			// 客户端停止，重新排队
+			c.DB.Model(item).Updates(map[string]interface{}{"status": OutboundPending, "attempts": item.Attempts - 1})
+			return
+		}
+
+		var sendErr *SendError
+		if !errors.As(err, &sendErr) && item.Attempts < item.MaxAttempts {
+			// 临时错误：稍后重试
+			retryAt := time.Now().Add(time.Duration(item.Attempts) * outboxRetryDelay)
+			c.DB.Model(item).Updates(map[string]interface{}{
+				"status":     OutboundPending,
+				"send_at":    retryAt,
+				"last_error": err.Error(),
+			})
+			log.Printf("⚠️ 发送失败，%s 后重试 [队列ID: %d, 尝试 %d/%d]: %v", time.Until(retryAt).Round(time.Second), item.ID, item.Attempts, item.MaxAttempts, err)
+			return
+		}
+
+		c.failOutbound(item, err)
+		return
+	}
+
+	message := c.outboundRecord(item)
+	c.recordSend(group.ChatID, message, msgID, nil)
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":              OutboundSent,
+		"telegram_message_id": int64(msgID),
+		"sent_at":             now,
+		"last_error":          "",
+	}
+	if item.DeleteAfter > 0 {
+		updates["delete_at"] = now.Add(time.Duration(item.DeleteAfter) * time.Second)
+	}
+	if message.ID > 0 {
+		updates["message_id"] = message.ID
+	}
+	c.DB.Model(item).Updates(updates)
+	removeOutboxMedia(item.MediaPath)
+
+	if item.PollID != nil {
+		pollUpdates := map[string]interface{}{
+			"status":              PollOpen,
+			"error":               "",
+			"telegram_message_id": int64(msgID),
+		}
+		if pollID != 0 {
+			pollUpdates["telegram_poll_id"] = pollID
+		}
+		if message.ID > 0 {
+			pollUpdates["message_id"] = message.ID
+		}
+		c.DB.Model(&models.Poll{}).Where("id = ?", *item.PollID).Updates(pollUpdates)
+	}
+
+	// 拆分回复的下一条至少间隔 MultiMsgInterval 再发送
+	if item.PartIndex < item.PartCount-1 {
+		interval := c.accountSettings().MultiMsgInterval
+		if interval <= 0 {
+			interval = 5
+		}
+		next := now.Add(time.Duration(interval) * time.Second)
+		c.DB.Model(&models.OutboundMessage{}).
+			Where("reply_group_id = ? AND part_index = ? AND status = ? AND send_at < ?", item.ReplyGroupID, item.PartIndex+1, OutboundPending, next).
+			Update("send_at", next)
+		log.Printf("📨 已发送第 %d/%d 条: %s", item.PartIndex+1, item.PartCount, truncateStr(item.Content, 50))
+	}
+}
+
+// failOutbound 标记消息发送失败，拆分回复中后续未发送的部分一并标记失败
+func (c *ClientV2) failOutbound(item *models.OutboundMessage, sendErr error) {
+	log.Printf("❌ 发送消息失败 [队列ID: %d]: %v", item.ID, sendErr)
+
+	var group models.Group
+	if err := c.DB.First(&group, item.GroupID).Error; err == nil {
+		c.recordSend(group.ChatID, c.outboundRecord(item), 0, sendErr)
+	}
+	c.DB.Model(item).Updates(map[string]interface{}{
+		"status":     OutboundFailed,
+		"last_error": sendErr.Error(),
+	})
+	removeOutboxMedia(item.MediaPath)
+
+	if item.PollID != nil {
+		c.DB.Model(&models.Poll{}).Where("id = ?", *item.PollID).Updates(map[string]interface{}{
+			"status": PollFailed,
+			"error":  sendErr.Error(),
+		})
+	}
+
+	var rest []models.OutboundMessage
+	c.DB.Where("reply_group_id = ? AND part_index > ? AND status = ?", item.ReplyGroupID, item.PartIndex, OutboundPending).
+		Order("part_index ASC").
+		Find(&rest)
+	for i := range rest {
+		if group.ID > 0 {
+			c.recordSend(group.ChatID, c.outboundRecord(&rest[i]), 0, errPartSkipped)
+		}
+		c.DB.Model(&rest[i]).Updates(map[string]interface{}{
+			"status":     OutboundFailed,
+			"last_error": errPartSkipped.Error(),
+		})
+		removeOutboxMedia(rest[i].MediaPath)
+	}
+}
+
+// outboundRecord 根据队列消息构造发言记录
+func (c *ClientV2) outboundRecord(item *models.OutboundMessage) *models.Message {
+	message := &models.Message{
+		Content:       item.Content,
+		TopicID:       item.TopicID,
+		MediaType:     item.MediaType,
+		FileName:      item.FileName,
+		FileSize:      item.FileSize,
+		ReplyGroupID:  item.ReplyGroupID,
+		PartIndex:     item.PartIndex,
+		PartCount:     item.PartCount,
+		ScheduleRunID: item.ScheduleRunID,
+		PollID:        item.PollID,
+	}
+	if item.ReplyToMsgID > 0 {
+		replyTo := int64(item.ReplyToMsgID)
+		message.ReplyToMessageID = &replyTo
+	}
+	return message
+}
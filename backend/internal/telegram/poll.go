@@ -0,0 +1,439 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"aibot/models"
+
+	"github.com/gotd/td/tg"
+	"gorm.io/gorm"
+)
+
+// 投票状态
+const (
+	PollPending = "pending" // 等待发送
+	PollOpen    = "open"    // 已发送，投票中
+	PollClosed  = "closed"  // 已结束
+	PollFailed  = "failed"  // 发送失败
+)
+
+// 投票限制（Telegram 限制）
+const (
+	maxPollOptions        = 10
+	maxPollQuestionLength = 255
+	maxPollOptionLength   = 100
+)
+
+// maxPollVoters 查询公开投票的投票人时最多返回的数量
+const maxPollVoters = 500
+
+// PollVoter 公开投票的投票人
+type PollVoter struct {
+	UserID   int64     `json:"user_id"`
+	Name     string    `json:"name"`
+	Username string    `json:"username"`
+	Options  []int     `json:"options"` // 选择的选项序号
+	VotedAt  time.Time `json:"voted_at"`
+}
+
+// ValidatePoll 校验投票内容
+func ValidatePoll(poll *models.Poll) error {
+	question := strings.TrimSpace(poll.Question)
+	if question == "" {
+		return fmt.Errorf("投票问题不能为空")
+	}
+	if utf8.RuneCountInString(question) > maxPollQuestionLength {
+		return fmt.Errorf("投票问题不能超过 %d 个字符", maxPollQuestionLength)
+	}
+	if len(poll.Options) < 2 || len(poll.Options) > maxPollOptions {
+		return fmt.Errorf("投票选项数量应为 2-%d 个", maxPollOptions)
+	}
+
+	seen := make(map[string]bool)
+	for _, option := range poll.Options {
+		text := strings.TrimSpace(option.Text)
+		if text == "" {
+			return fmt.Errorf("投票选项不能为空")
+		}
+		if utf8.RuneCountInString(text) > maxPollOptionLength {
+			return fmt.Errorf("投票选项不能超过 %d 个字符", maxPollOptionLength)
+		}
+		if seen[text] {
+			return fmt.Errorf("投票选项重复: %s", text)
+		}
+		seen[text] = true
+	}
+	return nil
+}
+
+// CreatePoll 保存投票并加入发送队列
+func (m *Manager) CreatePoll(poll *models.Poll, sendAt *time.Time) error {
+	if err := ValidatePoll(poll); err != nil {
+		return err
+	}
+
+	poll.Question = strings.TrimSpace(poll.Question)
+	poll.Status = PollPending
+	for i := range poll.Options {
+		poll.Options[i].Position = i
+		poll.Options[i].Text = strings.TrimSpace(poll.Options[i].Text)
+		poll.Options[i].Voters = 0
+	}
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(poll).Error; err != nil {
+			return fmt.Errorf("保存投票失败: %w", err)
+		}
+
+		pollID := poll.ID
+		item, _, err := EnqueueOutbound(tx, &models.OutboundMessage{
+			AccountID: poll.AccountID,
+			GroupID:   poll.GroupID,
+			Source:    "poll",
+			Content:   poll.Question,
+			MediaType: "poll",
+			PollID:    &pollID,
+			SendAt:    sendAt,
+		}, nil)
+		if err != nil {
+			return err
+		}
+		poll.OutboundID = &item.ID
+		return tx.Model(poll).Update("outbound_id", item.ID).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("📊 投票已加入发送队列 [投票ID: %d, 账号ID: %d, 群组ID: %d]", poll.ID, poll.AccountID, poll.GroupID)
+	m.wakeOutbox(poll.AccountID)
+	return nil
+}
+
+// ClosePoll 结束投票
+func (m *Manager) ClosePoll(poll *models.Poll) error {
+	client, group, err := m.pollClient(poll)
+	if err != nil {
+		return err
+	}
+	return client.closePoll(group.ChatID, poll)
+}
+
+// RefreshPollResults 从 Telegram 拉取最新的投票结果
+func (m *Manager) RefreshPollResults(poll *models.Poll) error {
+	client, group, err := m.pollClient(poll)
+	if err != nil {
+		return err
+	}
+	return client.refreshPollResults(group.ChatID, poll)
+}
+
+// GetPollVoters 获取公开投票的投票人
+func (m *Manager) GetPollVoters(poll *models.Poll) ([]PollVoter, error) {
+	if poll.Anonymous {
+		return nil, fmt.Errorf("匿名投票无法查看投票人")
+	}
+	client, group, err := m.pollClient(poll)
+	if err != nil {
+		return nil, err
+	}
+	if client.bot {
+		return nil, fmt.Errorf("机器人账号无法查看投票人")
+	}
+	return client.pollVoters(group.ChatID, poll)
+}
+
+// pollClient 获取发送投票的账号客户端
+func (m *Manager) pollClient(poll *models.Poll) (*ClientV2, *models.Group, error) {
+	if poll.TelegramMessageID == 0 {
+		return nil, nil, fmt.Errorf("投票尚未发送")
+	}
+	return m.clientForGroup(poll.AccountID, poll.GroupID)
+}
+
+// sendPoll 发送投票（topicID 大于0时发送到该论坛话题），返回新消息的 Telegram 消息ID 和投票ID
+func (c *ClientV2) sendPoll(ctx context.Context, chatID int64, poll *models.Poll, topicID int, randomID int64) (int, int64, error) {
+	api := c.TGClient.API()
+
+	peer, err := c.resolvePeer(ctx, chatID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if randomID == 0 {
+		randomID = rand.Int63()
+	}
+
+	var msgID int
+	var pollID int64
+	sendFn := func() error {
+		req := &tg.MessagesSendMediaRequest{
+			Peer:     peer,
+			Media:    &tg.InputMediaPoll{Poll: telegramPoll(poll)},
+			RandomID: randomID,
+		}
+		if replyTo := inputReplyTo(0, topicID); replyTo != nil {
+			req.ReplyTo = replyTo
+		}
+		updates, err := api.MessagesSendMedia(ctx, req)
+		if err != nil {
+			return err
+		}
+		msgID = sentMessageID(updates)
+		pollID = sentPollID(updates)
+		c.rememberOwnMessage(chatID, msgID)
+		return nil
+	}
+
+	if err := c.retrySend(ctx, chatID, sendFn); err != nil {
+		log.Printf("❌ 发送投票失败: %v", err)
+		return 0, 0, err
+	}
+
+	log.Printf("📊 已发送投票 [群组ID: %d, 消息ID: %d]: %s", chatID, msgID, truncateStr(poll.Question, 50))
+	return msgID, pollID, nil
+}
+
+// closePoll 通过编辑投票消息结束投票
+func (c *ClientV2) closePoll(chatID int64, poll *models.Poll) error {
+	ctx, cancel := context.WithTimeout(c.Context, 30*time.Second)
+	defer cancel()
+
+	peer, err := c.resolvePeer(ctx, chatID)
+	if err != nil {
+		return err
+	}
+
+	closed := telegramPoll(poll)
+	closed.Closed = true
+	updates, err := c.TGClient.API().MessagesEditMessage(ctx, &tg.MessagesEditMessageRequest{
+		Peer:  peer,
+		ID:    int(poll.TelegramMessageID),
+		Media: &tg.InputMediaPoll{Poll: closed},
+	})
+	if err != nil && !isPollClosedError(err) {
+		return fmt.Errorf("结束投票失败: %w", err)
+	}
+	if err == nil {
+		c.applyPollUpdates(updates)
+	}
+
+	now := time.Now()
+	c.DB.Model(&models.Poll{}).Where("id = ?", poll.ID).Updates(map[string]interface{}{
+		"status":    PollClosed,
+		"closed_at": now,
+	})
+	log.Printf("📊 投票已结束 [投票ID: %d]", poll.ID)
+	return nil
+}
+
+// refreshPollResults 拉取投票结果（结果通过 UpdateMessagePoll 返回）
+func (c *ClientV2) refreshPollResults(chatID int64, poll *models.Poll) error {
+	ctx, cancel := context.WithTimeout(c.Context, 30*time.Second)
+	defer cancel()
+
+	peer, err := c.resolvePeer(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	updates, err := c.TGClient.API().MessagesGetPollResults(ctx, &tg.MessagesGetPollResultsRequest{
+		Peer:  peer,
+		MsgID: int(poll.TelegramMessageID),
+	})
+	if err != nil {
+		return fmt.Errorf("获取投票结果失败: %w", err)
+	}
+	c.applyPollUpdates(updates)
+	return nil
+}
+
+// pollVoters 分页获取公开投票的投票人
+func (c *ClientV2) pollVoters(chatID int64, poll *models.Poll) ([]PollVoter, error) {
+	ctx, cancel := context.WithTimeout(c.Context, time.Minute)
+	defer cancel()
+
+	peer, err := c.resolvePeer(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	voters := make([]PollVoter, 0)
+	offset := ""
+	for len(voters) < maxPollVoters {
+		req := &tg.MessagesGetPollVotesRequest{
+			Peer:  peer,
+			ID:    int(poll.TelegramMessageID),
+			Limit: 50,
+		}
+		if offset != "" {
+			req.SetOffset(offset)
+		}
+		list, err := c.TGClient.API().MessagesGetPollVotes(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("获取投票人失败: %w", err)
+		}
+
+		users := make(map[int64]*tg.User)
+		for _, u := range list.Users {
+			if user, ok := u.(*tg.User); ok {
+				users[user.ID] = user
+			}
+		}
+		for _, vote := range list.Votes {
+			if voter, ok := pollVoter(vote, users); ok {
+				voters = append(voters, voter)
+			}
+		}
+
+		next, ok := list.GetNextOffset()
+		if !ok || next == "" || len(list.Votes) == 0 {
+			break
+		}
+		offset = next
+	}
+	return voters, nil
+}
+
+// handlePollUpdate 收到投票结果更新
+func (c *ClientV2) handlePollUpdate(update *tg.UpdateMessagePoll) {
+	var poll models.Poll
+	if err := c.DB.Where("telegram_poll_id = ?", update.PollID).First(&poll).Error; err != nil {
+		return // 不是由本系统创建的投票
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"results_at": now}
+	if total, ok := update.Results.GetTotalVoters(); ok {
+		updates["total_voters"] = total
+	}
+	if p, ok := update.GetPoll(); ok && p.Closed && poll.Status != PollClosed {
+		updates["status"] = PollClosed
+		updates["closed_at"] = now
+	}
+
+	results, _ := update.Results.GetResults()
+	err := c.DB.Transaction(func(tx *gorm.DB) error {
+		for _, result := range results {
+			position, err := strconv.Atoi(string(result.Option))
+			if err != nil {
+				continue
+			}
+			if err := tx.Model(&models.PollOption{}).
+				Where("poll_id = ? AND position = ?", poll.ID, position).
+				Update("voters", result.Voters).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Poll{}).Where("id = ?", poll.ID).Updates(updates).Error
+	})
+	if err != nil {
+		log.Printf("⚠️ 更新投票结果失败 [投票ID: %d]: %v", poll.ID, err)
+	}
+}
+
+// applyPollUpdates 处理接口调用返回的投票结果更新
+func (c *ClientV2) applyPollUpdates(updates tg.UpdatesClass) {
+	var list []tg.UpdateClass
+	switch u := updates.(type) {
+	case *tg.Updates:
+		list = u.Updates
+	case *tg.UpdatesCombined:
+		list = u.Updates
+	case *tg.UpdateShort:
+		list = []tg.UpdateClass{u.Update}
+	}
+	for _, update := range list {
+		if u, ok := update.(*tg.UpdateMessagePoll); ok {
+			c.handlePollUpdate(u)
+		}
+	}
+}
+
+// telegramPoll 根据投票记录构造 Telegram 投票（选项标识为选项序号）
+func telegramPoll(poll *models.Poll) tg.Poll {
+	answers := make([]tg.PollAnswer, 0, len(poll.Options))
+	for _, option := range poll.Options {
+		answers = append(answers, tg.PollAnswer{
+			Text:   option.Text,
+			Option: []byte(strconv.Itoa(option.Position)),
+		})
+	}
+	return tg.Poll{
+		ID:             poll.TelegramPollID,
+		Question:       poll.Question,
+		Answers:        answers,
+		PublicVoters:   !poll.Anonymous,
+		MultipleChoice: poll.MultipleChoice,
+	}
+}
+
+// sentPollID 从发送结果中提取新投票的ID
+func sentPollID(updates tg.UpdatesClass) int64 {
+	var list []tg.UpdateClass
+	switch u := updates.(type) {
+	case *tg.Updates:
+		list = u.Updates
+	case *tg.UpdatesCombined:
+		list = u.Updates
+	}
+	for _, update := range list {
+		var msg tg.MessageClass
+		switch u := update.(type) {
+		case *tg.UpdateNewChannelMessage:
+			msg = u.Message
+		case *tg.UpdateNewMessage:
+			msg = u.Message
+		default:
+			continue
+		}
+		if m, ok := msg.(*tg.Message); ok {
+			if media, ok := m.Media.(*tg.MessageMediaPoll); ok {
+				return media.Poll.ID
+			}
+		}
+	}
+	return 0
+}
+
+// pollVoter 解析一条投票记录
+func pollVoter(vote tg.MessagePeerVoteClass, users map[int64]*tg.User) (PollVoter, bool) {
+	var peer tg.PeerClass
+	var options [][]byte
+	var date int
+	switch v := vote.(type) {
+	case *tg.MessagePeerVote:
+		peer, options, date = v.Peer, [][]byte{v.Option}, v.Date
+	case *tg.MessagePeerVoteMultiple:
+		peer, options, date = v.Peer, v.Options, v.Date
+	default:
+		return PollVoter{}, false
+	}
+
+	from, ok := peer.(*tg.PeerUser)
+	if !ok {
+		return PollVoter{}, false
+	}
+	voter := PollVoter{UserID: from.UserID, VotedAt: time.Unix(int64(date), 0)}
+	if user, ok := users[from.UserID]; ok {
+		voter.Name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+		voter.Username = user.Username
+	}
+	for _, option := range options {
+		if position, err := strconv.Atoi(string(option)); err == nil {
+			voter.Options = append(voter.Options, position)
+		}
+	}
+	return voter, true
+}
+
+// isPollClosedError 投票已经结束（或内容未变化）
+func isPollClosedError(err error) bool {
+	return strings.Contains(err.Error(), "MESSAGE_NOT_MODIFIED") || strings.Contains(err.Error(), "POLL_CLOSED")
+}
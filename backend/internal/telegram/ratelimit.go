@@ -0,0 +1,75 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimiter 账号级发送限流器
+// 保证两次发送之间至少间隔 minInterval，并在收到 FLOOD_WAIT 后暂停该账号的所有发送
+type RateLimiter struct {
+	mu           sync.Mutex
+	minInterval  time.Duration
+	next         time.Time // 下一次允许发送的时间
+	blockedUntil time.Time // FLOOD_WAIT 解除时间
+}
+
+// NewRateLimiter 创建限流器
+func NewRateLimiter(minInterval time.Duration) *RateLimiter {
+	return &RateLimiter{minInterval: minInterval}
+}
+
+// Wait 等待直到允许发送；需要等待的时间超过 maxWait 时直接返回错误
+func (l *RateLimiter) Wait(ctx context.Context, maxWait time.Duration) error {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if l.blockedUntil.After(at) {
+		at = l.blockedUntil
+	}
+	if at.Before(now) {
+		at = now
+	}
+	wait := at.Sub(now)
+	if maxWait > 0 && wait > maxWait {
+		l.mu.Unlock()
+		return fmt.Errorf("账号发送受限，还需等待 %s", wait.Round(time.Second))
+	}
+	// 预占发送时间，后续调用顺延
+	l.next = at.Add(l.minInterval)
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
+
+// Block 收到 FLOOD_WAIT 后暂停发送
+func (l *RateLimiter) Block(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until := time.Now().Add(d)
+	if until.After(l.blockedUntil) {
+		l.blockedUntil = until
+	}
+}
+
+// BlockedUntil 返回 FLOOD_WAIT 解除时间（未受限时为零值）
+func (l *RateLimiter) BlockedUntil() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.blockedUntil.Before(time.Now()) {
+		return time.Time{}
+	}
+	return l.blockedUntil
+}
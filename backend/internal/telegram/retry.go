@@ -2,62 +2,179 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
+
+	"github.com/gotd/td/tgerr"
 )
 
 // RetryConfig 重试配置
 type RetryConfig struct {
-	MaxRetries int           // 最大重试次数
+	MaxRetries   int           // 最大重试次数
 	InitialDelay time.Duration // 初始延迟
 	MaxDelay     time.Duration // 最大延迟
-	Multiplier   float64      // 延迟倍数
+	Multiplier   float64       // 延迟倍数
+	MaxFloodWait time.Duration // 可接受的最长 FLOOD_WAIT / SLOWMODE_WAIT，超过则直接失败
+	Limiter      *RateLimiter  // 账号级限流器（可选）
 }
 
 // DefaultRetryConfig 默认重试配置
 func DefaultRetryConfig() RetryConfig {
 	return RetryConfig{
-		MaxRetries:  3,
+		MaxRetries:   3,
 		InitialDelay: 1 * time.Second,
 		MaxDelay:     10 * time.Second,
 		Multiplier:   2.0,
+		MaxFloodWait: 5 * time.Minute,
+	}
+}
+
+// ErrorClass 错误分类
+type ErrorClass int
+
+const (
+	// ErrorRetryable 临时错误（网络、服务端内部错误等），可以退避重试
+	ErrorRetryable ErrorClass = iota
+	// ErrorFloodWait 账号级限流（FLOOD_WAIT_X），等待指定时间后重试
+	ErrorFloodWait
+	// ErrorSlowMode 群组慢速模式（SLOWMODE_WAIT_X），等待指定时间后重试
+	ErrorSlowMode
+	// ErrorPermanent 永久错误（被禁言、被踢出、群组私有等），重试无意义，需要停用该群组
+	ErrorPermanent
+	// ErrorNonRetryable 请求本身有问题（参数错误等），不重试
+	ErrorNonRetryable
+)
+
+// permanentErrorTypes 表示账号在该群组已无法发言的错误（被禁言、被踢出、群组私有、禁止发言）
+// PEER_ID_INVALID、CHANNEL_INVALID、CHAT_ADMIN_REQUIRED 等可能只是缓存过期或单次操作缺少权限，按请求错误处理，不停用群组
+var permanentErrorTypes = []string{
+	"CHAT_WRITE_FORBIDDEN",
+	"USER_BANNED_IN_CHANNEL",
+	"CHANNEL_PRIVATE",
+	"CHAT_RESTRICTED",
+	"CHAT_SEND_PLAIN_FORBIDDEN",
+	"CHAT_GUEST_SEND_FORBIDDEN",
+	"USER_NOT_PARTICIPANT",
+}
+
+// SendError 不可重试的发送错误
+type SendError struct {
+	Class ErrorClass
+	Type  string // Telegram 错误类型，如 CHAT_WRITE_FORBIDDEN
+	Err   error
+}
+
+func (e *SendError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanentError 判断是否为永久错误（需要停用账号在该群组的分配）
+func IsPermanentError(err error) (*SendError, bool) {
+	var sendErr *SendError
+	if errors.As(err, &sendErr) && sendErr.Class == ErrorPermanent {
+		return sendErr, true
 	}
+	return nil, false
 }
 
-// Retry 重试函数
+// ClassifyError 对 Telegram 错误进行分类，FLOOD_WAIT / SLOWMODE_WAIT 同时返回需要等待的时间
+func ClassifyError(err error) (ErrorClass, time.Duration) {
+	if d, ok := tgerr.AsFloodWait(err); ok {
+		return ErrorFloodWait, d
+	}
+	if rpcErr, ok := tgerr.AsType(err, "SLOWMODE_WAIT"); ok {
+		return ErrorSlowMode, time.Duration(rpcErr.Argument) * time.Second
+	}
+
+	rpcErr, ok := tgerr.As(err)
+	if !ok {
+		// 非 RPC 错误（网络中断、超时等）
+		return ErrorRetryable, 0
+	}
+	if rpcErr.IsOneOf(permanentErrorTypes...) {
+		return ErrorPermanent, 0
+	}
+	// 5xx 为服务端内部错误，可以重试；其余 4xx 为请求错误
+	if rpcErr.Code >= 500 || rpcErr.Code == 0 {
+		return ErrorRetryable, 0
+	}
+	return ErrorNonRetryable, 0
+}
+
+// Retry 重试函数（按错误分类决定是否重试以及等待时间）
 func Retry(ctx context.Context, fn func() error, config RetryConfig) error {
 	var lastErr error
 	delay := config.InitialDelay
+	attempts, floodWaits := 0, 0
+
+	for {
+		if config.Limiter != nil {
+			if err := config.Limiter.Wait(ctx, config.MaxFloodWait); err != nil {
+				return err
+			}
+		}
 
-	for i := 0; i < config.MaxRetries; i++ {
-		if err := fn(); err == nil {
+		err := fn()
+		if err == nil {
 			return nil
-		} else {
-			lastErr = err
-			log.Printf("⚠️ 操作失败，准备重试 [尝试 %d/%d]: %v", i+1, config.MaxRetries, err)
 		}
+		lastErr = err
+
+		class, wait := ClassifyError(err)
+		switch class {
+		case ErrorPermanent, ErrorNonRetryable:
+			rpcType := ""
+			if rpcErr, ok := tgerr.As(err); ok {
+				rpcType = rpcErr.Type
+			}
+			log.Printf("⛔ 操作失败，不可重试 [%s]: %v", rpcType, err)
+			return &SendError{Class: class, Type: rpcType, Err: err}
 
-		// 如果不是最后一次尝试，等待后重试
-		if i < config.MaxRetries-1 {
+		case ErrorFloodWait, ErrorSlowMode:
+			// 限流等待不计入普通重试次数，但同样有上限
+			floodWaits++
+			if class == ErrorFloodWait && config.Limiter != nil {
+				config.Limiter.Block(wait)
+			}
+			if wait > config.MaxFloodWait || floodWaits > config.MaxRetries {
+				return fmt.Errorf("触发限流，需要等待 %s 后才能再次发送: %w", wait, err)
+			}
+			log.Printf("🐢 触发限流，等待 %s 后重试: %v", wait, err)
+			if class == ErrorFloodWait && config.Limiter != nil {
+				continue // 由限流器在下一轮统一等待
+			}
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
-			case <-time.After(delay):
-				// 指数退避
-				delay = time.Duration(float64(delay) * config.Multiplier)
-				if delay > config.MaxDelay {
-					delay = config.MaxDelay
-				}
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		attempts++
+		log.Printf("⚠️ 操作失败，准备重试 [尝试 %d/%d]: %v", attempts, config.MaxRetries, err)
+		if attempts >= config.MaxRetries {
+			break
+		}
+
+		// 等待后重试
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+			// 指数退避
+			delay = time.Duration(float64(delay) * config.Multiplier)
+			if delay > config.MaxDelay {
+				delay = config.MaxDelay
 			}
 		}
 	}
 
 	return fmt.Errorf("重试 %d 次后仍然失败: %w", config.MaxRetries, lastErr)
 }
-
-// RetryWithBackoff 带指数退避的重试
-func RetryWithBackoff(ctx context.Context, fn func() error) error {
-	return Retry(ctx, fn, DefaultRetryConfig())
-}
-
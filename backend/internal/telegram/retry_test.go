@@ -0,0 +1,49 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/tgerr"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorClass
+	}{
+		{tgerr.New(403, "CHAT_WRITE_FORBIDDEN"), ErrorPermanent},
+		{tgerr.New(400, "USER_BANNED_IN_CHANNEL"), ErrorPermanent},
+		{tgerr.New(400, "CHANNEL_PRIVATE"), ErrorPermanent},
+		{tgerr.New(400, "USER_NOT_PARTICIPANT"), ErrorPermanent},
+		// 缓存过期或单次操作缺少权限，不停用群组
+		{tgerr.New(400, "PEER_ID_INVALID"), ErrorNonRetryable},
+		{tgerr.New(400, "CHANNEL_INVALID"), ErrorNonRetryable},
+		{tgerr.New(400, "CHAT_ADMIN_REQUIRED"), ErrorNonRetryable},
+		{tgerr.New(420, "FLOOD_WAIT_3"), ErrorFloodWait},
+		{tgerr.New(500, "INTERNAL"), ErrorRetryable},
+		{errors.New("connection reset"), ErrorRetryable},
+	}
+	for _, tt := range tests {
+		if got, _ := ClassifyError(tt.err); got != tt.want {
+			t.Errorf("ClassifyError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryStopsOnNonRetryable(t *testing.T) {
+	calls := 0
+	err := Retry(context.Background(), func() error {
+		calls++
+		return tgerr.New(400, "PEER_ID_INVALID")
+	}, RetryConfig{MaxRetries: 3, InitialDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 1})
+
+	if calls != 1 {
+		t.Fatalf("calls = %d, want 1", calls)
+	}
+	if _, ok := IsPermanentError(err); ok {
+		t.Fatal("PEER_ID_INVALID should not be a permanent error")
+	}
+}
@@ -0,0 +1,427 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"aibot/models"
+
+	"github.com/gotd/td/tg"
+	"gorm.io/gorm"
+)
+
+// 规则动作
+const (
+	RuleActionAIReply  = "ai_reply"
+	RuleActionTemplate = "template"
+	RuleActionIgnore   = "ignore"
+	RuleActionApproval = "approval"
+)
+
+// 正则缓存的规则类型（回复规则和群管规则的ID相互独立）
+const (
+	ruleKindReply      = "reply"
+	ruleKindModeration = "moderation"
+)
+
+// ruleRegexKey 正则缓存键：每条规则只保留一个编译结果
+type ruleRegexKey struct {
+	kind string
+	id   uint
+}
+
+// ruleRegexEntry 规则正则的编译结果，version 为规则的更新时间
+type ruleRegexEntry struct {
+	version time.Time
+	pattern string
+	re      *regexp.Regexp
+}
+
+// 正则缓存（按规则ID和版本复用编译结果，规则更新或删除时淘汰）
+var (
+	ruleRegexCache     = make(map[ruleRegexKey]ruleRegexEntry)
+	ruleRegexCacheLock sync.Mutex
+)
+
+// compileRulePattern 编译并缓存规则正则，规则版本变化时重新编译并替换旧的编译结果
+func compileRulePattern(kind string, id uint, version time.Time, pattern string) (*regexp.Regexp, error) {
+	key := ruleRegexKey{kind: kind, id: id}
+
+	ruleRegexCacheLock.Lock()
+	defer ruleRegexCacheLock.Unlock()
+
+	if entry, ok := ruleRegexCache[key]; ok && entry.version.Equal(version) && entry.pattern == pattern {
+		return entry.re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		delete(ruleRegexCache, key)
+		return nil, err
+	}
+	ruleRegexCache[key] = ruleRegexEntry{version: version, pattern: pattern, re: re}
+	return re, nil
+}
+
+// evictRulePattern 淘汰规则的正则缓存
+func evictRulePattern(kind string, id uint) {
+	ruleRegexCacheLock.Lock()
+	defer ruleRegexCacheLock.Unlock()
+
+	delete(ruleRegexCache, ruleRegexKey{kind: kind, id: id})
+}
+
+// EvictReplyRulePattern 回复规则更新或删除后淘汰其正则缓存
+func EvictReplyRulePattern(ruleID uint) {
+	evictRulePattern(ruleKindReply, ruleID)
+}
+
+// EvictModerationRulePattern 群管规则更新或删除后淘汰其正则缓存
+func EvictModerationRulePattern(ruleID uint) {
+	evictRulePattern(ruleKindModeration, ruleID)
+}
+
+// ValidateRule 校验规则配置是否合法
+func ValidateRule(rule *models.ReplyRule) error {
+	if strings.TrimSpace(rule.Pattern) == "" {
+		return fmt.Errorf("匹配内容不能为空")
+	}
+	switch rule.MatchType {
+	case "", "keyword":
+	case "regex":
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("正则表达式无效: %w", err)
+		}
+	default:
+		return fmt.Errorf("不支持的匹配类型: %s", rule.MatchType)
+	}
+	switch rule.Action {
+	case RuleActionAIReply, RuleActionIgnore, RuleActionApproval:
+	case RuleActionTemplate:
+		if strings.TrimSpace(rule.Template) == "" {
+			return fmt.Errorf("固定回复内容不能为空")
+		}
+	default:
+		return fmt.Errorf("不支持的动作: %s", rule.Action)
+	}
+	if err := ValidateKeyboard(rule.Buttons); err != nil {
+		return err
+	}
+	for _, t := range []string{rule.ActiveFrom, rule.ActiveTo} {
+		if t == "" {
+			continue
+		}
+		if _, err := time.Parse("15:04", t); err != nil {
+			return fmt.Errorf("时间格式应为 HH:MM: %s", t)
+		}
+	}
+	return nil
+}
+
+// ruleMatches 判断规则是否命中消息
+func ruleMatches(rule *models.ReplyRule, msg BufferedMessage, now time.Time) bool {
+	if !ruleTextMatches(rule, msg.Content) {
+		return false
+	}
+	if !ruleSenderMatches(rule.Senders, msg) {
+		return false
+	}
+	if !ruleTypeMatches(rule.MessageTypes, msg.MessageType) {
+		return false
+	}
+	return ruleTimeMatches(rule.ActiveFrom, rule.ActiveTo, now)
+}
+
+// ruleTextMatches 关键词或正则匹配
+func ruleTextMatches(rule *models.ReplyRule, text string) bool {
+	if rule.MatchType == "regex" {
+		re, err := compileRulePattern(ruleKindReply, rule.ID, rule.UpdatedAt, rule.Pattern)
+		if err != nil {
+			log.Printf("⚠️ 规则 [%d] 正则无效: %v", rule.ID, err)
+			return false
+		}
+		return re.MatchString(text)
+	}
+
+	lower := strings.ToLower(text)
+	for _, keyword := range strings.Split(rule.Pattern, ",") {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if keyword != "" && strings.Contains(lower, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleSenderMatches 发送者条件（用户ID或@用户名）
+func ruleSenderMatches(senders string, msg BufferedMessage) bool {
+	if strings.TrimSpace(senders) == "" {
+		return true
+	}
+	for _, sender := range strings.Split(senders, ",") {
+		sender = strings.TrimSpace(sender)
+		if sender == "" {
+			continue
+		}
+		if strings.HasPrefix(sender, "@") {
+			if msg.SenderUsername != "" && strings.EqualFold(sender[1:], msg.SenderUsername) {
+				return true
+			}
+			continue
+		}
+		if id, err := strconv.ParseInt(sender, 10, 64); err == nil && id == msg.SenderID {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleTypeMatches 消息类型条件
+func ruleTypeMatches(types, messageType string) bool {
+	if strings.TrimSpace(types) == "" {
+		return true
+	}
+	if messageType == "" {
+		messageType = "text"
+	}
+	for _, t := range strings.Split(types, ",") {
+		if strings.TrimSpace(t) == messageType {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleTimeMatches 生效时间段条件（支持跨零点，如 22:00-06:00）
+func ruleTimeMatches(from, to string, now time.Time) bool {
+	if from == "" && to == "" {
+		return true
+	}
+	minutes := now.Hour()*60 + now.Minute()
+	start, end := 0, 24*60
+	if t, err := time.Parse("15:04", from); err == nil {
+		start = t.Hour()*60 + t.Minute()
+	}
+	if t, err := time.Parse("15:04", to); err == nil {
+		end = t.Hour()*60 + t.Minute()
+	}
+	if start <= end {
+		return minutes >= start && minutes < end
+	}
+	return minutes >= start || minutes < end
+}
+
+// messageMediaType 识别消息的媒体类型
+func messageMediaType(media tg.MessageMediaClass) string {
+	switch m := media.(type) {
+	case nil, *tg.MessageMediaEmpty, *tg.MessageMediaWebPage:
+		return "text"
+	case *tg.MessageMediaPhoto:
+		return "photo"
+	case *tg.MessageMediaDocument:
+		doc, ok := m.Document.(*tg.Document)
+		if !ok {
+			return "document"
+		}
+		for _, attr := range doc.Attributes {
+			switch a := attr.(type) {
+			case *tg.DocumentAttributeSticker:
+				return "sticker"
+			case *tg.DocumentAttributeVideo:
+				return "video"
+			case *tg.DocumentAttributeAudio:
+				if a.Voice {
+					return "voice"
+				}
+			}
+		}
+		return "document"
+	}
+	return "other"
+}
+
+// mediaPlaceholder 无文字的媒体消息在上下文中的占位文本
+func mediaPlaceholder(messageType string) string {
+	switch messageType {
+	case "photo":
+		return "[图片]"
+	case "video":
+		return "[视频]"
+	case "sticker":
+		return "[贴纸]"
+	case "voice":
+		return "[语音]"
+	case "document":
+		return "[文件]"
+	}
+	return "[其他消息]"
+}
+
+// loadRules 加载对群组生效的规则（全局规则 + 群组规则），按优先级排序
+func (c *ClientV2) loadRules(groupID uint) []models.ReplyRule {
+	var rules []models.ReplyRule
+	if err := c.DB.Where("enabled = ? AND (group_id IS NULL OR group_id = ?)", true, groupID).
+		Order("priority DESC, id ASC").
+		Find(&rules).Error; err != nil {
+		log.Printf("⚠️ 加载回复规则失败: %v", err)
+		return nil
+	}
+	return rules
+}
+
+// applyRules 对缓冲消息执行规则，返回未被规则处理的消息
+func (c *ClientV2) applyRules(ctx context.Context, w *groupWorker, groupID uint, messages []BufferedMessage) []BufferedMessage {
+	chatID := w.chatID
+	rules := c.loadRules(groupID)
+	if len(rules) == 0 {
+		return messages
+	}
+
+	now := time.Now()
+	remaining := make([]BufferedMessage, 0, len(messages))
+	for _, msg := range messages {
+		var matched *models.ReplyRule
+		for i := range rules {
+			if ruleMatches(&rules[i], msg, now) {
+				matched = &rules[i]
+				break
+			}
+		}
+		if matched == nil {
+			remaining = append(remaining, msg)
+			continue
+		}
+
+		log.Printf("📏 规则命中 [%s, 动作: %s, 群组ID: %d]: %s", matched.Name, matched.Action, chatID, truncateStr(msg.Content, 50))
+		c.recordRuleHit(matched.ID)
+
+		if matched.Action == RuleActionIgnore {
+			continue
+		}
+		if !w.ruleCooldownPassed(matched) {
+			log.Printf("⏳ 规则 [%s] 冷却中，跳过动作", matched.Name)
+			continue
+		}
+
+		if err := c.executeRuleAction(ctx, w, groupID, matched, msg); err != nil {
+			log.Printf("❌ 执行规则动作失败 [%s]: %v", matched.Name, err)
+			continue
+		}
+		w.ruleLastActions[matched.ID] = time.Now()
+	}
+
+	return remaining
+}
+
+// executeRuleAction 执行规则动作
+func (c *ClientV2) executeRuleAction(ctx context.Context, w *groupWorker, groupID uint, rule *models.ReplyRule, msg BufferedMessage) error {
+	chatID := w.chatID
+	switch rule.Action {
+	case RuleActionTemplate:
+		reply := renderRuleTemplate(rule.Template, msg, c.groupTitle(groupID))
+		if err := c.enqueueReplyWithButtons(chatID, w.topicID, reply, msg.MessageID, "rule", rule.Buttons); err != nil {
+			return err
+		}
+		w.lastReplyTime = time.Now()
+		log.Printf("✅ 固定回复已加入发送队列: %s", truncateStr(reply, 100))
+
+	case RuleActionAIReply:
+		reply, err := c.generateRuleReply(ctx, w, rule, msg)
+		if err != nil {
+			return err
+		}
+		if err := c.enqueueReplyWithButtons(chatID, w.topicID, reply, msg.MessageID, "rule", rule.Buttons); err != nil {
+			return err
+		}
+		w.lastReplyTime = time.Now()
+		w.addHistory(msg.Content, reply)
+		log.Printf("✅ 规则回复已加入发送队列: %s", truncateStr(reply, 100))
+
+	case RuleActionApproval:
+		draft, err := c.generateRuleReply(ctx, w, rule, msg)
+		if err != nil {
+			return err
+		}
+		ruleID := rule.ID
+		item := models.ApprovalItem{
+			AccountID:        c.Account.ID,
+			GroupID:          groupID,
+			RuleID:           &ruleID,
+			TriggerMessageID: int64(msg.MessageID),
+			TriggerTopicID:   msg.TopicID,
+			TriggerSender:    msg.SenderName,
+			TriggerContent:   msg.Content,
+			DraftReply:       draft,
+			Status:           "pending",
+		}
+		if err := c.DB.Create(&item).Error; err != nil {
+			return fmt.Errorf("写入审核队列失败: %w", err)
+		}
+		log.Printf("📝 回复已进入审核队列 [ID: %d]", item.ID)
+	}
+	return nil
+}
+
+// generateRuleReply 生成带规则附加指令的AI回复
+func (c *ClientV2) generateRuleReply(ctx context.Context, w *groupWorker, rule *models.ReplyRule, msg BufferedMessage) (string, error) {
+	sender := msg.SenderName
+	if sender == "" {
+		sender = "群友"
+	}
+	prompt := fmt.Sprintf("请直接回复群里的这条消息（直接输出你想说的话）：\n\n%s：%s", sender, msg.Content)
+	if strings.TrimSpace(rule.Instruction) != "" {
+		prompt += fmt.Sprintf("\n\n回复要求：%s", rule.Instruction)
+	}
+
+	reply, err := c.AIService.GenerateReply(
+		ctx,
+		w.account.AIApiKey,
+		w.account.AIModel,
+		w.account.SystemPrompt,
+		prompt,
+		w.chatHistory(),
+	)
+	if err != nil {
+		return "", err
+	}
+	if reply == "" {
+		return "", fmt.Errorf("AI未生成回复内容")
+	}
+	return reply, nil
+}
+
+// renderRuleTemplate 渲染固定回复模板
+func renderRuleTemplate(template string, msg BufferedMessage, groupTitle string) string {
+	sender := msg.SenderName
+	if msg.SenderUsername != "" {
+		sender = "@" + msg.SenderUsername
+	}
+	return strings.NewReplacer(
+		"{sender}", sender,
+		"{group}", groupTitle,
+		"{text}", msg.Content,
+	).Replace(template)
+}
+
+// groupTitle 获取群组标题
+func (c *ClientV2) groupTitle(groupID uint) string {
+	var group models.Group
+	if err := c.DB.Select("title").First(&group, groupID).Error; err != nil {
+		return ""
+	}
+	return group.Title
+}
+
+// recordRuleHit 记录规则命中次数
+func (c *ClientV2) recordRuleHit(ruleID uint) {
+	now := time.Now()
+	c.DB.Model(&models.ReplyRule{}).Where("id = ?", ruleID).UpdateColumns(map[string]interface{}{
+		"hit_count":   gorm.Expr("hit_count + ?", 1),
+		"last_hit_at": now,
+	})
+}
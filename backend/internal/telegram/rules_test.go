@@ -0,0 +1,333 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"aibot/internal/ai"
+	"aibot/models"
+
+	"gorm.io/gorm"
+)
+
+func ruleRegexCacheLen() int {
+	ruleRegexCacheLock.Lock()
+	defer ruleRegexCacheLock.Unlock()
+	return len(ruleRegexCache)
+}
+
+func TestCompileRulePatternVersioning(t *testing.T) {
+	const id = 90001
+	defer EvictReplyRulePattern(id)
+	before := ruleRegexCacheLen()
+
+	v1 := time.Now()
+	re1, err := compileRulePattern(ruleKindReply, id, v1, `^hello`)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	again, _ := compileRulePattern(ruleKindReply, id, v1, `^hello`)
+	if again != re1 {
+		t.Fatal("same rule version should reuse the compiled regex")
+	}
+
+	// 规则更新后替换旧的编译结果，缓存不增长
+	re2, err := compileRulePattern(ruleKindReply, id, v1.Add(time.Second), `^bye`)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if re2 == re1 || !re2.MatchString("bye") {
+		t.Fatal("new rule version should be recompiled")
+	}
+	if got := ruleRegexCacheLen(); got != before+1 {
+		t.Fatalf("cache size = %d, want %d", got, before+1)
+	}
+
+	// 回复规则和群管规则的ID相互独立
+	if _, err := compileRulePattern(ruleKindModeration, id, v1, `^spam`); err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	defer EvictModerationRulePattern(id)
+	if got := ruleRegexCacheLen(); got != before+2 {
+		t.Fatalf("cache size = %d, want %d", got, before+2)
+	}
+
+	EvictReplyRulePattern(id)
+	EvictModerationRulePattern(id)
+	if got := ruleRegexCacheLen(); got != before {
+		t.Fatalf("cache size after evict = %d, want %d", got, before)
+	}
+}
+
+func TestCompileRulePatternInvalid(t *testing.T) {
+	const id = 90002
+	defer EvictReplyRulePattern(id)
+
+	if _, err := compileRulePattern(ruleKindReply, id, time.Now(), `(`); err == nil {
+		t.Fatal("invalid pattern should fail")
+	}
+}
+
+func TestRuleTimeMatches(t *testing.T) {
+	at := func(clock string) time.Time {
+		v, _ := time.Parse("15:04", clock)
+		return time.Date(2024, 1, 1, v.Hour(), v.Minute(), 0, 0, time.Local)
+	}
+	tests := []struct {
+		name     string
+		from, to string
+		now      string
+		want     bool
+	}{
+		{"不限时间", "", "", "03:00", true},
+		{"白天窗口内", "09:00", "18:00", "12:30", true},
+		{"白天窗口开始时刻", "09:00", "18:00", "09:00", true},
+		{"白天窗口结束时刻不含", "09:00", "18:00", "18:00", false},
+		{"白天窗口外", "09:00", "18:00", "20:00", false},
+		{"跨零点窗口前半段", "22:00", "06:00", "23:30", true},
+		{"跨零点窗口后半段", "22:00", "06:00", "03:00", true},
+		{"跨零点窗口开始时刻", "22:00", "06:00", "22:00", true},
+		{"跨零点窗口结束时刻不含", "22:00", "06:00", "06:00", false},
+		{"跨零点窗口外", "22:00", "06:00", "12:00", false},
+		{"只有开始时间", "20:00", "", "23:59", true},
+		{"只有开始时间之前", "20:00", "", "19:59", false},
+		{"只有结束时间", "", "08:00", "07:00", true},
+		{"只有结束时间之后", "", "08:00", "09:00", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ruleTimeMatches(tt.from, tt.to, at(tt.now)); got != tt.want {
+				t.Fatalf("ruleTimeMatches(%q, %q, %s) = %v, want %v", tt.from, tt.to, tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleSenderMatches(t *testing.T) {
+	msg := BufferedMessage{SenderID: 12345, SenderUsername: "Alice"}
+	tests := []struct {
+		name    string
+		senders string
+		msg     BufferedMessage
+		want    bool
+	}{
+		{"不限发送者", "", msg, true},
+		{"只有空白", "  ", msg, true},
+		{"用户ID", "999, 12345", msg, true},
+		{"用户名忽略大小写", "@bob,@alice", msg, true},
+		{"不在列表中", "999,@bob", msg, false},
+		{"用户名不能按ID匹配", "@12345", msg, false},
+		{"发送者没有用户名", "@alice", BufferedMessage{SenderID: 12345}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ruleSenderMatches(tt.senders, tt.msg); got != tt.want {
+				t.Fatalf("ruleSenderMatches(%q) = %v, want %v", tt.senders, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleTypeMatches(t *testing.T) {
+	tests := []struct {
+		name        string
+		types       string
+		messageType string
+		want        bool
+	}{
+		{"不限类型", "", "photo", true},
+		{"未识别类型按文本", "text", "", true},
+		{"列表中的类型", "photo, video", "video", true},
+		{"不在列表中", "photo,video", "sticker", false},
+		{"文本不匹配媒体限定", "photo", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ruleTypeMatches(tt.types, tt.messageType); got != tt.want {
+				t.Fatalf("ruleTypeMatches(%q, %q) = %v, want %v", tt.types, tt.messageType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	const id = 90003
+	defer EvictReplyRulePattern(id)
+
+	noon := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	text := BufferedMessage{Content: "请问 Price 是多少？", SenderID: 1, SenderUsername: "alice"}
+	tests := []struct {
+		name string
+		rule models.ReplyRule
+		msg  BufferedMessage
+		want bool
+	}{
+		{"关键词忽略大小写", models.ReplyRule{Pattern: "price"}, text, true},
+		{"任一关键词命中", models.ReplyRule{Pattern: " ,价格, 多少"}, text, true},
+		{"关键词未命中", models.ReplyRule{Pattern: "退款,refund"}, text, false},
+		{"空关键词不命中", models.ReplyRule{Pattern: " , "}, text, false},
+		{"正则", models.ReplyRule{ID: id, MatchType: "regex", Pattern: `(?i)price\s*是`}, text, true},
+		{"正则未命中", models.ReplyRule{ID: id + 1, MatchType: "regex", Pattern: `^price`}, text, false},
+		{"无效正则不命中", models.ReplyRule{ID: id + 2, MatchType: "regex", Pattern: `(`}, text, false},
+		{"发送者不符", models.ReplyRule{Pattern: "price", Senders: "@bob"}, text, false},
+		{"消息类型不符", models.ReplyRule{Pattern: "price", MessageTypes: "photo"}, text, false},
+		{"不在生效时间", models.ReplyRule{Pattern: "price", ActiveFrom: "22:00", ActiveTo: "06:00"}, text, false},
+		{"全部条件满足", models.ReplyRule{Pattern: "price", Senders: "1", MessageTypes: "text", ActiveFrom: "09:00", ActiveTo: "18:00"}, text, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer EvictReplyRulePattern(tt.rule.ID)
+			if got := ruleMatches(&tt.rule, tt.msg, noon); got != tt.want {
+				t.Fatalf("ruleMatches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// stubGenerator 固定返回内容的AI回复生成器，记录调用次数
+type stubGenerator struct {
+	reply string
+	calls int
+}
+
+func (g *stubGenerator) GenerateReply(ctx context.Context, apiKey, model, systemPrompt, message string, contextMessages []ai.ChatMessage) (string, error) {
+	g.calls++
+	return g.reply, nil
+}
+
+// createRule 创建群组规则（enabled 默认开启）
+func createRule(t *testing.T, db *gorm.DB, rule models.ReplyRule) models.ReplyRule {
+	t.Helper()
+	rule.Enabled = true
+	if rule.Name == "" {
+		rule.Name = rule.Pattern
+	}
+	if err := db.Create(&rule).Error; err != nil {
+		t.Fatalf("create rule: %v", err)
+	}
+	return rule
+}
+
+// outboundContents 群组发送队列中的消息内容
+func outboundContents(t *testing.T, db *gorm.DB, groupID uint) []string {
+	t.Helper()
+	var contents []string
+	if err := db.Model(&models.OutboundMessage{}).Where("group_id = ?", groupID).Order("id").Pluck("content", &contents).Error; err != nil {
+		t.Fatalf("query outbox: %v", err)
+	}
+	return contents
+}
+
+func TestApplyRules(t *testing.T) {
+	db := testDB(t)
+	c := testClient(t, db)
+	gen := &stubGenerator{reply: "草稿回复"}
+	c.AIService = gen
+	ag := assignGroup(t, c, 100)
+	groupID := ag.GroupID
+
+	// 全局低优先级规则先创建，群组高优先级规则后创建，优先级高的先匹配
+	createRule(t, db, models.ReplyRule{Pattern: "price", Action: RuleActionTemplate, Template: "global", Priority: 1})
+	createRule(t, db, models.ReplyRule{GroupID: &groupID, Pattern: "price", Action: RuleActionTemplate, Template: "{sender} 问了 {text}", Priority: 10})
+	createRule(t, db, models.ReplyRule{GroupID: &groupID, Pattern: "spam", Action: RuleActionIgnore})
+	approval := createRule(t, db, models.ReplyRule{GroupID: &groupID, Pattern: "refund", Action: RuleActionApproval})
+	// 其他群组的规则不生效
+	other := groupID + 1
+	createRule(t, db, models.ReplyRule{GroupID: &other, Pattern: "hello", Action: RuleActionIgnore})
+
+	w := newGroupWorker(bufferKey{chatID: 100})
+	w.account = *c.Account
+	w.account.AIApiKey = "test-key"
+
+	messages := []BufferedMessage{
+		{MessageID: 1, Content: "price?", SenderUsername: "alice"},
+		{MessageID: 2, Content: "buy spam now"},
+		{MessageID: 3, Content: "I want a refund", SenderName: "Bob"},
+		{MessageID: 4, Content: "hello"},
+	}
+	remaining := c.applyRules(c.Context, w, groupID, messages)
+
+	if len(remaining) != 1 || remaining[0].MessageID != 4 {
+		t.Fatalf("remaining = %+v, want only message 4", remaining)
+	}
+
+	contents := outboundContents(t, db, groupID)
+	if len(contents) != 1 || contents[0] != "@alice 问了 price?" {
+		t.Fatalf("outbox = %q, want the higher-priority template only", contents)
+	}
+
+	// 人工审核规则：AI草稿进入审核队列，不直接发送
+	var items []models.ApprovalItem
+	db.Where("group_id = ?", groupID).Find(&items)
+	if len(items) != 1 {
+		t.Fatalf("approval items = %d, want 1", len(items))
+	}
+	item := items[0]
+	if item.Status != "pending" || item.DraftReply != "草稿回复" || item.RuleID == nil || *item.RuleID != approval.ID ||
+		item.TriggerMessageID != 3 || item.TriggerSender != "Bob" || item.AccountID != c.ID {
+		t.Fatalf("approval item = %+v", item)
+	}
+	if gen.calls != 1 {
+		t.Fatalf("AI calls = %d, want 1", gen.calls)
+	}
+
+	// 命中的规则（包括忽略）都记录命中次数
+	var hits []int64
+	db.Model(&models.ReplyRule{}).Order("id").Pluck("hit_count", &hits)
+	if want := []int64{0, 1, 1, 1, 0}; fmt.Sprint(hits) != fmt.Sprint(want) {
+		t.Fatalf("hit counts = %v, want %v", hits, want)
+	}
+}
+
+func TestApplyRulesCooldown(t *testing.T) {
+	db := testDB(t)
+	c := testClient(t, db)
+	ag := assignGroup(t, c, 100)
+	groupID := ag.GroupID
+	createRule(t, db, models.ReplyRule{GroupID: &groupID, Pattern: "price", Action: RuleActionTemplate, Template: "报价", CooldownSeconds: 60})
+
+	w := newGroupWorker(bufferKey{chatID: 100})
+	w.account = *c.Account
+	messages := []BufferedMessage{{MessageID: 1, Content: "price"}, {MessageID: 2, Content: "price again"}}
+
+	// 冷却期内命中的消息不执行动作，也不交给AI回复
+	if remaining := c.applyRules(c.Context, w, groupID, messages); len(remaining) != 0 {
+		t.Fatalf("remaining = %d, want 0", len(remaining))
+	}
+	if contents := outboundContents(t, db, groupID); len(contents) != 1 {
+		t.Fatalf("outbox = %q, want 1 reply within cooldown", contents)
+	}
+}
+
+func TestRuleRepliesBypassIntervalAndProbability(t *testing.T) {
+	db := testDB(t)
+	c := testClient(t, db)
+	gen := &stubGenerator{reply: "AI回复"}
+	c.AIService = gen
+	ag := assignGroup(t, c, 100)
+	groupID := ag.GroupID
+	// 群组回复概率极低，且刚刚发过言（发言间隔未到）
+	db.Model(&ag).Update("reply_probability", 0.0001)
+	createRule(t, db, models.ReplyRule{GroupID: &groupID, Pattern: "price", Action: RuleActionTemplate, Template: "报价"})
+
+	w := newGroupWorker(bufferKey{chatID: 100})
+	w.account = *c.Account
+	w.account.AutoReply = true
+	w.account.ReplyInterval = 3600
+	w.lastReplyTime = time.Now()
+
+	c.processGroupMessages(c.Context, w, []BufferedMessage{
+		{MessageID: 1, Content: "price"},
+		{MessageID: 2, Content: "随便聊聊"},
+	})
+
+	if contents := outboundContents(t, db, groupID); len(contents) != 1 || contents[0] != "报价" {
+		t.Fatalf("outbox = %q, want the rule reply", contents)
+	}
+	// 未命中规则的消息仍受发言间隔限制，不生成AI回复
+	if gen.calls != 0 {
+		t.Fatalf("AI calls = %d, want 0", gen.calls)
+	}
+}
@@ -0,0 +1,200 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"aibot/internal/state"
+)
+
+// 运行状态在状态存储中的保留时间（账号停止或切换实例超过该时长后不再恢复）
+const (
+	bufferStateTTL = time.Hour
+	workerStateTTL = 24 * time.Hour
+)
+
+// stateTimeout 单次读写状态存储的超时时间
+const stateTimeout = 3 * time.Second
+
+// bufferSaveDelay 缓冲区变化后延迟保存，合并短时间内的多次变化
+const bufferSaveDelay = 200 * time.Millisecond
+
+// workerState 群组处理协程需要跨重启保留的回复状态
+type workerState struct {
+	LastReplyTime     time.Time          `json:"last_reply_time"`
+	History           []MessageContext   `json:"history"`
+	TriggerReplyTimes []time.Time        `json:"trigger_reply_times"`
+	RuleLastActions   map[uint]time.Time `json:"rule_last_actions"`
+}
+
+// stateKey 账号的状态存储键
+func (c *ClientV2) stateKey(format string, args ...interface{}) string {
+	return fmt.Sprintf("account:%d:", c.ID) + fmt.Sprintf(format, args...)
+}
+
+// bufferStateKey 群组（话题）缓冲区的状态存储键
+func (c *ClientV2) bufferStateKey(key bufferKey) string {
+	return c.stateKey("buffer:%d:%d", key.chatID, key.topicID)
+}
+
+// workerStateKey 群组（话题）回复状态的状态存储键
+func (c *ClientV2) workerStateKey(key bufferKey) string {
+	return c.stateKey("worker:%d:%d", key.chatID, key.topicID)
+}
+
+// saveBuffer 标记群组（话题）的缓冲消息需要保存，调用方持有 messageBufferLock
+// 状态存储的读写在锁外进行（persistBuffer），不阻塞收消息和处理协程
+func (c *ClientV2) saveBuffer(key bufferKey) {
+	if c.bufferDirty[key] {
+		return
+	}
+	c.bufferDirty[key] = true
+	time.AfterFunc(bufferSaveDelay, func() { c.persistBuffer(key) })
+}
+
+// persistBuffer 复制缓冲消息后写入状态存储（同一账号的写入按顺序进行，旧内容不会覆盖新内容）
+func (c *ClientV2) persistBuffer(key bufferKey) {
+	c.bufferSaveLock.Lock()
+	defer c.bufferSaveLock.Unlock()
+
+	c.messageBufferLock.Lock()
+	if !c.bufferDirty[key] {
+		c.messageBufferLock.Unlock()
+		return
+	}
+	delete(c.bufferDirty, key)
+	messages := append([]BufferedMessage(nil), c.messageBuffer[key]...)
+	c.messageBufferLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+
+	var err error
+	if len(messages) == 0 {
+		err = c.store.Delete(ctx, c.bufferStateKey(key))
+	} else {
+		var data []byte
+		if data, err = json.Marshal(messages); err == nil {
+			err = c.store.Set(ctx, c.bufferStateKey(key), data, bufferStateTTL)
+		}
+	}
+	if err != nil {
+		log.Printf("⚠️ 保存消息缓冲区失败 [群组ID: %s]: %v", key, err)
+	}
+}
+
+// flushBuffers 立即保存全部待保存的缓冲区（客户端停止时，接管账号的实例可以恢复最新内容）
+func (c *ClientV2) flushBuffers() {
+	c.messageBufferLock.Lock()
+	keys := make([]bufferKey, 0, len(c.bufferDirty))
+	for key := range c.bufferDirty {
+		keys = append(keys, key)
+	}
+	c.messageBufferLock.Unlock()
+
+	for _, key := range keys {
+		c.persistBuffer(key)
+	}
+}
+
+// restoreBuffers 恢复账号的缓冲消息（客户端重启或由其他实例接管账号时）
+func (c *ClientV2) restoreBuffers() {
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+
+	prefix := c.stateKey("buffer:")
+	keys, err := c.store.Keys(ctx, prefix)
+	if err != nil {
+		log.Printf("⚠️ 读取消息缓冲区失败 [账号ID: %d]: %v", c.ID, err)
+		return
+	}
+
+	c.messageBufferLock.Lock()
+	defer c.messageBufferLock.Unlock()
+
+	restored := 0
+	for _, storeKey := range keys {
+		var key bufferKey
+		if _, err := fmt.Sscanf(strings.TrimPrefix(storeKey, prefix), "%d:%d", &key.chatID, &key.topicID); err != nil {
+			continue
+		}
+		data, err := c.store.Get(ctx, storeKey)
+		if err != nil {
+			continue
+		}
+		var messages []BufferedMessage
+		if err := json.Unmarshal(data, &messages); err != nil {
+			log.Printf("⚠️ 解析消息缓冲区失败 [群组ID: %s]: %v", key, err)
+			continue
+		}
+		c.messageBuffer[key] = messages
+		restored += len(messages)
+	}
+
+	if restored > 0 {
+		log.Printf("♻️ 已恢复 %d 条缓冲消息 [账号ID: %d]", restored, c.ID)
+	}
+}
+
+// loadWorkerState 恢复群组处理协程的回复状态（最近发言时间、对话上下文、冷却）
+func (c *ClientV2) loadWorkerState(w *groupWorker) {
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+
+	data, err := c.store.Get(ctx, c.workerStateKey(w.key()))
+	if err != nil {
+		if !errors.Is(err, state.ErrNotFound) {
+			log.Printf("⚠️ 读取回复状态失败 [群组ID: %s]: %v", w.key(), err)
+		}
+		return
+	}
+
+	var saved workerState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		log.Printf("⚠️ 解析回复状态失败 [群组ID: %s]: %v", w.key(), err)
+		return
+	}
+	w.lastReplyTime = saved.LastReplyTime
+	w.history = saved.History
+	w.triggerReplyTimes = saved.TriggerReplyTimes
+	if saved.RuleLastActions != nil {
+		w.ruleLastActions = saved.RuleLastActions
+	}
+}
+
+// saveWorkerState 保存群组处理协程的回复状态
+func (c *ClientV2) saveWorkerState(w *groupWorker) {
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+
+	data, err := json.Marshal(workerState{
+		LastReplyTime:     w.lastReplyTime,
+		History:           w.history,
+		TriggerReplyTimes: w.triggerReplyTimes,
+		RuleLastActions:   w.ruleLastActions,
+	})
+	if err == nil {
+		err = c.store.Set(ctx, c.workerStateKey(w.key()), data, workerStateTTL)
+	}
+	if err != nil {
+		log.Printf("⚠️ 保存回复状态失败 [群组ID: %s]: %v", w.key(), err)
+	}
+}
+
+// tryCooldown 开始一次冷却，仍在冷却期内时返回 false（状态存储不可用时不限制）
+func (c *ClientV2) tryCooldown(key string, cooldown time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+
+	started, err := c.store.SetNX(ctx, key, []byte("1"), cooldown)
+	if err != nil {
+		log.Printf("⚠️ 记录冷却时间失败 [%s]: %v", key, err)
+		return true
+	}
+	return started
+}
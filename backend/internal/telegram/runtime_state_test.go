@@ -0,0 +1,60 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// storedBuffer 读取状态存储中的缓冲消息
+func storedBuffer(t *testing.T, c *ClientV2, key bufferKey) []BufferedMessage {
+	t.Helper()
+	data, err := c.store.Get(context.Background(), c.bufferStateKey(key))
+	if err != nil {
+		return nil
+	}
+	var messages []BufferedMessage
+	if err := json.Unmarshal(data, &messages); err != nil {
+		t.Fatalf("decode buffer: %v", err)
+	}
+	return messages
+}
+
+func TestBufferSavedAfterDelay(t *testing.T) {
+	c := testClient(t, testDB(t))
+	key := bufferKey{chatID: 100}
+
+	for i := 1; i <= 3; i++ {
+		c.appendToBuffer(key.chatID, BufferedMessage{MessageID: i, Content: "hi"})
+	}
+	waitFor(t, "buffer saved", func() bool { return len(storedBuffer(t, c, key)) == 3 })
+
+	c.takeBuffered(key)
+	waitFor(t, "empty buffer deleted", func() bool { return storedBuffer(t, c, key) == nil })
+}
+
+func TestFlushAndRestoreBuffers(t *testing.T) {
+	db := testDB(t)
+	c := testClient(t, db)
+	key := bufferKey{chatID: 100, topicID: 7}
+
+	c.appendToBuffer(key.chatID, BufferedMessage{MessageID: 1, TopicID: key.topicID, Content: "hi"})
+	c.flushBuffers()
+	if n := len(storedBuffer(t, c, key)); n != 1 {
+		t.Fatalf("stored after flush = %d, want 1", n)
+	}
+
+	// 客户端重启（或由其他实例接管）后恢复
+	restored := &ClientV2{ID: c.ID, store: c.store, messageBuffer: make(map[bufferKey][]BufferedMessage), bufferDirty: make(map[bufferKey]bool)}
+	restored.restoreBuffers()
+	if n := restored.bufferedCount(key); n != 1 {
+		t.Fatalf("restored = %d, want 1", n)
+	}
+
+	// 已保存的内容不会被延迟的保存重复写入
+	time.Sleep(2 * bufferSaveDelay)
+	if n := len(storedBuffer(t, c, key)); n != 1 {
+		t.Fatalf("stored = %d, want 1", n)
+	}
+}
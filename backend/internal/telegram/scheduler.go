@@ -0,0 +1,251 @@
+package telegram
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // 内置时区数据，容器中没有 zoneinfo 时也能解析 Asia/Shanghai 等时区
+
+	"aibot/models"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// 定时公告状态
+const (
+	ScheduleActive    = "active"
+	SchedulePaused    = "paused"
+	ScheduleCompleted = "completed"
+)
+
+// scheduleCheckInterval 检查到期公告的间隔
+const scheduleCheckInterval = 30 * time.Second
+
+// scheduleMissedAfter 超过计划时间该时长仍未执行（服务停机），记为错过，不再补发
+const scheduleMissedAfter = time.Hour
+
+// scheduleMediaDir 定时公告媒体文件的保存目录（每次执行时复制到发送队列）
+var scheduleMediaDir = filepath.Join("data", "schedules")
+
+// ValidateSchedule 校验定时公告配置
+func ValidateSchedule(schedule *models.Schedule) error {
+	if schedule.AccountID == 0 {
+		return fmt.Errorf("缺少发送账号")
+	}
+	if len(ParseGroupIDs(schedule.GroupIDs)) == 0 {
+		return fmt.Errorf("至少需要一个目标群组")
+	}
+	if schedule.Content == "" && schedule.MediaType == "" {
+		return fmt.Errorf("公告内容不能为空")
+	}
+	if _, err := scheduleLocation(schedule.Timezone); err != nil {
+		return fmt.Errorf("无效的时区: %s", schedule.Timezone)
+	}
+
+	switch {
+	case schedule.CronExpr != "" && schedule.RunAt != nil:
+		return fmt.Errorf("cron 表达式和单次发送时间只能设置一个")
+	case schedule.CronExpr != "":
+		if _, err := cron.ParseStandard(schedule.CronExpr); err != nil {
+			return fmt.Errorf("无效的 cron 表达式: %w", err)
+		}
+	case schedule.RunAt == nil:
+		return fmt.Errorf("需要设置 cron 表达式或单次发送时间")
+	}
+	return nil
+}
+
+// ParseGroupIDs 解析逗号分隔的群组ID
+func ParseGroupIDs(value string) []uint {
+	var ids []uint
+	for _, part := range strings.Split(value, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64)
+		if err == nil && id > 0 {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
+
+// NextScheduleRun 计算公告在 after 之后的下一次执行时间，没有下一次时返回 nil
+func NextScheduleRun(schedule *models.Schedule, after time.Time) *time.Time {
+	if schedule.CronExpr == "" {
+		if schedule.RunAt != nil && schedule.RunAt.After(after) {
+			next := *schedule.RunAt
+			return &next
+		}
+		return nil
+	}
+
+	spec, err := cron.ParseStandard(schedule.CronExpr)
+	if err != nil {
+		return nil
+	}
+	loc, err := scheduleLocation(schedule.Timezone)
+	if err != nil {
+		return nil
+	}
+	next := spec.Next(after.In(loc))
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+// SaveScheduleMedia 保存定时公告的媒体文件，返回保存路径
+func SaveScheduleMedia(media *MediaFile) (string, error) {
+	if err := ValidateMediaFile(media); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(scheduleMediaDir, 0755); err != nil {
+		return "", fmt.Errorf("创建媒体目录失败: %w", err)
+	}
+	path := filepath.Join(scheduleMediaDir, fmt.Sprintf("%d_%s", time.Now().UnixNano(), filepath.Base(media.FileName)))
+	if err := os.WriteFile(path, media.Data, 0644); err != nil {
+		return "", fmt.Errorf("保存媒体文件失败: %w", err)
+	}
+	return path, nil
+}
+
+// RemoveScheduleMedia 删除定时公告的媒体文件
+func RemoveScheduleMedia(path string) {
+	removeOutboxMedia(path)
+}
+
+// scheduleLocation 解析时区，为空使用服务器时区
+func scheduleLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// startScheduler 启动定时公告调度（到期的公告写入发送队列，由账号的发送协程发送）
+func (m *Manager) startScheduler() {
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+
+	log.Printf("📅 定时公告调度已启动（每%s检查一次）", scheduleCheckInterval)
+
+	for range ticker.C {
+		m.runDueSchedules()
+	}
+}
+
+// runDueSchedules 执行所有到期的定时公告
+func (m *Manager) runDueSchedules() {
+	now := time.Now()
+
+	var schedules []models.Schedule
+	if err := m.db.Where("status = ? AND next_run_at <= ?", ScheduleActive, now).
+		Order("next_run_at ASC").
+		Find(&schedules).Error; err != nil {
+		log.Printf("⚠️ 查询到期公告失败: %v", err)
+		return
+	}
+
+	for i := range schedules {
+		m.runSchedule(&schedules[i], now)
+	}
+}
+
+// runSchedule 执行一次定时公告：推进下一次执行时间，并为每个目标群组入队一条消息
+func (m *Manager) runSchedule(schedule *models.Schedule, now time.Time) {
+	scheduledAt := *schedule.NextRunAt
+
+	// 先推进下一次执行时间（条件更新，避免重复执行）
+	next := NextScheduleRun(schedule, now)
+	updates := map[string]interface{}{
+		"next_run_at": next,
+		"last_run_at": now,
+		"run_count":   gorm.Expr("run_count + 1"),
+	}
+	if next == nil {
+		updates["status"] = ScheduleCompleted
+	}
+	result := m.db.Model(&models.Schedule{}).
+		Where("id = ? AND status = ? AND next_run_at = ?", schedule.ID, ScheduleActive, scheduledAt).
+		Updates(updates)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+
+	run := models.ScheduleRun{
+		ScheduleID:  schedule.ID,
+		ScheduledAt: scheduledAt,
+	}
+
+	if now.Sub(scheduledAt) > scheduleMissedAfter {
+		run.Status = "missed"
+		run.Error = fmt.Sprintf("服务停机，错过计划执行时间（已延迟 %s）", now.Sub(scheduledAt).Round(time.Minute))
+		m.db.Create(&run)
+		log.Printf("📅 定时公告 [%s] 错过执行时间 %s，跳过", schedule.Name, scheduledAt.Format("2006-01-02 15:04"))
+		return
+	}
+
+	if err := m.db.Create(&run).Error; err != nil {
+		log.Printf("⚠️ 创建公告执行记录失败: %v", err)
+		return
+	}
+
+	var media *MediaFile
+	if schedule.MediaType != "" {
+		data, err := os.ReadFile(schedule.MediaPath)
+		if err != nil {
+			m.db.Model(&run).Updates(map[string]interface{}{
+				"status": "failed",
+				"error":  fmt.Sprintf("读取媒体文件失败: %v", err),
+			})
+			return
+		}
+		media = &MediaFile{
+			Type:     schedule.MediaType,
+			FileName: schedule.FileName,
+			MimeType: schedule.MimeType,
+			Data:     data,
+			Caption:  schedule.Content,
+		}
+	}
+
+	var errs []string
+	queued := 0
+	for _, groupID := range ParseGroupIDs(schedule.GroupIDs) {
+		// 幂等键：同一公告同一计划时间同一群组只入队一次
+		key := fmt.Sprintf("schedule:%d:%d:%d", schedule.ID, scheduledAt.Unix(), groupID)
+		runID := run.ID
+		item := &models.OutboundMessage{
+			AccountID:      schedule.AccountID,
+			GroupID:        groupID,
+			Source:         "schedule",
+			ScheduleRunID:  &runID,
+			Content:        schedule.Content,
+			Priority:       schedule.Priority,
+			IdempotencyKey: &key,
+		}
+		if _, _, err := m.EnqueueOutbound(item, media); err != nil {
+			errs = append(errs, fmt.Sprintf("群组 %d: %v", groupID, err))
+			continue
+		}
+		queued++
+	}
+
+	status := "queued"
+	switch {
+	case queued == 0:
+		status = "failed"
+	case len(errs) > 0:
+		status = "partial"
+	}
+	m.db.Model(&run).Updates(map[string]interface{}{
+		"status":      status,
+		"group_count": queued,
+		"error":       strings.Join(errs, "; "),
+	})
+
+	log.Printf("📅 定时公告 [%s] 已执行，%d 个群组入队", schedule.Name, queued)
+}
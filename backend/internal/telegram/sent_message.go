@@ -0,0 +1,104 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"aibot/models"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// EditSentMessage 通过发送该消息的账号编辑已发送的消息，并保留编辑历史
+func (m *Manager) EditSentMessage(message *models.Message, content string) error {
+	client, group, err := m.sentMessageClient(message)
+	if err != nil {
+		return err
+	}
+	return client.editSentMessage(group.ChatID, message, content)
+}
+
+// DeleteSentMessage 通过发送该消息的账号撤回已发送的消息（对所有人删除）
+func (m *Manager) DeleteSentMessage(message *models.Message) error {
+	client, group, err := m.sentMessageClient(message)
+	if err != nil {
+		return err
+	}
+	return client.deleteSentMessage(group.ChatID, message)
+}
+
+// sentMessageClient 获取发送该消息的账号客户端
+func (m *Manager) sentMessageClient(message *models.Message) (*ClientV2, *models.Group, error) {
+	if message.Status != "sent" || message.TelegramMessageID == 0 {
+		return nil, nil, fmt.Errorf("消息未发送成功")
+	}
+	if message.RemovedAt != nil {
+		return nil, nil, fmt.Errorf("消息已撤回")
+	}
+	return m.clientForGroup(message.AccountID, message.GroupID)
+}
+
+// editSentMessage 编辑已发送的消息（媒体消息修改说明文字），消息上的按钮保持不变
+func (c *ClientV2) editSentMessage(chatID int64, message *models.Message, content string) error {
+	ctx, cancel := context.WithTimeout(c.Context, 30*time.Second)
+	defer cancel()
+
+	// 编辑时不带按钮会移除原有的按钮，从发送队列中取回
+	var markup tg.ReplyMarkupClass
+	var item models.OutboundMessage
+	if err := c.DB.Where("message_id = ?", message.ID).First(&item).Error; err == nil {
+		markup = c.replyMarkup(item.Buttons)
+	}
+
+	if err := c.editMessage(ctx, chatID, int(message.TelegramMessageID), content, markup); err != nil {
+		switch {
+		case tgerr.Is(err, "MESSAGE_NOT_MODIFIED"):
+			return fmt.Errorf("消息内容没有变化")
+		case tgerr.Is(err, "MESSAGE_EDIT_TIME_EXPIRED"):
+			return fmt.Errorf("消息已超过可编辑的时限")
+		}
+		return fmt.Errorf("编辑消息失败: %w", err)
+	}
+
+	now := time.Now()
+	message.EditHistory = append(message.EditHistory, models.MessageRevision{
+		Content:  message.Content,
+		EditedAt: now,
+	})
+	message.Content = content
+	message.EditCount++
+	message.EditedAt = &now
+	if err := c.DB.Model(message).Select("content", "edit_history", "edit_count", "edited_at").Updates(message).Error; err != nil {
+		log.Printf("⚠️ 保存编辑记录失败 [发言ID: %d]: %v", message.ID, err)
+	}
+
+	log.Printf("✏️ 已编辑消息 [群组ID: %d, 消息ID: %d]: %s", chatID, message.TelegramMessageID, truncateStr(content, 50))
+	return nil
+}
+
+// deleteSentMessage 撤回已发送的消息，并记录撤回时间
+func (c *ClientV2) deleteSentMessage(chatID int64, message *models.Message) error {
+	ctx, cancel := context.WithTimeout(c.Context, 30*time.Second)
+	defer cancel()
+
+	if err := c.deleteGroupMessages(ctx, chatID, []int{int(message.TelegramMessageID)}); err != nil {
+		if tgerr.Is(err, "MESSAGE_DELETE_FORBIDDEN") {
+			return fmt.Errorf("没有权限撤回该消息")
+		}
+		return fmt.Errorf("撤回消息失败: %w", err)
+	}
+
+	now := time.Now()
+	message.RemovedAt = &now
+	c.DB.Model(message).Update("removed_at", now)
+	// 设置了自动删除的队列消息不再重复删除
+	c.DB.Model(&models.OutboundMessage{}).
+		Where("message_id = ? AND removed_at IS NULL", message.ID).
+		Update("removed_at", now)
+
+	log.Printf("🗑️ 已撤回消息 [群组ID: %d, 消息ID: %d]", chatID, message.TelegramMessageID)
+	return nil
+}
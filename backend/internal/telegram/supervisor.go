@@ -0,0 +1,294 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"aibot/models"
+
+	"github.com/gotd/td/tgerr"
+)
+
+// 客户端运行状态
+const (
+	RuntimeConnecting = "connecting"  // 正在连接 / 等待登录验证
+	RuntimeRunning    = "running"     // 已连接，正在接收更新
+	RuntimeBackingOff = "backing_off" // 运行失败，等待重启
+	RuntimeNeedsLogin = "needs_login" // 会话失效或认证失败，需要重新登录
+	RuntimeStopped    = "stopped"     // 已停止（未启动或被手动停止）
+)
+
+const (
+	restartBackoffMin  = 2 * time.Second
+	restartBackoffMax  = 5 * time.Minute
+	restartStableAfter = 5 * time.Minute // 连续运行超过该时长后，下次失败从最短退避重新计算
+)
+
+// ErrNeedsLogin 认证失败，需要重新登录
+var ErrNeedsLogin = errors.New("需要重新登录")
+
+// fatalClientErrorTypes 会话失效或账号不可用，重启无意义的错误
+var fatalClientErrorTypes = []string{
+	"AUTH_KEY_UNREGISTERED",
+	"AUTH_KEY_INVALID",
+	"AUTH_KEY_PERM_EMPTY",
+	"AUTH_KEY_DUPLICATED",
+	"SESSION_REVOKED",
+	"SESSION_EXPIRED",
+	"USER_DEACTIVATED",
+	"USER_DEACTIVATED_BAN",
+	"PHONE_NUMBER_BANNED",
+	"API_ID_INVALID",
+	"API_ID_PUBLISHED_FLOOD",
+	"ACCESS_TOKEN_INVALID",
+	"ACCESS_TOKEN_EXPIRED",
+}
+
+// sessionRevokedTypes 会话已失效的错误，需要删除本地会话文件才能重新登录
+var sessionRevokedTypes = []string{
+	"AUTH_KEY_UNREGISTERED",
+	"AUTH_KEY_INVALID",
+	"AUTH_KEY_DUPLICATED",
+	"SESSION_REVOKED",
+	"SESSION_EXPIRED",
+}
+
+// IsFatalClientError 判断客户端错误是否不可恢复（需要人工重新登录）
+func IsFatalClientError(err error) bool {
+	if errors.Is(err, ErrNeedsLogin) {
+		return true
+	}
+	return tgerr.Is(err, fatalClientErrorTypes...)
+}
+
+// ClientRuntime 客户端运行时状态
+type ClientRuntime struct {
+	AccountID    uint       `json:"account_id"`
+	State        string     `json:"state"`
+	Restarts     int        `json:"restarts"` // 自动重启次数
+	LastError    string     `json:"last_error,omitempty"`
+	LastErrorAt  *time.Time `json:"last_error_at,omitempty"`
+	RunningSince *time.Time `json:"running_since,omitempty"`
+	NextRetryAt  *time.Time `json:"next_retry_at,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// supervisor 客户端守护：运行失败时按指数退避（带随机抖动）重启，不可恢复的错误停止并标记需要重新登录
+type supervisor struct {
+	manager *Manager
+	account *models.Account
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu      sync.Mutex
+	client  *ClientV2
+	runtime ClientRuntime
+}
+
+// newSupervisor 创建客户端守护
+func newSupervisor(m *Manager, account *models.Account) *supervisor {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &supervisor{
+		manager: m,
+		account: account,
+		ctx:     ctx,
+		cancel:  cancel,
+		runtime: ClientRuntime{
+			AccountID: account.ID,
+			State:     RuntimeConnecting,
+			UpdatedAt: time.Now(),
+		},
+	}
+}
+
+// run 运行客户端直到被停止或遇到不可恢复的错误
+func (s *supervisor) run(client *ClientV2) {
+	backoff := restartBackoffMin
+
+	for {
+		s.mu.Lock()
+		s.client = client
+		s.mu.Unlock()
+		client.onRunning = s.markRunning
+		s.setState(RuntimeConnecting, nil)
+
+		startedAt := time.Now()
+		err := client.Start()
+		if s.ctx.Err() != nil {
+			s.setState(RuntimeStopped, nil)
+			return
+		}
+		if err == nil {
+			err = errors.New("客户端意外退出")
+		}
+		client.Stop()
+
+		log.Printf("❌ 客户端 [ID: %d] 运行失败: %v", s.account.ID, err)
+		s.manager.db.Model(&models.Account{}).Where("id = ?", s.account.ID).Update("status", "error")
+
+		if IsFatalClientError(err) {
+			s.revokeSession(err)
+			s.setState(RuntimeNeedsLogin, err)
+			log.Printf("⛔ 客户端 [ID: %d] 需要重新登录，停止自动重启", s.account.ID)
+			return
+		}
+
+		// 稳定运行一段时间后再失败，从最短退避重新计算
+		if time.Since(startedAt) > restartStableAfter {
+			backoff = restartBackoffMin
+		}
+
+		// 等待退避时间后重建客户端，重建失败（如会话目录不可写）继续退避重试
+		for {
+			wait := jitter(backoff)
+			s.setBackingOff(err, wait)
+			log.Printf("🔁 客户端 [ID: %d] 将在 %s 后重启", s.account.ID, wait.Round(time.Second))
+
+			select {
+			case <-s.ctx.Done():
+				s.setState(RuntimeStopped, nil)
+				return
+			case <-time.After(wait):
+			}
+
+			backoff *= 2
+			if backoff > restartBackoffMax {
+				backoff = restartBackoffMax
+			}
+
+			client, err = s.newClient()
+			if err == nil {
+				break
+			}
+			log.Printf("❌ 重建客户端 [ID: %d] 失败: %v", s.account.ID, err)
+		}
+		if client == nil {
+			s.setState(RuntimeStopped, nil)
+			return
+		}
+
+		s.mu.Lock()
+		s.runtime.Restarts++
+		s.mu.Unlock()
+	}
+}
+
+// newClient 重新创建客户端并注册到管理器；守护已停止时返回 nil
+func (s *supervisor) newClient() (*ClientV2, error) {
+	if s.ctx.Err() != nil {
+		return nil, nil
+	}
+
+	// 重新读取账号配置，重启期间的修改同样生效
+	var account models.Account
+	if err := s.manager.db.First(&account, s.account.ID).Error; err == nil {
+		s.account = &account
+	}
+
+	client, err := s.manager.newClient(s.account)
+	if err != nil {
+		return nil, err
+	}
+
+	m := s.manager
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s.ctx.Err() != nil {
+		// 等待期间已被停止或替换
+		client.Cancel()
+		return nil, nil
+	}
+	m.clients[s.account.ID] = client
+	if client.AuthHelper != nil {
+		m.authHelpers[s.account.ID] = client.AuthHelper
+	}
+	return client, nil
+}
+
+// stop 停止守护和当前客户端
+func (s *supervisor) stop() {
+	s.cancel()
+
+	s.mu.Lock()
+	client := s.client
+	s.mu.Unlock()
+	if client != nil {
+		client.Stop()
+	}
+	s.setState(RuntimeStopped, nil)
+}
+
+// markRunning 客户端已连接并开始接收更新
+func (s *supervisor) markRunning() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.runtime.State = RuntimeRunning
+	s.runtime.RunningSince = &now
+	s.runtime.NextRetryAt = nil
+	s.runtime.UpdatedAt = now
+}
+
+// setState 更新运行状态，err 不为空时记录为最近一次错误
+func (s *supervisor) setState(state string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.runtime.State = state
+	s.runtime.RunningSince = nil
+	s.runtime.NextRetryAt = nil
+	s.runtime.UpdatedAt = now
+	if err != nil {
+		s.runtime.LastError = err.Error()
+		s.runtime.LastErrorAt = &now
+	}
+}
+
+// setBackingOff 记录失败原因和下一次重启时间
+func (s *supervisor) setBackingOff(err error, wait time.Duration) {
+	s.setState(RuntimeBackingOff, err)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := time.Now().Add(wait)
+	s.runtime.NextRetryAt = &next
+}
+
+// snapshot 获取运行状态副本
+func (s *supervisor) snapshot() ClientRuntime {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runtime
+}
+
+// revokeSession 会话已失效时将本地会话文件改名保留，下次登录重新走验证流程
+func (s *supervisor) revokeSession(err error) {
+	if !tgerr.Is(err, sessionRevokedTypes...) {
+		return
+	}
+
+	s.mu.Lock()
+	client := s.client
+	s.mu.Unlock()
+	if client == nil || client.SessionPath == "" {
+		return
+	}
+
+	revokedPath := fmt.Sprintf("%s.revoked-%d", client.SessionPath, time.Now().Unix())
+	if renameErr := os.Rename(client.SessionPath, revokedPath); renameErr != nil && !os.IsNotExist(renameErr) {
+		log.Printf("⚠️ 移除失效会话文件失败: %v", renameErr)
+		return
+	}
+	log.Printf("🗝️ 会话已失效，会话文件已移至 %s", revokedPath)
+}
+
+// jitter 在退避时间上增加 ±20% 的随机抖动，避免多个账号同时重连
+func jitter(d time.Duration) time.Duration {
+	delta := float64(d) * 0.2
+	return time.Duration(float64(d) - delta + rand.Float64()*2*delta)
+}
@@ -0,0 +1,106 @@
+package telegram
+
+import (
+	"fmt"
+
+	"aibot/models"
+
+	"github.com/gotd/td/tg"
+)
+
+// generalTopicID 论坛的 General 话题ID（General 中的消息不带话题信息，缓冲区和发送队列中记为0）
+const generalTopicID = 1
+
+// bufferKey 消息缓冲区和处理协程的键：开启话题的超级群组按话题分开处理
+type bufferKey struct {
+	chatID  int64
+	topicID int // 0 表示 General 或未开启话题的群组
+}
+
+// String 日志中的群组/话题描述
+func (k bufferKey) String() string {
+	if k.topicID == 0 {
+		return fmt.Sprintf("%d", k.chatID)
+	}
+	return fmt.Sprintf("%d/话题%d", k.chatID, k.topicID)
+}
+
+// messageTopic 从回复头中解析论坛话题ID和实际引用的消息ID
+// 话题中的消息都带有回复头：直接发在话题中时 ReplyToMsgID 就是话题ID；回复话题中的其他消息时 ReplyToTopID 为话题ID
+func messageTopic(header *tg.MessageReplyHeader) (topicID, replyToMsgID int) {
+	if !header.ForumTopic {
+		return 0, header.ReplyToMsgID
+	}
+	if header.ReplyToTopID != 0 {
+		return header.ReplyToTopID, header.ReplyToMsgID
+	}
+	return header.ReplyToMsgID, 0
+}
+
+// inputReplyTo 构造发送消息的回复信息：引用消息和/或发送到指定话题，都没有时返回 nil
+func inputReplyTo(replyToMsgID int64, topicID int) tg.InputReplyToClass {
+	if topicID <= generalTopicID {
+		topicID = 0
+	}
+	if replyToMsgID <= 0 && topicID == 0 {
+		return nil
+	}
+
+	replyTo := &tg.InputReplyToMessage{ReplyToMsgID: int(replyToMsgID)}
+	if topicID > 0 {
+		// 不引用消息时回复话题本身，消息发在话题中
+		if replyTo.ReplyToMsgID <= 0 {
+			replyTo.ReplyToMsgID = topicID
+		}
+		replyTo.SetTopMsgID(topicID)
+	}
+	return replyTo
+}
+
+// ValidateTopicSettings 校验分配的话题配置
+func ValidateTopicSettings(topics []models.TopicSetting) error {
+	seen := make(map[int]bool, len(topics))
+	for _, topic := range topics {
+		if topic.TopicID <= 0 {
+			return fmt.Errorf("话题ID必须大于0（General 话题为 %d）", generalTopicID)
+		}
+		if seen[topic.TopicID] {
+			return fmt.Errorf("话题 %d 重复配置", topic.TopicID)
+		}
+		seen[topic.TopicID] = true
+		if topic.ReplyProbability < 0 || topic.ReplyProbability > 1 {
+			return fmt.Errorf("话题 %d 的回复概率必须在 0-1 之间", topic.TopicID)
+		}
+	}
+	return nil
+}
+
+// topicSetting 获取分配中话题的单独配置，未配置时返回 nil
+func topicSetting(accountGroup *models.AccountGroup, topicID int) *models.TopicSetting {
+	if topicID == 0 {
+		topicID = generalTopicID
+	}
+	for i := range accountGroup.Topics {
+		if accountGroup.Topics[i].TopicID == topicID {
+			return &accountGroup.Topics[i]
+		}
+	}
+	return nil
+}
+
+// topicEnabled 判断是否在该话题中回复（话题配置关闭时不回复）
+func topicEnabled(accountGroup *models.AccountGroup, topicID int) bool {
+	topic := topicSetting(accountGroup, topicID)
+	return topic == nil || topic.Enabled
+}
+
+// sentMessageTopic 查询当前账号发送的消息所在的话题（未找到发言记录时为0）
+func (c *ClientV2) sentMessageTopic(groupID uint, telegramMessageID int64) int {
+	var topicID int
+	c.DB.Model(&models.Message{}).
+		Where("account_id = ? AND group_id = ? AND telegram_message_id = ?", c.Account.ID, groupID, telegramMessageID).
+		Select("topic_id").
+		Limit(1).
+		Scan(&topicID)
+	return topicID
+}
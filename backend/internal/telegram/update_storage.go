@@ -0,0 +1,160 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"aibot/models"
+
+	"github.com/gotd/td/telegram/updates"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UpdateStateStorage 基于数据库的 updates.Manager 状态存储
+// 保存 pts/qts/seq/date 和每个频道的 pts、AccessHash，重启后可以通过 getDifference 补齐离线期间的消息
+type UpdateStateStorage struct {
+	db        *gorm.DB
+	accountID uint
+}
+
+var (
+	_ updates.StateStorage        = (*UpdateStateStorage)(nil)
+	_ updates.ChannelAccessHasher = (*UpdateStateStorage)(nil)
+)
+
+// NewUpdateStateStorage 创建账号的更新状态存储
+func NewUpdateStateStorage(db *gorm.DB, accountID uint) *UpdateStateStorage {
+	return &UpdateStateStorage{db: db, accountID: accountID}
+}
+
+// GetState 读取账号的更新状态
+func (s *UpdateStateStorage) GetState(ctx context.Context, userID int64) (updates.State, bool, error) {
+	var state models.UpdateState
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).First(&state).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return updates.State{}, false, nil
+	}
+	if err != nil {
+		return updates.State{}, false, err
+	}
+	return updates.State{Pts: state.Pts, Qts: state.Qts, Date: state.Date, Seq: state.Seq}, true, nil
+}
+
+// SetState 覆盖账号的更新状态（同时作废已记录的频道 pts）
+func (s *UpdateStateStorage) SetState(ctx context.Context, userID int64, state updates.State) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		record := models.UpdateState{
+			UserID:    userID,
+			AccountID: s.accountID,
+			Pts:       state.Pts,
+			Qts:       state.Qts,
+			Date:      state.Date,
+			Seq:       state.Seq,
+		}
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&record).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.ChannelUpdateState{}).Where("user_id = ?", userID).Update("pts", 0).Error
+	})
+}
+
+// SetPts 更新 pts
+func (s *UpdateStateStorage) SetPts(ctx context.Context, userID int64, pts int) error {
+	return s.updateState(ctx, userID, map[string]interface{}{"pts": pts})
+}
+
+// SetQts 更新 qts
+func (s *UpdateStateStorage) SetQts(ctx context.Context, userID int64, qts int) error {
+	return s.updateState(ctx, userID, map[string]interface{}{"qts": qts})
+}
+
+// SetDate 更新 date
+func (s *UpdateStateStorage) SetDate(ctx context.Context, userID int64, date int) error {
+	return s.updateState(ctx, userID, map[string]interface{}{"date": date})
+}
+
+// SetSeq 更新 seq
+func (s *UpdateStateStorage) SetSeq(ctx context.Context, userID int64, seq int) error {
+	return s.updateState(ctx, userID, map[string]interface{}{"seq": seq})
+}
+
+// SetDateSeq 同时更新 date 和 seq
+func (s *UpdateStateStorage) SetDateSeq(ctx context.Context, userID int64, date, seq int) error {
+	return s.updateState(ctx, userID, map[string]interface{}{"date": date, "seq": seq})
+}
+
+// updateState 更新状态中的部分字段，状态不存在时返回错误（由 updates.Manager 重新获取）
+func (s *UpdateStateStorage) updateState(ctx context.Context, userID int64, values map[string]interface{}) error {
+	result := s.db.WithContext(ctx).Model(&models.UpdateState{}).Where("user_id = ?", userID).Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("更新状态不存在 [用户ID: %d]", userID)
+	}
+	return nil
+}
+
+// GetChannelPts 读取频道的 pts
+func (s *UpdateStateStorage) GetChannelPts(ctx context.Context, userID, channelID int64) (int, bool, error) {
+	var state models.ChannelUpdateState
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND channel_id = ? AND pts > 0", userID, channelID).
+		First(&state).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return state.Pts, true, nil
+}
+
+// SetChannelPts 更新频道的 pts
+func (s *UpdateStateStorage) SetChannelPts(ctx context.Context, userID, channelID int64, pts int) error {
+	record := models.ChannelUpdateState{UserID: userID, ChannelID: channelID, Pts: pts}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "channel_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"pts", "updated_at"}),
+	}).Create(&record).Error
+}
+
+// ForEachChannels 遍历已记录 pts 的频道
+func (s *UpdateStateStorage) ForEachChannels(ctx context.Context, userID int64, f func(ctx context.Context, channelID int64, pts int) error) error {
+	var states []models.ChannelUpdateState
+	if err := s.db.WithContext(ctx).Where("user_id = ? AND pts > 0", userID).Find(&states).Error; err != nil {
+		return err
+	}
+	for _, state := range states {
+		if err := f(ctx, state.ChannelID, state.Pts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetChannelAccessHash 保存频道的 AccessHash
+func (s *UpdateStateStorage) SetChannelAccessHash(ctx context.Context, userID, channelID, accessHash int64) error {
+	record := models.ChannelUpdateState{UserID: userID, ChannelID: channelID, AccessHash: accessHash}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "channel_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"access_hash", "updated_at"}),
+	}).Create(&record).Error
+}
+
+// GetChannelAccessHash 读取频道的 AccessHash
+func (s *UpdateStateStorage) GetChannelAccessHash(ctx context.Context, userID, channelID int64) (int64, bool, error) {
+	var state models.ChannelUpdateState
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND channel_id = ? AND access_hash <> 0", userID, channelID).
+		First(&state).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return state.AccessHash, true, nil
+}
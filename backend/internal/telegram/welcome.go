@@ -0,0 +1,212 @@
+package telegram
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"aibot/models"
+
+	"github.com/gotd/td/tg"
+)
+
+// defaultWelcomeBatch 未配置合并时间时，等待该时长合并同时入群的成员
+const defaultWelcomeBatch = 10 * time.Second
+
+// maxWelcomeNames 一条欢迎语中最多列出的成员名称
+const maxWelcomeNames = 20
+
+// welcomeBatch 等待发送欢迎语的新成员
+type welcomeBatch struct {
+	userIDs map[int64]bool
+	names   []string
+}
+
+// ValidateWelcome 校验欢迎语配置
+func ValidateWelcome(welcome *models.GroupWelcome) error {
+	if strings.TrimSpace(welcome.Template) == "" {
+		return fmt.Errorf("欢迎语内容不能为空")
+	}
+	if welcome.BatchSeconds < 0 || welcome.CooldownSeconds < 0 || welcome.DeleteAfterMinutes < 0 {
+		return fmt.Errorf("时间配置不能为负数")
+	}
+	return ValidateKeyboard(welcome.Buttons)
+}
+
+// handleServiceMessage 处理服务消息：成员被拉入群、通过链接入群、入群申请被通过
+func (c *ClientV2) handleServiceMessage(message *tg.MessageService, users map[int64]*tg.User) {
+	var chatID int64
+	switch p := message.PeerID.(type) {
+	case *tg.PeerChannel:
+		chatID = p.ChannelID
+	case *tg.PeerChat:
+		chatID = p.ChatID
+	default:
+		return
+	}
+
+	joinedAt := time.Unix(int64(message.Date), 0)
+	switch action := message.Action.(type) {
+	case *tg.MessageActionChatAddUser:
+		for _, userID := range action.Users {
+			c.memberJoined(chatID, userID, users, joinedAt)
+		}
+	case *tg.MessageActionChatJoinedByLink, *tg.MessageActionChatJoinedByRequest:
+		if from, ok := message.FromID.(*tg.PeerUser); ok {
+			c.memberJoined(chatID, from.UserID, users, joinedAt)
+		}
+	}
+}
+
+// memberJoined 处理新成员入群：需要入群验证时先验证，否则加入待欢迎列表
+func (c *ClientV2) memberJoined(chatID, userID int64, users map[int64]*tg.User, joinedAt time.Time) {
+	if userID == c.SelfID {
+		return
+	}
+	user := users[userID]
+	if user != nil && user.Bot {
+		return
+	}
+	// 离线期间补齐的入群事件不再欢迎
+	if time.Since(joinedAt) > maxInboundAge {
+		return
+	}
+
+	accountGroup, ok := c.getGroupAssignment(chatID)
+	if !ok || !accountGroup.Enabled {
+		return
+	}
+
+	// 开启了入群验证时，通过验证后再欢迎
+	if c.requireCaptcha(chatID, accountGroup.GroupID, userID, user) {
+		return
+	}
+
+	c.queueWelcome(chatID, accountGroup.GroupID, userID, welcomeName(userID, user))
+}
+
+// queueWelcome 将新成员加入待欢迎列表，合并时间结束后发送一条欢迎语
+func (c *ClientV2) queueWelcome(chatID int64, groupID uint, userID int64, name string) {
+	welcome, ok := c.loadWelcome(groupID)
+	if !ok {
+		return
+	}
+
+	c.welcomeBatchesLock.Lock()
+	defer c.welcomeBatchesLock.Unlock()
+
+	batch, exists := c.welcomeBatches[chatID]
+	if !exists {
+		batch = &welcomeBatch{userIDs: make(map[int64]bool)}
+		c.welcomeBatches[chatID] = batch
+
+		wait := time.Duration(welcome.BatchSeconds) * time.Second
+		if wait <= 0 {
+			wait = defaultWelcomeBatch
+		}
+		time.AfterFunc(wait, func() { c.flushWelcome(chatID, groupID) })
+	}
+
+	// 服务消息和成员变动更新可能同时到达，按用户去重
+	if batch.userIDs[userID] {
+		return
+	}
+	batch.userIDs[userID] = true
+	batch.names = append(batch.names, name)
+
+	log.Printf("👋 新成员入群 [群组ID: %d, 用户: %s]", chatID, name)
+}
+
+// flushWelcome 发送合并后的欢迎语
+func (c *ClientV2) flushWelcome(chatID int64, groupID uint) {
+	c.welcomeBatchesLock.Lock()
+	batch := c.welcomeBatches[chatID]
+	delete(c.welcomeBatches, chatID)
+	c.welcomeBatchesLock.Unlock()
+
+	if batch == nil || len(batch.names) == 0 || c.Context.Err() != nil {
+		return
+	}
+
+	welcome, ok := c.loadWelcome(groupID)
+	if !ok {
+		return
+	}
+
+	// 条件更新发送时间：冷却期内不再发送；多个账号在同一群组时只有一个账号发送
+	window := welcome.CooldownSeconds
+	if welcome.BatchSeconds > window {
+		window = welcome.BatchSeconds
+	}
+	if window <= 0 {
+		window = int(defaultWelcomeBatch / time.Second)
+	}
+	now := time.Now()
+	result := c.DB.Model(&models.GroupWelcome{}).
+		Where("id = ? AND (last_sent_at IS NULL OR last_sent_at <= ?)", welcome.ID, now.Add(-time.Duration(window)*time.Second)).
+		Update("last_sent_at", now)
+	if result.Error != nil || result.RowsAffected == 0 {
+		log.Printf("⏳ 群组 [%d] 欢迎语冷却中或已由其他账号发送，跳过 %d 位新成员", chatID, len(batch.names))
+		return
+	}
+
+	item := &models.OutboundMessage{
+		AccountID:   c.Account.ID,
+		GroupID:     groupID,
+		Source:      "welcome",
+		Content:     renderWelcome(welcome, batch.names, c.groupTitle(groupID)),
+		DeleteAfter: welcome.DeleteAfterMinutes * 60,
+		Buttons:     welcome.Buttons,
+	}
+	if _, _, err := EnqueueOutbound(c.DB, item, nil); err != nil {
+		log.Printf("❌ 欢迎语加入发送队列失败: %v", err)
+		return
+	}
+	c.wakeOutbox()
+
+	log.Printf("👋 欢迎语已加入发送队列 [群组ID: %d, 新成员: %d 位]", chatID, len(batch.names))
+}
+
+// loadWelcome 加载群组已启用的欢迎语配置
+func (c *ClientV2) loadWelcome(groupID uint) (*models.GroupWelcome, bool) {
+	var welcome models.GroupWelcome
+	if err := c.DB.Where("group_id = ? AND enabled = ?", groupID, true).First(&welcome).Error; err != nil {
+		return nil, false
+	}
+	return &welcome, true
+}
+
+// renderWelcome 渲染欢迎语模板
+func renderWelcome(welcome *models.GroupWelcome, names []string, groupTitle string) string {
+	listed := names
+	if len(listed) > maxWelcomeNames {
+		listed = listed[:maxWelcomeNames]
+	}
+	name := strings.Join(listed, "、")
+	if len(names) > len(listed) {
+		name += fmt.Sprintf(" 等 %d 位新朋友", len(names))
+	}
+
+	return strings.NewReplacer(
+		"{name}", name,
+		"{group}", groupTitle,
+		"{rules}", welcome.RulesLink,
+		"{count}", strconv.Itoa(len(names)),
+	).Replace(welcome.Template)
+}
+
+// welcomeName 新成员在欢迎语中的称呼（有用户名时使用 @用户名）
+func welcomeName(userID int64, user *tg.User) string {
+	if user == nil {
+		return fmt.Sprintf("用户%d", userID)
+	}
+	if user.Username != "" {
+		return "@" + user.Username
+	}
+	if name := strings.TrimSpace(user.FirstName + " " + user.LastName); name != "" {
+		return name
+	}
+	return fmt.Sprintf("用户%d", userID)
+}
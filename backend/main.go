@@ -1,12 +1,16 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"os"
+	"strconv"
 	"time"
 
 	"aibot/internal/config"
 	"aibot/internal/database"
 	"aibot/internal/server"
+	"aibot/internal/state"
 	"aibot/internal/telegram"
 
 	"github.com/joho/godotenv"
@@ -21,6 +25,12 @@ func main() {
 	// 加载配置
 	cfg := config.Load()
 
+	// 子命令：数据库迁移
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(cfg.Database, os.Args[2:])
+		return
+	}
+
 	// 初始化数据库
 	db, err := database.Init(cfg.Database)
 	if err != nil {
@@ -28,9 +38,17 @@ func main() {
 	}
 	defer database.Close(db)
 
+	// 初始化运行状态存储（启用 Redis 时多个实例共享）
+	store, err := state.New(cfg.Redis)
+	if err != nil {
+		log.Fatalf("状态存储初始化失败: %v", err)
+	}
+	defer store.Close()
+
 	// 初始化Telegram客户端管理器
 	tgManager := telegram.NewManager(cfg.Telegram)
 	tgManager.SetDB(db)
+	tgManager.SetStateStore(store, cfg.Server.InstanceID)
 
 	// 启动Telegram客户端（异步）
 	go func() {
@@ -49,3 +67,59 @@ func main() {
 	}
 }
 
+// runMigrate 执行数据库迁移命令：migrate [up | down [步数] | status]
+func runMigrate(cfg config.DatabaseConfig, args []string) {
+	db, err := database.Open(cfg)
+	if err != nil {
+		log.Fatalf("数据库连接失败: %v", err)
+	}
+	defer database.Close(db)
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		count, err := database.MigrateUp(db)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if count == 0 {
+			log.Println("✅ 数据库结构已是最新")
+			return
+		}
+		log.Printf("✅ 已执行 %d 个迁移", count)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				log.Fatalf("回滚步数无效: %s", args[1])
+			}
+		}
+		count, err := database.MigrateDown(db, steps)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		log.Printf("✅ 已回滚 %d 个迁移", count)
+
+	case "status":
+		statuses, err := database.MigrationStatuses(db)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		for _, status := range statuses {
+			state := "待执行"
+			if status.AppliedAt != nil {
+				state = "已执行 " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", status.Version, status.Name, state)
+		}
+
+	default:
+		log.Fatalf("未知的迁移命令: %s（可选 up/down/status）", command)
+	}
+}
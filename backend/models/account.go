@@ -9,7 +9,10 @@ import (
 // Account AI账号模型
 type Account struct {
 	ID            uint           `gorm:"primaryKey" json:"id"`
-	PhoneNumber   string         `gorm:"uniqueIndex;not null" json:"phone_number"`
+	Type          string         `gorm:"default:user;index" json:"type"` // user（手机号登录的用户账号）/bot（Bot Token 登录的官方机器人）
+	PhoneNumber   string         `gorm:"uniqueIndex;not null" json:"phone_number"` // 机器人账号为 bot{机器人ID}
+	BotToken      string         `json:"-"`                                        // 机器人账号的 Bot Token（只通过创建/更新请求写入，不在接口中返回）
+	HasBotToken   bool           `gorm:"-" json:"has_bot_token"`                   // 是否已设置 Bot Token
 	APIID         int            `gorm:"not null" json:"api_id"`
 	APIHash       string         `gorm:"not null" json:"api_hash"`
 	SessionFile   string         `gorm:"not null" json:"session_file"`
@@ -31,6 +34,13 @@ type Account struct {
 	MultiMsgInterval  int  `gorm:"default:5" json:"multi_msg_interval"`  // 多条消息发送间隔（秒）
 	SplitByNewline    bool `gorm:"default:true" json:"split_by_newline"` // 是否按换行拆分消息
 
+	// @提及 / 回复我的消息
+	ReplyToMentions   bool `gorm:"default:true" json:"reply_to_mentions"`  // 是否优先回复@提及和回复我的消息（不受发言间隔限制）
+	MentionReplyLimit int  `gorm:"default:10" json:"mention_reply_limit"` // 每个群组每小时最多触发回复数，0 表示不限制
+
+	// 机器人隐私模式（登录时从 Telegram 读取）：开启时机器人在非管理员群组中只能收到命令、@提及和回复
+	BotPrivacyMode bool `json:"bot_privacy_mode"`
+
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
@@ -41,3 +51,15 @@ func (Account) TableName() string {
 	return "ai_accounts"
 }
 
+// AfterFind 标记是否已设置 Bot Token
+func (a *Account) AfterFind(tx *gorm.DB) error {
+	a.HasBotToken = a.BotToken != ""
+	return nil
+}
+
+// AfterSave 标记是否已设置 Bot Token
+func (a *Account) AfterSave(tx *gorm.DB) error {
+	a.HasBotToken = a.BotToken != ""
+	return nil
+}
+
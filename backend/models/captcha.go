@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+)
+
+// GroupCaptcha 群组入群验证配置（账号是群管理员时，新成员入群先限制发言，完成验证后解除）
+type GroupCaptcha struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	GroupID        uint      `gorm:"not null;uniqueIndex" json:"group_id"`
+	Enabled        bool      `gorm:"default:true" json:"enabled"`
+	Mode           string    `gorm:"default:button" json:"mode"`         // button：点击按钮（仅机器人账号）；math：发送算式答案
+	TimeoutSeconds int       `gorm:"default:120" json:"timeout_seconds"` // 未在该时间内完成验证的成员将被移出
+	MaxAttempts    int       `gorm:"default:3" json:"max_attempts"`      // math：答错该次数后移出
+	BanMinutes     int       `gorm:"default:0" json:"ban_minutes"`       // 验证失败后的封禁时间（分钟），0 表示只移出、可以重新加入
+	Template       string    `gorm:"type:text" json:"template"`          // 验证提示，支持 {name}、{group}、{question}、{timeout}，为空使用默认提示
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	Group Group `gorm:"foreignKey:GroupID" json:"group,omitempty"`
+}
+
+// TableName 指定表名
+func (GroupCaptcha) TableName() string {
+	return "group_captchas"
+}
+
+// CaptchaChallenge 入群验证记录
+type CaptchaChallenge struct {
+	ID             uint   `gorm:"primaryKey" json:"id"`
+	AccountID      uint   `gorm:"not null;index" json:"account_id"` // 发起验证的账号
+	GroupID        uint   `gorm:"not null;index" json:"group_id"`
+	ChatID         int64  `json:"chat_id"`
+	UserID         int64  `gorm:"index" json:"user_id"`
+	UserAccessHash int64  `json:"-"`
+	UserName       string `json:"user_name"`
+
+	// 验证内容
+	Mode       string `json:"mode"`     // button/math
+	Question   string `json:"question"` // math：算式
+	Answer     string `json:"-"`
+	Attempts   int    `json:"attempts"`    // math：已回答次数
+	OutboundID *uint  `json:"outbound_id"` // 验证提示的发送队列ID
+
+	// 结果
+	Status     string     `gorm:"default:pending;index" json:"status"` // pending/passed/failed/timeout/error
+	Error      string     `gorm:"type:text" json:"error"`
+	ExpiresAt  time.Time  `gorm:"index" json:"expires_at"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Group Group `gorm:"foreignKey:GroupID" json:"group,omitempty"`
+}
+
+// TableName 指定表名
+func (CaptchaChallenge) TableName() string {
+	return "captcha_challenges"
+}
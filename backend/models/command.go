@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+)
+
+// GroupCommand 群成员可调用的斜杠命令（自定义命令，或覆盖/停用内置的 help、faq、ask）
+type GroupCommand struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	GroupID   *uint  `gorm:"index" json:"group_id"`       // 为空表示所有群组
+	AccountID *uint  `gorm:"index" json:"account_id"`     // 为空表示所有账号
+	Name      string `gorm:"not null;index" json:"name"`  // 命令名（不含斜杠，小写）
+	Enabled   bool   `gorm:"default:true" json:"enabled"` // 停用时该命令在对应范围内不可用（可用于停用内置命令）
+
+	Description string `json:"description"` // 在 /help 中显示的说明
+	Usage       string `json:"usage"`       // 参数说明，如 <问题>
+	MinArgs     int    `json:"min_args"`    // 最少参数个数，不足时回复用法
+
+	// 命令动作
+	Action      string `gorm:"not null" json:"action"`       // help/faq/ai/template
+	Instruction string `gorm:"type:text" json:"instruction"` // ai：附加给AI的指令
+	Template    string `gorm:"type:text" json:"template"`    // template：固定回复内容，支持 {sender}、{group}、{args}
+
+	Buttons InlineKeyboard `gorm:"type:text;serializer:json" json:"buttons"` // 回复附带的内联键盘（仅机器人账号发送）
+
+	CooldownSeconds int `gorm:"default:0" json:"cooldown_seconds"` // 同一用户再次调用的冷却时间（秒）
+
+	// 统计
+	HitCount  int64      `gorm:"default:0" json:"hit_count"`
+	LastHitAt *time.Time `json:"last_hit_at"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (GroupCommand) TableName() string {
+	return "group_commands"
+}
+
+// FAQEntry 知识库条目（供 /faq 查询，/ask 调用AI时作为参考资料）
+type FAQEntry struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	GroupID  *uint  `gorm:"index" json:"group_id"` // 为空表示所有群组
+	Topic    string `gorm:"not null" json:"topic"` // 主题，/faq <主题> 精确匹配
+	Keywords string `json:"keywords"`              // 关键词（逗号分隔），/faq 和 /ask 的内容包含任一关键词即命中
+	Answer   string `gorm:"type:text;not null" json:"answer"`
+	Enabled  bool   `gorm:"default:true" json:"enabled"`
+
+	// 统计
+	HitCount int64 `gorm:"default:0" json:"hit_count"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (FAQEntry) TableName() string {
+	return "faq_entries"
+}
@@ -10,14 +10,15 @@ import (
 type Group struct {
 	ID          uint           `gorm:"primaryKey" json:"id"`
 	ChatID      int64          `gorm:"uniqueIndex;not null" json:"chat_id"`
-	AccessHash  int64          `json:"access_hash"`          // Telegram AccessHash（用于发送消息）
+	AccessHash  int64          `json:"access_hash"` // Telegram AccessHash（首个同步到该群组的账号的，各账号自己的保存在成员身份中）
 	Username    string         `json:"username"`
 	Title       string         `json:"title"`
-	Type        string         `json:"type"`                  // group/supergroup/channel
+	Type        string         `json:"type"`                         // group/supergroup/channel
 	Status      string         `gorm:"default:active" json:"status"` // active/inactive
 	Language    string         `json:"language"`
-	MemberCount int            `json:"member_count"`         // 成员数量
+	MemberCount int            `json:"member_count"`                 // 成员数量
 	Description string         `gorm:"type:text" json:"description"` // 群组描述
+	FullInfoAt  *time.Time     `json:"full_info_at"`                 // 最近一次拉取完整信息（描述、成员数）的时间
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
@@ -30,15 +31,18 @@ func (Group) TableName() string {
 
 // AccountGroup 账号-群组关联表
 type AccountGroup struct {
-	ID              uint      `gorm:"primaryKey" json:"id"`
-	AccountID       uint      `gorm:"not null;index" json:"account_id"`
-	GroupID         uint      `gorm:"not null;index" json:"group_id"`
-	Priority        int       `gorm:"default:5" json:"priority"`
-	ReplyProbability float64   `gorm:"default:0.3" json:"reply_probability"`
-	Enabled         bool      `gorm:"default:true" json:"enabled"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
-	
+	ID               uint           `gorm:"primaryKey" json:"id"`
+	AccountID        uint           `gorm:"not null;index" json:"account_id"`
+	GroupID          uint           `gorm:"not null;index" json:"group_id"`
+	Priority         int            `gorm:"default:5" json:"priority"`
+	ReplyProbability float64        `gorm:"default:0.3" json:"reply_probability"`
+	Enabled          bool           `gorm:"default:true" json:"enabled"`
+	DisabledReason   string         `json:"disabled_reason"` // 自动停用原因（如 CHAT_WRITE_FORBIDDEN），手动启用时清空
+	DisabledAt       *time.Time     `json:"disabled_at"`
+	Topics           []TopicSetting `gorm:"type:text;serializer:json" json:"topics"` // 论坛话题的单独配置（超级群组开启话题时）
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+
 	Account Account `gorm:"foreignKey:AccountID" json:"account,omitempty"`
 	Group   Group   `gorm:"foreignKey:GroupID" json:"group,omitempty"`
 }
@@ -48,3 +52,32 @@ func (AccountGroup) TableName() string {
 	return "account_groups"
 }
 
+// TopicSetting 论坛话题的单独配置，未配置的话题使用分配本身的配置
+type TopicSetting struct {
+	TopicID          int     `json:"topic_id"`          // 话题ID（创建话题的消息ID，General 话题为 1）
+	Enabled          bool    `json:"enabled"`           // 关闭后不在该话题中回复
+	ReplyProbability float64 `json:"reply_probability"` // 0-1，为0时使用分配的回复概率
+}
+
+// GroupMembership 账号在群组中的实际成员身份（由群组同步写入）
+type GroupMembership struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	AccountID    uint       `gorm:"not null;uniqueIndex:idx_membership_account_group" json:"account_id"`
+	GroupID      uint       `gorm:"not null;uniqueIndex:idx_membership_account_group;index" json:"group_id"`
+	Status       string     `gorm:"default:member" json:"status"` // member/left/kicked
+	Role         string     `gorm:"default:member" json:"role"`   // creator/admin/member/restricted
+	CanSend      bool       `json:"can_send"`                     // 是否可以发言
+	AccessHash   int64      `json:"access_hash"`                  // 该账号自己的频道 AccessHash（因账号而异）
+	LastSyncedAt time.Time  `json:"last_synced_at"`
+	LeftAt       *time.Time `json:"left_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	Account Account `gorm:"foreignKey:AccountID" json:"account,omitempty"`
+	Group   Group   `gorm:"foreignKey:GroupID" json:"group,omitempty"`
+}
+
+// TableName 指定表名
+func (GroupMembership) TableName() string {
+	return "group_memberships"
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// InboundMessage 收到的群组消息存档（同一账号同一条消息只记录一次，用于去重）
+type InboundMessage struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	AccountID      uint      `gorm:"not null;uniqueIndex:idx_inbound_account_chat_msg" json:"account_id"`
+	GroupID        uint      `gorm:"not null;index" json:"group_id"`
+	ChatID         int64     `gorm:"not null;uniqueIndex:idx_inbound_account_chat_msg" json:"chat_id"`
+	MessageID      int       `gorm:"not null;uniqueIndex:idx_inbound_account_chat_msg" json:"message_id"` // Telegram 消息ID
+	SenderID       int64     `gorm:"index" json:"sender_id"`
+	SenderName     string    `json:"sender_name"`
+	SenderUsername string    `json:"sender_username"`
+	Content        string    `gorm:"type:text" json:"content"`
+	MessageType    string    `json:"message_type"` // text/photo/video/document/sticker/voice/other
+	ReplyToMsgID   int       `json:"reply_to_msg_id"`
+	TopicID        int       `gorm:"index" json:"topic_id"` // 所在的论坛话题ID，0 表示 General 或未开启话题的群组
+	Trigger        string    `json:"trigger"`               // mention/reply，空表示普通消息
+	Source         string    `json:"source"`                // push：实时推送/补齐，poll：轮询拉取
+	SentAt         time.Time `gorm:"index" json:"sent_at"`  // 消息在 Telegram 中的发送时间
+
+	// 编辑 / 删除跟踪
+	OriginalContent string     `gorm:"type:text" json:"original_content"` // 首次编辑前的内容
+	EditCount       int        `gorm:"default:0" json:"edit_count"`
+	EditedAt        *time.Time `json:"edited_at"`
+	RemovedAt       *time.Time `gorm:"index" json:"removed_at"` // 消息在 Telegram 中被删除的时间
+
+	CreatedAt time.Time `json:"created_at"`
+
+	Account Account `gorm:"foreignKey:AccountID" json:"account,omitempty"`
+	Group   Group   `gorm:"foreignKey:GroupID" json:"group,omitempty"`
+}
+
+// TableName 指定表名
+func (InboundMessage) TableName() string {
+	return "inbound_messages"
+}
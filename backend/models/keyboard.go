@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+)
+
+// InlineButton 内联键盘按钮（只有机器人账号可以发送）
+type InlineButton struct {
+	Text string `json:"text"`
+	URL  string `json:"url,omitempty"`  // URL 按钮：点击打开链接
+	Data string `json:"data,omitempty"` // 回调按钮：点击时按回调动作处理（最多64字节）
+}
+
+// InlineKeyboard 内联键盘，每个元素为一行按钮
+type InlineKeyboard [][]InlineButton
+
+// CallbackAction 回调按钮的处理动作（按 data 匹配）
+type CallbackAction struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	GroupID   *uint  `gorm:"index" json:"group_id"`      // 为空表示所有群组
+	AccountID *uint  `gorm:"index" json:"account_id"`    // 为空表示所有机器人账号
+	Data      string `gorm:"not null;index" json:"data"` // 匹配的回调数据，以 * 结尾时按前缀匹配
+	Enabled   bool   `gorm:"default:true" json:"enabled"`
+
+	// 处理动作
+	Action      string         `gorm:"not null" json:"action"`                   // answer/edit/ai
+	Text        string         `gorm:"type:text" json:"text"`                    // answer：提示内容；edit：新的消息内容。支持 {sender}、{group}、{data}
+	Alert       bool           `json:"alert"`                                    // answer：以弹窗显示提示
+	Instruction string         `gorm:"type:text" json:"instruction"`             // ai：附加给AI的指令
+	Buttons     InlineKeyboard `gorm:"type:text;serializer:json" json:"buttons"` // edit：替换后的按钮，为空时移除按钮；ai：回复附带的按钮
+
+	// 统计
+	HitCount  int64      `gorm:"default:0" json:"hit_count"`
+	LastHitAt *time.Time `json:"last_hit_at"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (CallbackAction) TableName() string {
+	return "callback_actions"
+}
+
+// CallbackClick 回调按钮点击记录（可用于统计“是否有帮助”等反馈）
+type CallbackClick struct {
+	ID                uint   `gorm:"primaryKey" json:"id"`
+	AccountID         uint   `gorm:"not null;index" json:"account_id"`
+	GroupID           uint   `gorm:"index" json:"group_id"`
+	CallbackActionID  *uint  `gorm:"index" json:"callback_action_id"` // 未匹配到动作时为空
+	TelegramMessageID int64  `gorm:"index" json:"telegram_message_id"`
+	UserID            int64  `gorm:"index" json:"user_id"`
+	UserName          string `json:"user_name"`
+	Data              string `gorm:"index" json:"data"`
+	Status            string `json:"status"` // handled/unmatched/failed
+	Error             string `gorm:"type:text" json:"error"`
+
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName 指定表名
+func (CallbackClick) TableName() string {
+	return "callback_clicks"
+}
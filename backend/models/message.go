@@ -8,17 +8,34 @@ import (
 
 // Message 发言记录模型
 type Message struct {
-	ID               uint           `gorm:"primaryKey" json:"id"`
-	AccountID        uint           `gorm:"not null;index" json:"account_id"`
-	GroupID          uint           `gorm:"not null;index" json:"group_id"`
-	TelegramMessageID int64         `json:"telegram_message_id"`
-	Content          string         `gorm:"type:text;not null" json:"content"`
-	ReplyToMessageID *int64         `json:"reply_to_message_id"`
-	Topic            string         `json:"topic"`
-	Sentiment        string         `json:"sentiment"` // positive/neutral/negative
-	CreatedAt        time.Time      `gorm:"index" json:"created_at"`
-	DeletedAt        gorm.DeletedAt  `gorm:"index" json:"deleted_at,omitempty"`
-	
+	ID                uint           `gorm:"primaryKey" json:"id"`
+	AccountID         uint           `gorm:"not null;index" json:"account_id"`
+	GroupID           uint           `gorm:"not null;index" json:"group_id"`
+	TelegramMessageID int64          `json:"telegram_message_id"`
+	Content           string         `gorm:"type:text;not null" json:"content"`
+	ReplyToMessageID  *int64         `json:"reply_to_message_id"`
+	TopicID           int            `json:"topic_id"` // 所在的论坛话题ID，0 表示 General 或未开启话题的群组
+	Topic             string         `json:"topic"`
+	Sentiment         string         `json:"sentiment"`  // positive/neutral/negative
+	MediaType         string         `json:"media_type"` // photo/video/document/poll，纯文本为空
+	FileName          string         `json:"file_name"`
+	FileSize          int64          `json:"file_size"`
+	ReplyGroupID      string         `gorm:"index" json:"reply_group_id"` // 同一条回复拆分出的多条消息共用
+	PartIndex         int            `json:"part_index"`                  // 拆分后的序号（从0开始）
+	PartCount         int            `gorm:"default:1" json:"part_count"`
+	Status            string         `gorm:"default:sent;index" json:"status"` // sent/failed
+	Error             string         `gorm:"type:text" json:"error"`           // 发送失败原因
+	ScheduleRunID     *uint          `gorm:"index" json:"schedule_run_id"`     // 由定时公告产生时的执行记录ID
+	PollID            *uint          `gorm:"index" json:"poll_id"`             // 投票消息对应的投票ID
+	CreatedAt         time.Time      `gorm:"index" json:"created_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+
+	// 通过接口编辑 / 撤回
+	EditHistory []MessageRevision `gorm:"type:text;serializer:json" json:"edit_history,omitempty"` // 每次编辑前的内容，按时间顺序
+	EditCount   int               `gorm:"default:0" json:"edit_count"`
+	EditedAt    *time.Time        `json:"edited_at"`
+	RemovedAt   *time.Time        `gorm:"index" json:"removed_at"` // 在 Telegram 中撤回（对所有人删除）的时间
+
 	Account Account `gorm:"foreignKey:AccountID" json:"account,omitempty"`
 	Group   Group   `gorm:"foreignKey:GroupID" json:"group,omitempty"`
 }
@@ -28,3 +45,8 @@ func (Message) TableName() string {
 	return "messages"
 }
 
+// MessageRevision 发言的历史版本
+type MessageRevision struct {
+	Content  string    `json:"content"`   // 被替换前的内容
+	EditedAt time.Time `json:"edited_at"` // 被替换的时间
+}
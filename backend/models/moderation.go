@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+)
+
+// ModerationRule 群管规则（账号在群组中是管理员时生效）
+type ModerationRule struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	GroupID  *uint  `gorm:"index" json:"group_id"` // 为空表示全局规则
+	Name     string `gorm:"not null" json:"name"`
+	Priority int    `gorm:"default:0" json:"priority"` // 数值越大越先匹配
+	Enabled  bool   `gorm:"default:true" json:"enabled"`
+
+	// 匹配条件
+	Type           string `gorm:"not null" json:"type"`              // link/banned_words/flood/new_member_link
+	MatchType      string `gorm:"default:keyword" json:"match_type"` // banned_words：keyword/regex
+	Pattern        string `gorm:"type:text" json:"pattern"`          // banned_words：违禁词（逗号分隔）或正则；link/new_member_link：允许的域名（逗号分隔），为空不允许任何链接
+	FloodCount     int    `json:"flood_count"`                       // flood：时间窗口内的消息数上限
+	FloodSeconds   int    `json:"flood_seconds"`                     // flood：时间窗口（秒）
+	NewMemberHours int    `json:"new_member_hours"`                  // new_member_link：入群不满该小时数视为新成员
+	ExemptSenders  string `gorm:"type:text" json:"exempt_senders"`   // 豁免的发送者（用户ID或@用户名，逗号分隔）
+
+	// 命中动作
+	Action          string `gorm:"not null" json:"action"`            // delete/restrict/ban/warn
+	DeleteMessage   bool   `json:"delete_message"`                    // restrict/ban/warn：是否同时删除触发消息
+	DurationSeconds int    `gorm:"default:0" json:"duration_seconds"` // restrict/ban：持续时间（秒），0 表示永久
+	WarnTemplate    string `gorm:"type:text" json:"warn_template"`    // warn：警告内容，支持 {sender}、{group}、{reason}
+
+	// 统计
+	HitCount  int64      `gorm:"default:0" json:"hit_count"`
+	LastHitAt *time.Time `json:"last_hit_at"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ModerationRule) TableName() string {
+	return "moderation_rules"
+}
+
+// ModerationAction 群管动作记录（用于申诉复核）
+type ModerationAction struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	AccountID uint   `gorm:"not null;index" json:"account_id"`
+	GroupID   uint   `gorm:"not null;index" json:"group_id"`
+	ChatID    int64  `json:"chat_id"`
+	RuleID    *uint  `gorm:"index" json:"rule_id"`
+	RuleName  string `json:"rule_name"`
+	Action    string `gorm:"index" json:"action"` // delete/restrict/ban/warn
+	Reason    string `json:"reason"`              // 命中原因，如 "包含链接 example.com"
+
+	// 触发消息
+	UserID         int64     `gorm:"index" json:"user_id"`
+	UserAccessHash int64     `json:"-"` // 撤销限制时使用
+	SenderName     string    `json:"sender_name"`
+	SenderUsername string    `json:"sender_username"`
+	MessageID      int       `json:"message_id"`
+	Content        string    `gorm:"type:text" json:"content"`
+	MessageDeleted bool      `json:"message_deleted"`
+	MessageSentAt  time.Time `json:"message_sent_at"`
+
+	// 执行结果
+	Status    string     `gorm:"default:done;index" json:"status"` // done/failed/reverted
+	Error     string     `gorm:"type:text" json:"error"`
+	UntilDate *time.Time `json:"until_date"` // restrict/ban 的结束时间，为空表示永久
+
+	// 申诉复核
+	ReviewStatus string     `gorm:"index" json:"review_status"` // 空/upheld/reverted
+	ReviewNote   string     `gorm:"type:text" json:"review_note"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Account Account `gorm:"foreignKey:AccountID" json:"account,omitempty"`
+	Group   Group   `gorm:"foreignKey:GroupID" json:"group,omitempty"`
+}
+
+// TableName 指定表名
+func (ModerationAction) TableName() string {
+	return "moderation_actions"
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+// OutboundMessage 待发送消息队列（自动回复、手动发送、定时发送都先入队，由账号的发送协程依次发送）
+type OutboundMessage struct {
+	ID             uint    `gorm:"primaryKey" json:"id"`
+	AccountID      uint    `gorm:"not null;index" json:"account_id"`
+	GroupID        uint    `gorm:"not null;index" json:"group_id"`
+	IdempotencyKey *string `gorm:"uniqueIndex" json:"idempotency_key,omitempty"` // 手动发送的幂等键，重复提交返回同一条记录
+	Source         string  `gorm:"index" json:"source"`                          // auto/trigger/rule/approval/manual/schedule/welcome/poll/command/callback/captcha
+	ScheduleRunID  *uint   `gorm:"index" json:"schedule_run_id"`                 // 定时公告的执行记录ID
+
+	// 消息内容
+	Content      string `gorm:"type:text" json:"content"` // 文本内容，媒体消息为说明文字
+	ReplyToMsgID int    `json:"reply_to_msg_id"`          // 引用回复的 Telegram 消息ID
+	TopicID      int    `json:"topic_id"`                 // 发送到的论坛话题ID，0 表示 General 或未开启话题的群组
+	MediaType    string `json:"media_type"`               // photo/video/document/poll，纯文本为空
+	FileName     string `json:"file_name"`
+	MimeType     string `json:"mime_type"`
+	FileSize     int64  `json:"file_size"`
+	MediaPath    string `json:"-"`                    // 媒体文件的本地暂存路径，发送结束后删除
+	PollID       *uint  `gorm:"index" json:"poll_id"` // 投票消息对应的投票ID
+
+	Buttons InlineKeyboard `gorm:"type:text;serializer:json" json:"buttons,omitempty"` // 内联键盘（仅机器人账号），拆分发送时附在最后一条
+
+	// 拆分发送：同一条回复拆出的多条消息按顺序发送
+	ReplyGroupID string `gorm:"index" json:"reply_group_id"`
+	PartIndex    int    `json:"part_index"`
+	PartCount    int    `gorm:"default:1" json:"part_count"`
+
+	// 调度
+	SendAt      *time.Time `gorm:"index" json:"send_at"` // 最早发送时间，为空表示立即发送
+	Priority    int        `gorm:"default:0" json:"priority"`
+	Status      string     `gorm:"default:pending;index" json:"status"` // pending/sending/sent/failed/cancelled
+	Attempts    int        `gorm:"default:0" json:"attempts"`
+	MaxAttempts int        `gorm:"default:3" json:"max_attempts"`
+	LastError   string     `gorm:"type:text" json:"last_error"`
+	RandomID    int64      `json:"-"` // 发送时使用的 random_id，重试时复用，避免重复发送
+
+	// 发送结果
+	TelegramMessageID int64      `json:"telegram_message_id"`
+	MessageID         *uint      `json:"message_id"` // 对应的发言记录ID
+	SentAt            *time.Time `json:"sent_at"`
+
+	// 自动删除：发送成功后 DeleteAfter 秒删除该消息
+	DeleteAfter int        `gorm:"default:0" json:"delete_after"`
+	DeleteAt    *time.Time `gorm:"index" json:"delete_at"`
+	RemovedAt   *time.Time `json:"removed_at"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Account Account `gorm:"foreignKey:AccountID" json:"account,omitempty"`
+	Group   Group   `gorm:"foreignKey:GroupID" json:"group,omitempty"`
+}
+
+// TableName 指定表名
+func (OutboundMessage) TableName() string {
+	return "outbound_queue"
+}
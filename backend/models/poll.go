@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+)
+
+// Poll 群组投票（通过发送队列发送，结果由 UpdateMessagePoll 更新）
+type Poll struct {
+	ID             uint   `gorm:"primaryKey" json:"id"`
+	AccountID      uint   `gorm:"not null;index" json:"account_id"`
+	GroupID        uint   `gorm:"not null;index" json:"group_id"`
+	Question       string `gorm:"type:text;not null" json:"question"`
+	Anonymous      bool   `json:"anonymous"`       // 匿名投票（false 为公开投票，可查看投票人）
+	MultipleChoice bool   `json:"multiple_choice"` // 允许多选
+
+	// 状态
+	Status      string     `gorm:"default:pending;index" json:"status"` // pending/open/closed/failed
+	Error       string     `gorm:"type:text" json:"error"`
+	TotalVoters int        `json:"total_voters"`
+	ResultsAt   *time.Time `json:"results_at"` // 最近一次收到投票结果的时间
+	ClosedAt    *time.Time `json:"closed_at"`
+
+	// 发送结果
+	OutboundID        *uint `gorm:"index" json:"outbound_id"` // 发送队列记录ID
+	MessageID         *uint `gorm:"index" json:"message_id"`  // 对应的发言记录ID
+	TelegramMessageID int64 `json:"telegram_message_id"`
+	TelegramPollID    int64 `gorm:"index" json:"telegram_poll_id"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Options []PollOption `gorm:"foreignKey:PollID" json:"options"`
+	Account Account      `gorm:"foreignKey:AccountID" json:"account,omitempty"`
+	Group   Group        `gorm:"foreignKey:GroupID" json:"group,omitempty"`
+}
+
+// TableName 指定表名
+func (Poll) TableName() string {
+	return "polls"
+}
+
+// PollOption 投票选项及票数
+type PollOption struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	PollID   uint   `gorm:"not null;index" json:"poll_id"`
+	Position int    `json:"position"` // 选项序号（从0开始），同时作为 Telegram 选项标识
+	Text     string `gorm:"not null" json:"text"`
+	Voters   int    `json:"voters"`
+}
+
+// TableName 指定表名
+func (PollOption) TableName() string {
+	return "poll_options"
+}
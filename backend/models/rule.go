@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+)
+
+// ReplyRule 群组回复规则（关键词/正则触发）
+type ReplyRule struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	GroupID  *uint  `gorm:"index" json:"group_id"` // 为空表示全局规则
+	Name     string `gorm:"not null" json:"name"`
+	Priority int    `gorm:"default:0" json:"priority"` // 数值越大越先匹配
+	Enabled  bool   `gorm:"default:true" json:"enabled"`
+
+	// 匹配条件
+	MatchType    string `gorm:"default:keyword" json:"match_type"` // keyword/regex
+	Pattern      string `gorm:"type:text;not null" json:"pattern"` // 关键词（多个用逗号分隔，任一命中即可）或正则
+	Senders      string `gorm:"type:text" json:"senders"`          // 限定发送者（用户ID或@用户名，逗号分隔），为空不限
+	MessageTypes string `json:"message_types"`                     // 限定消息类型（text/photo/video/document/sticker/voice/other，逗号分隔），为空不限
+	ActiveFrom   string `json:"active_from"`                       // 生效开始时间 HH:MM，为空不限
+	ActiveTo     string `json:"active_to"`                         // 生效结束时间 HH:MM（可跨零点）
+
+	// 命中动作
+	Action          string `gorm:"not null" json:"action"`            // ai_reply/template/ignore/approval
+	Instruction     string `gorm:"type:text" json:"instruction"`      // ai_reply/approval：附加给AI的指令
+	Template        string `gorm:"type:text" json:"template"`         // template：固定回复内容，支持 {sender}、{group}、{text}
+	CooldownSeconds int    `gorm:"default:0" json:"cooldown_seconds"` // 同一群组内再次执行动作的冷却时间（秒）
+
+	Buttons InlineKeyboard `gorm:"type:text;serializer:json" json:"buttons"` // ai_reply/template：回复附带的内联键盘（仅机器人账号发送）
+
+	// 统计
+	HitCount  int64      `gorm:"default:0" json:"hit_count"`
+	LastHitAt *time.Time `json:"last_hit_at"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ReplyRule) TableName() string {
+	return "reply_rules"
+}
+
+// ApprovalItem 待人工审核的回复
+type ApprovalItem struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	AccountID        uint       `gorm:"not null;index" json:"account_id"`
+	GroupID          uint       `gorm:"not null;index" json:"group_id"`
+	RuleID           *uint      `json:"rule_id"`
+	TriggerMessageID int64      `json:"trigger_message_id"` // 触发消息的 Telegram 消息ID
+	TriggerTopicID   int        `json:"trigger_topic_id"`   // 触发消息所在的论坛话题ID
+	TriggerSender    string     `json:"trigger_sender"`
+	TriggerContent   string     `gorm:"type:text" json:"trigger_content"`
+	DraftReply       string     `gorm:"type:text" json:"draft_reply"`        // AI生成的回复草稿
+	Status           string     `gorm:"default:pending;index" json:"status"` // pending/approved/rejected/failed
+	Error            string     `gorm:"type:text" json:"error"`
+	ReviewedAt       *time.Time `json:"reviewed_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+
+	Account Account `gorm:"foreignKey:AccountID" json:"account,omitempty"`
+	Group   Group   `gorm:"foreignKey:GroupID" json:"group,omitempty"`
+}
+
+// TableName 指定表名
+func (ApprovalItem) TableName() string {
+	return "approval_queue"
+}
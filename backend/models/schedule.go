@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+)
+
+// Schedule 定时公告（cron 周期发送或单次定时发送）
+type Schedule struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	Name      string `gorm:"not null" json:"name"`
+	AccountID uint   `gorm:"not null;index" json:"account_id"` // 发送公告的账号
+	GroupIDs  string `gorm:"not null" json:"group_ids"`        // 目标群组ID，逗号分隔
+
+	// 时间规则：CronExpr 与 RunAt 二选一
+	CronExpr string     `json:"cron_expr"` // 标准5段 cron 表达式（分 时 日 月 周），如 "0 20 * * 5"
+	RunAt    *time.Time `json:"run_at"`    // 单次发送时间
+	Timezone string     `json:"timezone"`  // IANA 时区，如 Asia/Shanghai，为空使用服务器时区
+
+	// 公告内容：文本，或媒体 + 说明文字
+	Content   string `gorm:"type:text" json:"content"`
+	MediaType string `json:"media_type"` // photo/video/document，纯文本为空
+	FileName  string `json:"file_name"`
+	MimeType  string `json:"mime_type"`
+	FileSize  int64  `json:"file_size"`
+	MediaPath string `json:"-"` // 媒体文件的本地保存路径
+
+	Priority  int        `gorm:"default:0" json:"priority"`          // 入队优先级
+	Status    string     `gorm:"default:active;index" json:"status"` // active/paused/completed
+	NextRunAt *time.Time `gorm:"index" json:"next_run_at"`
+	LastRunAt *time.Time `json:"last_run_at"`
+	RunCount  int        `gorm:"default:0" json:"run_count"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+
+	Account Account `gorm:"foreignKey:AccountID" json:"account,omitempty"`
+}
+
+// TableName 指定表名
+func (Schedule) TableName() string {
+	return "schedules"
+}
+
+// ScheduleRun 定时公告的执行记录
+type ScheduleRun struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ScheduleID  uint      `gorm:"not null;index" json:"schedule_id"`
+	ScheduledAt time.Time `json:"scheduled_at"` // 计划执行时间
+	Status      string    `json:"status"`       // queued：已全部入队，partial：部分群组入队失败，failed：全部失败，missed：服务停机错过执行
+	GroupCount  int       `json:"group_count"`  // 成功入队的群组数
+	Error       string    `gorm:"type:text" json:"error"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+
+	Items []OutboundMessage `gorm:"foreignKey:ScheduleRunID" json:"items,omitempty"`
+}
+
+// TableName 指定表名
+func (ScheduleRun) TableName() string {
+	return "schedule_runs"
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// UpdateState Telegram 更新状态（pts/qts/seq），用于重启后通过 getDifference 补齐离线期间的更新
+type UpdateState struct {
+	UserID    int64     `gorm:"primaryKey;autoIncrement:false" json:"user_id"` // Telegram 用户ID
+	AccountID uint      `gorm:"index" json:"account_id"`
+	Pts       int       `json:"pts"`
+	Qts       int       `json:"qts"`
+	Date      int       `json:"date"`
+	Seq       int       `json:"seq"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (UpdateState) TableName() string {
+	return "tg_update_states"
+}
+
+// ChannelUpdateState 频道 / 超级群的更新状态（每个账号独立的 pts 和 AccessHash）
+type ChannelUpdateState struct {
+	UserID     int64     `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	ChannelID  int64     `gorm:"primaryKey;autoIncrement:false" json:"channel_id"`
+	Pts        int       `json:"pts"` // 0 表示尚未记录
+	AccessHash int64     `json:"access_hash"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ChannelUpdateState) TableName() string {
+	return "tg_channel_states"
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// GroupWelcome 群组欢迎语配置（新成员入群时由分配到该群组的账号发送）
+type GroupWelcome struct {
+	ID                 uint           `gorm:"primaryKey" json:"id"`
+	GroupID            uint           `gorm:"not null;uniqueIndex" json:"group_id"`
+	Enabled            bool           `gorm:"default:true" json:"enabled"`
+	Template           string         `gorm:"type:text;not null" json:"template"`       // 支持 {name}、{group}、{rules}、{count}
+	RulesLink          string         `json:"rules_link"`                               // 群规链接，用于 {rules}
+	Buttons            InlineKeyboard `gorm:"type:text;serializer:json" json:"buttons"` // 附带的内联键盘（仅机器人账号发送）
+	BatchSeconds       int            `gorm:"default:10" json:"batch_seconds"`          // 合并该时间内入群的成员，只发送一条欢迎语
+	CooldownSeconds    int            `gorm:"default:0" json:"cooldown_seconds"`        // 两次欢迎语之间的最短间隔，期间入群的成员不再欢迎
+	DeleteAfterMinutes int            `gorm:"default:0" json:"delete_after_minutes"`    // 欢迎语发送后自动删除的时间（分钟），0 表示不删除
+	LastSentAt         *time.Time     `json:"last_sent_at"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+
+	Group Group `gorm:"foreignKey:GroupID" json:"group,omitempty"`
+}
+
+// TableName 指定表名
+func (GroupWelcome) TableName() string {
+	return "group_welcomes"
+}